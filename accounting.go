@@ -0,0 +1,74 @@
+package ddstats
+
+import (
+	"sync/atomic"
+
+	"github.com/jmizell/ddstats/client"
+)
+
+// ClassAccounting counts the points of one metric class at each stage of the pipeline.
+// Comparing the stages shows where points were lost, Submitted minus Dropped should
+// equal Aggregated, once the queue is drained, and Flushed minus Sent is the number of
+// points the api client failed to send.
+type ClassAccounting struct {
+	Submitted  uint64 // Submissions accepted, after sampling, and tag policies
+	Dropped    uint64 // Submissions dropped because the queue was full
+	Aggregated uint64 // Submissions aggregated by the workers
+	Flushed    uint64 // Points flushed, one for each aggregated metric, and window
+	Sent       uint64 // Points sent to the api without an error
+}
+
+// Pipeline stages counted by accounting.
+const (
+	stageSubmitted = iota
+	stageDropped
+	stageAggregated
+	stageFlushed
+	stageSent
+	stageCount
+)
+
+// accounting holds the counters for each metric class, indexed by stage. The classes are
+// fixed when stats is created, so the counters can be updated without a lock.
+type accounting map[string]*[stageCount]uint64
+
+func newAccounting() accounting {
+	a := accounting{}
+	for _, class := range []string{client.Count, client.Rate, client.Gauge, histogram, set, client.Distribution} {
+		a[class] = &[stageCount]uint64{}
+	}
+	return a
+}
+
+// add adds n to the counter of stage for class.
+func (a accounting) add(class string, stage int, n uint64) {
+	if counters, ok := a[class]; ok && n > 0 {
+		atomic.AddUint64(&counters[stage], n)
+	}
+}
+
+// Accounting returns the number of points at each stage of the pipeline, by metric class,
+// count, rate, gauge, histogram, set, and distribution, since stats was created. Series
+// added with QueueSeries, or SendSeries are not included.
+func (c *Stats) Accounting() map[string]ClassAccounting {
+	result := make(map[string]ClassAccounting, len(c.accounting))
+	for class, counters := range c.accounting {
+		result[class] = ClassAccounting{
+			Submitted:  atomic.LoadUint64(&counters[stageSubmitted]),
+			Dropped:    atomic.LoadUint64(&counters[stageDropped]),
+			Aggregated: atomic.LoadUint64(&counters[stageAggregated]),
+			Flushed:    atomic.LoadUint64(&counters[stageFlushed]),
+			Sent:       atomic.LoadUint64(&counters[stageSent]),
+		}
+	}
+	return result
+}
+
+// countPoints returns the number of points in series.
+func countPoints(series []*client.DDMetric) uint64 {
+	var n uint64
+	for _, m := range series {
+		n += uint64(len(m.Points))
+	}
+	return n
+}
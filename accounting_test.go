@@ -0,0 +1,40 @@
+package ddstats
+
+import (
+	"fmt"
+	"testing"
+
+	"github.com/jmizell/ddstats/client"
+)
+
+func TestStats_Accounting(t *testing.T) {
+
+	stats, testApi, err := NewTestStats()
+	if err != nil {
+		t.Fatalf(err.Error())
+	}
+	stats.Increment("count", nil)
+	stats.Increment("count", nil)
+	stats.Gauge("gauge", 1, nil)
+	stats.Histogram("histogram", 1, nil)
+	stats.Flush()
+
+	testApi.lock.Lock()
+	testApi.sendSeriesError = fmt.Errorf("api unavailable")
+	testApi.lock.Unlock()
+	stats.Gauge("gauge", 2, nil)
+	stats.Close()
+
+	accounting := stats.Accounting()
+	expected := map[string]ClassAccounting{
+		client.Count: {Submitted: 2, Aggregated: 2, Flushed: 1, Sent: 1},
+		client.Gauge: {Submitted: 2, Aggregated: 2, Flushed: 2, Sent: 1},
+		histogram:    {Submitted: 1, Aggregated: 1, Flushed: 6, Sent: 6},
+		client.Rate:  {},
+	}
+	for class, e := range expected {
+		if accounting[class] != e {
+			t.Fatalf("expected %s accounting to be %+v, have %+v", class, e, accounting[class])
+		}
+	}
+}
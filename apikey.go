@@ -0,0 +1,201 @@
+package ddstats
+
+import (
+	"context"
+	"fmt"
+	"sync"
+	"sync/atomic"
+
+	"github.com/jmizell/ddstats/client"
+)
+
+// MissingAPIKeyMode controls how NewStats handles a config without an api key, or client.
+type MissingAPIKeyMode string
+
+// Missing api key modes
+const (
+	// MissingAPIKeyError fails NewStats. This is the default.
+	MissingAPIKeyError = MissingAPIKeyMode("")
+
+	// MissingAPIKeyBuffer buffers flushed series in memory, up to the max buffered metrics,
+	// until an api key is set with SetAPIKey. Service checks, events, and distributions
+	// return an error until the key is set.
+	MissingAPIKeyBuffer = MissingAPIKeyMode("buffer")
+
+	// MissingAPIKeyNoop discards everything sent to the api until an api key is set with
+	// SetAPIKey. A warning is added to the errors list.
+	MissingAPIKeyNoop = MissingAPIKeyMode("noop")
+)
+
+// DefaultMissingAPIKeyBuffer is the default number of metrics buffered while the api key
+// is missing.
+const DefaultMissingAPIKeyBuffer = 10000
+
+// pendingClient is the api client used while the api key is missing. Once a client is
+// set, all calls are passed to it.
+type pendingClient struct {
+	mode      MissingAPIKeyMode
+	maxBuffer int
+	buffered  []*client.DDMetric
+	dropped   uint64
+	client    client.APIClient
+	lock      *sync.RWMutex
+}
+
+func newPendingClient(mode MissingAPIKeyMode, maxBuffer int) *pendingClient {
+	if maxBuffer <= 0 {
+		maxBuffer = DefaultMissingAPIKeyBuffer
+	}
+	return &pendingClient{
+		mode:      mode,
+		maxBuffer: maxBuffer,
+		lock:      &sync.RWMutex{},
+	}
+}
+
+func (p *pendingClient) errNoAPIKey() error {
+	if p.mode == MissingAPIKeyNoop {
+		return nil
+	}
+	return fmt.Errorf("api key is not set")
+}
+
+func (p *pendingClient) SendSeries(series *client.DDMetricSeries) error {
+	return p.SendSeriesContext(context.Background(), series)
+}
+
+func (p *pendingClient) SendSeriesContext(ctx context.Context, series *client.DDMetricSeries) error {
+
+	p.lock.Lock()
+	if c := p.client; c != nil {
+		p.lock.Unlock()
+		return client.SendSeriesContext(ctx, c, series)
+	}
+	defer p.lock.Unlock()
+	if p.mode != MissingAPIKeyBuffer {
+		return nil
+	}
+
+	n := len(series.Series)
+	if space := p.maxBuffer - len(p.buffered); n > space {
+		atomic.AddUint64(&p.dropped, uint64(n-space))
+		n = space
+	}
+	p.buffered = append(p.buffered, series.Series[:n]...)
+	return nil
+}
+
+func (p *pendingClient) SendServiceCheck(check *client.DDServiceCheck) error {
+	if c := p.getClient(); c != nil {
+		return c.SendServiceCheck(check)
+	}
+	return p.errNoAPIKey()
+}
+
+func (p *pendingClient) SendEvent(event *client.DDEvent) error {
+	if c := p.getClient(); c != nil {
+		return c.SendEvent(event)
+	}
+	return p.errNoAPIKey()
+}
+
+func (p *pendingClient) SendDistributions(series *client.DDDistributionSeries) error {
+	return p.SendDistributionsContext(context.Background(), series)
+}
+
+func (p *pendingClient) SendDistributionsContext(ctx context.Context, series *client.DDDistributionSeries) error {
+	c := p.getClient()
+	if c == nil {
+		return p.errNoAPIKey()
+	}
+	distributionClient, ok := c.(client.DistributionClient)
+	if !ok {
+		return fmt.Errorf("api client does not support distributions")
+	}
+	return client.SendDistributionsContext(ctx, distributionClient, series)
+}
+
+func (p *pendingClient) SetHTTPClient(httpClient client.HTTPClient) {
+	if c := p.getClient(); c != nil {
+		c.SetHTTPClient(httpClient)
+	}
+}
+
+func (p *pendingClient) getClient() client.APIClient {
+	p.lock.RLock()
+	defer p.lock.RUnlock()
+	return p.client
+}
+
+// setClient sets the client, and sends any buffered series with it.
+func (p *pendingClient) setClient(c client.APIClient) error {
+
+	p.lock.Lock()
+	p.client = c
+	buffered := p.buffered
+	p.buffered = nil
+	p.lock.Unlock()
+
+	if len(buffered) == 0 {
+		return nil
+	}
+	return c.SendSeries(&client.DDMetricSeries{Series: buffered})
+}
+
+// SetAPIKey sets the api key, when stats was created without one, using the buffer, or noop
+// missing api key mode. If series were buffered, they're sent immediately, and any error
+// sending them is returned. Datadog rejects points older than an hour, so buffered series
+// older than that are lost.
+func (c *Stats) SetAPIKey(apiKey string) error {
+
+	pending, ok := c.client.(*pendingClient)
+	if !ok {
+		return fmt.Errorf("api key can only be set when stats was created without an api key")
+	}
+	if apiKey == "" {
+		return fmt.Errorf("api key is empty")
+	}
+
+	ddClient, err := c.newDDClient(apiKey)
+	if err != nil {
+		return err
+	}
+	if c.debugCaptureDir != "" {
+		ddClient.SetDebugCapture(c.debugCaptureDir, c.debugCaptureMax)
+	}
+	return pending.setClient(ddClient)
+}
+
+// newDDClient creates a Datadog api client for apiKey, using the configured site, or
+// base url, api key mode, http settings, compression, and chunking.
+func (c *Stats) newDDClient(apiKey string) (*client.DDClient, error) {
+
+	ddClient := client.NewDDClient(apiKey)
+	ddClient.SetAPIKeyHeader(c.apiKeyHeader)
+	ddClient.SetChunking(c.maxSeriesPerRequest, c.maxPayloadBytes)
+	if err := ddClient.SetTransport(c.transport); err != nil {
+		return nil, err
+	}
+	if err := ddClient.SetCompression(c.compression, c.compressionLevel, c.compressionThreshold); err != nil {
+		return nil, err
+	}
+	var err error
+	if c.apiBaseURL != "" {
+		err = ddClient.SetBaseURL(c.apiBaseURL)
+	} else if c.site != "" {
+		err = ddClient.SetSite(c.site)
+	}
+	if err != nil {
+		return nil, err
+	}
+	return ddClient, nil
+}
+
+// GetDroppedMissingAPIKeyCount returns the number of metrics dropped because the buffer for
+// a missing api key was full.
+func (c *Stats) GetDroppedMissingAPIKeyCount() uint64 {
+	if pending, ok := c.client.(*pendingClient); ok {
+		return atomic.LoadUint64(&pending.dropped)
+	}
+	return 0
+}
@@ -0,0 +1,83 @@
+package ddstats
+
+import (
+	"testing"
+
+	"github.com/jmizell/ddstats/client"
+)
+
+func TestStats_MissingAPIKey(t *testing.T) {
+
+	t.Run("error", func(tt *testing.T) {
+		if _, err := NewStats(NewConfig()); err == nil {
+			tt.Fatalf("expected error for missing api key")
+		}
+	})
+
+	t.Run("buffer", func(tt *testing.T) {
+		stats, err := NewStats(NewConfig().
+			WithNamespace(testNamespace).
+			WithMissingAPIKey(MissingAPIKeyBuffer, 2))
+		if err != nil {
+			tt.Fatalf(err.Error())
+		}
+		defer stats.Close()
+
+		stats.Gauge("a", 1, nil)
+		stats.Gauge("b", 1, nil)
+		stats.Gauge("c", 1, nil)
+		stats.Flush()
+		if dropped := stats.GetDroppedMissingAPIKeyCount(); dropped != 1 {
+			tt.Fatalf("expected %d dropped metrics, have %d", 1, dropped)
+		}
+		if err := stats.ServiceCheck("check", "", client.Okay, nil); err == nil {
+			tt.Fatalf("expected service check error without api key")
+		}
+
+		pending := stats.client.(*pendingClient)
+		testClient := NewTestAPIClient()
+		if err := pending.setClient(testClient); err != nil {
+			tt.Fatalf(err.Error())
+		}
+		if len(testClient.series) != 1 || len(testClient.series[0].Series) != 2 {
+			tt.Fatalf("expected buffered series to be sent, have %v", testClient.series)
+		}
+
+		stats.Gauge("d", 1, nil)
+		stats.Flush()
+		if len(testClient.series) != 2 {
+			tt.Fatalf("expected flush to be sent, have %d series calls", len(testClient.series))
+		}
+	})
+
+	t.Run("noop", func(tt *testing.T) {
+		stats, err := NewStats(NewConfig().WithMissingAPIKey(MissingAPIKeyNoop, 0))
+		if err != nil {
+			tt.Fatalf(err.Error())
+		}
+		defer stats.Close()
+
+		stats.Gauge("a", 1, nil)
+		stats.Flush()
+		if err := stats.ServiceCheck("check", "", client.Okay, nil); err != nil {
+			tt.Fatalf("expected no error in noop mode, have %s", err.Error())
+		}
+		if errs := stats.Errors(); len(errs) != 1 {
+			tt.Fatalf("expected missing api key warning, have %v", errs)
+		}
+		if err := stats.SetAPIKey(""); err == nil {
+			tt.Fatalf("expected error for empty api key")
+		}
+	})
+
+	t.Run("set api key with client", func(tt *testing.T) {
+		stats, _, err := NewTestStats()
+		if err != nil {
+			tt.Fatalf(err.Error())
+		}
+		defer stats.Close()
+		if err := stats.SetAPIKey("key"); err == nil {
+			tt.Fatalf("expected error setting api key with a configured client")
+		}
+	})
+}
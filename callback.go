@@ -0,0 +1,145 @@
+package ddstats
+
+import (
+	"strings"
+	"sync/atomic"
+	"time"
+
+	"github.com/jmizell/ddstats/client"
+)
+
+// DefaultErrorCallbackBuffer is the default number of errors that can be waiting for the
+// error callback.
+const DefaultErrorCallbackBuffer = 10
+
+type errorCallbackJob struct {
+	err    error
+	series []*client.DDMetric
+}
+
+// errorCallbackWorker invokes the error callback for each queued error, until the queue is
+// closed.
+func (c *Stats) errorCallbackWorker() {
+	defer c.errorCallbackWG.Done()
+	for job := range c.errorCallbacks {
+		if c.errorCallback != nil {
+			c.errorCallback(job.err, job.series)
+		}
+	}
+}
+
+// queueErrorCallback queues err for the error callback. The error is dropped if the queue
+// is full, so a slow callback can't delay flushes.
+func (c *Stats) queueErrorCallback(err error, series []*client.DDMetric) {
+	if c.errorCallback == nil {
+		return
+	}
+	select {
+	case c.errorCallbacks <- &errorCallbackJob{err: err, series: series}:
+	default:
+		atomic.AddUint64(&c.errorCallbackDropped, 1)
+	}
+}
+
+// GetDroppedErrorCallbackCount returns the number of errors that were not delivered to the
+// error callback, because the callback queue was full.
+func (c *Stats) GetDroppedErrorCallbackCount() uint64 {
+	return atomic.LoadUint64(&c.errorCallbackDropped)
+}
+
+// FlushResult summarizes a flush, it's passed to the flush result callback.
+type FlushResult struct {
+	Series        int           // Number of series sent, including series that failed
+	FailedSeries  int           // Number of series not accepted, fewer than Series if the flush failed partially
+	Distributions int           // Number of distributions sent, including distributions that failed
+	Bytes         int           // Size of the series payload, before compression
+	Duration      time.Duration // Time spent in api calls
+	Retries       int           // Number of spooled series resent after the flush
+	Success       bool          // True if all api calls succeeded
+	Err           error         // The series error, or the distribution error, if the flush failed
+	Dropped       uint64        // Number of metrics dropped since the previous flush result
+}
+
+// FlushResultCallback registers a call back function that will be called at the end of
+// every flush that sends metrics, with a summary of the flush. Callbacks are invoked after
+// the flush callback, in flush order, and never concurrently.
+func (c *Stats) FlushResultCallback(f func(result *FlushResult)) {
+	c.flushResultCallback = f
+}
+
+// droppedSinceResult returns the number of metrics dropped since the last call.
+func (c *Stats) droppedSinceResult() uint64 {
+	dropped := atomic.LoadUint64(&c.dropped)
+	if last := atomic.SwapUint64(&c.droppedResult, dropped); dropped > last {
+		return dropped - last
+	}
+	return 0
+}
+
+// FlushFilter selects the series passed to a filtered flush callback. A metric must
+// match every field that is set.
+type FlushFilter struct {
+	// Prefix matches metric names starting with prefix, either as submitted, or with the
+	// namespace applied. A trailing wildcard is ignored, so billing.* is the same as billing.
+	Prefix string
+
+	// Tag matches metrics with the tag. A tag without a value, or with a value of *,
+	// matches any value for that key.
+	Tag string
+}
+
+type filteredCallback struct {
+	filter FlushFilter
+	f      func(metricSeries []*client.DDMetric)
+}
+
+// FilteredFlushCallback registers a call back function that will be called at the end of
+// every flush, with only the series that match filter. The callback is not called if no
+// series match. Filtered callbacks are invoked after the flush callback, in flush order,
+// and never concurrently. Any number of filtered callbacks may be registered.
+func (c *Stats) FilteredFlushCallback(filter FlushFilter, f func(metricSeries []*client.DDMetric)) {
+	c.filteredCallbackLock.Lock()
+	defer c.filteredCallbackLock.Unlock()
+	c.filteredCallbacks = append(c.filteredCallbacks, &filteredCallback{filter: filter, f: f})
+}
+
+func (c *Stats) runFilteredCallbacks(series []*client.DDMetric) {
+
+	c.filteredCallbackLock.Lock()
+	callbacks := c.filteredCallbacks
+	c.filteredCallbackLock.Unlock()
+
+	for _, cb := range callbacks {
+		var matched []*client.DDMetric
+		for _, m := range series {
+			if c.matchFlushFilter(cb.filter, m) {
+				matched = append(matched, m)
+			}
+		}
+		if len(matched) > 0 {
+			cb.f(matched)
+		}
+	}
+}
+
+func (c *Stats) matchFlushFilter(filter FlushFilter, m *client.DDMetric) bool {
+
+	if prefix := strings.TrimSuffix(filter.Prefix, "*"); prefix != "" {
+		if !strings.HasPrefix(m.Metric, prefix) && !strings.HasPrefix(m.Metric, c.withNamespace(prefix)) {
+			return false
+		}
+	}
+
+	if filter.Tag != "" {
+		key := strings.TrimSuffix(filter.Tag, ":*")
+		anyValue := !strings.Contains(key, ":")
+		for _, tag := range m.Tags {
+			if tag == filter.Tag || anyValue && strings.HasPrefix(tag, key+":") {
+				return true
+			}
+		}
+		return false
+	}
+
+	return true
+}
@@ -0,0 +1,171 @@
+package ddstats
+
+import (
+	"context"
+	"fmt"
+	"testing"
+	"time"
+
+	"github.com/jmizell/ddstats/client"
+)
+
+func TestStats_ErrorCallbackQueue(t *testing.T) {
+
+	testClient := NewTestAPIClient()
+	testClient.sendSeriesError = fmt.Errorf("failed send")
+	stats, err := NewStats(NewConfig().
+		WithNamespace(testNamespace).
+		WithHost(testHost).
+		WithClient(testClient).
+		WithErrorCallbackBuffer(1))
+	if err != nil {
+		t.Fatalf(err.Error())
+	}
+
+	release := make(chan bool)
+	calls := 0
+	stats.ErrorCallback(func(err error, metricSeries []*client.DDMetric) {
+		<-release
+		calls++
+	})
+
+	// The first error blocks the callback, the second fills the queue, and the third is dropped
+	flushed := make(chan bool)
+	go func() {
+		for i := 0; i < 3; i++ {
+			stats.Gauge("test", 1, nil)
+			stats.Flush()
+		}
+		close(flushed)
+	}()
+	select {
+	case <-flushed:
+	case <-time.After(time.Second * 5):
+		t.Fatalf("expected flushes not to be blocked by the error callback")
+	}
+
+	close(release)
+	stats.Close()
+	if calls != 2 {
+		t.Fatalf("expected %d callbacks, have %d", 2, calls)
+	}
+	if dropped := stats.GetDroppedErrorCallbackCount(); dropped != 1 {
+		t.Fatalf("expected %d dropped callbacks, have %d", 1, dropped)
+	}
+}
+
+func TestStats_FilteredFlushCallback(t *testing.T) {
+
+	stats, _, err := NewTestStatsWithStart()
+	if err != nil {
+		t.Fatalf(err.Error())
+	}
+
+	have := map[string][]string{}
+	register := func(name string, filter FlushFilter) {
+		stats.FilteredFlushCallback(filter, func(metricSeries []*client.DDMetric) {
+			for _, m := range metricSeries {
+				have[name] = append(have[name], m.Metric)
+			}
+		})
+	}
+	register("prefix", FlushFilter{Prefix: "billing.*"})
+	register("tag", FlushFilter{Tag: "team:payments"})
+	register("tag key", FlushFilter{Tag: "team"})
+	register("both", FlushFilter{Prefix: "billing.", Tag: "team:*"})
+	register("none", FlushFilter{Prefix: "missing."})
+
+	stats.flushWG.Add(1)
+	stats.send(context.Background(), map[string]*metric{
+		"billing.charges": {name: "billing.charges", class: client.Gauge, value: 1},
+		"billing.refunds": {name: "billing.refunds", class: client.Gauge, value: 1, tags: []string{"team:payments"}},
+		"search.queries":  {name: "search.queries", class: client.Gauge, value: 1, tags: []string{"team:search"}},
+	}, time.Second, nil)
+
+	expected := map[string]int{"prefix": 2, "tag": 1, "tag key": 2, "both": 1}
+	for name, count := range expected {
+		if len(have[name]) != count {
+			t.Fatalf("expected %s callback to receive %d series, have %v", name, count, have[name])
+		}
+	}
+	if _, ok := have["none"]; ok {
+		t.Fatalf("expected callback with no matching series to not be called")
+	}
+	if have["tag"][0] != prependNamespace(testNamespace, "billing.refunds") {
+		t.Fatalf("expected tag callback to receive billing.refunds, have %v", have["tag"])
+	}
+}
+
+func TestStats_ErrorCallbackPartialError(t *testing.T) {
+
+	failed := []*client.DDMetric{{Metric: "failed"}}
+	testClient := NewTestAPIClient()
+	testClient.sendSeriesError = &client.PartialError{Requests: 2, Errors: []error{fmt.Errorf("failed send")}, Failed: failed}
+	stats, err := NewStats(NewConfig().
+		WithNamespace(testNamespace).
+		WithHost(testHost).
+		WithClient(testClient))
+	if err != nil {
+		t.Fatalf(err.Error())
+	}
+
+	var series []*client.DDMetric
+	done := make(chan bool)
+	stats.ErrorCallback(func(err error, metricSeries []*client.DDMetric) {
+		series = metricSeries
+		close(done)
+	})
+	stats.Gauge("a", 1, nil)
+	stats.Gauge("b", 1, nil)
+	stats.Flush()
+	<-done
+	stats.Close()
+
+	if len(series) != 1 || series[0] != failed[0] {
+		t.Fatalf("expected only the failed series to be passed to the callback, have %v", series)
+	}
+}
+
+func TestStats_FlushResultCallback(t *testing.T) {
+
+	testClient := NewTestAPIClient()
+	cfg := NewConfig().
+		WithNamespace(testNamespace).
+		WithHost(testHost).
+		WithClient(testClient)
+	cfg.MetricBuffer = 1
+	stats, err := NewStats(cfg)
+	if err != nil {
+		t.Fatalf(err.Error())
+	}
+
+	var results []*FlushResult
+	stats.FlushResultCallback(func(result *FlushResult) {
+		results = append(results, result)
+	})
+
+	for i := 0; i < 100; i++ {
+		stats.Gauge(fmt.Sprintf("test.%d", i%2), 1, nil)
+	}
+	stats.Flush()
+	testClient.lock.Lock()
+	testClient.sendSeriesError = fmt.Errorf("failed send")
+	testClient.lock.Unlock()
+	stats.Gauge("test.0", 1, nil)
+	stats.Flush()
+	stats.Close()
+
+	if len(results) != 2 {
+		t.Fatalf("expected %d results, have %d", 2, len(results))
+	}
+	first, second := results[0], results[1]
+	if !first.Success || first.Err != nil || first.Series == 0 || first.Bytes == 0 {
+		t.Fatalf("expected a successful result, have %+v", first)
+	}
+	if first.Dropped != stats.GetDroppedMetricCount() || first.Dropped == 0 {
+		t.Fatalf("expected %d dropped, have %d", stats.GetDroppedMetricCount(), first.Dropped)
+	}
+	if second.Success || second.Err == nil || second.Series != 1 || second.Dropped != 0 {
+		t.Fatalf("expected a failed result, have %+v", second)
+	}
+}
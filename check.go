@@ -0,0 +1,38 @@
+package ddstats
+
+import (
+	"github.com/jmizell/ddstats/client"
+)
+
+type registeredCheck struct {
+	name string
+	fn   func() (client.Status, string)
+	tags []string
+}
+
+// RegisterCheck registers a service check that is evaluated on every flush. The function
+// is called from the flush goroutine, and the returned status, and message are submitted
+// as a service check named name, with tags, using ServiceCheck. The namespace is prepended
+// to the check name. Errors submitting the check are added to Errors. Checks are evaluated
+// one at a time, a slow check function delays the flush.
+func (c *Stats) RegisterCheck(name string, fn func() (client.Status, string), tags []string) {
+	c.checkLock.Lock()
+	defer c.checkLock.Unlock()
+	c.checks = append(c.checks, &registeredCheck{name: name, fn: fn, tags: tags})
+}
+
+// runChecks evaluates, and submits all registered checks.
+func (c *Stats) runChecks() {
+
+	c.checkLock.Lock()
+	checks := make([]*registeredCheck, len(c.checks))
+	copy(checks, c.checks)
+	c.checkLock.Unlock()
+
+	for _, check := range checks {
+		status, message := check.fn()
+		if err := c.ServiceCheck(check.name, message, status, check.tags); err != nil {
+			c.addError(ErrorClassServiceCheck, err)
+		}
+	}
+}
@@ -0,0 +1,49 @@
+package ddstats
+
+import (
+	"testing"
+
+	"github.com/jmizell/ddstats/client"
+)
+
+func TestStats_RegisterCheck(t *testing.T) {
+
+	stats, testApi, err := NewTestStats()
+	if err != nil {
+		t.Fatalf(err.Error())
+	}
+
+	calls := 0
+	stats.RegisterCheck("health", func() (client.Status, string) {
+		calls++
+		return client.Warning, "degraded"
+	}, []string{"check:1"})
+
+	stats.Flush()
+	stats.Gauge("test", 1, nil)
+	stats.Flush()
+	stats.Close()
+
+	if calls != 3 {
+		t.Fatalf("expected check to be evaluated %d times, have %d", 3, calls)
+	}
+
+	testApi.lock.Lock()
+	defer testApi.lock.Unlock()
+	if len(testApi.checks) != 3 {
+		t.Fatalf("expected %d service checks, have %d", 3, len(testApi.checks))
+	}
+	check := testApi.checks[0]
+	if check.Check != "testNamespace.health" {
+		t.Fatalf("expected check name to be %s, have %s", "testNamespace.health", check.Check)
+	}
+	if check.Status != client.Warning {
+		t.Fatalf("expected check status to be %d, have %d", client.Warning, check.Status)
+	}
+	if check.Message != "degraded" {
+		t.Fatalf("expected check message to be %s, have %s", "degraded", check.Message)
+	}
+	if len(check.Tags) != 2 {
+		t.Fatalf("expected check to have %d tags, have %d", 2, len(check.Tags))
+	}
+}
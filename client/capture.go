@@ -0,0 +1,75 @@
+package client
+
+import (
+	"fmt"
+	"io/ioutil"
+	"path"
+	"path/filepath"
+	"strings"
+	"sync/atomic"
+)
+
+// DefaultDebugCaptureMax is the default number of requests captured by debug capture.
+const DefaultDebugCaptureMax = 10
+
+// debugCapture writes request, and response bodies to a directory, for a bounded number
+// of requests.
+type debugCapture struct {
+	dir    string
+	max    int64
+	count  int64
+	apiKey string
+}
+
+// start records the request body, and returns the sequence number of the capture, or zero
+// if the request is not captured.
+func (d *debugCapture) start(url string, body []byte) int64 {
+
+	if d == nil {
+		return 0
+	}
+	seq := atomic.AddInt64(&d.count, 1)
+	if seq > d.max {
+		return 0
+	}
+
+	d.write(seq, url, "request.json", body)
+	return seq
+}
+
+// compressed records the request body as it was sent, compressed with encoding. The
+// capture started with the uncompressed body, so both are kept.
+func (d *debugCapture) compressed(seq int64, url string, encoding Compression, body []byte) {
+
+	if d == nil || seq == 0 || encoding == CompressionNone {
+		return
+	}
+
+	// The compressed body is written as is, masking could corrupt it
+	name := fmt.Sprintf("%06d-%s-request.json.%s", seq, path.Base(url), encoding)
+	_ = ioutil.WriteFile(filepath.Join(d.dir, name), body, 0600)
+}
+
+// finish records the response status, and body, or the error if the request failed.
+func (d *debugCapture) finish(seq int64, url string, status int, body []byte, err error) {
+
+	if d == nil || seq == 0 {
+		return
+	}
+
+	var data []byte
+	if err != nil {
+		data = []byte(fmt.Sprintf("error: %s\n", err.Error()))
+	} else {
+		data = append([]byte(fmt.Sprintf("status: %d\n\n", status)), body...)
+	}
+	d.write(seq, url, "response.txt", data)
+}
+
+func (d *debugCapture) write(seq int64, url, suffix string, data []byte) {
+	name := fmt.Sprintf("%06d-%s-%s", seq, path.Base(url), suffix)
+	if d.apiKey != "" {
+		data = []byte(strings.ReplaceAll(string(data), d.apiKey, maskedAPIKey))
+	}
+	_ = ioutil.WriteFile(filepath.Join(d.dir, name), data, 0600)
+}
@@ -0,0 +1,95 @@
+package client
+
+import (
+	"bytes"
+	"compress/gzip"
+	"fmt"
+	"io/ioutil"
+	"net/http"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func TestDDClient_SetDebugCapture(t *testing.T) {
+
+	dir, err := ioutil.TempDir("", "ddstats")
+	if err != nil {
+		t.Fatalf(err.Error())
+	}
+	defer func() { _ = os.RemoveAll(dir) }()
+
+	t.Run("capture bounded", func(tt *testing.T) {
+		client := NewDDClient("testKey")
+		client.SetHTTPClient(newTestHTTPClient(http.StatusOK, "", nil))
+		client.SetDebugCapture(dir, 1)
+
+		_ = client.SendEvent(&DDEvent{Title: "first"})
+		_ = client.SendEvent(&DDEvent{Title: "second"})
+
+		request, err := ioutil.ReadFile(filepath.Join(dir, "000001-events-request.json"))
+		if err != nil {
+			tt.Fatalf("expected request capture, %s", err.Error())
+		}
+		if !strings.Contains(string(request), `"title":"first"`) {
+			tt.Fatalf("expected request capture to contain the event, have %s", request)
+		}
+		response, err := ioutil.ReadFile(filepath.Join(dir, "000001-events-response.txt"))
+		if err != nil {
+			tt.Fatalf("expected response capture, %s", err.Error())
+		}
+		if !strings.HasPrefix(string(response), "status: 200") {
+			tt.Fatalf("expected response capture to have status, have %s", response)
+		}
+		if _, err := os.Stat(filepath.Join(dir, "000002-events-request.json")); err == nil {
+			tt.Fatalf("expected second request to not be captured")
+		}
+	})
+
+	t.Run("mask api key", func(tt *testing.T) {
+		client := NewDDClient("secretKey")
+		client.SetHTTPClient(newTestHTTPClient(0, "", fmt.Errorf("request to secretKey failed")))
+		client.SetDebugCapture(dir, 1)
+
+		_ = client.SendSeries(&DDMetricSeries{})
+
+		response, err := ioutil.ReadFile(filepath.Join(dir, "000001-series-response.txt"))
+		if err != nil {
+			tt.Fatalf("expected response capture, %s", err.Error())
+		}
+		if strings.Contains(string(response), "secretKey") {
+			tt.Fatalf("expected api key to be masked, have %s", response)
+		}
+	})
+	t.Run("compressed payload", func(tt *testing.T) {
+		compressedDir, err := ioutil.TempDir(dir, "compressed")
+		if err != nil {
+			tt.Fatalf(err.Error())
+		}
+		client := NewDDClient("testKey")
+		client.SetHTTPClient(&testDoClient{testHTTPClient: newTestHTTPClient(http.StatusOK, "", nil)})
+		if err := client.SetCompression(CompressionGzip, 0, 0); err != nil {
+			tt.Fatalf(err.Error())
+		}
+		client.SetDebugCapture(compressedDir, 1)
+
+		_ = client.SendSeries(&DDMetricSeries{Series: []*DDMetric{{Metric: "test"}}})
+
+		request, err := ioutil.ReadFile(filepath.Join(compressedDir, "000001-series-request.json"))
+		if err != nil || !strings.Contains(string(request), `"metric":"test"`) {
+			tt.Fatalf("expected uncompressed request capture, have %s, %v", request, err)
+		}
+		data, err := ioutil.ReadFile(filepath.Join(compressedDir, "000001-series-request.json.gzip"))
+		if err != nil {
+			tt.Fatalf("expected compressed request capture, %s", err.Error())
+		}
+		reader, err := gzip.NewReader(bytes.NewReader(data))
+		if err != nil {
+			tt.Fatalf("expected a gzip capture, %s", err.Error())
+		}
+		if uncompressed, err := ioutil.ReadAll(reader); err != nil || !bytes.Equal(uncompressed, request) {
+			tt.Fatalf("expected the compressed capture to match the request, have %s, %v", uncompressed, err)
+		}
+	})
+}
@@ -0,0 +1,131 @@
+package client
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"strings"
+)
+
+// Default limits of a single series request. Larger series are split into multiple
+// requests.
+const (
+	DefaultMaxSeriesPerRequest = 500
+	DefaultMaxPayloadBytes     = 3200000
+)
+
+// seriesOverhead is the size of the json encoding of an empty DDMetricSeries.
+const seriesOverhead = len(`{"series":[]}`)
+
+// PartialError is returned by SendSeries when a series was split into multiple requests,
+// and some of them failed. Failed holds the metrics of the failed requests, which were
+// not accepted by the api.
+type PartialError struct {
+	Requests int     // Number of requests the series was split into
+	Errors   []error // Error of each failed request
+	Failed   []*DDMetric
+}
+
+func (e *PartialError) Error() string {
+	msgs := make([]string, len(e.Errors))
+	for i, err := range e.Errors {
+		msgs[i] = err.Error()
+	}
+	return fmt.Sprintf("%d of %d series requests failed, %s", len(e.Errors), e.Requests, strings.Join(msgs, "; "))
+}
+
+// Unwrap returns the error of the first failed request.
+func (e *PartialError) Unwrap() error {
+	if len(e.Errors) == 0 {
+		return nil
+	}
+	return e.Errors[0]
+}
+
+// SetChunking sets the max number of metrics, and the max uncompressed payload size in
+// bytes of a single series request. Zero, or negative values use the defaults.
+func (c *DDClient) SetChunking(maxSeries, maxBytes int) {
+	c.maxSeries = maxSeries
+	c.maxBytes = maxBytes
+}
+
+// sendChunked sends series in requests within the series, and payload size limits. If
+// any request fails, the remaining requests are still sent, and a *PartialError is
+// returned. If the series fits in a single request, its error is returned unchanged.
+func (c *DDClient) sendChunked(ctx context.Context, series *DDMetricSeries) error {
+
+	maxSeries, maxBytes := c.chunkLimits()
+	if len(series.Series) <= maxSeries {
+		data, err := json.Marshal(series)
+		if err != nil {
+			return maskAPIKey(fmt.Errorf("could not marshal data to json, %s", err.Error()), c.apiKey)
+		}
+		if len(data) <= maxBytes {
+			return c.postData(ctx, data, encodingJSON, endpointSeries)
+		}
+	}
+
+	chunks, err := c.chunkSeries(series.Series, maxSeries, maxBytes)
+	if err != nil {
+		return err
+	}
+	if len(chunks) <= 1 {
+		return c.post(ctx, series, encodingJSON, endpointSeries)
+	}
+
+	partial := &PartialError{Requests: len(chunks)}
+	for _, chunk := range chunks {
+		if err := c.post(ctx, &DDMetricSeries{Series: chunk}, encodingJSON, endpointSeries); err != nil {
+			partial.Errors = append(partial.Errors, err)
+			partial.Failed = append(partial.Failed, chunk...)
+		}
+	}
+	if len(partial.Errors) > 0 {
+		return partial
+	}
+	return nil
+}
+
+// chunkLimits returns the max metrics, and payload size of a series request.
+func (c *DDClient) chunkLimits() (int, int) {
+	maxSeries, maxBytes := c.maxSeries, c.maxBytes
+	if maxSeries <= 0 {
+		maxSeries = DefaultMaxSeriesPerRequest
+	}
+	if maxBytes <= 0 {
+		maxBytes = DefaultMaxPayloadBytes
+	}
+	return maxSeries, maxBytes
+}
+
+// chunkSeries splits series into chunks within the limits. A metric larger than the
+// payload limit is sent alone.
+func (c *DDClient) chunkSeries(series []*DDMetric, maxSeries, maxBytes int) ([][]*DDMetric, error) {
+
+	var chunks [][]*DDMetric
+	var chunk []*DDMetric
+	size := seriesOverhead
+	for _, m := range series {
+		data, err := json.Marshal(m)
+		if err != nil {
+			return nil, maskAPIKey(fmt.Errorf("could not marshal data to json, %s", err.Error()), c.apiKey)
+		}
+
+		// Each metric after the first is preceded by a comma
+		n := len(data)
+		if len(chunk) > 0 {
+			n++
+		}
+		if len(chunk) > 0 && (len(chunk) >= maxSeries || size+n > maxBytes) {
+			chunks = append(chunks, chunk)
+			chunk, size, n = nil, seriesOverhead, len(data)
+		}
+		chunk = append(chunk, m)
+		size += n
+	}
+	if len(chunk) > 0 {
+		chunks = append(chunks, chunk)
+	}
+
+	return chunks, nil
+}
@@ -0,0 +1,120 @@
+package client
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"net/http"
+	"strings"
+	"testing"
+)
+
+// chunkHTTPClient records the series of each request, and fails the requests in fail.
+type chunkHTTPClient struct {
+	requests [][]*DDMetric
+	fail     map[int]bool
+}
+
+func (c *chunkHTTPClient) Post(url, contentType string, body io.Reader) (*http.Response, error) {
+
+	series := &DDMetricSeries{}
+	if err := json.NewDecoder(body).Decode(series); err != nil {
+		return nil, err
+	}
+	c.requests = append(c.requests, series.Series)
+
+	status, response := http.StatusAccepted, `{}`
+	if c.fail[len(c.requests)-1] {
+		status, response = http.StatusBadRequest, `{"errors":["bad request"]}`
+	}
+	return &http.Response{
+		StatusCode: status,
+		Body:       ioutil.NopCloser(strings.NewReader(response)),
+	}, nil
+}
+
+func testSeries(n int) *DDMetricSeries {
+	series := &DDMetricSeries{}
+	for i := 0; i < n; i++ {
+		series.Series = append(series.Series, &DDMetric{
+			Metric: fmt.Sprintf("test.%d", i),
+			Points: [][2]interface{}{{1, 1.0}},
+			Type:   Gauge,
+		})
+	}
+	return series
+}
+
+func TestDDClient_SendSeriesChunked(t *testing.T) {
+
+	t.Run("max series", func(tt *testing.T) {
+		client := NewDDClient("testKey")
+		httpClient := &chunkHTTPClient{}
+		client.SetHTTPClient(httpClient)
+		client.SetChunking(2, 0)
+
+		if err := client.SendSeries(testSeries(5)); err != nil {
+			tt.Fatalf(err.Error())
+		}
+		if len(httpClient.requests) != 3 {
+			tt.Fatalf("expected %d requests, have %d", 3, len(httpClient.requests))
+		}
+		for i, expected := range []int{2, 2, 1} {
+			if len(httpClient.requests[i]) != expected {
+				tt.Fatalf("expected request %d to have %d metrics, have %d", i, expected, len(httpClient.requests[i]))
+			}
+		}
+	})
+
+	t.Run("max bytes", func(tt *testing.T) {
+		client := NewDDClient("testKey")
+		httpClient := &chunkHTTPClient{}
+		client.SetHTTPClient(httpClient)
+
+		series := testSeries(4)
+		metric, _ := json.Marshal(series.Series[0])
+		client.SetChunking(0, seriesOverhead+len(metric)*2+1)
+
+		if err := client.SendSeries(series); err != nil {
+			tt.Fatalf(err.Error())
+		}
+		if len(httpClient.requests) != 2 {
+			tt.Fatalf("expected %d requests, have %d", 2, len(httpClient.requests))
+		}
+	})
+
+	t.Run("partial failure", func(tt *testing.T) {
+		client := NewDDClient("testKey")
+		httpClient := &chunkHTTPClient{fail: map[int]bool{1: true}}
+		client.SetHTTPClient(httpClient)
+		client.SetChunking(2, 0)
+
+		err := client.SendSeries(testSeries(5))
+		partial, ok := err.(*PartialError)
+		if !ok {
+			tt.Fatalf("expected a *PartialError, have %v", err)
+		}
+		if len(httpClient.requests) != 3 {
+			tt.Fatalf("expected all %d requests to be sent, have %d", 3, len(httpClient.requests))
+		}
+		if partial.Requests != 3 || len(partial.Errors) != 1 {
+			tt.Fatalf("expected %d of %d requests to fail, have %s", 1, 3, partial.Error())
+		}
+		if len(partial.Failed) != 2 || partial.Failed[0].Metric != "test.2" || partial.Failed[1].Metric != "test.3" {
+			tt.Fatalf("expected test.2, and test.3 to fail, have %v", partial.Failed)
+		}
+		if apiErr, ok := partial.Unwrap().(*APIError); !ok || apiErr.StatusCode != http.StatusBadRequest {
+			tt.Fatalf("expected the first error to be an api error, have %v", partial.Unwrap())
+		}
+	})
+
+	t.Run("single request error", func(tt *testing.T) {
+		client := NewDDClient("testKey")
+		client.SetHTTPClient(&chunkHTTPClient{fail: map[int]bool{0: true}})
+
+		if _, ok := client.SendSeries(testSeries(2)).(*APIError); !ok {
+			tt.Fatalf("expected an unsplit series to return the api error")
+		}
+	})
+}
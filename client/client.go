@@ -2,22 +2,33 @@ package client
 
 import (
 	"bytes"
+	"context"
 	"encoding/json"
 	"fmt"
 	"io"
 	"io/ioutil"
 	"net/http"
+	"net/url"
 	"strings"
+	"time"
 )
 
 const (
 	datadogAPIURL = "https://api.datadoghq.com/api/v1"
 	encodingJSON  = "application/json"
+	maskedAPIKey  = "XXXXAPI_KEYXXXX"
 )
 
-var endpointSeries = fmt.Sprintf("%s/series", datadogAPIURL)
-var endpointCheck = fmt.Sprintf("%s/check_run", datadogAPIURL)
-var endpointEvent = fmt.Sprintf("%s/events", datadogAPIURL)
+// DefaultSite is the Datadog site used when no site, or base url is set.
+const DefaultSite = "datadoghq.com"
+
+// Api endpoints, relative to the base url.
+const (
+	endpointSeries       = "/series"
+	endpointCheck        = "/check_run"
+	endpointEvent        = "/events"
+	endpointDistribution = "/distribution_points"
+)
 
 type APIClient interface {
 	SendSeries(*DDMetricSeries) error
@@ -26,82 +37,257 @@ type APIClient interface {
 	SetHTTPClient(HTTPClient)
 }
 
+// ContextClient is implemented by api clients that can cancel a send in progress, when
+// ctx is done. It's separate from APIClient, so existing APIClient implementations
+// continue to work.
+type ContextClient interface {
+	SendSeriesContext(ctx context.Context, series *DDMetricSeries) error
+	SendDistributionsContext(ctx context.Context, series *DDDistributionSeries) error
+}
+
+// SendSeriesContext sends series with c, the send is cancelled when ctx is done, if c
+// implements ContextClient.
+func SendSeriesContext(ctx context.Context, c APIClient, series *DDMetricSeries) error {
+	if contextClient, ok := c.(ContextClient); ok {
+		return contextClient.SendSeriesContext(ctx, series)
+	}
+	return c.SendSeries(series)
+}
+
+// SendDistributionsContext sends series with c, the send is cancelled when ctx is done, if
+// c implements ContextClient.
+func SendDistributionsContext(ctx context.Context, c DistributionClient, series *DDDistributionSeries) error {
+	if contextClient, ok := c.(ContextClient); ok {
+		return contextClient.SendDistributionsContext(ctx, series)
+	}
+	return c.SendDistributions(series)
+}
+
 type HTTPClient interface {
 	Post(url, contentType string, body io.Reader) (resp *http.Response, err error)
 }
 
+// HTTPDoer is implemented by http clients that can send a request with headers, such as
+// *http.Client. It's required to send the api key in a header.
+type HTTPDoer interface {
+	Do(req *http.Request) (*http.Response, error)
+}
+
+// HeaderAPIKey is the request header the api key is sent in, when header mode is enabled.
+const HeaderAPIKey = "DD-API-KEY"
+
 type DDClient struct {
-	apiKey string
-	client HTTPClient
+	apiKey       string
+	apiKeyHeader bool
+	compression  compression
+	maxSeries    int
+	maxBytes     int
+	baseURL      string
+	client       HTTPClient
+	proxy        *ProxyConfig
+	capture      *debugCapture
+	skew         clockSkew
 }
 
+// NewDDClient creates a new client for the Datadog api. Proxy settings are loaded from
+// the environment, see ProxyConfigFromEnvironment for the supported variables.
 func NewDDClient(apiKey string) *DDClient {
+	proxy := ProxyConfigFromEnvironment()
+	httpClient, _ := NewHTTPClient(TransportConfig{}, proxy)
 	return &DDClient{
-		apiKey: apiKey,
-		client: &http.Client{},
+		apiKey:  apiKey,
+		baseURL: datadogAPIURL,
+		client:  httpClient,
+		proxy:   proxy,
 	}
 }
 
+// ProxyDecision describes whether requests to the Datadog api are sent through a proxy,
+// and which environment variable the proxy was configured from.
+func (c *DDClient) ProxyDecision() string {
+	endpoint, _ := url.Parse(c.baseURL)
+	return c.proxy.Describe(endpoint)
+}
+
 func (c *DDClient) SetHTTPClient(client HTTPClient) {
 	c.client = client
 }
 
+// SetAPIKeyHeader sets whether the api key is sent in the DD-API-KEY header, instead of
+// the api_key query parameter. Query parameters are often recorded in proxy, and access
+// logs, the header is not. Header mode requires an http client that implements HTTPDoer.
+func (c *DDClient) SetAPIKeyHeader(enabled bool) {
+	c.apiKeyHeader = enabled
+}
+
+// SiteURL returns the api base url for a Datadog site, such as datadoghq.eu, or
+// us3.datadoghq.com.
+func SiteURL(site string) string {
+	return fmt.Sprintf("https://api.%s/api/v1", strings.TrimPrefix(strings.Trim(site, "/"), "api."))
+}
+
+// SetSite sets the Datadog site the client sends to, such as datadoghq.eu. The default
+// site is datadoghq.com.
+func (c *DDClient) SetSite(site string) error {
+	if site == "" {
+		site = DefaultSite
+	}
+	return c.SetBaseURL(SiteURL(site))
+}
+
+// SetBaseURL sets the base url of the api, including the version path, for example
+// https://api.datadoghq.eu/api/v1. Endpoints such as /series are appended to the base
+// url, which allows the client to be pointed at a proxy, or mock of the api.
+func (c *DDClient) SetBaseURL(baseURL string) error {
+	u, err := url.Parse(baseURL)
+	if err != nil {
+		return fmt.Errorf("could not parse api base url, %s", err.Error())
+	}
+	if u.Scheme != "http" && u.Scheme != "https" || u.Host == "" {
+		return fmt.Errorf("invalid api base url %q, expected an http, or https url", baseURL)
+	}
+	c.baseURL = strings.TrimRight(baseURL, "/")
+	return nil
+}
+
+// BaseURL returns the base url of the api.
+func (c *DDClient) BaseURL() string {
+	return c.baseURL
+}
+
+// SetDebugCapture enables writing the body of each request, and the status, and body of
+// each response to files in dir, for the first max requests. Files are named with the
+// request sequence number, and the api endpoint. Compressed requests are also captured as
+// sent, with the content encoding as an extension. The api key is masked in all captured
+// data, except the compressed requests. An empty dir disables capture.
+func (c *DDClient) SetDebugCapture(dir string, max int) {
+	if dir == "" {
+		c.capture = nil
+		return
+	}
+	c.capture = &debugCapture{dir: dir, max: int64(max), apiKey: c.apiKey}
+}
+
+// SendSeries posts series to the api. Series larger than the chunking limits are split
+// into multiple requests, see SetChunking.
 func (c *DDClient) SendSeries(series *DDMetricSeries) error {
-	return c.post(series, encodingJSON, endpointSeries)
+	return c.sendChunked(context.Background(), series)
+}
+
+// SendSeriesContext is the same as SendSeries, but the requests are cancelled when ctx is
+// done.
+func (c *DDClient) SendSeriesContext(ctx context.Context, series *DDMetricSeries) error {
+	return c.sendChunked(ctx, series)
 }
 
 func (c *DDClient) SendServiceCheck(check *DDServiceCheck) error {
-	return c.post(check, encodingJSON, endpointCheck)
+	return c.post(context.Background(), check, encodingJSON, endpointCheck)
 }
 
 func (c *DDClient) SendEvent(event *DDEvent) error {
-	return c.post(event, encodingJSON, endpointEvent)
+	return c.post(context.Background(), event, encodingJSON, endpointEvent)
 }
 
-func (c *DDClient) post(payload interface{}, encoding, url string) error {
+func (c *DDClient) SendDistributions(series *DDDistributionSeries) error {
+	return c.post(context.Background(), series, encodingJSON, endpointDistribution)
+}
 
-	// TODO implement retry logic
+// SendDistributionsContext is the same as SendDistributions, but the request is cancelled
+// when ctx is done.
+func (c *DDClient) SendDistributionsContext(ctx context.Context, series *DDDistributionSeries) error {
+	return c.post(ctx, series, encodingJSON, endpointDistribution)
+}
 
-	url = fmt.Sprintf("%s?api_key=%s", url, c.apiKey)
+func (c *DDClient) post(ctx context.Context, payload interface{}, encoding, endpoint string) error {
 
 	data, err := json.Marshal(payload)
 	if err != nil {
 		return maskAPIKey(fmt.Errorf("could not marshal data to json, %s", err.Error()), c.apiKey)
 	}
+	return c.postData(ctx, data, encoding, endpoint)
+}
+
+// postData posts the encoded payload data to endpoint.
+func (c *DDClient) postData(ctx context.Context, data []byte, encoding, endpoint string) (err error) {
+
+	// TODO implement retry logic
+
+	url := fmt.Sprintf("%s%s", c.baseURL, endpoint)
+	if !c.apiKeyHeader {
+		url = fmt.Sprintf("%s?api_key=%s", url, c.apiKey)
+	}
+
+	var status int
+	var responseBytes []byte
+	seq := c.capture.start(endpoint, data)
+	defer func() { c.capture.finish(seq, endpoint, status, responseBytes, err) }()
+
+	// Only series are compressed, the debug capture has the uncompressed payload, and
+	// the compressed payload as it was sent
+	var contentEncoding Compression
+	if _, isDoer := c.client.(HTTPDoer); isDoer && endpoint == endpointSeries {
+		if data, contentEncoding, err = c.compression.compress(data); err != nil {
+			return maskAPIKey(err, c.apiKey)
+		}
+		c.capture.compressed(seq, endpoint, contentEncoding, data)
+	}
 
-	response, err := c.client.Post(url, encoding, bytes.NewReader(data))
+	start := time.Now()
+	response, err := c.send(ctx, url, encoding, contentEncoding, data)
 	if err != nil {
 		return maskAPIKey(err, c.apiKey)
 	}
+	c.skew.observe(response, start)
 	defer func() { _ = response.Body.Close() }()
 
-	responseBytes, err := ioutil.ReadAll(response.Body)
+	status = response.StatusCode
+	responseBytes, err = ioutil.ReadAll(response.Body)
 	if err != nil {
-		return maskAPIKey(fmt.Errorf("could not read api response, %s", err.Error()), c.apiKey)
+		return maskAPIKey(networkError("could not read api response", err), c.apiKey)
 	}
 
+	// Error responses aren't always json, the status is reported even if the body can't
+	// be decoded
 	apiResponse := &DDApiResponse{}
-	if err := json.Unmarshal(responseBytes, apiResponse); err != nil {
+	if err := json.Unmarshal(responseBytes, apiResponse); err != nil && response.StatusCode <= 299 {
 		return maskAPIKey(fmt.Errorf("could not read api response, %s", err.Error()), c.apiKey)
 	}
 
 	if response.StatusCode > 299 {
-		err := fmt.Errorf("api response %d: %s", response.StatusCode, strings.Join(apiResponse.Errors, ", "))
-		return maskAPIKey(err, c.apiKey)
+		return maskAPIKey(newAPIError(response, apiResponse.Errors, responseBytes), c.apiKey)
 	}
 
 	return nil
 }
 
-func maskAPIKey(err error, key string) error {
-
-	if err == nil {
-		return nil
-	}
-
-	return fmt.Errorf(strings.ReplaceAll(err.Error(), key, "XXXXAPI_KEYXXXX"))
-}
-
 type DDApiResponse struct {
 	Errors []string `json:"errors"`
 }
+
+// send posts data to url, with the api key in a header if header mode is enabled, and the
+// content encoding of compressed data. The request is cancelled when ctx is done, if the
+// http client implements Do.
+func (c *DDClient) send(ctx context.Context, url, encoding string, contentEncoding Compression, data []byte) (*http.Response, error) {
+
+	doer, ok := c.client.(HTTPDoer)
+	if !c.apiKeyHeader && contentEncoding == CompressionNone && (!ok || ctx.Done() == nil) {
+		response, err := c.client.Post(url, encoding, bytes.NewReader(data))
+		return response, networkError("", err)
+	}
+	if !ok {
+		return nil, fmt.Errorf("could not send api key header, http client does not implement Do")
+	}
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, url, bytes.NewReader(data))
+	if err != nil {
+		return nil, fmt.Errorf("could not create request, %s", err.Error())
+	}
+	req.Header.Set("Content-Type", encoding)
+	if contentEncoding != CompressionNone {
+		req.Header.Set("Content-Encoding", string(contentEncoding))
+	}
+	if c.apiKeyHeader {
+		req.Header.Set(HeaderAPIKey, c.apiKey)
+	}
+	response, err := doer.Do(req)
+	return response, networkError("", err)
+}
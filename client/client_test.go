@@ -2,11 +2,15 @@ package client
 
 import (
 	"bytes"
+	"context"
 	"encoding/json"
+	"errors"
 	"fmt"
 	"io"
 	"io/ioutil"
 	"net/http"
+	"net/http/httptest"
+	"net/url"
 	"strings"
 	"testing"
 	"time"
@@ -147,7 +151,7 @@ func TestDDClient_SendSeries(t *testing.T) {
 				Host:     "host1",
 				Interval: 60,
 				Metric:   "test.metric",
-				Points: [][2]interface{}{{time.Now().Unix(), 1}},
+				Points:   [][2]interface{}{{time.Now().Unix(), 1}},
 				Tags:     []string{"tag:1"},
 				Type:     Count,
 			},
@@ -179,6 +183,37 @@ func TestDDClient_SendSeries(t *testing.T) {
 	})
 }
 
+func TestDDClient_SendSeriesContext(t *testing.T) {
+
+	release := make(chan bool)
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		<-release
+	}))
+	defer server.Close()
+	defer close(release)
+
+	client := NewDDClient("testKey")
+	client.SetHTTPClient(&http.Client{})
+	if err := client.SetBaseURL(server.URL); err != nil {
+		t.Fatalf(err.Error())
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), time.Millisecond*50)
+	defer cancel()
+	result := make(chan error, 1)
+	go func() {
+		result <- client.SendSeriesContext(ctx, &DDMetricSeries{Series: []*DDMetric{{Metric: "test"}}})
+	}()
+
+	select {
+	case err := <-result:
+		if err == nil || !strings.Contains(err.Error(), context.DeadlineExceeded.Error()) {
+			t.Fatalf("expected error %v, have %v", context.DeadlineExceeded, err)
+		}
+	case <-time.After(time.Second * 5):
+		t.Fatalf("expected the request to be cancelled with the context")
+	}
+}
 
 func TestDDClient_SendServiceCheck(t *testing.T) {
 
@@ -187,7 +222,7 @@ func TestDDClient_SendServiceCheck(t *testing.T) {
 		Hostname:  "testHost",
 		Message:   "check ran",
 		Status:    1,
-		Tags:     []string{"tag:1"},
+		Tags:      []string{"tag:1"},
 		Timestamp: time.Now().Unix(),
 	}
 
@@ -223,7 +258,7 @@ func TestDDClient_post(t *testing.T) {
 		httpClient := newTestHTTPClient(0, "", nil)
 		client.SetHTTPClient(httpClient)
 
-		if err := client.post(make(chan int), "", ""); err == nil {
+		if err := client.post(context.Background(), make(chan int), "", ""); err == nil {
 			t.Fatalf("expected an error, have nil")
 		} else if !strings.HasPrefix(err.Error(), "could not marshal data to json") {
 			t.Fatalf("expected error to have prefix \"%s\", have \"%s\"", "could not marshal data to json", err.Error())
@@ -241,10 +276,220 @@ func TestDDClient_post(t *testing.T) {
 		}
 		client.SetHTTPClient(httpClient)
 
-		if err := client.post(nil, "", ""); err == nil {
+		if err := client.post(context.Background(), nil, "", ""); err == nil {
 			t.Fatalf("expected an error, have nil")
 		} else if !strings.HasPrefix(err.Error(), "could not read api response") {
 			t.Fatalf("expected error to have prefix \"%s\", have \"%s\"", "could not read api response", err.Error())
 		}
 	})
-}
\ No newline at end of file
+}
+
+func TestDDClient_SetSite(t *testing.T) {
+
+	client := NewDDClient("testKey")
+	httpClient := newTestHTTPClient(http.StatusOK, "", nil)
+	client.SetHTTPClient(httpClient)
+
+	if err := client.SetSite("datadoghq.eu"); err != nil {
+		t.Fatalf(err.Error())
+	}
+	if err := client.SendSeries(&DDMetricSeries{}); err != nil {
+		t.Fatalf(err.Error())
+	}
+	expected := "https://api.datadoghq.eu/api/v1/series?api_key=testKey"
+	if httpClient.callURL != expected {
+		t.Fatalf("expected request url to be %s, have %s", expected, httpClient.callURL)
+	}
+
+	if err := client.SetBaseURL("ftp://example.com"); err == nil {
+		t.Fatalf("expected an error for a non http base url")
+	}
+	if client.BaseURL() != "https://api.datadoghq.eu/api/v1" {
+		t.Fatalf("expected an invalid base url to not replace the base url, have %s", client.BaseURL())
+	}
+}
+
+type testDoClient struct {
+	*testHTTPClient
+	request *http.Request
+}
+
+func (t *testDoClient) Do(req *http.Request) (*http.Response, error) {
+	t.request = req
+	return t.response, t.error
+}
+
+func TestDDClient_SetAPIKeyHeader(t *testing.T) {
+
+	t.Run("header", func(tt *testing.T) {
+		client := NewDDClient("testKey")
+		httpClient := &testDoClient{testHTTPClient: newTestHTTPClient(http.StatusOK, "", nil)}
+		client.SetHTTPClient(httpClient)
+		client.SetAPIKeyHeader(true)
+
+		if err := client.SendSeries(&DDMetricSeries{}); err != nil {
+			tt.Fatalf(err.Error())
+		}
+		if httpClient.request == nil {
+			tt.Fatalf("expected request to be sent with Do")
+		}
+		if key := httpClient.request.Header.Get(HeaderAPIKey); key != "testKey" {
+			tt.Fatalf("expected %s header to be %s, have %s", HeaderAPIKey, "testKey", key)
+		}
+		if strings.Contains(httpClient.request.URL.String(), "testKey") {
+			tt.Fatalf("expected api key to not be in the url, have %s", httpClient.request.URL.String())
+		}
+	})
+
+	t.Run("http client without Do", func(tt *testing.T) {
+		client := NewDDClient("testKey")
+		httpClient := newTestHTTPClient(http.StatusOK, "", nil)
+		client.SetHTTPClient(httpClient)
+		client.SetAPIKeyHeader(true)
+
+		if err := client.SendSeries(&DDMetricSeries{}); err == nil {
+			tt.Fatalf("expected an error, have nil")
+		}
+		if httpClient.callURL != "" {
+			tt.Fatalf("expected no request to be sent, have %s", httpClient.callURL)
+		}
+	})
+}
+
+func TestDDClient_postErrors(t *testing.T) {
+
+	t.Run("api error without json body", func(tt *testing.T) {
+		client := NewDDClient("testKey")
+		client.SetHTTPClient(&testHTTPClient{
+			response: &http.Response{
+				StatusCode: http.StatusRequestEntityTooLarge,
+				Body:       ioutil.NopCloser(bytes.NewReader([]byte("<html>too large</html>"))),
+			},
+		})
+
+		err := client.post(context.Background(), nil, "", "")
+		apiErr, ok := err.(*APIError)
+		if !ok {
+			tt.Fatalf("expected an *APIError, have %v", err)
+		}
+		if apiErr.StatusCode != http.StatusRequestEntityTooLarge {
+			tt.Fatalf("expected status %d, have %d", http.StatusRequestEntityTooLarge, apiErr.StatusCode)
+		}
+		if apiErr.Body != "<html>too large</html>" {
+			tt.Fatalf("expected body %q, have %q", "<html>too large</html>", apiErr.Body)
+		}
+	})
+
+	t.Run("rate limited", func(tt *testing.T) {
+		client := NewDDClient("testKey")
+		client.SetHTTPClient(&testHTTPClient{
+			response: &http.Response{
+				StatusCode: http.StatusTooManyRequests,
+				Header:     http.Header{"Retry-After": []string{"30"}},
+				Body:       ioutil.NopCloser(bytes.NewReader([]byte(`{"errors":["rate limited testKey"]}`))),
+			},
+		})
+
+		err := client.post(context.Background(), nil, "", "")
+		rateErr, ok := err.(*RateLimitError)
+		if !ok {
+			tt.Fatalf("expected a *RateLimitError, have %v", err)
+		}
+		if rateErr.RetryAfter != time.Second*30 {
+			tt.Fatalf("expected retry after %s, have %s", time.Second*30, rateErr.RetryAfter)
+		}
+		var apiErr *APIError
+		if !errors.As(err, &apiErr) || apiErr.StatusCode != http.StatusTooManyRequests {
+			tt.Fatalf("expected rate limit error to wrap an *APIError, have %v", err)
+		}
+		if strings.Contains(apiErr.Body, "testKey") || strings.Contains(err.Error(), "testKey") {
+			tt.Fatalf("expected api key to be masked, have %s, %s", err.Error(), apiErr.Body)
+		}
+	})
+
+	t.Run("network error", func(tt *testing.T) {
+		client := NewDDClient("testKey")
+		client.SetHTTPClient(newTestHTTPClient(http.StatusOK, "", &url.Error{
+			Op:  "Post",
+			URL: "https://example.com?api_key=testKey",
+			Err: timeoutError{},
+		}))
+
+		err := client.post(context.Background(), nil, "", "")
+		netErr, ok := err.(*NetworkError)
+		if !ok {
+			tt.Fatalf("expected a *NetworkError, have %v", err)
+		}
+		if !netErr.Timeout() {
+			tt.Fatalf("expected network error to be a timeout")
+		}
+		if strings.Contains(err.Error(), "testKey") {
+			tt.Fatalf("expected api key to be masked, have %s", err.Error())
+		}
+	})
+
+	t.Run("masked timeout", func(tt *testing.T) {
+		err := maskAPIKey(&url.Error{Op: "Post", URL: "https://example.com?api_key=testKey", Err: timeoutError{}}, "testKey")
+		if strings.Contains(err.Error(), "testKey") {
+			tt.Fatalf("expected api key to be masked, have %s", err.Error())
+		}
+		if timeout, ok := err.(interface{ Timeout() bool }); !ok || !timeout.Timeout() {
+			tt.Fatalf("expected masked error to be a timeout")
+		}
+	})
+}
+
+type timeoutError struct{}
+
+func (timeoutError) Error() string   { return "i/o timeout" }
+func (timeoutError) Timeout() bool   { return true }
+func (timeoutError) Temporary() bool { return true }
+
+func TestDDClient_ClockSkew(t *testing.T) {
+
+	client := NewDDClient("testKey")
+	httpClient := newTestHTTPClient(http.StatusOK, "", nil)
+	client.SetHTTPClient(httpClient)
+
+	if _, ok := client.ClockSkew(); ok {
+		t.Fatalf("expected no skew estimate before a response")
+	}
+
+	httpClient.response.Header = http.Header{}
+	httpClient.response.Header.Set("Date", time.Now().Add(time.Minute).UTC().Format(http.TimeFormat))
+	if err := client.SendSeries(&DDMetricSeries{}); err != nil {
+		t.Fatalf(err.Error())
+	}
+
+	skew, ok := client.ClockSkew()
+	if !ok {
+		t.Fatalf("expected skew estimate after a response")
+	}
+	if skew < time.Minute-time.Second*2 || skew > time.Minute+time.Second*2 {
+		t.Fatalf("expected skew to be about %s, have %s", time.Minute, skew)
+	}
+}
+
+func TestRetryAfter(t *testing.T) {
+
+	now := time.Date(2020, 1, 1, 0, 0, 0, 0, time.UTC)
+	tests := []struct {
+		name     string
+		header   http.Header
+		expected time.Duration
+	}{
+		{"seconds", http.Header{"Retry-After": []string{"5"}}, time.Second * 5},
+		{"date", http.Header{"Retry-After": []string{now.Add(time.Minute).Format(http.TimeFormat)}}, time.Minute},
+		{"rate limit reset", http.Header{"X-Ratelimit-Reset": []string{"12"}}, time.Second * 12},
+		{"invalid", http.Header{"Retry-After": []string{"soon"}}, 0},
+		{"none", http.Header{}, 0},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(tt *testing.T) {
+			if wait := retryAfter(test.header, now); wait != test.expected {
+				tt.Fatalf("expected %s, have %s", test.expected, wait)
+			}
+		})
+	}
+}
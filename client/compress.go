@@ -0,0 +1,76 @@
+package client
+
+import (
+	"bytes"
+	"compress/gzip"
+	"compress/zlib"
+	"fmt"
+	"io"
+)
+
+// Compression is the content encoding of compressed payloads.
+type Compression string
+
+// Supported compression encodings
+const (
+	CompressionNone    = Compression("")
+	CompressionDeflate = Compression("deflate")
+	CompressionGzip    = Compression("gzip")
+)
+
+// DefaultCompressionThreshold is the default payload size in bytes, below which payloads
+// are sent uncompressed.
+const DefaultCompressionThreshold = 1024
+
+type compression struct {
+	encoding  Compression
+	level     int
+	threshold int
+}
+
+// SetCompression enables compressing series payloads of at least threshold bytes, with
+// encoding, deflate (zlib), or gzip, at level. Level is one of the compress/flate levels,
+// such as flate.BestSpeed, zero uses the default level. Compression requires an http
+// client that implements HTTPDoer, payloads are sent uncompressed by other clients.
+func (c *DDClient) SetCompression(encoding Compression, level, threshold int) error {
+
+	if encoding != CompressionNone && encoding != CompressionDeflate && encoding != CompressionGzip {
+		return fmt.Errorf("unsupported compression %q", encoding)
+	}
+	if level == 0 {
+		level = -1
+	}
+	if level < -2 || level > 9 {
+		return fmt.Errorf("invalid compression level %d", level)
+	}
+	c.compression = compression{encoding: encoding, level: level, threshold: threshold}
+	return nil
+}
+
+// compress returns data compressed, and the content encoding, or data unchanged, and an
+// empty encoding if compression is disabled, or data is below the threshold.
+func (c compression) compress(data []byte) ([]byte, Compression, error) {
+
+	if c.encoding == CompressionNone || len(data) < c.threshold {
+		return data, CompressionNone, nil
+	}
+
+	buf := &bytes.Buffer{}
+	var w io.WriteCloser
+	var err error
+	if c.encoding == CompressionGzip {
+		w, err = gzip.NewWriterLevel(buf, c.level)
+	} else {
+		w, err = zlib.NewWriterLevel(buf, c.level)
+	}
+	if err != nil {
+		return nil, CompressionNone, fmt.Errorf("could not compress payload, %s", err.Error())
+	}
+	if _, err := w.Write(data); err != nil {
+		return nil, CompressionNone, fmt.Errorf("could not compress payload, %s", err.Error())
+	}
+	if err := w.Close(); err != nil {
+		return nil, CompressionNone, fmt.Errorf("could not compress payload, %s", err.Error())
+	}
+	return buf.Bytes(), c.encoding, nil
+}
@@ -0,0 +1,92 @@
+package client
+
+import (
+	"bytes"
+	"compress/flate"
+	"compress/gzip"
+	"compress/zlib"
+	"encoding/json"
+	"io"
+	"io/ioutil"
+	"net/http"
+	"testing"
+)
+
+func TestDDClient_SetCompression(t *testing.T) {
+
+	series := &DDMetricSeries{Series: []*DDMetric{{Metric: "test", Points: [][2]interface{}{{1, 1.0}}}}}
+	tests := []struct {
+		encoding  Compression
+		threshold int
+		expected  string
+		reader    func(io.Reader) (io.Reader, error)
+	}{
+		{CompressionDeflate, 0, "deflate", func(r io.Reader) (io.Reader, error) { return zlib.NewReader(r) }},
+		{CompressionGzip, 0, "gzip", func(r io.Reader) (io.Reader, error) { return gzip.NewReader(r) }},
+		{CompressionGzip, 1 << 20, "", func(r io.Reader) (io.Reader, error) { return r, nil }},
+	}
+
+	for _, test := range tests {
+		t.Run(string(test.encoding)+test.expected, func(tt *testing.T) {
+			client := NewDDClient("testKey")
+			httpClient := &testDoClient{testHTTPClient: newTestHTTPClient(http.StatusOK, "", nil)}
+			client.SetHTTPClient(httpClient)
+			if err := client.SetCompression(test.encoding, flate.BestSpeed, test.threshold); err != nil {
+				tt.Fatalf(err.Error())
+			}
+
+			if err := client.SendSeries(series); err != nil {
+				tt.Fatalf(err.Error())
+			}
+			// Uncompressed payloads are sent with Post
+			body := io.Reader(bytes.NewReader(httpClient.callBody))
+			if httpClient.request != nil {
+				if encoding := httpClient.request.Header.Get("Content-Encoding"); encoding != test.expected {
+					tt.Fatalf("expected content encoding %q, have %q", test.expected, encoding)
+				}
+				body = httpClient.request.Body
+			} else if test.expected != "" {
+				tt.Fatalf("expected a compressed request")
+			}
+
+			r, err := test.reader(body)
+			if err != nil {
+				tt.Fatalf(err.Error())
+			}
+			data, err := ioutil.ReadAll(r)
+			if err != nil {
+				tt.Fatalf(err.Error())
+			}
+			expected, _ := json.Marshal(series)
+			if !bytes.Equal(data, expected) {
+				tt.Fatalf("expected payload %s, have %s", expected, data)
+			}
+		})
+	}
+
+	t.Run("post only http client", func(tt *testing.T) {
+		client := NewDDClient("testKey")
+		httpClient := newTestHTTPClient(http.StatusOK, "", nil)
+		client.SetHTTPClient(httpClient)
+		if err := client.SetCompression(CompressionGzip, 0, 0); err != nil {
+			tt.Fatalf(err.Error())
+		}
+		if err := client.SendSeries(series); err != nil {
+			tt.Fatalf(err.Error())
+		}
+		expected, _ := json.Marshal(series)
+		if !bytes.Equal(httpClient.callBody, expected) {
+			tt.Fatalf("expected an uncompressed payload, have %s", httpClient.callBody)
+		}
+	})
+
+	t.Run("invalid", func(tt *testing.T) {
+		client := NewDDClient("testKey")
+		if err := client.SetCompression("zstd", 0, 0); err == nil {
+			tt.Fatalf("expected an error for an unsupported encoding")
+		}
+		if err := client.SetCompression(CompressionGzip, 10, 0); err == nil {
+			tt.Fatalf("expected an error for an invalid level")
+		}
+	})
+}
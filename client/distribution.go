@@ -0,0 +1,24 @@
+package client
+
+// Distribution is the DDDistribution Type value
+const Distribution = "distribution"
+
+// DDDistribution is a distribution metric. Each point is a unix timestamp, and a list of
+// all values recorded for the metric, which Datadog aggregates server side.
+type DDDistribution struct {
+	Host   string           `json:"host"`
+	Metric string           `json:"metric"`
+	Points [][2]interface{} `json:"points"`
+	Tags   []string         `json:"tags"`
+	Type   string           `json:"type"`
+}
+
+type DDDistributionSeries struct {
+	Series []*DDDistribution `json:"series"`
+}
+
+// DistributionClient is implemented by api clients that can send distribution metrics.
+// It's separate from APIClient, so existing APIClient implementations continue to work.
+type DistributionClient interface {
+	SendDistributions(*DDDistributionSeries) error
+}
@@ -0,0 +1,283 @@
+package client
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"net"
+	"strconv"
+	"strings"
+	"sync"
+)
+
+// DefaultDogStatsDAddr is the default address of the Datadog Agent dogstatsd server.
+const DefaultDogStatsDAddr = "127.0.0.1:8125"
+
+// dogStatsDMaxPacketSize is the max size of a datagram, sized to fit the common 1500
+// byte MTU, the same as the official clients.
+const dogStatsDMaxPacketSize = 1432
+
+// Replacers for characters that are part of the dogstatsd protocol
+var (
+	dogStatsDNameReplacer = strings.NewReplacer(":", "_", "|", "_", "@", "_", "\n", "_")
+	dogStatsDTagReplacer  = strings.NewReplacer(",", "_", "|", "_", "\n", "_")
+)
+
+// DogStatsDClient is an APIClient that sends metrics, events, and service checks to a
+// local Datadog Agent using the dogstatsd protocol, instead of the Datadog api. No api
+// key is required.
+//
+// The Agent sets the host, and timestamp of metrics, so the host, and timestamps of
+// series are not sent. Rate metrics are sent as counts of the rate multiplied by the
+// interval, which the Agent reports as a rate.
+type DogStatsDClient struct {
+	conn        net.Conn
+	containerID string
+	lock        *sync.Mutex
+}
+
+// NewDogStatsDClient creates a client for the dogstatsd server at addr. Addresses are
+// host:port for UDP, or unix:///path/to/socket for a Unix domain datagram socket.
+func NewDogStatsDClient(addr string) (*DogStatsDClient, error) {
+
+	network := "udp"
+	if strings.HasPrefix(addr, "unix://") {
+		network = "unixgram"
+		addr = strings.TrimPrefix(addr, "unix://")
+	}
+
+	conn, err := net.Dial(network, addr)
+	if err != nil {
+		return nil, fmt.Errorf("could not connect to dogstatsd, %s", err.Error())
+	}
+
+	return &DogStatsDClient{
+		conn: conn,
+		lock: &sync.Mutex{},
+	}, nil
+}
+
+// SetContainerID sets the container id sent with each metric, in the dogstatsd origin
+// field, so the Agent can tag metrics with the container, and pod they came from. It
+// must be set before the client is used.
+func (c *DogStatsDClient) SetContainerID(id string) {
+	c.containerID = id
+}
+
+func (c *DogStatsDClient) SendSeries(series *DDMetricSeries) error {
+	return c.write(withDogStatsDOrigin(dogStatsDSeries(series), c.containerID))
+}
+
+func (c *DogStatsDClient) SendServiceCheck(check *DDServiceCheck) error {
+	return c.write([][]byte{dogStatsDServiceCheck(check)})
+}
+
+func (c *DogStatsDClient) SendEvent(event *DDEvent) error {
+	return c.write([][]byte{dogStatsDEvent(event)})
+}
+
+func (c *DogStatsDClient) SendDistributions(series *DDDistributionSeries) error {
+	return c.write(withDogStatsDOrigin(dogStatsDDistributions(series), c.containerID))
+}
+
+// SetHTTPClient is a no-op, the dogstatsd client does not use http.
+func (c *DogStatsDClient) SetHTTPClient(HTTPClient) {}
+
+// Close closes the connection to the dogstatsd server.
+func (c *DogStatsDClient) Close() error {
+	return c.conn.Close()
+}
+
+// dogStatsDSeries returns a dogstatsd line for each point in series.
+func dogStatsDSeries(series *DDMetricSeries) [][]byte {
+
+	var lines [][]byte
+	for _, m := range series.Series {
+		for _, point := range m.Points {
+			value, ok := toFloat(point[1])
+			if !ok {
+				continue
+			}
+
+			var metricType string
+			switch m.Type {
+			case Count:
+				metricType = "c"
+			case Rate:
+				metricType = "c"
+				if m.Interval > 0 {
+					value *= float64(m.Interval)
+				}
+			default:
+				metricType = "g"
+			}
+
+			line := &bytes.Buffer{}
+			line.WriteString(sanitizeDogStatsD(m.Metric))
+			line.WriteByte(':')
+			line.WriteString(strconv.FormatFloat(value, 'f', -1, 64))
+			line.WriteByte('|')
+			line.WriteString(metricType)
+			writeDogStatsDTags(line, m.Tags)
+			lines = append(lines, line.Bytes())
+		}
+	}
+
+	return lines
+}
+
+// withDogStatsDOrigin appends the container id field to each metric line.
+func withDogStatsDOrigin(lines [][]byte, containerID string) [][]byte {
+	if containerID == "" {
+		return lines
+	}
+	for i := range lines {
+		lines[i] = append(lines[i], "|c:"+containerID...)
+	}
+	return lines
+}
+
+// dogStatsDServiceCheck returns the dogstatsd line for check.
+func dogStatsDServiceCheck(check *DDServiceCheck) []byte {
+
+	line := &bytes.Buffer{}
+	fmt.Fprintf(line, "_sc|%s|%d", sanitizeDogStatsD(check.Check), check.Status)
+	if check.Timestamp > 0 {
+		fmt.Fprintf(line, "|d:%d", check.Timestamp)
+	}
+	if check.Hostname != "" {
+		fmt.Fprintf(line, "|h:%s", sanitizeDogStatsD(check.Hostname))
+	}
+	writeDogStatsDTags(line, check.Tags)
+	if check.Message != "" {
+		// The message must be the last field
+		fmt.Fprintf(line, "|m:%s", strings.Replace(check.Message, "\n", "\\n", -1))
+	}
+
+	return line.Bytes()
+}
+
+// dogStatsDEvent returns the dogstatsd line for event.
+func dogStatsDEvent(event *DDEvent) []byte {
+
+	title := strings.Replace(event.Title, "\n", "\\n", -1)
+	line := &bytes.Buffer{}
+	fmt.Fprintf(line, "_e{%d,%d}:%s|", len(title), 0, title)
+	if event.DateHappened > 0 {
+		fmt.Fprintf(line, "|d:%d", event.DateHappened)
+	}
+	if event.Host != "" {
+		fmt.Fprintf(line, "|h:%s", sanitizeDogStatsD(event.Host))
+	}
+	if event.AggregationKey != "" {
+		fmt.Fprintf(line, "|k:%s", sanitizeDogStatsD(event.AggregationKey))
+	}
+	if event.Priority != "" {
+		fmt.Fprintf(line, "|p:%s", event.Priority)
+	}
+	if event.SourceTypeName != "" {
+		fmt.Fprintf(line, "|s:%s", sanitizeDogStatsD(event.SourceTypeName))
+	}
+	if event.AlertType != "" {
+		fmt.Fprintf(line, "|t:%s", event.AlertType)
+	}
+	writeDogStatsDTags(line, event.Tags)
+
+	return line.Bytes()
+}
+
+// dogStatsDDistributions returns a dogstatsd line for each value in series.
+func dogStatsDDistributions(series *DDDistributionSeries) [][]byte {
+
+	var lines [][]byte
+	for _, d := range series.Series {
+		for _, point := range d.Points {
+			values, ok := point[1].([]float64)
+			if !ok {
+				continue
+			}
+			for _, value := range values {
+				line := &bytes.Buffer{}
+				line.WriteString(sanitizeDogStatsD(d.Metric))
+				line.WriteByte(':')
+				line.WriteString(strconv.FormatFloat(value, 'f', -1, 64))
+				line.WriteString("|d")
+				writeDogStatsDTags(line, d.Tags)
+				lines = append(lines, line.Bytes())
+			}
+		}
+	}
+
+	return lines
+}
+
+// write sends lines, packing as many lines as fit into each datagram.
+func (c *DogStatsDClient) write(lines [][]byte) error {
+
+	c.lock.Lock()
+	defer c.lock.Unlock()
+
+	var errs []string
+	packet := make([]byte, 0, dogStatsDMaxPacketSize)
+	flush := func() {
+		if len(packet) == 0 {
+			return
+		}
+		if _, err := c.conn.Write(packet); err != nil {
+			errs = append(errs, err.Error())
+		}
+		packet = packet[:0]
+	}
+
+	for _, line := range lines {
+		if len(packet) > 0 && len(packet)+1+len(line) > dogStatsDMaxPacketSize {
+			flush()
+		}
+		if len(packet) > 0 {
+			packet = append(packet, '\n')
+		}
+		packet = append(packet, line...)
+	}
+	flush()
+
+	if len(errs) > 0 {
+		return &NetworkError{Op: "could not write to dogstatsd", Err: errors.New(strings.Join(errs, ", "))}
+	}
+	return nil
+}
+
+func writeDogStatsDTags(line *bytes.Buffer, tags []string) {
+	if len(tags) == 0 {
+		return
+	}
+	line.WriteString("|#")
+	for i, tag := range tags {
+		if i > 0 {
+			line.WriteByte(',')
+		}
+		line.WriteString(dogStatsDTagReplacer.Replace(tag))
+	}
+}
+
+// sanitizeDogStatsD replaces characters that are part of the dogstatsd protocol.
+func sanitizeDogStatsD(s string) string {
+	return dogStatsDNameReplacer.Replace(s)
+}
+
+func toFloat(v interface{}) (float64, bool) {
+	switch n := v.(type) {
+	case float64:
+		return n, true
+	case float32:
+		return float64(n), true
+	case int:
+		return float64(n), true
+	case int64:
+		return float64(n), true
+	case int32:
+		return float64(n), true
+	case uint64:
+		return float64(n), true
+	}
+	return 0, false
+}
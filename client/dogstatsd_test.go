@@ -0,0 +1,108 @@
+package client
+
+import (
+	"net"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestDogStatsDClient(t *testing.T) {
+
+	server, err := net.ListenPacket("udp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatalf(err.Error())
+	}
+	defer server.Close()
+
+	c, err := NewDogStatsDClient(server.LocalAddr().String())
+	if err != nil {
+		t.Fatalf(err.Error())
+	}
+	defer c.Close()
+
+	read := func() string {
+		buf := make([]byte, dogStatsDMaxPacketSize)
+		_ = server.SetReadDeadline(time.Now().Add(time.Second))
+		n, _, err := server.ReadFrom(buf)
+		if err != nil {
+			t.Fatalf(err.Error())
+		}
+		return string(buf[:n])
+	}
+
+	t.Run("series", func(tt *testing.T) {
+		err := c.SendSeries(&DDMetricSeries{Series: []*DDMetric{
+			{Metric: "test.count", Points: [][2]interface{}{{int64(1), 2.0}}, Tags: []string{"a:1", "b:2"}, Type: Count},
+			{Metric: "test.rate", Interval: 10, Points: [][2]interface{}{{int64(1), 0.5}}, Type: Rate},
+			{Metric: "test.gauge", Points: [][2]interface{}{{int64(1), 1.5}}, Type: Gauge},
+		}})
+		if err != nil {
+			tt.Fatalf(err.Error())
+		}
+		expected := "test.count:2|c|#a:1,b:2\ntest.rate:5|c\ntest.gauge:1.5|g"
+		if packet := read(); packet != expected {
+			tt.Fatalf("expected packet %q, have %q", expected, packet)
+		}
+	})
+
+	t.Run("service check", func(tt *testing.T) {
+		err := c.SendServiceCheck(&DDServiceCheck{Check: "test.check", Status: Critical, Hostname: "host", Message: "down"})
+		if err != nil {
+			tt.Fatalf(err.Error())
+		}
+		expected := "_sc|test.check|2|h:host|m:down"
+		if packet := read(); packet != expected {
+			tt.Fatalf("expected packet %q, have %q", expected, packet)
+		}
+	})
+
+	t.Run("event", func(tt *testing.T) {
+		err := c.SendEvent(&DDEvent{Title: "deploy", AlertType: AlertInfo, Tags: []string{"env:prod"}})
+		if err != nil {
+			tt.Fatalf(err.Error())
+		}
+		expected := "_e{6,0}:deploy||t:info|#env:prod"
+		if packet := read(); packet != expected {
+			tt.Fatalf("expected packet %q, have %q", expected, packet)
+		}
+	})
+
+	t.Run("container id", func(tt *testing.T) {
+		c.SetContainerID("abc123")
+		defer c.SetContainerID("")
+		err := c.SendSeries(&DDMetricSeries{Series: []*DDMetric{
+			{Metric: "test.count", Points: [][2]interface{}{{int64(1), 2.0}}, Tags: []string{"a:1"}, Type: Count},
+			{Metric: "test.gauge", Points: [][2]interface{}{{int64(1), 1.5}}, Type: Gauge},
+		}})
+		if err != nil {
+			tt.Fatalf(err.Error())
+		}
+		expected := "test.count:2|c|#a:1|c:abc123\ntest.gauge:1.5|g|c:abc123"
+		if packet := read(); packet != expected {
+			tt.Fatalf("expected packet %q, have %q", expected, packet)
+		}
+	})
+
+	t.Run("packet size", func(tt *testing.T) {
+		series := &DDMetricSeries{}
+		for i := 0; i < 100; i++ {
+			series.Series = append(series.Series, &DDMetric{
+				Metric: "test.packet.size.metric.name",
+				Points: [][2]interface{}{{int64(1), 1.0}},
+				Type:   Gauge,
+			})
+		}
+		if err := c.SendSeries(series); err != nil {
+			tt.Fatalf(err.Error())
+		}
+		lines := 0
+		for lines < 100 {
+			packet := read()
+			if len(packet) > dogStatsDMaxPacketSize {
+				tt.Fatalf("expected packets of at most %d bytes, have %d", dogStatsDMaxPacketSize, len(packet))
+			}
+			lines += len(strings.Split(packet, "\n"))
+		}
+	})
+}
@@ -0,0 +1,164 @@
+package client
+
+import (
+	"errors"
+	"fmt"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// MaxErrorBodySize is the max number of bytes of a response body kept in an APIError.
+const MaxErrorBodySize = 1024
+
+// APIError is returned when the api responds with a status other than 2xx. Errors holds
+// the error messages from the response body, if it could be decoded, and Body the raw
+// response body, truncated to MaxErrorBodySize bytes.
+type APIError struct {
+	StatusCode int
+	Errors     []string
+	Body       string
+}
+
+func (e *APIError) Error() string {
+	if len(e.Errors) == 0 {
+		return fmt.Sprintf("api response %d", e.StatusCode)
+	}
+	return fmt.Sprintf("api response %d: %s", e.StatusCode, strings.Join(e.Errors, ", "))
+}
+
+func (e *APIError) mask(key string) {
+	for i, msg := range e.Errors {
+		e.Errors[i] = strings.ReplaceAll(msg, key, maskedAPIKey)
+	}
+	e.Body = strings.ReplaceAll(e.Body, key, maskedAPIKey)
+}
+
+// RateLimitError is returned when the api responds with 429, too many requests. RetryAfter
+// is the time to wait before sending again, from the Retry-After, or X-RateLimit-Reset
+// response headers, or zero if neither was set. RateLimitError wraps the *APIError of the
+// response, so it can be found with errors.As.
+type RateLimitError struct {
+	APIError
+	RetryAfter time.Duration
+}
+
+func (e *RateLimitError) Error() string {
+	if e.RetryAfter <= 0 {
+		return e.APIError.Error()
+	}
+	return fmt.Sprintf("%s, retry after %s", e.APIError.Error(), e.RetryAfter)
+}
+
+// Unwrap returns the api error of the response.
+func (e *RateLimitError) Unwrap() error {
+	return &e.APIError
+}
+
+// NetworkError is returned when a request could not be sent, or the response could not
+// be read, such as when the connection is refused, dns resolution fails, or the request
+// times out. The api may, or may not have received the request.
+type NetworkError struct {
+	Op  string // What was being done when the error occurred, may be empty
+	Err error
+}
+
+func (e *NetworkError) Error() string {
+	if e.Op == "" {
+		return e.Err.Error()
+	}
+	return fmt.Sprintf("%s, %s", e.Op, e.Err.Error())
+}
+
+// Unwrap returns the underlying error.
+func (e *NetworkError) Unwrap() error {
+	return e.Err
+}
+
+// Timeout returns true if the underlying error was a timeout.
+func (e *NetworkError) Timeout() bool {
+	var timeout interface{ Timeout() bool }
+	return errors.As(e.Err, &timeout) && timeout.Timeout()
+}
+
+// networkError returns err wrapped in a *NetworkError, or nil if err is nil.
+func networkError(op string, err error) error {
+	if err == nil {
+		return nil
+	}
+	return &NetworkError{Op: op, Err: err}
+}
+
+// newAPIError returns the error for a response with a status other than 2xx, with the
+// decoded error messages, and body. A 429 response returns a *RateLimitError.
+func newAPIError(response *http.Response, messages []string, body []byte) error {
+
+	if len(body) > MaxErrorBodySize {
+		body = body[:MaxErrorBodySize]
+	}
+	apiErr := APIError{StatusCode: response.StatusCode, Errors: messages, Body: string(body)}
+	if response.StatusCode == http.StatusTooManyRequests {
+		return &RateLimitError{APIError: apiErr, RetryAfter: retryAfter(response.Header, time.Now())}
+	}
+	return &apiErr
+}
+
+// retryAfter returns the wait from the Retry-After header, either seconds, or an http
+// date, or the Datadog X-RateLimit-Reset header, in seconds.
+func retryAfter(header http.Header, now time.Time) time.Duration {
+
+	if val := strings.TrimSpace(header.Get("Retry-After")); val != "" {
+		if seconds, err := strconv.Atoi(val); err == nil && seconds > 0 {
+			return time.Duration(seconds) * time.Second
+		}
+		if date, err := http.ParseTime(val); err == nil && date.After(now) {
+			return date.Sub(now)
+		}
+	}
+	if val := strings.TrimSpace(header.Get("X-RateLimit-Reset")); val != "" {
+		if seconds, err := strconv.Atoi(val); err == nil && seconds > 0 {
+			return time.Duration(seconds) * time.Second
+		}
+	}
+	return 0
+}
+
+// maskedError is an error with the api key removed from the message. The original error
+// isn't wrapped, as its message contains the key, but whether it was a timeout is kept.
+type maskedError struct {
+	msg     string
+	timeout bool
+}
+
+func (e *maskedError) Error() string {
+	return e.msg
+}
+
+// Timeout returns true if the masked error was a timeout.
+func (e *maskedError) Timeout() bool {
+	return e.timeout
+}
+
+func maskAPIKey(err error, key string) error {
+
+	if err == nil || key == "" {
+		return err
+	}
+
+	switch e := err.(type) {
+	case *APIError:
+		e.mask(key)
+		return e
+	case *RateLimitError:
+		e.mask(key)
+		return e
+	case *NetworkError:
+		e.Err = maskAPIKey(e.Err, key)
+		return e
+	case interface{ Timeout() bool }:
+		return &maskedError{msg: strings.ReplaceAll(err.Error(), key, maskedAPIKey), timeout: e.Timeout()}
+	}
+
+	return fmt.Errorf(strings.ReplaceAll(err.Error(), key, maskedAPIKey))
+}
@@ -0,0 +1,133 @@
+package client
+
+import (
+	"fmt"
+	"io"
+	"strings"
+	"sync"
+)
+
+// MultiError is returned by MultiClient when any of its clients fail. Errors has the
+// error of each client, in the order the clients were given, nil for the clients that
+// succeeded.
+type MultiError struct {
+	Errors []error
+}
+
+func (e *MultiError) Error() string {
+	var msgs []string
+	failed := 0
+	for i, err := range e.Errors {
+		if err != nil {
+			failed++
+			msgs = append(msgs, fmt.Sprintf("client %d: %s", i, err.Error()))
+		}
+	}
+	return fmt.Sprintf("%d of %d clients failed, %s", failed, len(e.Errors), strings.Join(msgs, "; "))
+}
+
+// Unwrap returns the error of the first failed client.
+func (e *MultiError) Unwrap() error {
+	for _, err := range e.Errors {
+		if err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// MultiClient is an APIClient that sends every series, service check, and event to
+// multiple clients, such as Datadog, and a local log sink, for migrations between
+// backends, and redundancy. Clients are called concurrently, with the same payload, so
+// they must not modify it.
+type MultiClient struct {
+	clients []APIClient
+}
+
+// NewMultiClient creates a client that sends to each of clients.
+func NewMultiClient(clients ...APIClient) *MultiClient {
+	return &MultiClient{clients: clients}
+}
+
+func (c *MultiClient) SendSeries(series *DDMetricSeries) error {
+	return c.each(func(client APIClient) error {
+		return client.SendSeries(series)
+	})
+}
+
+func (c *MultiClient) SendServiceCheck(check *DDServiceCheck) error {
+	return c.each(func(client APIClient) error {
+		return client.SendServiceCheck(check)
+	})
+}
+
+func (c *MultiClient) SendEvent(event *DDEvent) error {
+	return c.each(func(client APIClient) error {
+		return client.SendEvent(event)
+	})
+}
+
+// SendDistributions sends series to the clients that implement DistributionClient. The
+// other clients are skipped.
+func (c *MultiClient) SendDistributions(series *DDDistributionSeries) error {
+	supported := false
+	for _, client := range c.clients {
+		if _, ok := client.(DistributionClient); ok {
+			supported = true
+		}
+	}
+	if !supported {
+		return fmt.Errorf("no client supports distributions")
+	}
+	return c.each(func(client APIClient) error {
+		if distributionClient, ok := client.(DistributionClient); ok {
+			return distributionClient.SendDistributions(series)
+		}
+		return nil
+	})
+}
+
+// SetHTTPClient sets the http client of every client.
+func (c *MultiClient) SetHTTPClient(httpClient HTTPClient) {
+	for _, client := range c.clients {
+		client.SetHTTPClient(httpClient)
+	}
+}
+
+// Close closes the clients that implement io.Closer.
+func (c *MultiClient) Close() error {
+	errs := make([]error, len(c.clients))
+	failed := false
+	for i, client := range c.clients {
+		if closer, ok := client.(io.Closer); ok {
+			errs[i] = closer.Close()
+			failed = failed || errs[i] != nil
+		}
+	}
+	if failed {
+		return &MultiError{Errors: errs}
+	}
+	return nil
+}
+
+// each calls fn for every client concurrently, and returns a *MultiError if any failed.
+func (c *MultiClient) each(fn func(client APIClient) error) error {
+
+	errs := make([]error, len(c.clients))
+	wg := &sync.WaitGroup{}
+	for i, client := range c.clients {
+		wg.Add(1)
+		go func(i int, client APIClient) {
+			defer wg.Done()
+			errs[i] = fn(client)
+		}(i, client)
+	}
+	wg.Wait()
+
+	for _, err := range errs {
+		if err != nil {
+			return &MultiError{Errors: errs}
+		}
+	}
+	return nil
+}
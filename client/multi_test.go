@@ -0,0 +1,83 @@
+package client
+
+import (
+	"errors"
+	"strings"
+	"sync"
+	"testing"
+)
+
+type multiTestClient struct {
+	err    error
+	calls  int
+	closed bool
+	lock   sync.Mutex
+}
+
+func (c *multiTestClient) call() error {
+	c.lock.Lock()
+	defer c.lock.Unlock()
+	c.calls++
+	return c.err
+}
+
+func (c *multiTestClient) SendSeries(*DDMetricSeries) error       { return c.call() }
+func (c *multiTestClient) SendServiceCheck(*DDServiceCheck) error { return c.call() }
+func (c *multiTestClient) SendEvent(*DDEvent) error               { return c.call() }
+func (c *multiTestClient) SetHTTPClient(HTTPClient)               {}
+func (c *multiTestClient) Close() error {
+	c.closed = true
+	return nil
+}
+
+type multiTestDistributionClient struct {
+	multiTestClient
+}
+
+func (c *multiTestDistributionClient) SendDistributions(*DDDistributionSeries) error {
+	return c.call()
+}
+
+func TestMultiClient(t *testing.T) {
+
+	ok := &multiTestClient{}
+	failing := &multiTestClient{err: errors.New("unavailable")}
+	c := NewMultiClient(ok, failing)
+
+	if err := c.SendSeries(&DDMetricSeries{}); err == nil {
+		t.Fatalf("expected an error, when a client fails")
+	} else if multiErr, isMulti := err.(*MultiError); !isMulti {
+		t.Fatalf("expected a *MultiError, have %T", err)
+	} else if multiErr.Errors[0] != nil || multiErr.Errors[1] != failing.err {
+		t.Fatalf("expected only the second client to fail, have %v", multiErr.Errors)
+	} else if !errors.Is(err, failing.err) || !strings.HasPrefix(err.Error(), "1 of 2 clients failed") {
+		t.Fatalf("expected the error to wrap the client error, have %s", err.Error())
+	}
+
+	failing.err = nil
+	if err := c.SendServiceCheck(&DDServiceCheck{}); err != nil {
+		t.Fatalf("expected no error, have %s", err.Error())
+	}
+	if err := c.SendEvent(&DDEvent{}); err != nil {
+		t.Fatalf("expected no error, have %s", err.Error())
+	}
+	if ok.calls != 3 || failing.calls != 3 {
+		t.Fatalf("expected every client to be called 3 times, have %d, and %d", ok.calls, failing.calls)
+	}
+
+	if err := c.SendDistributions(&DDDistributionSeries{}); err == nil {
+		t.Fatalf("expected an error, when no client supports distributions")
+	}
+	distributions := &multiTestDistributionClient{}
+	c = NewMultiClient(ok, distributions)
+	if err := c.SendDistributions(&DDDistributionSeries{}); err != nil {
+		t.Fatalf("expected no error, have %s", err.Error())
+	}
+	if ok.calls != 3 || distributions.calls != 1 {
+		t.Fatalf("expected only the distribution client to be called, have %d, and %d", ok.calls, distributions.calls)
+	}
+
+	if err := c.Close(); err != nil || !ok.closed || !distributions.closed {
+		t.Fatalf("expected every client to be closed, have %v", err)
+	}
+}
@@ -0,0 +1,359 @@
+package client
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"net/http"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// DefaultOTLPEndpoint is the default OTLP/HTTP metrics endpoint of an OpenTelemetry
+// collector.
+const DefaultOTLPEndpoint = "http://localhost:4318/v1/metrics"
+
+// otlpScope is the instrumentation scope name of exported metrics.
+const otlpScope = "github.com/jmizell/ddstats"
+
+// OTLP aggregation temporality values
+const (
+	otlpTemporalityDelta = 1
+)
+
+// OTLPClient is an APIClient that exports metrics to an OpenTelemetry collector, using
+// OTLP/HTTP with the JSON encoding, instead of the Datadog api. No api key is required.
+//
+// Gauges are exported as OTLP gauges. Counts, and rates are exported as non monotonic
+// delta sums, rates are multiplied by the interval, the same as the dogstatsd client.
+// Distributions are exported as delta histograms without buckets. Tags are exported as
+// attributes, and the host as the host.name resource attribute. Service checks are
+// exported as a gauge of the check status. OTLP has no equivalent of events, SendEvent
+// returns an error.
+type OTLPClient struct {
+	endpoint string
+	headers  map[string]string
+	client   HTTPClient
+}
+
+// NewOTLPClient creates a client for the OTLP/HTTP metrics endpoint, or
+// DefaultOTLPEndpoint if endpoint is empty. Headers are added to every request, such as
+// the authentication header of a hosted collector. Proxy settings are loaded from the
+// environment, see ProxyConfigFromEnvironment.
+func NewOTLPClient(endpoint string, headers map[string]string) *OTLPClient {
+	if endpoint == "" {
+		endpoint = DefaultOTLPEndpoint
+	}
+	httpClient, _ := NewHTTPClient(TransportConfig{Timeout: time.Second * 30}, ProxyConfigFromEnvironment())
+	return &OTLPClient{
+		endpoint: endpoint,
+		headers:  headers,
+		client:   httpClient,
+	}
+}
+
+func (c *OTLPClient) SendSeries(series *DDMetricSeries) error {
+
+	metrics := map[string][]*otlpMetric{}
+	for _, m := range series.Series {
+		metrics[m.Host] = append(metrics[m.Host], otlpSeriesMetric(m))
+	}
+	return c.export(metrics)
+}
+
+func (c *OTLPClient) SendServiceCheck(check *DDServiceCheck) error {
+
+	point := &otlpNumberDataPoint{
+		Attributes:   otlpAttributes(check.Tags),
+		TimeUnixNano: otlpTime(check.Timestamp),
+		AsDouble:     float64(check.Status),
+	}
+	if check.Message != "" {
+		point.Attributes = append(point.Attributes, otlpAttribute("message", check.Message))
+	}
+	return c.export(map[string][]*otlpMetric{
+		check.Hostname: {{
+			Name:  check.Check,
+			Gauge: &otlpGauge{DataPoints: []*otlpNumberDataPoint{point}},
+		}},
+	})
+}
+
+// SendEvent returns an error, OTLP metrics have no equivalent of events.
+func (c *OTLPClient) SendEvent(*DDEvent) error {
+	return fmt.Errorf("could not send event, events are not supported by the otlp client")
+}
+
+func (c *OTLPClient) SendDistributions(series *DDDistributionSeries) error {
+
+	metrics := map[string][]*otlpMetric{}
+	for _, d := range series.Series {
+		histogram := &otlpHistogram{AggregationTemporality: otlpTemporalityDelta}
+		for _, point := range d.Points {
+			values, ok := point[1].([]float64)
+			if !ok || len(values) == 0 {
+				continue
+			}
+			ts, _ := toFloat(point[0])
+			histogram.DataPoints = append(histogram.DataPoints, otlpHistogramPoint(d.Tags, int64(ts), values))
+		}
+		metrics[d.Host] = append(metrics[d.Host], &otlpMetric{Name: d.Metric, Histogram: histogram})
+	}
+	return c.export(metrics)
+}
+
+// SetHTTPClient sets the http client used to send requests. Headers can only be sent by
+// clients that implement HTTPDoer.
+func (c *OTLPClient) SetHTTPClient(client HTTPClient) {
+	c.client = client
+}
+
+// export posts the metrics, grouped by host, to the collector.
+func (c *OTLPClient) export(metrics map[string][]*otlpMetric) error {
+
+	hosts := make([]string, 0, len(metrics))
+	for host := range metrics {
+		hosts = append(hosts, host)
+	}
+	sort.Strings(hosts)
+
+	request := &otlpExportRequest{}
+	for _, host := range hosts {
+		resource := &otlpResourceMetrics{
+			ScopeMetrics: []*otlpScopeMetrics{{
+				Scope:   otlpInstrumentationScope{Name: otlpScope},
+				Metrics: metrics[host],
+			}},
+		}
+		if host != "" {
+			resource.Resource.Attributes = []*otlpKeyValue{otlpAttribute("host.name", host)}
+		}
+		request.ResourceMetrics = append(request.ResourceMetrics, resource)
+	}
+
+	data, err := json.Marshal(request)
+	if err != nil {
+		return fmt.Errorf("could not marshal data to json, %s", err.Error())
+	}
+
+	var response *http.Response
+	if len(c.headers) == 0 {
+		response, err = c.client.Post(c.endpoint, encodingJSON, bytes.NewReader(data))
+		err = networkError("", err)
+	} else {
+		response, err = c.sendWithHeaders(data)
+	}
+	if err != nil {
+		return err
+	}
+	defer func() { _ = response.Body.Close() }()
+
+	body, err := ioutil.ReadAll(response.Body)
+	if err != nil {
+		return networkError("could not read otlp response", err)
+	}
+	if response.StatusCode > 299 {
+		var messages []string
+		if msg := strings.TrimSpace(string(body)); msg != "" {
+			messages = []string{msg}
+		}
+		return newAPIError(response, messages, body)
+	}
+
+	return nil
+}
+
+func (c *OTLPClient) sendWithHeaders(data []byte) (*http.Response, error) {
+
+	doer, ok := c.client.(HTTPDoer)
+	if !ok {
+		return nil, fmt.Errorf("could not send otlp headers, http client does not implement Do")
+	}
+	req, err := http.NewRequest(http.MethodPost, c.endpoint, bytes.NewReader(data))
+	if err != nil {
+		return nil, fmt.Errorf("could not create request, %s", err.Error())
+	}
+	req.Header.Set("Content-Type", encodingJSON)
+	for key, value := range c.headers {
+		req.Header.Set(key, value)
+	}
+	response, err := doer.Do(req)
+	return response, networkError("", err)
+}
+
+// otlpSeriesMetric converts m to an OTLP gauge, or sum.
+func otlpSeriesMetric(m *DDMetric) *otlpMetric {
+
+	attributes := otlpAttributes(m.Tags)
+	points := make([]*otlpNumberDataPoint, 0, len(m.Points))
+	for _, point := range m.Points {
+		value, ok := toFloat(point[1])
+		if !ok {
+			continue
+		}
+		ts, _ := toFloat(point[0])
+		p := &otlpNumberDataPoint{
+			Attributes:   attributes,
+			TimeUnixNano: otlpTime(int64(ts)),
+			AsDouble:     value,
+		}
+		if m.Type == Count || m.Type == Rate {
+			if m.Type == Rate && m.Interval > 0 {
+				p.AsDouble *= float64(m.Interval)
+			}
+			if m.Interval > 0 {
+				p.StartTimeUnixNano = otlpTime(int64(ts) - m.Interval)
+			}
+		}
+		points = append(points, p)
+	}
+
+	if m.Type == Count || m.Type == Rate {
+		return &otlpMetric{
+			Name: m.Metric,
+			Sum:  &otlpSum{DataPoints: points, AggregationTemporality: otlpTemporalityDelta},
+		}
+	}
+	return &otlpMetric{Name: m.Metric, Gauge: &otlpGauge{DataPoints: points}}
+}
+
+// otlpHistogramPoint returns a histogram data point of values, with a single bucket.
+func otlpHistogramPoint(tags []string, ts int64, values []float64) *otlpHistogramDataPoint {
+
+	point := &otlpHistogramDataPoint{
+		Attributes:   otlpAttributes(tags),
+		TimeUnixNano: otlpTime(ts),
+		Count:        strconv.Itoa(len(values)),
+		BucketCounts: []string{strconv.Itoa(len(values))},
+		Min:          values[0],
+		Max:          values[0],
+	}
+	for _, v := range values {
+		point.Sum += v
+		if v < point.Min {
+			point.Min = v
+		}
+		if v > point.Max {
+			point.Max = v
+		}
+	}
+	return point
+}
+
+// otlpAttributes converts tags to attributes. Tags without a value are set to true, and
+// multiple values of the same key are joined with a comma.
+func otlpAttributes(tags []string) []*otlpKeyValue {
+
+	if len(tags) == 0 {
+		return nil
+	}
+
+	values := map[string][]string{}
+	for _, tag := range tags {
+		key, value := tag, "true"
+		if i := strings.Index(tag, ":"); i >= 0 {
+			key, value = tag[:i], tag[i+1:]
+		}
+		values[key] = append(values[key], value)
+	}
+
+	keys := make([]string, 0, len(values))
+	for key := range values {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+
+	attributes := make([]*otlpKeyValue, 0, len(keys))
+	for _, key := range keys {
+		attributes = append(attributes, otlpAttribute(key, strings.Join(values[key], ",")))
+	}
+	return attributes
+}
+
+func otlpAttribute(key, value string) *otlpKeyValue {
+	return &otlpKeyValue{Key: key, Value: otlpAnyValue{StringValue: value}}
+}
+
+// otlpTime returns the unix time ts in nanoseconds, as a string, or the current time if
+// ts is zero.
+func otlpTime(ts int64) string {
+	if ts <= 0 {
+		return strconv.FormatInt(time.Now().UnixNano(), 10)
+	}
+	return strconv.FormatInt(ts*int64(time.Second), 10)
+}
+
+// The OTLP JSON encoding of an export metrics request. 64 bit integers are encoded as
+// strings.
+type otlpExportRequest struct {
+	ResourceMetrics []*otlpResourceMetrics `json:"resourceMetrics"`
+}
+
+type otlpResourceMetrics struct {
+	Resource     otlpResource        `json:"resource"`
+	ScopeMetrics []*otlpScopeMetrics `json:"scopeMetrics"`
+}
+
+type otlpResource struct {
+	Attributes []*otlpKeyValue `json:"attributes,omitempty"`
+}
+
+type otlpScopeMetrics struct {
+	Scope   otlpInstrumentationScope `json:"scope"`
+	Metrics []*otlpMetric            `json:"metrics"`
+}
+
+type otlpInstrumentationScope struct {
+	Name string `json:"name"`
+}
+
+type otlpMetric struct {
+	Name      string         `json:"name"`
+	Gauge     *otlpGauge     `json:"gauge,omitempty"`
+	Sum       *otlpSum       `json:"sum,omitempty"`
+	Histogram *otlpHistogram `json:"histogram,omitempty"`
+}
+
+type otlpGauge struct {
+	DataPoints []*otlpNumberDataPoint `json:"dataPoints"`
+}
+
+type otlpSum struct {
+	DataPoints             []*otlpNumberDataPoint `json:"dataPoints"`
+	AggregationTemporality int                    `json:"aggregationTemporality"`
+	IsMonotonic            bool                   `json:"isMonotonic"`
+}
+
+type otlpHistogram struct {
+	DataPoints             []*otlpHistogramDataPoint `json:"dataPoints"`
+	AggregationTemporality int                       `json:"aggregationTemporality"`
+}
+
+type otlpNumberDataPoint struct {
+	Attributes        []*otlpKeyValue `json:"attributes,omitempty"`
+	StartTimeUnixNano string          `json:"startTimeUnixNano,omitempty"`
+	TimeUnixNano      string          `json:"timeUnixNano"`
+	AsDouble          float64         `json:"asDouble"`
+}
+
+type otlpHistogramDataPoint struct {
+	Attributes   []*otlpKeyValue `json:"attributes,omitempty"`
+	TimeUnixNano string          `json:"timeUnixNano"`
+	Count        string          `json:"count"`
+	Sum          float64         `json:"sum"`
+	BucketCounts []string        `json:"bucketCounts"`
+	Min          float64         `json:"min"`
+	Max          float64         `json:"max"`
+}
+
+type otlpKeyValue struct {
+	Key   string       `json:"key"`
+	Value otlpAnyValue `json:"value"`
+}
+
+type otlpAnyValue struct {
+	StringValue string `json:"stringValue"`
+}
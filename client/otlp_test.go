@@ -0,0 +1,104 @@
+package client
+
+import (
+	"encoding/json"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+)
+
+func newOTLPTestServer(t *testing.T, status int, requests *[]map[string]interface{}, headers *http.Header) *httptest.Server {
+	return httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		body, _ := ioutil.ReadAll(r.Body)
+		request := map[string]interface{}{}
+		if err := json.Unmarshal(body, &request); err != nil {
+			t.Errorf("could not decode request, %s", err.Error())
+		}
+		*requests = append(*requests, request)
+		*headers = r.Header
+		w.WriteHeader(status)
+	}))
+}
+
+func TestOTLPClient_SendSeries(t *testing.T) {
+
+	var requests []map[string]interface{}
+	var headers http.Header
+	server := newOTLPTestServer(t, http.StatusOK, &requests, &headers)
+	defer server.Close()
+
+	c := NewOTLPClient(server.URL, map[string]string{"Authorization": "Bearer token"})
+	c.SetHTTPClient(server.Client())
+	err := c.SendSeries(&DDMetricSeries{Series: []*DDMetric{
+		{Host: "host1", Metric: "test.gauge", Type: Gauge, Points: [][2]interface{}{{int64(100), 1.5}}, Tags: []string{"env:test", "flag"}},
+		{Host: "host1", Metric: "test.rate", Type: Rate, Interval: 10, Points: [][2]interface{}{{int64(100), 2.0}}},
+		{Host: "host2", Metric: "test.count", Type: Count, Interval: 10, Points: [][2]interface{}{{int64(100), 3.0}}},
+	}})
+	if err != nil {
+		t.Fatalf(err.Error())
+	}
+	if headers.Get("Authorization") != "Bearer token" {
+		t.Fatalf("expected the authorization header to be sent, have %v", headers)
+	}
+	if len(requests) != 1 {
+		t.Fatalf("expected %d request, have %d", 1, len(requests))
+	}
+
+	data, _ := json.Marshal(requests[0])
+	decoded := &otlpExportRequest{}
+	if err := json.Unmarshal(data, decoded); err != nil {
+		t.Fatalf(err.Error())
+	}
+	if len(decoded.ResourceMetrics) != 2 {
+		t.Fatalf("expected a resource per host, have %d", len(decoded.ResourceMetrics))
+	}
+	host1 := decoded.ResourceMetrics[0]
+	if host1.Resource.Attributes[0].Key != "host.name" || host1.Resource.Attributes[0].Value.StringValue != "host1" {
+		t.Fatalf("expected the host.name attribute to be host1, have %v", host1.Resource.Attributes[0])
+	}
+
+	metrics := host1.ScopeMetrics[0].Metrics
+	gauge := metrics[0].Gauge.DataPoints[0]
+	if gauge.AsDouble != 1.5 || gauge.TimeUnixNano != "100000000000" {
+		t.Fatalf("expected gauge point 1.5 at 100000000000, have %f at %s", gauge.AsDouble, gauge.TimeUnixNano)
+	}
+	if len(gauge.Attributes) != 2 || gauge.Attributes[0].Key != "env" || gauge.Attributes[1].Value.StringValue != "true" {
+		t.Fatalf("expected attributes env:test, and flag:true, have %v", gauge.Attributes)
+	}
+
+	rate := metrics[1].Sum
+	if rate == nil || rate.AggregationTemporality != otlpTemporalityDelta {
+		t.Fatalf("expected the rate to be a delta sum, have %v", metrics[1])
+	}
+	if rate.DataPoints[0].AsDouble != 20 || rate.DataPoints[0].StartTimeUnixNano != "90000000000" {
+		t.Fatalf("expected rate point 20 starting at 90000000000, have %f starting at %s",
+			rate.DataPoints[0].AsDouble, rate.DataPoints[0].StartTimeUnixNano)
+	}
+}
+
+func TestOTLPClient_errors(t *testing.T) {
+
+	var requests []map[string]interface{}
+	var headers http.Header
+	server := newOTLPTestServer(t, http.StatusBadRequest, &requests, &headers)
+	defer server.Close()
+
+	c := NewOTLPClient(server.URL, nil)
+	err := c.SendServiceCheck(&DDServiceCheck{Check: "check", Status: Critical})
+	if apiErr, ok := err.(*APIError); !ok || apiErr.StatusCode != http.StatusBadRequest {
+		t.Fatalf("expected an api error of %d, have %v", http.StatusBadRequest, err)
+	}
+	if err := c.SendEvent(&DDEvent{Title: "event"}); err == nil {
+		t.Fatalf("expected events to be unsupported")
+	}
+}
+
+func TestOTLPHistogramPoint(t *testing.T) {
+
+	point := otlpHistogramPoint([]string{"a:1"}, 100, []float64{3, 1, 2})
+	if point.Count != "3" || point.Sum != 6 || point.Min != 1 || point.Max != 3 {
+		t.Fatalf("expected count 3, sum 6, min 1, and max 3, have %s, %f, %f, and %f",
+			point.Count, point.Sum, point.Min, point.Max)
+	}
+}
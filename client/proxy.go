@@ -0,0 +1,165 @@
+package client
+
+import (
+	"fmt"
+	"net"
+	"net/http"
+	"net/url"
+	"os"
+	"strings"
+)
+
+// Proxy environment variables. The Datadog specific variables take precedence over
+// the standard variables, and upper case standard variables take precedence over the
+// lower case variants.
+const (
+	EnvDDProxyHTTPS   = "DD_PROXY_HTTPS"
+	EnvDDProxyNoProxy = "DD_PROXY_NO_PROXY"
+	EnvHTTPSProxy     = "HTTPS_PROXY"
+	EnvNoProxy        = "NO_PROXY"
+)
+
+// ProxyConfig holds the proxy settings used by DDClient. All Datadog api endpoints
+// use https, so only the https proxy is considered.
+type ProxyConfig struct {
+	HTTPSProxy string   // Proxy url, if empty no proxy is used
+	NoProxy    []string // Hosts, domains, and CIDR ranges that bypass the proxy
+	Source     string   // Environment variable the proxy was loaded from
+}
+
+// ProxyConfigFromEnvironment loads the proxy settings from the environment.
+//
+// Precedence
+//
+// The proxy is read from DD_PROXY_HTTPS, then HTTPS_PROXY, then https_proxy. The bypass
+// list is read from DD_PROXY_NO_PROXY, then NO_PROXY, then no_proxy. The first variable
+// that is set, and not empty is used. DD_PROXY_NO_PROXY is space separated, as it is
+// for the Datadog Agent, the standard variables are comma separated.
+func ProxyConfigFromEnvironment() *ProxyConfig {
+
+	p := &ProxyConfig{}
+	for _, key := range []string{EnvDDProxyHTTPS, EnvHTTPSProxy, strings.ToLower(EnvHTTPSProxy)} {
+		if val := os.Getenv(key); val != "" {
+			p.HTTPSProxy = val
+			p.Source = key
+			break
+		}
+	}
+
+	if val := os.Getenv(EnvDDProxyNoProxy); val != "" {
+		p.NoProxy = strings.Fields(val)
+	} else if val := getEnvAny(EnvNoProxy, strings.ToLower(EnvNoProxy)); val != "" {
+		p.NoProxy = strings.Split(val, ",")
+	}
+
+	return p
+}
+
+// ProxyURL returns the proxy url to use for a request to reqURL, or nil if the
+// request should be sent directly.
+func (p *ProxyConfig) ProxyURL(reqURL *url.URL) (*url.URL, error) {
+
+	if p == nil || p.HTTPSProxy == "" || p.bypass(reqURL) {
+		return nil, nil
+	}
+
+	proxyURL, err := url.Parse(p.HTTPSProxy)
+	if err != nil || proxyURL.Scheme == "" || proxyURL.Host == "" {
+		// Mirror net/http, and allow a proxy to be set without a scheme
+		if proxyURL, err = url.Parse("http://" + p.HTTPSProxy); err != nil {
+			return nil, fmt.Errorf("invalid proxy address %q, %s", p.HTTPSProxy, err.Error())
+		}
+	}
+
+	return proxyURL, nil
+}
+
+// Proxy is a proxy function suitable for http.Transport.
+func (p *ProxyConfig) Proxy(req *http.Request) (*url.URL, error) {
+	return p.ProxyURL(req.URL)
+}
+
+// Describe returns a human readable description of the proxy decision for reqURL,
+// this is intended for troubleshooting connectivity.
+func (p *ProxyConfig) Describe(reqURL *url.URL) string {
+
+	proxyURL, err := p.ProxyURL(reqURL)
+	switch {
+	case err != nil:
+		return fmt.Sprintf("error: %s", err.Error())
+	case proxyURL != nil:
+		proxyURL.User = nil
+		return fmt.Sprintf("proxy %s (from %s)", proxyURL.String(), p.Source)
+	case p != nil && p.HTTPSProxy != "":
+		return fmt.Sprintf("direct (%s matched no proxy list)", reqURL.Hostname())
+	default:
+		return "direct"
+	}
+}
+
+func (p *ProxyConfig) bypass(reqURL *url.URL) bool {
+
+	host := strings.ToLower(reqURL.Hostname())
+	port := reqURL.Port()
+	if port == "" && reqURL.Scheme == "https" {
+		port = "443"
+	} else if port == "" {
+		port = "80"
+	}
+	if host == "localhost" {
+		return true
+	}
+	ip := net.ParseIP(host)
+	if ip != nil && ip.IsLoopback() {
+		return true
+	}
+
+	for _, entry := range p.NoProxy {
+		entry = strings.ToLower(strings.TrimSpace(entry))
+		if entry == "" {
+			continue
+		}
+		if entry == "*" {
+			return true
+		}
+
+		// CIDR ranges
+		if _, ipNet, err := net.ParseCIDR(entry); err == nil {
+			if ip != nil && ipNet.Contains(ip) {
+				return true
+			}
+			continue
+		}
+
+		// Entries may restrict the match to a port
+		if entryHost, entryPort, err := net.SplitHostPort(entry); err == nil {
+			if entryPort != port {
+				continue
+			}
+			entry = entryHost
+		}
+		if entryIP := net.ParseIP(entry); entryIP != nil {
+			if ip != nil && entryIP.Equal(ip) {
+				return true
+			}
+			continue
+		}
+
+		// Domains match themselves, and all sub domains, with or without a leading dot
+		entry = strings.TrimPrefix(strings.TrimPrefix(entry, "*"), ".")
+		if host == entry || strings.HasSuffix(host, "."+entry) {
+			return true
+		}
+	}
+
+	return false
+}
+
+func getEnvAny(keys ...string) string {
+	for _, key := range keys {
+		if val := os.Getenv(key); val != "" {
+			return val
+		}
+	}
+	return ""
+}
@@ -0,0 +1,79 @@
+package client
+
+import (
+	"errors"
+	"sync"
+	"time"
+)
+
+// ErrRateLimitExceeded is returned when a call is rejected by a RateLimiter, before any
+// request is sent to the api.
+var ErrRateLimitExceeded = errors.New("client rate limit exceeded")
+
+// RateLimiter is a token bucket rate limiter. The bucket holds up to burst tokens, and
+// is refilled at rate tokens per second. Each call takes one token. RateLimiter is safe
+// for concurrent use.
+type RateLimiter struct {
+	rate   float64
+	burst  float64
+	tokens float64
+	last   time.Time
+	now    func() time.Time
+	lock   sync.Mutex
+}
+
+// NewRateLimiter creates a rate limiter that allows rate calls per second, with bursts
+// of up to burst calls. The bucket starts full. A burst below one is replaced with one.
+func NewRateLimiter(rate float64, burst int) *RateLimiter {
+	if burst < 1 {
+		burst = 1
+	}
+	return &RateLimiter{
+		rate:   rate,
+		burst:  float64(burst),
+		tokens: float64(burst),
+		now:    time.Now,
+	}
+}
+
+// Allow takes a token, and returns true if one was available.
+func (r *RateLimiter) Allow() bool {
+
+	r.lock.Lock()
+	defer r.lock.Unlock()
+
+	r.refill()
+	if r.tokens < 1 {
+		return false
+	}
+	r.tokens--
+	return true
+}
+
+// Reserve takes a token, and returns how long the caller must wait before using it.
+// The bucket may go into debt, so concurrent callers are spaced by the rate.
+func (r *RateLimiter) Reserve() time.Duration {
+
+	r.lock.Lock()
+	defer r.lock.Unlock()
+
+	r.refill()
+	r.tokens--
+	if r.tokens >= 0 || r.rate <= 0 {
+		return 0
+	}
+	return time.Duration(-r.tokens / r.rate * float64(time.Second))
+}
+
+// refill adds the tokens accumulated since the last call, up to the burst.
+func (r *RateLimiter) refill() {
+
+	now := r.now()
+	if !r.last.IsZero() && r.rate > 0 {
+		r.tokens += now.Sub(r.last).Seconds() * r.rate
+		if r.tokens > r.burst {
+			r.tokens = r.burst
+		}
+	}
+	r.last = now
+}
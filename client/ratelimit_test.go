@@ -0,0 +1,54 @@
+package client
+
+import (
+	"testing"
+	"time"
+)
+
+func TestRateLimiter(t *testing.T) {
+
+	now := time.Unix(1000, 0)
+	limiter := NewRateLimiter(2, 3)
+	limiter.now = func() time.Time { return now }
+
+	for i := 0; i < 3; i++ {
+		if !limiter.Allow() {
+			t.Fatalf("expected call %d within the burst to be allowed", i)
+		}
+	}
+	if limiter.Allow() {
+		t.Fatalf("expected call after the burst to be rejected")
+	}
+
+	now = now.Add(time.Millisecond * 500)
+	if !limiter.Allow() {
+		t.Fatalf("expected call to be allowed after the bucket refilled")
+	}
+	if limiter.Allow() {
+		t.Fatalf("expected call to be rejected after the refilled token was used")
+	}
+
+	now = now.Add(time.Hour)
+	for i := 0; i < 3; i++ {
+		if !limiter.Allow() {
+			t.Fatalf("expected call %d to be allowed after a long idle period", i)
+		}
+	}
+	if limiter.Allow() {
+		t.Fatalf("expected the bucket to refill no more than the burst")
+	}
+}
+
+func TestRateLimiter_Reserve(t *testing.T) {
+
+	now := time.Unix(1000, 0)
+	limiter := NewRateLimiter(4, 1)
+	limiter.now = func() time.Time { return now }
+
+	expected := []time.Duration{0, time.Millisecond * 250, time.Millisecond * 500}
+	for i, wait := range expected {
+		if have := limiter.Reserve(); have != wait {
+			t.Fatalf("expected reservation %d to wait %s, have %s", i, wait, have)
+		}
+	}
+}
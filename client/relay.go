@@ -0,0 +1,163 @@
+package client
+
+import (
+	"encoding/binary"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net"
+	"sync"
+	"time"
+)
+
+// Relay message types
+const (
+	RelaySeries       = "series"
+	RelayServiceCheck = "service_check"
+	RelayEvent        = "event"
+	RelayDistribution = "distribution"
+)
+
+// MaxRelayMessageSize is the largest relay message accepted, in bytes.
+const MaxRelayMessageSize = 64 * 1024 * 1024
+
+// RelayMessage is the unit of the relay protocol. Messages are sent as JSON, prefixed
+// with the length of the JSON as a 4 byte big endian unsigned integer. Every request
+// message is answered with a response message, with Error set if the request failed.
+type RelayMessage struct {
+	Type         string                `json:"type,omitempty"`
+	Series       *DDMetricSeries       `json:"series,omitempty"`
+	ServiceCheck *DDServiceCheck       `json:"service_check,omitempty"`
+	Event        *DDEvent              `json:"event,omitempty"`
+	Distribution *DDDistributionSeries `json:"distribution,omitempty"`
+	Error        string                `json:"error,omitempty"`
+}
+
+// WriteRelayMessage writes a length prefixed message to w.
+func WriteRelayMessage(w io.Writer, msg *RelayMessage) error {
+
+	data, err := json.Marshal(msg)
+	if err != nil {
+		return fmt.Errorf("could not marshal relay message, %s", err.Error())
+	}
+	if len(data) > MaxRelayMessageSize {
+		return fmt.Errorf("relay message of %d bytes exceeds max size", len(data))
+	}
+
+	frame := make([]byte, 4+len(data))
+	binary.BigEndian.PutUint32(frame, uint32(len(data)))
+	copy(frame[4:], data)
+	_, err = w.Write(frame)
+	return err
+}
+
+// ReadRelayMessage reads a length prefixed message from r.
+func ReadRelayMessage(r io.Reader) (*RelayMessage, error) {
+
+	header := make([]byte, 4)
+	if _, err := io.ReadFull(r, header); err != nil {
+		return nil, err
+	}
+	size := binary.BigEndian.Uint32(header)
+	if size > MaxRelayMessageSize {
+		return nil, fmt.Errorf("relay message of %d bytes exceeds max size", size)
+	}
+
+	data := make([]byte, size)
+	if _, err := io.ReadFull(r, data); err != nil {
+		return nil, err
+	}
+
+	msg := &RelayMessage{}
+	if err := json.Unmarshal(data, msg); err != nil {
+		return nil, fmt.Errorf("could not read relay message, %s", err.Error())
+	}
+	return msg, nil
+}
+
+// RelayClient is an APIClient that forwards series, service checks, and events to a relay
+// over a Unix socket, instead of the Datadog api. The relay merges series from all of
+// its clients into its own flush.
+type RelayClient struct {
+	path    string
+	timeout time.Duration
+	conn    net.Conn
+	lock    *sync.Mutex
+}
+
+// NewRelayClient creates a client for the relay listening on the Unix socket at path. The
+// connection is opened on first use, and reopened after any error.
+func NewRelayClient(path string) *RelayClient {
+	return &RelayClient{
+		path:    path,
+		timeout: time.Second * 10,
+		lock:    &sync.Mutex{},
+	}
+}
+
+func (c *RelayClient) SendSeries(series *DDMetricSeries) error {
+	return c.send(&RelayMessage{Type: RelaySeries, Series: series})
+}
+
+func (c *RelayClient) SendServiceCheck(check *DDServiceCheck) error {
+	return c.send(&RelayMessage{Type: RelayServiceCheck, ServiceCheck: check})
+}
+
+func (c *RelayClient) SendEvent(event *DDEvent) error {
+	return c.send(&RelayMessage{Type: RelayEvent, Event: event})
+}
+
+func (c *RelayClient) SendDistributions(series *DDDistributionSeries) error {
+	return c.send(&RelayMessage{Type: RelayDistribution, Distribution: series})
+}
+
+// SetHTTPClient is a no-op, the relay client does not use http.
+func (c *RelayClient) SetHTTPClient(HTTPClient) {}
+
+// Close closes the connection to the relay.
+func (c *RelayClient) Close() error {
+	c.lock.Lock()
+	defer c.lock.Unlock()
+	if c.conn == nil {
+		return nil
+	}
+	err := c.conn.Close()
+	c.conn = nil
+	return err
+}
+
+func (c *RelayClient) send(msg *RelayMessage) error {
+
+	c.lock.Lock()
+	defer c.lock.Unlock()
+
+	if c.conn == nil {
+		conn, err := net.DialTimeout("unix", c.path, c.timeout)
+		if err != nil {
+			return networkError("could not connect to relay", err)
+		}
+		c.conn = conn
+	}
+
+	response, err := c.roundTrip(msg)
+	if err != nil {
+		_ = c.conn.Close()
+		c.conn = nil
+		return networkError("relay request failed", err)
+	}
+	if response.Error != "" {
+		return fmt.Errorf("relay error: %s", response.Error)
+	}
+
+	return nil
+}
+
+func (c *RelayClient) roundTrip(msg *RelayMessage) (*RelayMessage, error) {
+	if err := c.conn.SetDeadline(time.Now().Add(c.timeout)); err != nil {
+		return nil, err
+	}
+	if err := WriteRelayMessage(c.conn, msg); err != nil {
+		return nil, err
+	}
+	return ReadRelayMessage(c.conn)
+}
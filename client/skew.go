@@ -0,0 +1,55 @@
+package client
+
+import (
+	"net/http"
+	"sync/atomic"
+	"time"
+)
+
+// ClockSkewReporter is implemented by api clients that can estimate the clock skew
+// between the host, and the api.
+type ClockSkewReporter interface {
+
+	// ClockSkew returns the most recent estimate of api time minus host time. A positive
+	// skew means the host clock is behind. The second value is false until an estimate is
+	// available.
+	ClockSkew() (time.Duration, bool)
+}
+
+// clockSkew tracks the skew estimated from the Date header of api responses.
+type clockSkew struct {
+	skew  int64 // nanoseconds
+	known int32
+}
+
+// observe updates the estimate from response, for a request sent at start. The Date
+// header is truncated to the second, so half a second is added to it, and the server
+// time is assumed to be the midpoint of the request.
+func (s *clockSkew) observe(response *http.Response, start time.Time) {
+	if response == nil {
+		return
+	}
+	date, err := http.ParseTime(response.Header.Get("Date"))
+	if err != nil {
+		return
+	}
+	end := time.Now()
+	local := start.Add(end.Sub(start) / 2)
+	atomic.StoreInt64(&s.skew, int64(date.Add(time.Second/2).Sub(local)))
+	atomic.StoreInt32(&s.known, 1)
+}
+
+func (s *clockSkew) get() (time.Duration, bool) {
+	if atomic.LoadInt32(&s.known) == 0 {
+		return 0, false
+	}
+	return time.Duration(atomic.LoadInt64(&s.skew)), true
+}
+
+// ClockSkew returns the clock skew estimated from the Date header of the most recent api
+// response, as api time minus host time. The Date header has a resolution of one second,
+// so skew under a second is not meaningful. The second value is false until a response
+// with a Date header has been received.
+func (c *DDClient) ClockSkew() (time.Duration, bool) {
+	return c.skew.get()
+}
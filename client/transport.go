@@ -0,0 +1,122 @@
+package client
+
+import (
+	"crypto/tls"
+	"crypto/x509"
+	"fmt"
+	"io/ioutil"
+	"net"
+	"net/http"
+	"time"
+)
+
+// TransportConfig holds the http settings used by DDClient. Zero values use the defaults
+// of http.DefaultTransport.
+type TransportConfig struct {
+	ProxyURL            string            // Proxy url, overrides the proxy environment variables
+	RoundTripper        http.RoundTripper // Custom transport, if set the settings below are ignored
+	TLSConfig           *tls.Config       // Base tls config, cloned before use
+	CAFile              string            // PEM file of CA certificates trusted in addition to the system pool
+	InsecureSkipVerify  bool              // Skip verifying the server certificate, only for test gateways
+	Timeout             time.Duration     // Max time for a request, including reading the response
+	DialTimeout         time.Duration     // Max time to establish a connection
+	TLSHandshakeTimeout time.Duration     // Max time for the tls handshake
+	KeepAlive           time.Duration     // TCP keep alive period, negative disables keep alive probes
+	IdleConnTimeout     time.Duration     // Time an idle connection is kept open
+	MaxIdleConns        int               // Max idle connections kept open
+	DisableKeepAlives   bool              // Use a new connection for each request
+}
+
+// NewHTTPClient creates an http client from cfg. The proxy is used for all requests, when
+// no proxy url is set in cfg.
+func NewHTTPClient(cfg TransportConfig, proxy *ProxyConfig) (*http.Client, error) {
+
+	httpClient := &http.Client{Timeout: cfg.Timeout}
+	if cfg.RoundTripper != nil {
+		httpClient.Transport = cfg.RoundTripper
+		return httpClient, nil
+	}
+
+	transport := http.DefaultTransport.(*http.Transport).Clone()
+	transport.Proxy = proxy.Proxy
+	if cfg.DialTimeout > 0 || cfg.KeepAlive != 0 {
+		dialer := &net.Dialer{Timeout: 30 * time.Second, KeepAlive: 30 * time.Second}
+		if cfg.DialTimeout > 0 {
+			dialer.Timeout = cfg.DialTimeout
+		}
+		if cfg.KeepAlive != 0 {
+			dialer.KeepAlive = cfg.KeepAlive
+		}
+		transport.DialContext = dialer.DialContext
+	}
+	if cfg.TLSHandshakeTimeout > 0 {
+		transport.TLSHandshakeTimeout = cfg.TLSHandshakeTimeout
+	}
+	if cfg.IdleConnTimeout > 0 {
+		transport.IdleConnTimeout = cfg.IdleConnTimeout
+	}
+	if cfg.MaxIdleConns > 0 {
+		transport.MaxIdleConns = cfg.MaxIdleConns
+	}
+	transport.DisableKeepAlives = cfg.DisableKeepAlives
+
+	if cfg.TLSConfig != nil || cfg.CAFile != "" || cfg.InsecureSkipVerify {
+		tlsConfig := &tls.Config{}
+		if cfg.TLSConfig != nil {
+			tlsConfig = cfg.TLSConfig.Clone()
+		}
+		if cfg.CAFile != "" {
+			pool, err := loadCAFile(cfg.CAFile)
+			if err != nil {
+				return nil, err
+			}
+			tlsConfig.RootCAs = pool
+		}
+		if cfg.InsecureSkipVerify {
+			tlsConfig.InsecureSkipVerify = true
+		}
+		transport.TLSClientConfig = tlsConfig
+	}
+
+	httpClient.Transport = transport
+	return httpClient, nil
+}
+
+// loadCAFile returns the system cert pool, with the certificates in the PEM file path
+// added.
+func loadCAFile(path string) (*x509.CertPool, error) {
+
+	data, err := ioutil.ReadFile(path)
+	if err != nil {
+		return nil, fmt.Errorf("could not read ca file, %s", err.Error())
+	}
+
+	pool, err := x509.SystemCertPool()
+	if err != nil || pool == nil {
+		pool = x509.NewCertPool()
+	}
+	if !pool.AppendCertsFromPEM(data) {
+		return nil, fmt.Errorf("could not read ca file, no certificates found in %s", path)
+	}
+	return pool, nil
+}
+
+// SetTransport replaces the http client with one created from cfg. If cfg has a proxy
+// url, it's used in place of the proxy environment variables, the no proxy list is still
+// loaded from the environment.
+func (c *DDClient) SetTransport(cfg TransportConfig) error {
+
+	proxy := ProxyConfigFromEnvironment()
+	if cfg.ProxyURL != "" {
+		proxy.HTTPSProxy = cfg.ProxyURL
+		proxy.Source = "config"
+	}
+
+	httpClient, err := NewHTTPClient(cfg, proxy)
+	if err != nil {
+		return err
+	}
+	c.client = httpClient
+	c.proxy = proxy
+	return nil
+}
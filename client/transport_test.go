@@ -0,0 +1,95 @@
+package client
+
+import (
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+)
+
+func TestNewHTTPClient(t *testing.T) {
+
+	t.Run("settings", func(tt *testing.T) {
+		httpClient, err := NewHTTPClient(TransportConfig{
+			Timeout:             time.Second,
+			TLSHandshakeTimeout: time.Second * 2,
+			IdleConnTimeout:     time.Second * 3,
+			MaxIdleConns:        4,
+			DisableKeepAlives:   true,
+			InsecureSkipVerify:  true,
+		}, nil)
+		if err != nil {
+			tt.Fatalf(err.Error())
+		}
+		if httpClient.Timeout != time.Second {
+			tt.Fatalf("expected timeout to be %s, have %s", time.Second, httpClient.Timeout)
+		}
+		transport := httpClient.Transport.(*http.Transport)
+		if transport.TLSHandshakeTimeout != time.Second*2 || transport.IdleConnTimeout != time.Second*3 {
+			tt.Fatalf("expected transport timeouts to be set, have %s, and %s", transport.TLSHandshakeTimeout, transport.IdleConnTimeout)
+		}
+		if transport.MaxIdleConns != 4 || !transport.DisableKeepAlives {
+			tt.Fatalf("expected transport connection settings to be set")
+		}
+		if transport.TLSClientConfig == nil || !transport.TLSClientConfig.InsecureSkipVerify {
+			tt.Fatalf("expected insecure skip verify to be set")
+		}
+	})
+
+	t.Run("insecure test gateway", func(tt *testing.T) {
+		server := httptest.NewTLSServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			_, _ = w.Write([]byte("{}"))
+		}))
+		defer server.Close()
+
+		client := NewDDClient("testKey")
+		if err := client.SetBaseURL(server.URL); err != nil {
+			tt.Fatalf(err.Error())
+		}
+		if err := client.SendSeries(&DDMetricSeries{}); err == nil {
+			tt.Fatalf("expected an error for an untrusted certificate")
+		}
+		if err := client.SetTransport(TransportConfig{InsecureSkipVerify: true}); err != nil {
+			tt.Fatalf(err.Error())
+		}
+		if err := client.SendSeries(&DDMetricSeries{}); err != nil {
+			tt.Fatalf("expected no error, have %s", err.Error())
+		}
+	})
+
+	t.Run("round tripper", func(tt *testing.T) {
+		rt := &http.Transport{}
+		httpClient, err := NewHTTPClient(TransportConfig{RoundTripper: rt, CAFile: "missing"}, nil)
+		if err != nil {
+			tt.Fatalf(err.Error())
+		}
+		if httpClient.Transport != rt {
+			tt.Fatalf("expected custom round tripper to be used")
+		}
+	})
+
+	t.Run("bad ca file", func(tt *testing.T) {
+		path := filepath.Join(os.TempDir(), "ddstats-test-ca.pem")
+		if err := ioutil.WriteFile(path, []byte("not a certificate"), 0600); err != nil {
+			tt.Fatalf(err.Error())
+		}
+		defer func() { _ = os.Remove(path) }()
+
+		if _, err := NewHTTPClient(TransportConfig{CAFile: path}, nil); err == nil {
+			tt.Fatalf("expected an error for a ca file without certificates")
+		}
+	})
+
+	t.Run("proxy url", func(tt *testing.T) {
+		client := NewDDClient("testKey")
+		if err := client.SetTransport(TransportConfig{ProxyURL: "http://proxy.example.com:3128"}); err != nil {
+			tt.Fatalf(err.Error())
+		}
+		if decision := client.ProxyDecision(); decision != "proxy http://proxy.example.com:3128 (from config)" {
+			tt.Fatalf("expected proxy from config, have %s", decision)
+		}
+	})
+}
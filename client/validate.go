@@ -0,0 +1,115 @@
+package client
+
+import (
+	"fmt"
+	"math"
+	"strings"
+	"time"
+	"unicode"
+)
+
+// Limits applied by Validate. Points outside of the accepted time range are silently
+// dropped by the api.
+const (
+	MaxMetricNameLength = 200
+	MaxTagLength        = 200
+	MaxPointFuture      = time.Minute * 10
+	MaxPointAge         = time.Hour
+)
+
+// MetricError describes the problems found with one metric in a series.
+type MetricError struct {
+	Index    int    // Index of the metric in the series
+	Metric   string // Name of the metric
+	Problems []string
+}
+
+func (e *MetricError) Error() string {
+	return fmt.Sprintf("invalid metric %d %q, %s", e.Index, e.Metric, strings.Join(e.Problems, ", "))
+}
+
+// SeriesError lists the invalid metrics found in a series.
+type SeriesError struct {
+	Metrics []*MetricError
+}
+
+func (e *SeriesError) Error() string {
+	msgs := make([]string, len(e.Metrics))
+	for i, m := range e.Metrics {
+		msgs[i] = m.Error()
+	}
+	return fmt.Sprintf("%d invalid metrics in series, %s", len(e.Metrics), strings.Join(msgs, "; "))
+}
+
+// ValidateSeries validates each metric in series, at time now. It returns a *SeriesError
+// listing every invalid metric, or nil if all metrics are valid.
+func ValidateSeries(series []*DDMetric, now time.Time) error {
+	var invalid []*MetricError
+	for i, m := range series {
+		if problems := m.Validate(now); len(problems) > 0 {
+			invalid = append(invalid, &MetricError{Index: i, Metric: m.Metric, Problems: problems})
+		}
+	}
+	if len(invalid) > 0 {
+		return &SeriesError{Metrics: invalid}
+	}
+	return nil
+}
+
+// Validate returns the problems found with the metric, at time now, that would cause the
+// api to reject, or silently drop it. The name must be non empty, start with a letter,
+// and be no longer than MaxMetricNameLength. There must be at least one point, each with
+// a unix timestamp no more than MaxPointFuture ahead of now, or MaxPointAge behind it,
+// and a finite numeric value. The type must be empty, count, rate, or gauge. Tags must be
+// non empty, without whitespace, and no longer than MaxTagLength.
+func (m *DDMetric) Validate(now time.Time) []string {
+
+	var problems []string
+	switch {
+	case m.Metric == "":
+		problems = append(problems, "empty name")
+	case len(m.Metric) > MaxMetricNameLength:
+		problems = append(problems, fmt.Sprintf("name longer than %d characters", MaxMetricNameLength))
+	case !unicode.IsLetter([]rune(m.Metric)[0]):
+		problems = append(problems, "name does not start with a letter")
+	}
+
+	switch m.Type {
+	case "", Count, Rate, Gauge:
+	default:
+		problems = append(problems, fmt.Sprintf("unknown type %q", m.Type))
+	}
+	if m.Interval < 0 {
+		problems = append(problems, "negative interval")
+	}
+
+	if len(m.Points) == 0 {
+		problems = append(problems, "no points")
+	}
+	for i, p := range m.Points {
+		ts, ok := toFloat(p[0])
+		if !ok {
+			problems = append(problems, fmt.Sprintf("point %d timestamp is not a number", i))
+		} else if t := time.Unix(int64(ts), 0); t.After(now.Add(MaxPointFuture)) {
+			problems = append(problems, fmt.Sprintf("point %d timestamp is more than %s in the future", i, MaxPointFuture))
+		} else if t.Before(now.Add(-MaxPointAge)) {
+			problems = append(problems, fmt.Sprintf("point %d timestamp is more than %s in the past", i, MaxPointAge))
+		}
+		if v, ok := toFloat(p[1]); !ok || math.IsNaN(v) || math.IsInf(v, 0) {
+			problems = append(problems, fmt.Sprintf("point %d value is not a finite number", i))
+		}
+	}
+
+	for _, tag := range m.Tags {
+		switch {
+		case tag == "":
+			problems = append(problems, "empty tag")
+		case len(tag) > MaxTagLength:
+			problems = append(problems, fmt.Sprintf("tag %q longer than %d characters", tag, MaxTagLength))
+		case strings.IndexFunc(tag, unicode.IsSpace) >= 0:
+			problems = append(problems, fmt.Sprintf("tag %q contains whitespace", tag))
+		}
+	}
+
+	return problems
+}
@@ -0,0 +1,78 @@
+package client
+
+import (
+	"math"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestDDMetric_Validate(t *testing.T) {
+
+	now := time.Unix(1600000000, 0)
+	valid := func() *DDMetric {
+		return &DDMetric{
+			Metric: "app.requests",
+			Points: [][2]interface{}{{now.Unix(), 1.0}},
+			Tags:   []string{"env:test"},
+			Type:   Count,
+		}
+	}
+
+	tests := []struct {
+		name    string
+		modify  func(m *DDMetric)
+		problem string
+	}{
+		{"valid", func(m *DDMetric) {}, ""},
+		{"empty name", func(m *DDMetric) { m.Metric = "" }, "empty name"},
+		{"long name", func(m *DDMetric) { m.Metric = strings.Repeat("a", MaxMetricNameLength+1) }, "name longer"},
+		{"name start", func(m *DDMetric) { m.Metric = "1requests" }, "start with a letter"},
+		{"type", func(m *DDMetric) { m.Type = "histogram" }, "unknown type"},
+		{"no points", func(m *DDMetric) { m.Points = nil }, "no points"},
+		{"future", func(m *DDMetric) { m.Points[0][0] = now.Add(time.Hour).Unix() }, "in the future"},
+		{"past", func(m *DDMetric) { m.Points[0][0] = now.Add(-time.Hour * 2).Unix() }, "in the past"},
+		{"milliseconds", func(m *DDMetric) { m.Points[0][0] = now.UnixNano() / int64(time.Millisecond) }, "in the future"},
+		{"timestamp type", func(m *DDMetric) { m.Points[0][0] = "now" }, "timestamp is not a number"},
+		{"nan", func(m *DDMetric) { m.Points[0][1] = math.NaN() }, "not a finite number"},
+		{"empty tag", func(m *DDMetric) { m.Tags = []string{""} }, "empty tag"},
+		{"tag whitespace", func(m *DDMetric) { m.Tags = []string{"env: test"} }, "contains whitespace"},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(tt *testing.T) {
+			m := valid()
+			test.modify(m)
+			problems := m.Validate(now)
+			if test.problem == "" {
+				if len(problems) > 0 {
+					tt.Fatalf("expected no problems, have %v", problems)
+				}
+				return
+			}
+			if len(problems) != 1 || !strings.Contains(problems[0], test.problem) {
+				tt.Fatalf("expected problem %q, have %v", test.problem, problems)
+			}
+		})
+	}
+}
+
+func TestValidateSeries(t *testing.T) {
+
+	now := time.Now()
+	series := []*DDMetric{
+		{Metric: "valid", Points: [][2]interface{}{{now.Unix(), 1.0}}},
+		{Metric: "", Points: nil},
+	}
+	err := ValidateSeries(series, now)
+	seriesErr, ok := err.(*SeriesError)
+	if !ok {
+		t.Fatalf("expected *SeriesError, have %v", err)
+	}
+	if len(seriesErr.Metrics) != 1 || seriesErr.Metrics[0].Index != 1 || len(seriesErr.Metrics[0].Problems) != 2 {
+		t.Fatalf("expected metric 1 to have 2 problems, have %s", err.Error())
+	}
+	if err := ValidateSeries(series[:1], now); err != nil {
+		t.Fatalf("expected no error, have %s", err.Error())
+	}
+}
@@ -0,0 +1,145 @@
+package client
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"io"
+	"sync"
+)
+
+// WriterEncoder encodes api requests for a WriterClient.
+type WriterEncoder interface {
+	EncodeSeries(*DDMetricSeries) ([]byte, error)
+	EncodeServiceCheck(*DDServiceCheck) ([]byte, error)
+	EncodeEvent(*DDEvent) ([]byte, error)
+	EncodeDistributions(*DDDistributionSeries) ([]byte, error)
+}
+
+// JSONLEncoder encodes each metric, service check, event, and distribution as a line of
+// JSON, wrapped in an object with a single key naming the kind of record.
+//
+//	{"metric":{"host":"","interval":10,"metric":"requests","points":[[1600000000,1]],...}}
+//	{"event":{"title":"deploy",...}}
+type JSONLEncoder struct{}
+
+func (JSONLEncoder) EncodeSeries(series *DDMetricSeries) ([]byte, error) {
+	buf := &bytes.Buffer{}
+	for _, m := range series.Series {
+		if err := encodeJSONLine(buf, "metric", m); err != nil {
+			return nil, err
+		}
+	}
+	return buf.Bytes(), nil
+}
+
+func (JSONLEncoder) EncodeServiceCheck(check *DDServiceCheck) ([]byte, error) {
+	buf := &bytes.Buffer{}
+	err := encodeJSONLine(buf, "service_check", check)
+	return buf.Bytes(), err
+}
+
+func (JSONLEncoder) EncodeEvent(event *DDEvent) ([]byte, error) {
+	buf := &bytes.Buffer{}
+	err := encodeJSONLine(buf, "event", event)
+	return buf.Bytes(), err
+}
+
+func (JSONLEncoder) EncodeDistributions(series *DDDistributionSeries) ([]byte, error) {
+	buf := &bytes.Buffer{}
+	for _, d := range series.Series {
+		if err := encodeJSONLine(buf, "distribution", d); err != nil {
+			return nil, err
+		}
+	}
+	return buf.Bytes(), nil
+}
+
+func encodeJSONLine(buf *bytes.Buffer, kind string, record interface{}) error {
+	if err := json.NewEncoder(buf).Encode(map[string]interface{}{kind: record}); err != nil {
+		return fmt.Errorf("could not marshal %s, %s", kind, err.Error())
+	}
+	return nil
+}
+
+// StatsDEncoder encodes records as dogstatsd lines, one per line. See DogStatsDClient for
+// how series are converted.
+type StatsDEncoder struct{}
+
+func (StatsDEncoder) EncodeSeries(series *DDMetricSeries) ([]byte, error) {
+	return joinLines(dogStatsDSeries(series)), nil
+}
+
+func (StatsDEncoder) EncodeServiceCheck(check *DDServiceCheck) ([]byte, error) {
+	return joinLines([][]byte{dogStatsDServiceCheck(check)}), nil
+}
+
+func (StatsDEncoder) EncodeEvent(event *DDEvent) ([]byte, error) {
+	return joinLines([][]byte{dogStatsDEvent(event)}), nil
+}
+
+func (StatsDEncoder) EncodeDistributions(series *DDDistributionSeries) ([]byte, error) {
+	return joinLines(dogStatsDDistributions(series)), nil
+}
+
+func joinLines(lines [][]byte) []byte {
+	buf := &bytes.Buffer{}
+	for _, line := range lines {
+		buf.Write(line)
+		buf.WriteByte('\n')
+	}
+	return buf.Bytes()
+}
+
+// WriterClient is an APIClient that writes every request to an io.Writer, instead of the
+// Datadog api. This can be used to write metrics to stdout for log based collection, or
+// to a named pipe. Each request is written with a single call to Write.
+type WriterClient struct {
+	w       io.Writer
+	encoder WriterEncoder
+	lock    *sync.Mutex
+}
+
+// NewWriterClient creates a client that writes requests encoded with encoder to w.
+func NewWriterClient(w io.Writer, encoder WriterEncoder) *WriterClient {
+	return &WriterClient{
+		w:       w,
+		encoder: encoder,
+		lock:    &sync.Mutex{},
+	}
+}
+
+func (c *WriterClient) SendSeries(series *DDMetricSeries) error {
+	return c.write(c.encoder.EncodeSeries(series))
+}
+
+func (c *WriterClient) SendServiceCheck(check *DDServiceCheck) error {
+	return c.write(c.encoder.EncodeServiceCheck(check))
+}
+
+func (c *WriterClient) SendEvent(event *DDEvent) error {
+	return c.write(c.encoder.EncodeEvent(event))
+}
+
+func (c *WriterClient) SendDistributions(series *DDDistributionSeries) error {
+	return c.write(c.encoder.EncodeDistributions(series))
+}
+
+// SetHTTPClient is a no-op, the writer client does not use http.
+func (c *WriterClient) SetHTTPClient(HTTPClient) {}
+
+func (c *WriterClient) write(data []byte, err error) error {
+	if err != nil {
+		return err
+	}
+	if len(data) == 0 {
+		return nil
+	}
+
+	c.lock.Lock()
+	defer c.lock.Unlock()
+	if _, err := c.w.Write(data); err != nil {
+		return fmt.Errorf("could not write to writer, %s", err.Error())
+	}
+	return nil
+}
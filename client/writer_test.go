@@ -0,0 +1,49 @@
+package client
+
+import (
+	"bytes"
+	"testing"
+)
+
+func TestWriterClient(t *testing.T) {
+
+	series := &DDMetricSeries{Series: []*DDMetric{
+		{Metric: "a", Points: [][2]interface{}{{int64(1), 1.0}}, Type: Count},
+		{Metric: "b", Points: [][2]interface{}{{int64(1), 2.0}}, Type: Gauge, Tags: []string{"t:1"}},
+	}}
+
+	t.Run("jsonl", func(tt *testing.T) {
+		buf := &bytes.Buffer{}
+		c := NewWriterClient(buf, JSONLEncoder{})
+		if err := c.SendSeries(series); err != nil {
+			tt.Fatalf(err.Error())
+		}
+		if err := c.SendEvent(&DDEvent{Title: "deploy"}); err != nil {
+			tt.Fatalf(err.Error())
+		}
+
+		lines := bytes.Split(bytes.TrimSpace(buf.Bytes()), []byte("\n"))
+		if len(lines) != 3 {
+			tt.Fatalf("expected %d lines, have %d", 3, len(lines))
+		}
+		expected := `{"metric":{"host":"","interval":0,"metric":"a","points":[[1,1]],"tags":null,"type":"count"}}`
+		if string(lines[0]) != expected {
+			tt.Fatalf("expected line %s, have %s", expected, lines[0])
+		}
+		if !bytes.HasPrefix(lines[2], []byte(`{"event":{`)) {
+			tt.Fatalf("expected event line, have %s", lines[2])
+		}
+	})
+
+	t.Run("statsd", func(tt *testing.T) {
+		buf := &bytes.Buffer{}
+		c := NewWriterClient(buf, StatsDEncoder{})
+		if err := c.SendSeries(series); err != nil {
+			tt.Fatalf(err.Error())
+		}
+		expected := "a:1|c\nb:2|g|#t:1\n"
+		if buf.String() != expected {
+			tt.Fatalf("expected %q, have %q", expected, buf.String())
+		}
+	})
+}
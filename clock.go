@@ -0,0 +1,143 @@
+package ddstats
+
+import (
+	"sort"
+	"sync"
+	"time"
+)
+
+// Clock is the source of time used to schedule flushes, to measure the interval of each
+// flush, which rates are calculated over, and to assign downsampling windows. The system
+// clock is used by default, tests can drive flushes with a ManualClock, see
+// Config.WithClock.
+type Clock interface {
+	Now() time.Time
+	NewTimer(d time.Duration) ClockTimer
+}
+
+// ClockTimer is a timer created by a Clock, it behaves like time.Timer.
+type ClockTimer interface {
+	C() <-chan time.Time
+	Reset(d time.Duration) bool
+	Stop() bool
+}
+
+// systemClock is the Clock of the time package.
+type systemClock struct{}
+
+func (systemClock) Now() time.Time {
+	return time.Now()
+}
+
+func (systemClock) NewTimer(d time.Duration) ClockTimer {
+	return &systemTimer{timer: time.NewTimer(d)}
+}
+
+type systemTimer struct {
+	timer *time.Timer
+}
+
+func (t *systemTimer) C() <-chan time.Time {
+	return t.timer.C
+}
+
+func (t *systemTimer) Reset(d time.Duration) bool {
+	return t.timer.Reset(d)
+}
+
+func (t *systemTimer) Stop() bool {
+	return t.timer.Stop()
+}
+
+// ManualClock is a Clock that only moves when Advance is called, so tests can run flushes
+// deterministically, without sleeping.
+//
+//	clock := ddstats.NewManualClock(time.Now())
+//	stats, _ := ddstats.NewStats(cfg.WithClock(clock))
+//	stats.Increment("requests", nil)
+//	clock.Advance(ddstats.DefaultFlushInterval) // the scheduled flush is sent
+//
+// Flushes are sent asynchronously after the timer fires, use a flush callback, or
+// ddstatstest.RecordingClient.WaitForFlush to wait for the send.
+type ManualClock struct {
+	now    time.Time
+	timers []*manualTimer
+	lock   sync.Mutex
+}
+
+// NewManualClock creates a manual clock set to now.
+func NewManualClock(now time.Time) *ManualClock {
+	return &ManualClock{now: now}
+}
+
+func (c *ManualClock) Now() time.Time {
+	c.lock.Lock()
+	defer c.lock.Unlock()
+	return c.now
+}
+
+func (c *ManualClock) NewTimer(d time.Duration) ClockTimer {
+	c.lock.Lock()
+	defer c.lock.Unlock()
+	t := &manualTimer{clock: c, c: make(chan time.Time, 1), when: c.now.Add(d), active: true}
+	c.timers = append(c.timers, t)
+	return t
+}
+
+// Advance moves the clock forward by d, and fires the timers that expire, in order of
+// expiry. Each timer fires at most once per call, a timer that is reset while advancing
+// fires on a later call, so advance by one flush interval at a time to run consecutive
+// flushes.
+func (c *ManualClock) Advance(d time.Duration) {
+
+	c.lock.Lock()
+	defer c.lock.Unlock()
+	target := c.now.Add(d)
+
+	var expired []*manualTimer
+	for _, t := range c.timers {
+		if t.active && !t.when.After(target) {
+			expired = append(expired, t)
+		}
+	}
+	sort.SliceStable(expired, func(i, j int) bool {
+		return expired[i].when.Before(expired[j].when)
+	})
+	for _, t := range expired {
+		c.now = t.when
+		t.active = false
+		select {
+		case t.c <- t.when:
+		default:
+		}
+	}
+	c.now = target
+}
+
+type manualTimer struct {
+	clock  *ManualClock
+	c      chan time.Time
+	when   time.Time
+	active bool
+}
+
+func (t *manualTimer) C() <-chan time.Time {
+	return t.c
+}
+
+func (t *manualTimer) Reset(d time.Duration) bool {
+	t.clock.lock.Lock()
+	defer t.clock.lock.Unlock()
+	active := t.active
+	t.when = t.clock.now.Add(d)
+	t.active = true
+	return active
+}
+
+func (t *manualTimer) Stop() bool {
+	t.clock.lock.Lock()
+	defer t.clock.lock.Unlock()
+	active := t.active
+	t.active = false
+	return active
+}
@@ -0,0 +1,136 @@
+package ddstats
+
+import (
+	"testing"
+	"time"
+
+	"github.com/jmizell/ddstats/client"
+)
+
+func TestManualClock(t *testing.T) {
+
+	start := time.Unix(1000, 0)
+	clock := NewManualClock(start)
+	first := clock.NewTimer(time.Second * 10)
+	second := clock.NewTimer(time.Second * 5)
+
+	clock.Advance(time.Second * 4)
+	select {
+	case <-first.C():
+		t.Fatalf("expected timer to not fire before it expires")
+	case <-second.C():
+		t.Fatalf("expected timer to not fire before it expires")
+	default:
+	}
+
+	clock.Advance(time.Second * 6)
+	if at := <-second.C(); !at.Equal(start.Add(time.Second * 5)) {
+		t.Fatalf("expected timer to fire at %s, have %s", start.Add(time.Second*5), at)
+	}
+	if at := <-first.C(); !at.Equal(start.Add(time.Second * 10)) {
+		t.Fatalf("expected timer to fire at %s, have %s", start.Add(time.Second*10), at)
+	}
+	if !clock.Now().Equal(start.Add(time.Second * 10)) {
+		t.Fatalf("expected now to be %s, have %s", start.Add(time.Second*10), clock.Now())
+	}
+
+	if first.Reset(time.Second) {
+		t.Fatalf("expected reset of an expired timer to return false")
+	}
+	if !first.Stop() {
+		t.Fatalf("expected stop of an active timer to return true")
+	}
+	clock.Advance(time.Second * 2)
+	select {
+	case <-first.C():
+		t.Fatalf("expected stopped timer to not fire")
+	default:
+	}
+}
+
+func TestStats_WithClock(t *testing.T) {
+
+	clock := NewManualClock(time.Unix(1000, 0))
+	cfg := NewConfig().
+		WithNamespace(testNamespace).
+		WithHost(testHost).
+		WithClient(NewTestAPIClient()).
+		WithClock(clock)
+	cfg.FlushIntervalSeconds = 10
+	stats, err := NewStats(cfg)
+	if err != nil {
+		t.Fatalf(err.Error())
+	}
+	defer stats.Close()
+
+	flushed := make(chan []*client.DDMetric, 10)
+	stats.FlushCallback(func(metricSeries []*client.DDMetric) {
+		flushed <- metricSeries
+	})
+
+	stats.Rate("requests", 50, nil)
+	clock.Advance(time.Second * 5)
+	select {
+	case <-flushed:
+		t.Fatalf("expected no flush before the flush interval")
+	case <-time.After(time.Millisecond * 50):
+	}
+
+	clock.Advance(time.Second * 5)
+	select {
+	case series := <-flushed:
+		if len(series) != 1 || series[0].Points[0][1] != float64(5) || series[0].Interval != 10 {
+			t.Fatalf("expected a rate of 5 over 10 seconds, have %v", series[0])
+		}
+		if series[0].Points[0][0] != int64(1010) {
+			t.Fatalf("expected the point time to be read from the clock, have %v", series[0].Points[0][0])
+		}
+	case <-time.After(time.Second * 5):
+		t.Fatalf("expected a flush at the flush interval")
+	}
+
+	stats.Rate("requests", 20, nil)
+	clock.Advance(time.Second * 10)
+	select {
+	case series := <-flushed:
+		if len(series) != 1 || series[0].Points[0][1] != float64(2) {
+			t.Fatalf("expected a rate of 2, have %v", series[0])
+		}
+	case <-time.After(time.Second * 5):
+		t.Fatalf("expected a second flush at the flush interval")
+	}
+}
+
+func TestStats_WithClockTimestamps(t *testing.T) {
+
+	clock := NewManualClock(time.Unix(1000, 0))
+	testClient := NewTestAPIClient()
+	stats, err := NewStats(NewConfig().
+		WithNamespace(testNamespace).
+		WithHost(testHost).
+		WithClient(testClient).
+		WithClock(clock))
+	if err != nil {
+		t.Fatalf(err.Error())
+	}
+	defer stats.Close()
+
+	if err := stats.ServiceCheck("check", "", client.Okay, nil); err != nil {
+		t.Fatalf(err.Error())
+	}
+	if err := stats.Event(&client.DDEvent{Title: "event"}); err != nil {
+		t.Fatalf(err.Error())
+	}
+	stats.Gauge("test", 1, nil)
+	stats.Flush()
+
+	testClient.lock.Lock()
+	checkTime, eventTime := testClient.checks[0].Timestamp, testClient.events[0].DateHappened
+	testClient.lock.Unlock()
+	if checkTime != 1000 || eventTime != 1000 {
+		t.Fatalf("expected check, and event times to be read from the clock, have %d, and %d", checkTime, eventTime)
+	}
+	if h := stats.Health(); !h.LastSuccessfulFlush.Equal(time.Unix(1000, 0)) {
+		t.Fatalf("expected the last flush time to be read from the clock, have %s", h.LastSuccessfulFlush)
+	}
+}
@@ -0,0 +1,89 @@
+// Command ddstats-gen generates typed ddstats metric handles from a metric schema, so
+// metric names, and types are defined in one place, and renaming a metric is a compile
+// time change. It's intended to be run with go generate.
+//
+//	//go:generate go run github.com/jmizell/ddstats/cmd/ddstats-gen -schema metrics.yaml -out metrics_gen.go
+//
+// See parseSchema for the schema format. Each metric is generated as a package variable,
+// named from the metric name, requests.total is generated as RequestsTotal, or from
+// go_name if it's set.
+package main
+
+import (
+	"bytes"
+	"flag"
+	"fmt"
+	"go/format"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+)
+
+func main() {
+
+	schemaFile := flag.String("schema", "metrics.yaml", "metric schema file")
+	out := flag.String("out", "", "output file, defaults to stdout")
+	flag.Parse()
+
+	if err := run(*schemaFile, *out); err != nil {
+		fmt.Fprintf(os.Stderr, "ddstats-gen: %s\n", err.Error())
+		os.Exit(1)
+	}
+}
+
+func run(schemaFile, out string) error {
+
+	f, err := os.Open(schemaFile)
+	if err != nil {
+		return fmt.Errorf("could not open schema, %s", err.Error())
+	}
+	defer f.Close()
+
+	s, err := parseSchema(f)
+	if err != nil {
+		return fmt.Errorf("%s: %s", schemaFile, err.Error())
+	}
+
+	code, err := generate(s, filepath.Base(schemaFile))
+	if err != nil {
+		return err
+	}
+
+	if out == "" {
+		_, err = os.Stdout.Write(code)
+		return err
+	}
+	return ioutil.WriteFile(out, code, 0644)
+}
+
+// generate returns the formatted Go source for s.
+func generate(s *schema, source string) ([]byte, error) {
+
+	buf := &bytes.Buffer{}
+	fmt.Fprintf(buf, "// Code generated by ddstats-gen from %s. DO NOT EDIT.\n\n", source)
+	fmt.Fprintf(buf, "package %s\n\n", s.Package)
+	if len(s.Metrics) > 0 {
+		fmt.Fprintf(buf, "import \"github.com/jmizell/ddstats\"\n\n")
+	}
+
+	for _, m := range s.Metrics {
+		fmt.Fprintf(buf, "// %s is the %s metric %s.", m.GoName, m.Type, m.Name)
+		if m.Description != "" {
+			fmt.Fprintf(buf, " %s", strings.Replace(m.Description, "\n", " ", -1))
+		}
+		buf.WriteString("\n")
+		if len(m.Tags) > 0 {
+			fmt.Fprintf(buf, "//\n// Tags: %s\n", strings.Join(m.Tags, ", "))
+		}
+		fmt.Fprintf(buf, "var %s = ddstats.%s{Name: %s, Description: %s}\n\n",
+			m.GoName, handleTypes[m.Type], strconv.Quote(m.Name), strconv.Quote(m.Description))
+	}
+
+	code, err := format.Source(buf.Bytes())
+	if err != nil {
+		return nil, fmt.Errorf("could not format generated code, %s", err.Error())
+	}
+	return code, nil
+}
@@ -0,0 +1,96 @@
+package main
+
+import (
+	"strings"
+	"testing"
+)
+
+const testSchema = `# Service metrics
+package: metrics
+metrics:
+  - name: requests.total
+    type: count
+    description: "Total requests handled # including errors"
+    tags: [endpoint, status] # declared tag keys
+  - name: queue-depth
+    type: gauge
+  - type: set
+    name: users.unique
+    go_name: UniqueUsers
+`
+
+func TestParseSchema(t *testing.T) {
+
+	s, err := parseSchema(strings.NewReader(testSchema))
+	if err != nil {
+		t.Fatalf(err.Error())
+	}
+	if s.Package != "metrics" {
+		t.Fatalf("expected package %s, have %s", "metrics", s.Package)
+	}
+	if len(s.Metrics) != 3 {
+		t.Fatalf("expected %d metrics, have %d", 3, len(s.Metrics))
+	}
+
+	m := s.Metrics[0]
+	if m.Name != "requests.total" || m.Type != "count" || m.GoName != "RequestsTotal" {
+		t.Fatalf("unexpected metric %+v", m)
+	}
+	if m.Description != "Total requests handled # including errors" {
+		t.Fatalf("unexpected description %q", m.Description)
+	}
+	if len(m.Tags) != 2 || m.Tags[0] != "endpoint" || m.Tags[1] != "status" {
+		t.Fatalf("unexpected tags %v", m.Tags)
+	}
+	if s.Metrics[1].GoName != "QueueDepth" {
+		t.Fatalf("expected Go name %s, have %s", "QueueDepth", s.Metrics[1].GoName)
+	}
+	if s.Metrics[2].GoName != "UniqueUsers" {
+		t.Fatalf("expected Go name %s, have %s", "UniqueUsers", s.Metrics[2].GoName)
+	}
+}
+
+func TestParseSchema_Errors(t *testing.T) {
+
+	tests := map[string]string{
+		"missing package": "metrics:\n  - name: a\n    type: count\n",
+		"unknown type":    "package: m\nmetrics:\n  - name: a\n    type: counter\n",
+		"duplicate name":  "package: m\nmetrics:\n  - name: a\n    type: count\n  - name: a\n    type: gauge\n",
+		"duplicate Go":    "package: m\nmetrics:\n  - name: a.b\n    type: count\n  - name: a_b\n    type: gauge\n",
+		"unknown key":     "package: m\nmetrics:\n  - name: a\n    type: count\n    unit: ms\n",
+		"block scalar":    "package: m\nmetrics:\n  - name: a\n    type: count\n    description: |\n",
+		"invalid Go name": "package: m\nmetrics:\n  - name: 1xx\n    type: count\n",
+	}
+	for name, schema := range tests {
+		t.Run(name, func(tt *testing.T) {
+			if _, err := parseSchema(strings.NewReader(schema)); err == nil {
+				tt.Fatalf("expected error")
+			}
+		})
+	}
+}
+
+func TestGenerate(t *testing.T) {
+
+	s, err := parseSchema(strings.NewReader(testSchema))
+	if err != nil {
+		t.Fatalf(err.Error())
+	}
+	code, err := generate(s, "metrics.yaml")
+	if err != nil {
+		t.Fatalf(err.Error())
+	}
+
+	for _, expected := range []string{
+		"// Code generated by ddstats-gen from metrics.yaml. DO NOT EDIT.",
+		"package metrics",
+		`var RequestsTotal = ddstats.CountMetric{Name: "requests.total", Description: "Total requests handled # including errors"}`,
+		"// Tags: endpoint, status",
+		`var QueueDepth = ddstats.GaugeMetric{Name: "queue-depth", Description: ""}`,
+		`var UniqueUsers = ddstats.SetMetric{Name: "users.unique", Description: ""}`,
+	} {
+		if !strings.Contains(string(code), expected) {
+			t.Fatalf("expected generated code to contain %q, have\n%s", expected, code)
+		}
+	}
+}
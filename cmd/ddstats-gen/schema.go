@@ -0,0 +1,269 @@
+package main
+
+import (
+	"bufio"
+	"fmt"
+	"io"
+	"strconv"
+	"strings"
+	"unicode"
+)
+
+// Metric types supported in a schema, and the ddstats handle type for each.
+var handleTypes = map[string]string{
+	"count":        "CountMetric",
+	"rate":         "RateMetric",
+	"gauge":        "GaugeMetric",
+	"histogram":    "HistogramMetric",
+	"distribution": "DistributionMetric",
+	"set":          "SetMetric",
+}
+
+// schema is a metric schema file.
+type schema struct {
+	Package string
+	Metrics []*metricDef
+}
+
+// metricDef is a single metric in a schema.
+type metricDef struct {
+	Name        string
+	Type        string
+	Description string
+	Tags        []string
+	GoName      string
+	line        int
+}
+
+// parseSchema reads a schema in a small subset of YAML. The schema is a mapping with a
+// package name, and a list of metrics. Each metric is a mapping of scalar values, or flow
+// sequences for tags. Comments, and quoted strings are supported. Anchors, block scalars,
+// and nested mappings are not.
+//
+//	package: metrics
+//	metrics:
+//	  - name: requests.total
+//	    type: count
+//	    description: Total requests handled
+//	    tags: [endpoint, status]
+func parseSchema(r io.Reader) (*schema, error) {
+
+	s := &schema{}
+	var current *metricDef
+	inMetrics := false
+	scanner := bufio.NewScanner(r)
+	for n := 1; scanner.Scan(); n++ {
+
+		raw := stripComment(scanner.Text())
+		line := strings.TrimSpace(raw)
+		if line == "" {
+			continue
+		}
+		indent := len(raw) - len(strings.TrimLeft(raw, " "))
+
+		if indent == 0 {
+			current = nil
+			key, value, err := splitKey(line, n)
+			if err != nil {
+				return nil, err
+			}
+			switch key {
+			case "package":
+				if s.Package, err = parseScalar(value, n); err != nil {
+					return nil, err
+				}
+				inMetrics = false
+			case "metrics":
+				if value != "" {
+					return nil, fmt.Errorf("line %d: metrics must be a list", n)
+				}
+				inMetrics = true
+			default:
+				return nil, fmt.Errorf("line %d: unknown key %q", n, key)
+			}
+			continue
+		}
+
+		if !inMetrics {
+			return nil, fmt.Errorf("line %d: unexpected indentation", n)
+		}
+		if strings.HasPrefix(line, "-") {
+			current = &metricDef{line: n}
+			s.Metrics = append(s.Metrics, current)
+			line = strings.TrimSpace(strings.TrimPrefix(line, "-"))
+			if line == "" {
+				continue
+			}
+		}
+		if current == nil {
+			return nil, fmt.Errorf("line %d: expected a list item", n)
+		}
+
+		key, value, err := splitKey(line, n)
+		if err != nil {
+			return nil, err
+		}
+		if err := current.set(key, value, n); err != nil {
+			return nil, err
+		}
+	}
+	if err := scanner.Err(); err != nil {
+		return nil, fmt.Errorf("could not read schema, %s", err.Error())
+	}
+
+	return s, s.validate()
+}
+
+func (m *metricDef) set(key, value string, n int) error {
+
+	if key == "tags" {
+		tags, err := parseFlowSequence(value, n)
+		m.Tags = tags
+		return err
+	}
+
+	scalar, err := parseScalar(value, n)
+	if err != nil {
+		return err
+	}
+	switch key {
+	case "name":
+		m.Name = scalar
+	case "type":
+		m.Type = scalar
+	case "description":
+		m.Description = scalar
+	case "go_name":
+		m.GoName = scalar
+	default:
+		return fmt.Errorf("line %d: unknown metric key %q", n, key)
+	}
+	return nil
+}
+
+// validate checks the schema, and sets the Go name of each metric.
+func (s *schema) validate() error {
+
+	if s.Package == "" {
+		return fmt.Errorf("schema is missing a package name")
+	}
+	if !isIdentifier(s.Package) {
+		return fmt.Errorf("invalid package name %q", s.Package)
+	}
+
+	names := map[string]int{}
+	goNames := map[string]int{}
+	for _, m := range s.Metrics {
+		if m.Name == "" {
+			return fmt.Errorf("line %d: metric is missing a name", m.line)
+		}
+		if _, ok := handleTypes[m.Type]; !ok {
+			return fmt.Errorf("line %d: metric %s has unknown type %q", m.line, m.Name, m.Type)
+		}
+		if line, ok := names[m.Name]; ok {
+			return fmt.Errorf("line %d: metric %s is already defined on line %d", m.line, m.Name, line)
+		}
+		names[m.Name] = m.line
+
+		if m.GoName == "" {
+			m.GoName = goName(m.Name)
+		}
+		if !isIdentifier(m.GoName) {
+			return fmt.Errorf("line %d: metric %s has invalid Go name %q, set go_name", m.line, m.Name, m.GoName)
+		}
+		if line, ok := goNames[m.GoName]; ok {
+			return fmt.Errorf("line %d: metric %s Go name %s is already used on line %d, set go_name", m.line, m.Name, m.GoName, line)
+		}
+		goNames[m.GoName] = m.line
+	}
+
+	return nil
+}
+
+// goName converts a metric name to an exported Go identifier, requests.total is converted
+// to RequestsTotal.
+func goName(name string) string {
+	var b strings.Builder
+	for _, word := range strings.FieldsFunc(name, func(r rune) bool {
+		return !unicode.IsLetter(r) && !unicode.IsDigit(r)
+	}) {
+		runes := []rune(word)
+		b.WriteRune(unicode.ToUpper(runes[0]))
+		b.WriteString(string(runes[1:]))
+	}
+	return b.String()
+}
+
+func isIdentifier(s string) bool {
+	for i, r := range s {
+		if !unicode.IsLetter(r) && r != '_' && (i == 0 || !unicode.IsDigit(r)) {
+			return false
+		}
+	}
+	return s != ""
+}
+
+// stripComment removes a comment from line, ignoring # inside quoted strings.
+func stripComment(line string) string {
+	var quote rune
+	for i, r := range line {
+		switch {
+		case quote != 0 && r == quote:
+			quote = 0
+		case quote != 0:
+		case r == '"' || r == '\'':
+			quote = r
+		case r == '#' && (i == 0 || line[i-1] == ' ' || line[i-1] == '\t'):
+			return strings.TrimRight(line[:i], " \t")
+		}
+	}
+	return strings.TrimRight(line, " \t")
+}
+
+func splitKey(line string, n int) (string, string, error) {
+	i := strings.Index(line, ":")
+	if i <= 0 {
+		return "", "", fmt.Errorf("line %d: expected key: value", n)
+	}
+	return strings.TrimSpace(line[:i]), strings.TrimSpace(line[i+1:]), nil
+}
+
+func parseScalar(value string, n int) (string, error) {
+	switch {
+	case strings.HasPrefix(value, `"`):
+		s, err := strconv.Unquote(value)
+		if err != nil {
+			return "", fmt.Errorf("line %d: invalid quoted string %s", n, value)
+		}
+		return s, nil
+	case strings.HasPrefix(value, "'"):
+		if len(value) < 2 || !strings.HasSuffix(value, "'") {
+			return "", fmt.Errorf("line %d: invalid quoted string %s", n, value)
+		}
+		return strings.Replace(value[1:len(value)-1], "''", "'", -1), nil
+	case strings.HasPrefix(value, "[") || strings.HasPrefix(value, "{") ||
+		strings.HasPrefix(value, "|") || strings.HasPrefix(value, ">") ||
+		strings.HasPrefix(value, "&") || strings.HasPrefix(value, "*"):
+		return "", fmt.Errorf("line %d: unsupported value %s", n, value)
+	}
+	return value, nil
+}
+
+func parseFlowSequence(value string, n int) ([]string, error) {
+	if !strings.HasPrefix(value, "[") || !strings.HasSuffix(value, "]") {
+		return nil, fmt.Errorf("line %d: tags must be a list, [a, b]", n)
+	}
+	var items []string
+	for _, item := range strings.Split(value[1:len(value)-1], ",") {
+		item = strings.TrimSpace(item)
+		if item == "" {
+			continue
+		}
+		s, err := parseScalar(item, n)
+		if err != nil {
+			return nil, err
+		}
+		items = append(items, s)
+	}
+	return items, nil
+}
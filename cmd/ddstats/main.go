@@ -0,0 +1,105 @@
+// Command ddstats is a set of tools for working with ddstats.
+//
+//	ddstats replay -dir /var/spool/ddstats
+//
+// The replay subcommand sends the series of flushes spooled during an outage, see
+// Config.WithSpool. Points older than the api accepts are reported, and can be rewritten
+// as events with -events. Each replayed file is deleted once it has been sent, if only
+// the events fail, the file is rewritten with the points that are left to send.
+package main
+
+import (
+	"flag"
+	"fmt"
+	"io"
+	"os"
+	"time"
+
+	"github.com/jmizell/ddstats"
+	"github.com/jmizell/ddstats/client"
+)
+
+func main() {
+
+	if len(os.Args) < 2 {
+		usage(os.Stderr)
+		os.Exit(2)
+	}
+
+	var err error
+	switch os.Args[1] {
+	case "replay":
+		err = runReplay(os.Args[2:], os.Stdout)
+	case "help", "-h", "-help", "--help":
+		usage(os.Stdout)
+		return
+	default:
+		fmt.Fprintf(os.Stderr, "ddstats: unknown command %q\n", os.Args[1])
+		usage(os.Stderr)
+		os.Exit(2)
+	}
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "ddstats: %s\n", err.Error())
+		os.Exit(1)
+	}
+}
+
+func usage(w io.Writer) {
+	fmt.Fprintf(w, "usage: ddstats <command> [flags]\n\n")
+	fmt.Fprintf(w, "commands:\n")
+	fmt.Fprintf(w, "  replay    send the series of a failed flush spool directory\n")
+}
+
+func runReplay(args []string, out io.Writer) error {
+
+	flags := flag.NewFlagSet("replay", flag.ContinueOnError)
+	dir := flags.String("dir", "", "spool directory")
+	apiKey := flags.String("api-key", os.Getenv(ddstats.EnvAPIKey), "api key, defaults to "+ddstats.EnvAPIKey)
+	site := flags.String("site", os.Getenv(ddstats.EnvSite), "Datadog site, defaults to "+ddstats.EnvSite)
+	apiURL := flags.String("api-url", os.Getenv(ddstats.EnvAPIBaseURL), "api base url, defaults to "+ddstats.EnvAPIBaseURL)
+	rate := flags.Float64("rate", 1, "requests per second, 0 for no limit")
+	events := flags.Bool("events", false, "send points too old to accept as events")
+	dryRun := flags.Bool("dry-run", false, "report what would be sent, without sending, or deleting files")
+	keep := flags.Bool("keep", false, "keep files after they are sent")
+	if err := flags.Parse(args); err != nil {
+		return err
+	}
+	if *dir == "" {
+		return fmt.Errorf("replay requires -dir")
+	}
+
+	var api client.APIClient
+	if !*dryRun {
+		if *apiKey == "" {
+			return fmt.Errorf("replay requires an api key, set -api-key, or %s", ddstats.EnvAPIKey)
+		}
+		ddClient := client.NewDDClient(*apiKey)
+		if *apiURL != "" {
+			if err := ddClient.SetBaseURL(*apiURL); err != nil {
+				return err
+			}
+		} else if *site != "" {
+			if err := ddClient.SetSite(*site); err != nil {
+				return err
+			}
+		}
+		api = ddClient
+	}
+
+	r := &replayer{
+		dir:     *dir,
+		api:     api,
+		limiter: client.NewRateLimiter(*rate, 1),
+		events:  *events,
+		keep:    *keep || *dryRun,
+		out:     out,
+		now:     time.Now,
+		sleep:   time.Sleep,
+	}
+	report, err := r.replay()
+	if err != nil && report.Files == 0 {
+		return err
+	}
+	fmt.Fprintln(out, report)
+	return err
+}
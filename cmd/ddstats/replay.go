@@ -0,0 +1,247 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/jmizell/ddstats/client"
+)
+
+// replayReport counts the outcome of a replay.
+type replayReport struct {
+	Files   int // Spool files read
+	Sent    int // Points sent as metrics
+	TooOld  int // Points older than the api accepts
+	Events  int // Too old points sent as events
+	Invalid int // Metrics rejected by validation
+	Failed  int // Files that could not be sent, and were kept
+}
+
+func (r *replayReport) String() string {
+	return fmt.Sprintf("replayed %d files, %d points sent, %d points too old, %d sent as events, %d invalid metrics, %d files failed",
+		r.Files, r.Sent, r.TooOld, r.Events, r.Invalid, r.Failed)
+}
+
+// replayer sends the series of a spool directory, oldest file first. Each request waits
+// on the rate limiter. A nil api reports what would be sent, without sending.
+type replayer struct {
+	dir     string
+	api     client.APIClient
+	limiter *client.RateLimiter
+	events  bool
+	keep    bool
+	out     io.Writer
+	now     func() time.Time
+	sleep   func(time.Duration)
+}
+
+func (r *replayer) replay() (*replayReport, error) {
+
+	report := &replayReport{}
+	files, err := spoolFiles(r.dir)
+	if err != nil {
+		return report, err
+	}
+
+	for _, name := range files {
+		path := filepath.Join(r.dir, name)
+		data, err := ioutil.ReadFile(path)
+		if err != nil {
+			return report, fmt.Errorf("could not read spool file, %s", err.Error())
+		}
+		var series []*client.DDMetric
+		if err := json.Unmarshal(data, &series); err != nil {
+			fmt.Fprintf(r.out, "%s: could not decode spool file, %s\n", name, err.Error())
+			report.Failed++
+			continue
+		}
+		report.Files++
+
+		if err := r.replayFile(path, series, report); err != nil {
+			fmt.Fprintf(r.out, "%s: %s\n", name, err.Error())
+			report.Failed++
+			continue
+		}
+		if !r.keep {
+			if err := os.Remove(path); err != nil && !os.IsNotExist(err) {
+				return report, fmt.Errorf("could not delete spool file, %s", err.Error())
+			}
+		}
+	}
+
+	return report, nil
+}
+
+// replayFile sends the points of series that the api still accepts, and reports the
+// points that are too old, sending them as events if enabled. If an event fails after
+// the series were sent, the file at path is rewritten with only the points not yet sent
+// as events, so the series aren't sent again by the next replay.
+func (r *replayer) replayFile(path string, series []*client.DDMetric, report *replayReport) error {
+
+	name := filepath.Base(path)
+	now := r.now()
+	var send []*client.DDMetric
+	var stale []*client.DDMetric
+	for _, m := range series {
+		fresh, old := splitPoints(m, now)
+		if fresh != nil {
+			send = append(send, fresh)
+		}
+		if old != nil {
+			stale = append(stale, old)
+			for _, p := range old.Points {
+				fmt.Fprintf(r.out, "%s: %s %v at %s is too old to accept\n",
+					name, old.Metric, p[1], pointTime(p).UTC().Format(time.RFC3339))
+			}
+			report.TooOld += len(old.Points)
+		}
+	}
+
+	// Metrics the api would reject are reported, and left out of the request
+	if err := client.ValidateSeries(send, now); err != nil {
+		if seriesErr, ok := err.(*client.SeriesError); ok {
+			invalid := map[int]bool{}
+			for _, metricErr := range seriesErr.Metrics {
+				fmt.Fprintf(r.out, "%s: %s\n", name, metricErr.Error())
+				invalid[metricErr.Index] = true
+			}
+			valid := send[:0]
+			for i, m := range send {
+				if !invalid[i] {
+					valid = append(valid, m)
+				}
+			}
+			send = valid
+			report.Invalid += len(invalid)
+		}
+	}
+
+	if len(send) > 0 {
+		if err := r.call(func() error {
+			return r.api.SendSeries(&client.DDMetricSeries{Series: send})
+		}); err != nil {
+			return fmt.Errorf("could not send series, %s", err.Error())
+		}
+		for _, m := range send {
+			report.Sent += len(m.Points)
+		}
+	}
+
+	if !r.events {
+		return nil
+	}
+	for i, m := range stale {
+		for j, p := range m.Points {
+			event := &client.DDEvent{
+				AggregationKey: m.Metric,
+				AlertType:      client.AlertInfo,
+				DateHappened:   pointTime(p).Unix(),
+				Host:           m.Host,
+				Priority:       client.PriorityLow,
+				SourceTypeName: "ddstats",
+				Tags:           m.Tags,
+				Title:          fmt.Sprintf("%s %s %v", m.Type, m.Metric, p[1]),
+			}
+			if err := r.call(func() error { return r.api.SendEvent(event) }); err != nil {
+				unsent := *m
+				unsent.Points = m.Points[j:]
+				if rewriteErr := r.rewrite(path, append([]*client.DDMetric{&unsent}, stale[i+1:]...)); rewriteErr != nil {
+					return fmt.Errorf("could not send event, %s, %s", err.Error(), rewriteErr.Error())
+				}
+				return fmt.Errorf("could not send event, %s", err.Error())
+			}
+			report.Events++
+		}
+	}
+
+	return nil
+}
+
+// rewrite replaces the spool file at path with series, unless files are kept. The new
+// file is written to a temporary file first, which spoolFiles skips, and renamed over path.
+func (r *replayer) rewrite(path string, series []*client.DDMetric) error {
+
+	if r.keep {
+		return nil
+	}
+	data, err := json.Marshal(series)
+	if err != nil {
+		return fmt.Errorf("could not encode spool file, %s", err.Error())
+	}
+	tmp := filepath.Join(filepath.Dir(path), "."+filepath.Base(path))
+	if err := ioutil.WriteFile(tmp, data, 0644); err != nil {
+		return fmt.Errorf("could not rewrite spool file, %s", err.Error())
+	}
+	if err := os.Rename(tmp, path); err != nil {
+		_ = os.Remove(tmp)
+		return fmt.Errorf("could not rewrite spool file, %s", err.Error())
+	}
+
+	return nil
+}
+
+// call waits on the rate limiter, and calls send, unless this is a dry run.
+func (r *replayer) call(send func() error) error {
+	if r.api == nil {
+		return nil
+	}
+	if wait := r.limiter.Reserve(); wait > 0 {
+		r.sleep(wait)
+	}
+	return send()
+}
+
+// splitPoints splits the points of m into a copy with the points the api accepts at now,
+// and a copy with the points that are too old. A copy is nil if it has no points. Points
+// with an invalid timestamp are kept with the accepted points, so validation reports them.
+func splitPoints(m *client.DDMetric, now time.Time) (fresh, old *client.DDMetric) {
+
+	oldest := now.Add(-client.MaxPointAge)
+	for _, p := range m.Points {
+		dst := &fresh
+		if _, ok := p[0].(float64); ok && pointTime(p).Before(oldest) {
+			dst = &old
+		}
+		if *dst == nil {
+			c := *m
+			c.Points = nil
+			*dst = &c
+		}
+		(*dst).Points = append((*dst).Points, p)
+	}
+
+	return fresh, old
+}
+
+// pointTime returns the timestamp of a decoded point.
+func pointTime(p [2]interface{}) time.Time {
+	ts, _ := p[0].(float64)
+	return time.Unix(int64(ts), 0)
+}
+
+// spoolFiles returns the names of the spool files in dir, oldest first. Files are named
+// with the time they were spooled, temporary files start with a dot.
+func spoolFiles(dir string) ([]string, error) {
+
+	entries, err := ioutil.ReadDir(dir)
+	if err != nil {
+		return nil, fmt.Errorf("could not read spool directory, %s", err.Error())
+	}
+
+	var files []string
+	for _, f := range entries {
+		if f.Mode().IsRegular() && !strings.HasPrefix(f.Name(), ".") && strings.HasSuffix(f.Name(), ".json") {
+			files = append(files, f.Name())
+		}
+	}
+	sort.Strings(files)
+
+	return files, nil
+}
@@ -0,0 +1,183 @@
+package main
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/jmizell/ddstats/client"
+)
+
+type testAPIClient struct {
+	series   []*client.DDMetric
+	events   []*client.DDEvent
+	err      error
+	eventErr error
+}
+
+func (c *testAPIClient) SendSeries(series *client.DDMetricSeries) error {
+	if c.err != nil {
+		return c.err
+	}
+	c.series = append(c.series, series.Series...)
+	return nil
+}
+
+func (c *testAPIClient) SendServiceCheck(*client.DDServiceCheck) error {
+	return nil
+}
+
+func (c *testAPIClient) SendEvent(event *client.DDEvent) error {
+	if c.eventErr != nil {
+		return c.eventErr
+	}
+	c.events = append(c.events, event)
+	return nil
+}
+
+func (c *testAPIClient) SetHTTPClient(client.HTTPClient) {}
+
+func writeSpoolFile(tt *testing.T, dir, name string, series []*client.DDMetric) {
+	data, err := json.Marshal(series)
+	if err != nil {
+		tt.Fatalf(err.Error())
+	}
+	if err := ioutil.WriteFile(filepath.Join(dir, name), data, 0644); err != nil {
+		tt.Fatalf(err.Error())
+	}
+}
+
+func TestReplayer_Replay(t *testing.T) {
+
+	now := time.Unix(1600000000, 0)
+	fresh := float64(now.Add(-time.Minute).Unix())
+	old := float64(now.Add(-time.Hour * 2).Unix())
+
+	newReplayer := func(tt *testing.T, api client.APIClient, events bool) (*replayer, *bytes.Buffer) {
+		dir, err := ioutil.TempDir("", "ddstats-replay")
+		if err != nil {
+			tt.Fatalf(err.Error())
+		}
+		tt.Cleanup(func() { _ = os.RemoveAll(dir) })
+		out := &bytes.Buffer{}
+		return &replayer{
+			dir:     dir,
+			api:     api,
+			limiter: client.NewRateLimiter(0, 1),
+			events:  events,
+			out:     out,
+			now:     func() time.Time { return now },
+			sleep:   func(time.Duration) {},
+		}, out
+	}
+
+	t.Run("too old points", func(tt *testing.T) {
+		api := &testAPIClient{}
+		r, out := newReplayer(tt, api, true)
+		writeSpoolFile(tt, r.dir, fmt.Sprintf("%020d-%06d.json", now.UnixNano(), 1), []*client.DDMetric{
+			{Metric: "app.requests", Type: client.Count, Tags: []string{"path:/login"},
+				Points: [][2]interface{}{{old, 2}, {fresh, 3}}},
+			{Metric: "1invalid", Type: client.Gauge, Points: [][2]interface{}{{fresh, 1}}},
+		})
+		writeSpoolFile(tt, r.dir, ".temporary.json", []*client.DDMetric{
+			{Metric: "app.temporary", Type: client.Gauge, Points: [][2]interface{}{{fresh, 1}}},
+		})
+
+		report, err := r.replay()
+		if err != nil {
+			tt.Fatalf(err.Error())
+		}
+		if report.Files != 1 || report.Sent != 1 || report.TooOld != 1 || report.Events != 1 || report.Invalid != 1 || report.Failed != 0 {
+			tt.Fatalf("unexpected report %s", report)
+		}
+		if len(api.series) != 1 || api.series[0].Metric != "app.requests" || len(api.series[0].Points) != 1 || api.series[0].Points[0][0] != fresh {
+			tt.Fatalf("expected the fresh app.requests point to be sent, have %+v", api.series)
+		}
+		if len(api.events) != 1 || api.events[0].DateHappened != int64(old) || api.events[0].Title != "count app.requests 2" {
+			tt.Fatalf("expected the old point as an event, have %+v", api.events)
+		}
+		if !strings.Contains(out.String(), "app.requests 2 at") || !strings.Contains(out.String(), "1invalid") {
+			tt.Fatalf("expected the old point, and invalid metric to be reported, have %q", out.String())
+		}
+		if files, _ := spoolFiles(r.dir); len(files) != 0 {
+			tt.Fatalf("expected the replayed file to be deleted, have %v", files)
+		}
+	})
+
+	t.Run("send failure keeps file", func(tt *testing.T) {
+		api := &testAPIClient{err: fmt.Errorf("unavailable")}
+		r, _ := newReplayer(tt, api, false)
+		writeSpoolFile(tt, r.dir, fmt.Sprintf("%020d-%06d.json", now.UnixNano(), 1), []*client.DDMetric{
+			{Metric: "app.requests", Type: client.Count, Points: [][2]interface{}{{fresh, 1}}},
+		})
+
+		report, err := r.replay()
+		if err != nil {
+			tt.Fatalf(err.Error())
+		}
+		if report.Failed != 1 || report.Sent != 0 {
+			tt.Fatalf("unexpected report %s", report)
+		}
+		if files, _ := spoolFiles(r.dir); len(files) != 1 {
+			tt.Fatalf("expected the failed file to be kept, have %v", files)
+		}
+	})
+
+	t.Run("event failure keeps only unsent points", func(tt *testing.T) {
+		api := &testAPIClient{eventErr: fmt.Errorf("unavailable")}
+		r, _ := newReplayer(tt, api, true)
+		writeSpoolFile(tt, r.dir, fmt.Sprintf("%020d-%06d.json", now.UnixNano(), 1), []*client.DDMetric{
+			{Metric: "app.requests", Type: client.Count, Points: [][2]interface{}{{old, 1}, {fresh, 1}}},
+			{Metric: "app.errors", Type: client.Count, Points: [][2]interface{}{{old, 1}}},
+		})
+
+		report, err := r.replay()
+		if err != nil {
+			tt.Fatalf(err.Error())
+		}
+		if report.Failed != 1 || report.Sent != 1 || report.Events != 0 {
+			tt.Fatalf("unexpected report %s", report)
+		}
+
+		// The series were sent, replaying again only sends the events
+		api.eventErr = nil
+		report, err = r.replay()
+		if err != nil {
+			tt.Fatalf(err.Error())
+		}
+		if report.Failed != 0 || report.Sent != 0 || report.Events != 2 {
+			tt.Fatalf("unexpected report %s", report)
+		}
+		if len(api.series) != 1 {
+			tt.Fatalf("expected the series to be sent once, have %+v", api.series)
+		}
+		if files, _ := spoolFiles(r.dir); len(files) != 0 {
+			tt.Fatalf("expected the replayed file to be deleted, have %v", files)
+		}
+	})
+
+	t.Run("dry run", func(tt *testing.T) {
+		r, _ := newReplayer(tt, nil, true)
+		r.keep = true
+		writeSpoolFile(tt, r.dir, fmt.Sprintf("%020d-%06d.json", now.UnixNano(), 1), []*client.DDMetric{
+			{Metric: "app.requests", Type: client.Count, Points: [][2]interface{}{{old, 1}, {fresh, 1}}},
+		})
+
+		report, err := r.replay()
+		if err != nil {
+			tt.Fatalf(err.Error())
+		}
+		if report.Sent != 1 || report.TooOld != 1 || report.Events != 1 {
+			tt.Fatalf("unexpected report %s", report)
+		}
+		if files, _ := spoolFiles(r.dir); len(files) != 1 {
+			tt.Fatalf("expected a dry run to keep files, have %v", files)
+		}
+	})
+}
@@ -1,9 +1,10 @@
 package ddstats
 
 import (
+	"crypto/tls"
+	"net/http"
 	"os"
 	"strconv"
-	"strings"
 	"time"
 
 	"github.com/jmizell/ddstats/client"
@@ -16,6 +17,77 @@ const (
 	DefaultFlushInterval = time.Second * 60
 	DefaultMaxErrorCount = 100
 	DefaultNamespace     = "ddstats"
+
+	// DefaultStartFlushInterval is the length of the first flush interval, when flush on
+	// start is enabled.
+	DefaultStartFlushInterval = time.Second
+)
+
+// NamespaceMode controls how the namespace is prepended to metric names
+type NamespaceMode string
+
+// Namespace modes
+const (
+	// NamespaceModePrefix skips prepending the namespace to any name that starts with the
+	// namespace. This is the default, and original behavior. Namespace "app" will not be
+	// prepended to "apples.count".
+	NamespaceModePrefix = NamespaceMode("prefix")
+
+	// NamespaceModeBoundary skips prepending the namespace only to names that equal the
+	// namespace, or start with the namespace followed by a dot. Namespace "app" will be
+	// prepended to "apples.count", but not "app.count".
+	NamespaceModeBoundary = NamespaceMode("boundary")
+
+	// NamespaceModeStrict always prepends the namespace.
+	NamespaceModeStrict = NamespaceMode("strict")
+)
+
+// NegativeCountPolicy controls how count, and rate metrics with a negative value for a
+// flush interval are handled.
+type NegativeCountPolicy string
+
+// Negative count policies
+const (
+	// NegativeCountAllow sends negative counts as is. This is the default.
+	NegativeCountAllow = NegativeCountPolicy("")
+
+	// NegativeCountClamp sends negative counts as zero, and reports them to the negative
+	// count callback.
+	NegativeCountClamp = NegativeCountPolicy("clamp")
+
+	// NegativeCountReport sends negative counts as is, and reports them to the negative
+	// count callback.
+	NegativeCountReport = NegativeCountPolicy("report")
+)
+
+// GaugeAggregation controls how multiple updates to a gauge in a flush interval are
+// aggregated.
+type GaugeAggregation string
+
+// Gauge aggregations
+const (
+	// GaugeLast sends the last value submitted. Updates are ordered by a sequence number
+	// assigned when the update is submitted, so the result doesn't depend on worker
+	// scheduling. This is the default.
+	GaugeLast = GaugeAggregation("")
+
+	// GaugeMax sends the largest value submitted.
+	GaugeMax = GaugeAggregation("max")
+
+	// GaugeMin sends the smallest value submitted.
+	GaugeMin = GaugeAggregation("min")
+)
+
+// DurationUnit is the unit durations are reported in by GaugeDuration.
+type DurationUnit string
+
+// Duration units
+const (
+	// DurationSeconds reports durations in seconds. This is the default.
+	DurationSeconds = DurationUnit("seconds")
+
+	// DurationMilliseconds reports durations in milliseconds.
+	DurationMilliseconds = DurationUnit("milliseconds")
 )
 
 // Config environment variables
@@ -29,6 +101,13 @@ const (
 	EnvHost                 = "DDSTATS_HOST"
 	EnvTags                 = "DDSTATS_TAGS"
 	EnvAPIKey               = "DDSTATS_API_KEY"
+	EnvExpectedMetrics      = "DDSTATS_EXPECTED_METRICS"
+	EnvMaxFlushInterval     = "DDSTATS_MAX_FLUSH_INTERVAL"
+	EnvNamespaceMode        = "DDSTATS_NAMESPACE_MODE"
+	EnvMissingAPIKey        = "DDSTATS_MISSING_API_KEY"
+	EnvSite                 = "DDSTATS_SITE"
+	EnvAPIBaseURL           = "DDSTATS_API_URL"
+	EnvDisabled             = "DDSTATS_DISABLED"
 )
 
 // Config is required to create an new stats object. A config object can be manually created,
@@ -39,17 +118,95 @@ const (
 // An API client is required in order to use stats. Either the API key must be set, or an
 // API client can be manually created, and added to the config using WithClient.
 type Config struct {
-	Namespace            string   `json:"namespace"`      // Namespace is prepended to the name of every metric
-	Host                 string   `json:"host"`           // Host to apply to every metric
-	Tags                 []string `json:"tags"`           // A global list of tags to append to metrics
-	APIKey               string   `json:"api_key"`        // Datadog API key
-	FlushIntervalSeconds float64  `json:"flush_interval"` // Interval in seconds to send metrics to Datadog
-	WorkerCount          int      `json:"worker_count"`   // Number of workers to process metrics updates
-	WorkerBuffer         int      `json:"worker_buffer"`  // Buffer capacity for worker queue
-	MetricBuffer         int      `json:"metric_buffer"`  // Global buffer capacity for new metrics not yet assigned a worker
-	MaxErrors            int      `json:"max_errors"`     // Max number of flush errors to store
+	Disabled                bool                `json:"disabled"`               // Discard all metrics, stats is a noop, see NewNoop
+	Namespace               string              `json:"namespace"`              // Namespace is prepended to the name of every metric
+	NamespaceMode           NamespaceMode       `json:"namespace_mode"`         // Controls when the namespace is prepended, defaults to prefix
+	Host                    string              `json:"host"`                   // Host to apply to every metric
+	CloudHostDetection      bool                `json:"cloud_host_detection"`   // Detect the cloud instance from the EC2, GCE, or Azure metadata endpoints
+	ContainerTags           bool                `json:"container_tags"`         // Tag metrics with the container, and Kubernetes pod they're sent from
+	Tags                    []string            `json:"tags"`                   // A global list of tags to append to metrics
+	APIKey                  string              `json:"api_key"`                // Datadog API key
+	Site                    string              `json:"site"`                   // Datadog site, such as datadoghq.eu, defaults to datadoghq.com
+	APIBaseURL              string              `json:"api_base_url"`           // Base url of the api, overrides the site
+	APIKeyHeader            bool                `json:"api_key_header"`         // Send the api key in the DD-API-KEY header, instead of the query string
+	HTTPProxy               string              `json:"http_proxy"`             // Proxy url, overrides the proxy environment variables
+	TLSCAFile               string              `json:"tls_ca_file"`            // PEM file of CA certificates trusted in addition to the system pool
+	TLSInsecureSkipVerify   bool                `json:"tls_insecure"`           // Skip verifying the api certificate, only for test gateways
+	HTTPTimeoutSeconds      float64             `json:"http_timeout"`           // Max time in seconds for an api request, zero is unlimited
+	DialTimeoutSeconds      float64             `json:"dial_timeout"`           // Max time in seconds to connect to the api
+	KeepAliveSeconds        float64             `json:"keep_alive"`             // TCP keep alive period in seconds, negative disables keep alive probes
+	IdleConnTimeoutSeconds  float64             `json:"idle_conn_timeout"`      // Time in seconds an idle connection is kept open
+	MaxIdleConns            int                 `json:"max_idle_conns"`         // Max idle connections kept open
+	DisableKeepAlives       bool                `json:"disable_keep_alives"`    // Use a new connection for each api request
+	Compression             client.Compression  `json:"compression"`            // Series payload compression, deflate, or gzip, disabled by default
+	CompressionLevel        int                 `json:"compression_level"`      // Compression level, zero uses the default level
+	CompressionThreshold    int                 `json:"compression_threshold"`  // Min payload size in bytes to compress
+	MaxSeriesPerRequest     int                 `json:"max_series_per_request"` // Max metrics in a series request, larger series are split
+	MaxPayloadBytes         int                 `json:"max_payload_bytes"`      // Max uncompressed size of a series request, larger series are split
+	FlushIntervalSeconds    float64             `json:"flush_interval"`         // Interval in seconds to send metrics to Datadog
+	MaxFlushIntervalSeconds float64             `json:"max_flush_interval"`     // Max interval in seconds the flush interval can be widened to on errors
+	DownsampleSeconds       float64             `json:"downsample"`             // Window in seconds to sub-aggregate metrics into, for long flush intervals
+	NegativeCounts          NegativeCountPolicy `json:"negative_counts"`        // Handling of counts that are negative for a flush interval
+	WorkerCount             int                 `json:"worker_count"`           // Number of workers to process metrics updates
+	WorkerBuffer            int                 `json:"worker_buffer"`          // Buffer capacity for worker queue
+	MetricBuffer            int                 `json:"metric_buffer"`          // Global buffer capacity for new metrics not yet assigned a worker
+	MaxErrors               int                 `json:"max_errors"`             // Max number of flush errors to store, limited to MaxErrorsLimit
+	DedupErrors             bool                `json:"dedup_errors"`           // Store identical consecutive errors once, with a repeat count
+	ExpectedMetrics         int                 `json:"expected_metrics"`       // Expected number of unique metrics per flush interval, used to pre-size maps
+	RuntimeTags             bool                `json:"runtime_tags"`           // Tag runtime collector metrics with Go version, and platform facts
+	RuntimeMetricsPrefix    string              `json:"runtime_metrics_prefix"` // Prefix of runtime metrics, defaults to runtime.go
+	ProcessMetrics          bool                `json:"process_metrics"`        // Report process cpu, memory, file descriptor, and thread metrics on each flush
+	ClockSkewMetric         bool                `json:"clock_skew_metric"`      // Report the estimated clock skew with Datadog as a gauge on each flush
+	StrictTags              bool                `json:"strict_tags"`            // Fail NewStats if any global tag has a problem
+	StrictSeries            bool                `json:"strict_series"`          // Validate series passed to SendSeries, and QueueSeries, and reject invalid series
+	DebugCaptureDir         string              `json:"debug_capture_dir"`      // Directory to write api requests, and responses to for debugging
+	DebugCaptureMax         int                 `json:"debug_capture_max"`      // Number of api requests to capture
+	MirrorFile              string              `json:"mirror_file"`            // Path of a JSONL file every flushed series is appended to
+	MirrorMaxBytes          int64               `json:"mirror_max_bytes"`       // Size in bytes the mirror file is rotated at
+	MirrorMaxAgeSeconds     float64             `json:"mirror_max_age"`         // Age in seconds the mirror file is rotated at
+	MirrorMaxBackups        int                 `json:"mirror_max_backups"`     // Number of rotated mirror files to keep, defaults to DefaultMirrorMaxBackups
+	TenantTagKey            string              `json:"tenant_tag_key"`         // Tag key identifying the tenant of a metric, defaults to tenant
+	TenantMaxSeries         int                 `json:"tenant_max_series"`      // Max distinct series per tenant per flush interval, zero is unlimited
+	TenantMaxPoints         int                 `json:"tenant_max_points"`      // Max submissions per tenant per flush interval, zero is unlimited
+	DurationUnit            DurationUnit        `json:"duration_unit"`          // Unit durations are reported in, defaults to seconds
+	ErrorCallbackBuffer     int                 `json:"error_callback_buffer"`  // Number of errors that can be waiting for the error callback
+	FlushErrorBuffer        int                 `json:"flush_error_buffer"`     // Number of errors that can be waiting in the errors channel
+	HealthFailureThreshold  int                 `json:"health_threshold"`       // Consecutive failed flushes at which HealthHandler responds with 503
+	KeptValueExpiry         int                 `json:"kept_value_expiry"`      // Flush intervals without an update after which a value kept across intervals is forgotten
+	FlushOnStart            bool                `json:"flush_on_start"`         // Shorten the first flush interval, so data is sent shortly after startup
+	BlockOnFull             bool                `json:"block_on_full"`          // Block submissions while the metric queue is full, instead of dropping them
+	BlockTimeoutSeconds     float64             `json:"block_timeout"`          // Max time in seconds a submission blocks, zero blocks until queued
+	ShutdownTimeoutSeconds  float64             `json:"shutdown_timeout"`       // Max time in seconds Close waits for api sends, zero is unlimited
+	DroppedMetric           bool                `json:"dropped_metric"`         // Report dropped metrics by name as a count on each flush
+	ClientTelemetry         bool                `json:"client_telemetry"`       // Report the library's own telemetry under ddstats.client on each flush
+	SourceTags              bool                `json:"source_tags"`            // Tag each metric with the source file, and line it was submitted from
+	GaugeAggregation        GaugeAggregation    `json:"gauge_aggregation"`      // How multiple updates to a gauge in a flush interval are aggregated
+	HistogramAggregates     []string            `json:"histogram_aggregates"`   // Aggregates sent for each histogram, defaults to max, median, avg, count, and min
+	HistogramPercentiles    []float64           `json:"histogram_percentiles"`  // Percentiles sent for each histogram, defaults to 0.95
+	FlushIntervals          map[string]float64  `json:"flush_intervals"`        // Flush interval in seconds of metric names flushed at a different interval
+	MaxTagLength            int                 `json:"max_tag_length"`         // Max length of a tag in bytes, defaults to 200
+	TagLengthPolicy         TagLengthPolicy     `json:"tag_length_policy"`      // Handling of tags longer than the max tag length
+	TagNormalization        bool                `json:"tag_normalization"`      // Sanitize tags, and remove duplicate keys, see SanitizeTags
+	MetricNamePolicy        MetricNamePolicy    `json:"metric_name_policy"`     // Handling of metric names Datadog would reject
+	SpoolDir                string              `json:"spool_dir"`              // Directory to store the series of failed flushes in, for replay
+	SpoolMaxBytes           int64               `json:"spool_max_bytes"`        // Max size in bytes of the spool directory
+	SpoolMaxAgeSeconds      float64             `json:"spool_max_age"`          // Age in seconds spooled series are discarded at
+	MissingAPIKey           MissingAPIKeyMode   `json:"missing_api_key"`        // Handling of a missing api key, defaults to failing NewStats
+	MissingAPIKeyBuffer     int                 `json:"missing_api_key_buffer"` // Max metrics buffered while the api key is missing
+	RateLimit               float64             `json:"rate_limit"`             // Max SendSeries, ServiceCheck, and Event calls per second, zero is unlimited
+	RateLimitBurst          int                 `json:"rate_limit_burst"`       // Number of calls allowed in a burst above the rate limit
+	RateLimitMode           RateLimitMode       `json:"rate_limit_mode"`        // Handling of calls exceeding the rate limit, defaults to an error
+	RateLimitQueue          int                 `json:"rate_limit_queue"`       // Max calls waiting for the rate limit in queue mode
+	Prometheus              bool                `json:"prometheus"`             // Expose the aggregated metrics with PrometheusHandler
+	PrometheusBuckets       []float64           `json:"prometheus_buckets"`     // Upper bounds of the Prometheus histogram buckets
 
-	client client.APIClient
+	client       client.APIClient
+	spool        SpoolStore
+	faults       FaultInjector
+	logger       Logger
+	clock        Clock
+	roundTripper http.RoundTripper
+	tlsConfig    *tls.Config
 }
 
 // NewConfig creates a new config with default values. The host value is
@@ -65,6 +222,16 @@ func NewConfig() *Config {
 		WorkerBuffer:         DefaultWorkerBuffer,
 		MetricBuffer:         DefaultWorkerBuffer * DefaultWorkerCount,
 		MaxErrors:            DefaultMaxErrorCount,
+		DebugCaptureMax:      client.DefaultDebugCaptureMax,
+		MirrorMaxBytes:       DefaultMirrorMaxBytes,
+		MirrorMaxBackups:     DefaultMirrorMaxBackups,
+		ErrorCallbackBuffer:  DefaultErrorCallbackBuffer,
+		FlushErrorBuffer:     DefaultFlushErrorBuffer,
+		MaxTagLength:         DefaultMaxTagLength,
+		SpoolMaxBytes:        DefaultSpoolMaxBytes,
+		SpoolMaxAgeSeconds:   DefaultSpoolMaxAge.Seconds(),
+		MissingAPIKeyBuffer:  DefaultMissingAPIKeyBuffer,
+		CompressionThreshold: client.DefaultCompressionThreshold,
 	}
 }
 
@@ -74,22 +241,37 @@ func NewConfig() *Config {
 //
 // Supported variables
 //
-// DDSTATS_WORKER_COUNT, DDSTATS_WORKER_BUFFER, DDSTATS_METRIC_BUFFER DDSTATS_FLUSH_INTERVAL,
-// DDSTATS_MAX_ERROR_COUNT, DDSTATS_NAMESPACE, DDSTATS_HOST, DDSTATS_TAGS, DDSTATS_API_KEY
+// DDSTATS_WORKER_COUNT, DDSTATS_WORKER_BUFFER, DDSTATS_METRIC_BUFFER, DDSTATS_FLUSH_INTERVAL,
+// DDSTATS_MAX_ERROR_COUNT, DDSTATS_NAMESPACE, DDSTATS_HOST, DDSTATS_TAGS, DDSTATS_API_KEY,
+// DDSTATS_EXPECTED_METRICS, DDSTATS_MAX_FLUSH_INTERVAL, DDSTATS_NAMESPACE_MODE,
+// DDSTATS_MISSING_API_KEY, DDSTATS_SITE, DDSTATS_API_URL, DDSTATS_DISABLED
 //
 func (c *Config) FromEnv() *Config {
 
 	loadEnvString(&c.Namespace, EnvNamespace)
+	if mode := os.Getenv(EnvNamespaceMode); mode != "" {
+		c.NamespaceMode = NamespaceMode(mode)
+	}
 	loadEnvString(&c.Host, EnvHost)
 	loadEnvString(&c.APIKey, EnvAPIKey)
+	loadEnvString(&c.Site, EnvSite)
+	loadEnvString(&c.APIBaseURL, EnvAPIBaseURL)
+	if mode := os.Getenv(EnvMissingAPIKey); mode != "" {
+		c.MissingAPIKey = MissingAPIKeyMode(mode)
+	}
 	loadEnvFloat64(&c.FlushIntervalSeconds, EnvFlushIntervalSeconds)
+	loadEnvFloat64(&c.MaxFlushIntervalSeconds, EnvMaxFlushInterval)
 	loadEnvInt(&c.WorkerCount, EnvWorkerCount)
 	loadEnvInt(&c.WorkerBuffer, EnvWorkerBuffer)
 	loadEnvInt(&c.MetricBuffer, EnvMetricBuffer)
 	loadEnvInt(&c.MaxErrors, EnvMaxErrorCount)
+	loadEnvInt(&c.ExpectedMetrics, EnvExpectedMetrics)
+	if disabled, err := strconv.ParseBool(os.Getenv(EnvDisabled)); err == nil {
+		c.Disabled = disabled
+	}
 
 	if tags := os.Getenv(EnvTags); tags != "" {
-		c.Tags = strings.Split(tags, ",")
+		c.Tags, _ = ParseTags(tags)
 	}
 
 	return c
@@ -101,6 +283,148 @@ func (c *Config) WithHost(Host string) *Config {
 	return c
 }
 
+// WithCloudHostDetection sets whether NewStats queries the EC2, GCE, and Azure metadata
+// endpoints for the instance the process is running on, waiting for up to
+// DefaultCloudDetectionTimeout. The cloud_provider, and instance_id tags are added to
+// the global tags. As the Datadog Agent does, an empty host is replaced by the instance
+// hostname, or id, and a default EC2 hostname, such as ip-10-0-0-1, is replaced by the
+// instance id.
+func (c *Config) WithCloudHostDetection(enabled bool) *Config {
+	c.CloudHostDetection = enabled
+	return c
+}
+
+// WithContainerTags sets whether NewStats detects the container, and Kubernetes pod the
+// process is running in, and adds the container_id, pod_name, kube_namespace, and
+// kube_node tags to the global tags. The container id is read from the process cgroup,
+// and the pod from the POD_NAME, POD_NAMESPACE, and NODE_NAME environment variables,
+// set with the downward api. If the api client has a SetContainerID(string) method,
+// such as client.DogStatsDClient, it's passed the container id, so the Agent can do its
+// own origin detection.
+func (c *Config) WithContainerTags(enabled bool) *Config {
+	c.ContainerTags = enabled
+	return c
+}
+
+// WithSite sets the Datadog site metrics are sent to, such as datadoghq.eu, or
+// us3.datadoghq.com. The site is only used when the api client is created from the
+// api key.
+func (c *Config) WithSite(site string) *Config {
+	c.Site = site
+	return c
+}
+
+// WithAPIBaseURL sets the base url of the api, including the version path, for example
+// https://api.datadoghq.eu/api/v1. This allows metrics to be sent through a proxy, or
+// to a mock of the api, and takes precedence over the site. The base url is only used
+// when the api client is created from the api key.
+func (c *Config) WithAPIBaseURL(baseURL string) *Config {
+	c.APIBaseURL = baseURL
+	return c
+}
+
+// WithAPIKeyHeader sets whether the api key is sent in the DD-API-KEY request header,
+// instead of the api_key query parameter, so the key isn't recorded in proxy, and access
+// logs. This is only used when the api client is created from the api key.
+func (c *Config) WithAPIKeyHeader(enabled bool) *Config {
+	c.APIKeyHeader = enabled
+	return c
+}
+
+// WithHTTPProxy sets the proxy url api requests are sent through, in place of the proxy
+// environment variables. The no proxy list is still loaded from the environment.
+func (c *Config) WithHTTPProxy(proxyURL string) *Config {
+	c.HTTPProxy = proxyURL
+	return c
+}
+
+// WithRoundTripper sets the transport used for api requests. When set, the proxy, tls,
+// and connection settings are ignored, and must be configured on the transport.
+func (c *Config) WithRoundTripper(rt http.RoundTripper) *Config {
+	c.roundTripper = rt
+	return c
+}
+
+// WithTLSConfig sets the tls config used for api requests. The ca file, and insecure skip
+// verify settings are applied to a copy of it.
+func (c *Config) WithTLSConfig(tlsConfig *tls.Config) *Config {
+	c.tlsConfig = tlsConfig
+	return c
+}
+
+// WithCAFile adds the certificates in the PEM file path to the trusted certificates, for
+// gateways using a private certificate authority.
+func (c *Config) WithCAFile(path string) *Config {
+	c.TLSCAFile = path
+	return c
+}
+
+// WithInsecureSkipVerify disables verifying the api certificate. This should only be used
+// with test gateways.
+func (c *Config) WithInsecureSkipVerify(insecure bool) *Config {
+	c.TLSInsecureSkipVerify = insecure
+	return c
+}
+
+// WithHTTPTimeouts sets the max time for an api request, including reading the response,
+// and the max time to connect. Zero keeps the default, which for requests is unlimited.
+func (c *Config) WithHTTPTimeouts(request, dial time.Duration) *Config {
+	c.HTTPTimeoutSeconds = request.Seconds()
+	c.DialTimeoutSeconds = dial.Seconds()
+	return c
+}
+
+// WithKeepAlive sets the tcp keep alive period, the time idle connections are kept open,
+// and the max number of idle connections. Zero keeps the default, a negative period
+// disables keep alive probes. Set disabled to use a new connection for each request.
+func (c *Config) WithKeepAlive(period, idleTimeout time.Duration, maxIdle int, disabled bool) *Config {
+	c.KeepAliveSeconds = period.Seconds()
+	c.IdleConnTimeoutSeconds = idleTimeout.Seconds()
+	c.MaxIdleConns = maxIdle
+	c.DisableKeepAlives = disabled
+	return c
+}
+
+// WithCompression enables compressing series payloads of at least threshold bytes, with
+// client.CompressionDeflate, or client.CompressionGzip, at level. Level is one of the
+// compress/flate levels, zero uses the default level. This is only used when the api
+// client is created from the api key.
+func (c *Config) WithCompression(encoding client.Compression, level, threshold int) *Config {
+	c.Compression = encoding
+	c.CompressionLevel = level
+	c.CompressionThreshold = threshold
+	return c
+}
+
+// WithChunking sets the max number of metrics, and the max uncompressed payload size in
+// bytes of a single series request. Larger flushes are split into multiple requests, and
+// if only some fail, only their series are spooled, and passed to the error callback.
+// Zero uses the defaults, client.DefaultMaxSeriesPerRequest, and
+// client.DefaultMaxPayloadBytes. This is only used when the api client is created from
+// the api key.
+func (c *Config) WithChunking(maxSeries, maxBytes int) *Config {
+	c.MaxSeriesPerRequest = maxSeries
+	c.MaxPayloadBytes = maxBytes
+	return c
+}
+
+// transportConfig returns the http settings of the api client.
+func (c *Config) transportConfig() client.TransportConfig {
+	return client.TransportConfig{
+		ProxyURL:           c.HTTPProxy,
+		RoundTripper:       c.roundTripper,
+		TLSConfig:          c.tlsConfig,
+		CAFile:             c.TLSCAFile,
+		InsecureSkipVerify: c.TLSInsecureSkipVerify,
+		Timeout:            time.Duration(c.HTTPTimeoutSeconds * float64(time.Second)),
+		DialTimeout:        time.Duration(c.DialTimeoutSeconds * float64(time.Second)),
+		KeepAlive:          time.Duration(c.KeepAliveSeconds * float64(time.Second)),
+		IdleConnTimeout:    time.Duration(c.IdleConnTimeoutSeconds * float64(time.Second)),
+		MaxIdleConns:       c.MaxIdleConns,
+		DisableKeepAlives:  c.DisableKeepAlives,
+	}
+}
+
 // WithAPIKey set the api key. Instructions on how to acquire an api key
 // can be found here https://docs.datadoghq.com/account_management/api-app-keys/
 // If a client has been set with WithClient, then api key will be ignored.
@@ -115,12 +439,377 @@ func (c *Config) WithNamespace(Namespace string) *Config {
 	return c
 }
 
+// WithNamespaceMode sets how the namespace is prepended to metric names. Changing the mode
+// from the default NamespaceModePrefix can change the names of emitted metrics, see the
+// NamespaceMode values.
+func (c *Config) WithNamespaceMode(mode NamespaceMode) *Config {
+	c.NamespaceMode = mode
+	return c
+}
+
 // WithTags set global slice of tags
 func (c *Config) WithTags(tags []string) *Config {
 	c.Tags = tags
 	return c
 }
 
+// WithExpectedMetricCount sets a hint for the number of unique metrics, by name and tags,
+// expected in each flush interval. The metric maps are pre-sized with this value, which
+// avoids growing the maps during steady state operation.
+func (c *Config) WithExpectedMetricCount(n int) *Config {
+	c.ExpectedMetrics = n
+	return c
+}
+
+// WithAdaptiveFlushInterval enables widening of the flush interval while flushes are
+// failing. Each consecutive failed flush doubles the interval, up to max, aggregating
+// metrics for longer client side to reduce pressure on the api during an incident. Each
+// successful flush halves the interval, until it's back to the configured interval.
+// Count, and rate metrics are interval aware, so no data is lost by widening.
+func (c *Config) WithAdaptiveFlushInterval(max time.Duration) *Config {
+	c.MaxFlushIntervalSeconds = max.Seconds()
+	return c
+}
+
+// WithMirrorFile enables appending every flushed series, and the api result, to a local
+// JSONL file at path. The file is rotated when it exceeds maxBytes, or is older than
+// maxAge, a zero value disables that rotation trigger. Rotated files are renamed with a
+// timestamp suffix, and only the most recent MirrorMaxBackups files are kept.
+func (c *Config) WithMirrorFile(path string, maxBytes int64, maxAge time.Duration) *Config {
+	c.MirrorFile = path
+	c.MirrorMaxBytes = maxBytes
+	c.MirrorMaxAgeSeconds = maxAge.Seconds()
+	return c
+}
+
+// WithDownsampling enables sub-aggregation of metrics into windows within each flush
+// interval. Each metric is sent with one timestamped point per window that received an
+// update, instead of a single point per flush. This is intended for long flush intervals,
+// five minutes or more, where a single point per flush shows up as a spike on graphs.
+// Windows are aligned to multiples of window since the unix epoch, and are whole seconds.
+func (c *Config) WithDownsampling(window time.Duration) *Config {
+	c.DownsampleSeconds = window.Seconds()
+	return c
+}
+
+// WithDebugCapture enables writing the body of each api request, and response to files in
+// dir, for the first DebugCaptureMax requests. The api key is masked in captured files.
+// This is only supported by api clients with a SetDebugCapture(dir string, max int) method,
+// such as the default Datadog client.
+func (c *Config) WithDebugCapture(dir string) *Config {
+	c.DebugCaptureDir = dir
+	return c
+}
+
+// WithNegativeCountPolicy sets how count, and rate metrics that are negative for a flush
+// interval are handled. Negative per interval counts are usually an instrumentation bug,
+// such as a missing Increment for a Decrement. Register a callback to be notified of
+// negative counts with Stats.NegativeCountCallback.
+func (c *Config) WithNegativeCountPolicy(policy NegativeCountPolicy) *Config {
+	c.NegativeCounts = policy
+	return c
+}
+
+// WithRuntimeTags enables tagging of runtime collector metrics with go_version, goos,
+// goarch, and num_cpu tags. Only metrics from the runtime collectors are tagged.
+func (c *Config) WithRuntimeTags(enabled bool) *Config {
+	c.RuntimeTags = enabled
+	return c
+}
+
+// WithStrictTags enables strict validation of the global tags. Global tags are always
+// normalized with NormalizeTags, in strict mode NewStats returns an error if normalization
+// reports any problems.
+func (c *Config) WithStrictTags(strict bool) *Config {
+	c.StrictTags = strict
+	return c
+}
+
+// WithTenantLimits caps the distinct series, and the submissions each tenant can make in a
+// flush interval. The tenant is the value of the tag with key, DefaultTenantTagKey if key
+// is empty. Submissions from a tenant over either limit are recorded with the tenant tag
+// value replaced by OverflowTagValue. A limit of zero is unlimited.
+func (c *Config) WithTenantLimits(key string, maxSeries, maxPoints int) *Config {
+	c.TenantTagKey = key
+	c.TenantMaxSeries = maxSeries
+	c.TenantMaxPoints = maxPoints
+	return c
+}
+
+// WithDurationUnit sets the unit GaugeDuration reports durations in.
+func (c *Config) WithDurationUnit(unit DurationUnit) *Config {
+	c.DurationUnit = unit
+	return c
+}
+
+// WithErrorCallbackBuffer sets the number of errors that can be waiting for the error
+// callback, before errors are dropped.
+func (c *Config) WithErrorCallbackBuffer(n int) *Config {
+	c.ErrorCallbackBuffer = n
+	return c
+}
+
+// WithHealthFailureThreshold sets the number of consecutive failed flushes, at which
+// HealthHandler reports the pipeline as failing, and responds with 503. Values below one
+// are replaced with DefaultHealthFailureThreshold.
+func (c *Config) WithHealthFailureThreshold(n int) *Config {
+	c.HealthFailureThreshold = n
+	return c
+}
+
+// WithKeptValueExpiry sets the number of flush intervals without an update, after which
+// the value kept across intervals for an EWMA, GaugeAdd, or MonotonicCount metric is
+// forgotten, so metrics that stop being recorded don't grow memory without bound. The next
+// update starts the metric over. Values below one are replaced with DefaultKeptValueExpiry.
+func (c *Config) WithKeptValueExpiry(intervals int) *Config {
+	c.KeptValueExpiry = intervals
+	return c
+}
+
+// WithFlushErrorBuffer sets the number of errors that can be waiting in the channel
+// returned by ErrorsChan, before errors are dropped.
+func (c *Config) WithFlushErrorBuffer(n int) *Config {
+	c.FlushErrorBuffer = n
+	return c
+}
+
+// WithFlushOnStart shortens the first flush interval to DefaultStartFlushInterval, so metrics
+// show up in Datadog immediately after startup, instead of after a full flush interval. This
+// is useful for verifying canary deployments. Rate metrics for the first interval are
+// calculated over the shortened interval.
+func (c *Config) WithFlushOnStart(flushOnStart bool) *Config {
+	c.FlushOnStart = flushOnStart
+	return c
+}
+
+// WithDroppedMetric enables reporting the number of metrics dropped since the last flush,
+// by metric name, as the count MetricDropped on each flush.
+func (c *Config) WithDroppedMetric(enabled bool) *Config {
+	c.DroppedMetric = enabled
+	return c
+}
+
+// WithClientTelemetry enables reporting the library's own telemetry on each flush, the
+// duration of the last flush, payload bytes, series count, api errors, spool retries,
+// and the metric queue depth, under ddstats.client.
+func (c *Config) WithClientTelemetry(enabled bool) *Config {
+	c.ClientTelemetry = enabled
+	return c
+}
+
+// WithSourceTags enables tagging each metric with source:<dir>/<file>:<line> of the code
+// that submitted it, to find where a series originates. This is intended for development,
+// it adds a stack walk to every submission, and a tag value for every call site, so it's
+// disabled by default.
+func (c *Config) WithSourceTags(enabled bool) *Config {
+	c.SourceTags = enabled
+	return c
+}
+
+// WithStrictSeries enables validating series passed to SendSeries, and QueueSeries with
+// client.ValidateSeries. A series with any invalid metric is rejected with an error
+// describing each problem, instead of being silently dropped by the api.
+func (c *Config) WithStrictSeries(strict bool) *Config {
+	c.StrictSeries = strict
+	return c
+}
+
+// WithBlockOnFull sets whether submissions block the caller while the metric queue is
+// full, instead of dropping the metric, for applications that prefer latency over data
+// loss. Blocked submissions wait for up to timeout, or until queued if timeout is zero,
+// and are dropped if stats is closed. Blocking can also be enabled per submission with
+// WithBlocking.
+func (c *Config) WithBlockOnFull(enabled bool, timeout time.Duration) *Config {
+	c.BlockOnFull = enabled
+	c.BlockTimeoutSeconds = timeout.Seconds()
+	return c
+}
+
+// WithShutdownTimeout sets the max time Close waits for the api, while sending the final
+// flush, and any flushes still in progress. Once the timeout expires, the pending sends are
+// abandoned, and fail with ErrShutdownTimeout. Their series are spooled, if spooling is
+// enabled, and passed to the error callback, so process exit isn't blocked by a slow api.
+// An abandoned send may still complete in the background, so spooled series can be sent
+// twice. Zero, the default, waits for the api client to return.
+func (c *Config) WithShutdownTimeout(timeout time.Duration) *Config {
+	c.ShutdownTimeoutSeconds = timeout.Seconds()
+	return c
+}
+
+// WithMetricFlushInterval sets the interval the metric name is flushed at, instead of the
+// flush interval, such as a heartbeat sent every 10s, while other metrics are flushed every
+// 60s. The name is matched as submitted, without the namespace. Metrics with the same
+// interval are aggregated, and flushed together. Flush, and Close flush every interval.
+//
+// Only metrics aggregated by stats use the interval, distributions, and series queued with
+// QueueSeries are sent with the flush interval. The interval isn't widened by adaptive
+// flushing.
+func (c *Config) WithMetricFlushInterval(name string, interval time.Duration) *Config {
+	if c.FlushIntervals == nil {
+		c.FlushIntervals = map[string]float64{}
+	}
+	c.FlushIntervals[name] = interval.Seconds()
+	return c
+}
+
+// WithHistogramAggregates sets the aggregates sent for each histogram, any of max, min,
+// avg, median, sum, and count, the same as the Agent's histogram_aggregates setting. Each
+// aggregate is a separate series, so fewer aggregates reduce the number of series. With
+// no aggregates, only percentiles are sent. NewStats returns an error for an unknown
+// aggregate.
+func (c *Config) WithHistogramAggregates(aggregates ...string) *Config {
+	c.HistogramAggregates = append([]string{}, aggregates...)
+	return c
+}
+
+// WithHistogramPercentiles sets the percentiles sent for each histogram, between 0, and 1,
+// the same as the Agent's histogram_percentiles setting. Percentile 0.99 is sent with the
+// suffix .99percentile. With no percentiles, only the aggregates are sent. NewStats returns
+// an error for a percentile outside of (0, 1).
+func (c *Config) WithHistogramPercentiles(percentiles ...float64) *Config {
+	c.HistogramPercentiles = append([]float64{}, percentiles...)
+	return c
+}
+
+// WithGaugeAggregation sets how multiple updates to a gauge in a flush interval are
+// aggregated, the last value by default.
+func (c *Config) WithGaugeAggregation(aggregation GaugeAggregation) *Config {
+	c.GaugeAggregation = aggregation
+	return c
+}
+
+// WithTagLengthPolicy sets how tags longer than max bytes are handled. Long tags are
+// counted by GetLongTagCount.
+func (c *Config) WithTagLengthPolicy(max int, policy TagLengthPolicy) *Config {
+	c.MaxTagLength = max
+	c.TagLengthPolicy = policy
+	return c
+}
+
+// WithTagNormalization sets whether tags are sanitized with SanitizeTags before they're
+// aggregated, so malformed tags don't create separate series from the tags Datadog
+// stores. Tags are lower cased, invalid characters are replaced, tags are truncated to
+// the max tag length, and only the last value of each key is kept.
+func (c *Config) WithTagNormalization(enabled bool) *Config {
+	c.TagNormalization = enabled
+	return c
+}
+
+// WithMetricNamePolicy sets how metric names that Datadog would reject are handled, they
+// can be sent as is, sanitized, or rejected. The policy is applied before the metric is
+// aggregated.
+func (c *Config) WithMetricNamePolicy(policy MetricNamePolicy) *Config {
+	c.MetricNamePolicy = policy
+	return c
+}
+
+// WithSpool enables spooling the series of failed flushes to files in dir, so they can be
+// sent after the api is reachable again. After each successful flush, spooled series are
+// sent oldest first. The oldest files are removed to keep the spool under maxBytes, and
+// series older than maxAge are discarded, Datadog rejects points older than an hour.
+func (c *Config) WithSpool(dir string, maxBytes int64, maxAge time.Duration) *Config {
+	c.SpoolDir = dir
+	c.SpoolMaxBytes = maxBytes
+	c.SpoolMaxAgeSeconds = maxAge.Seconds()
+	return c
+}
+
+// WithSpoolStore sets a custom store for the series of failed flushes, in place of the
+// spool directory.
+func (c *Config) WithSpoolStore(store SpoolStore) *Config {
+	c.spool = store
+	return c
+}
+
+// WithMissingAPIKey sets how NewStats handles a missing api key, for environments where
+// the key is only available after startup. In buffer mode, up to maxBuffer metrics are
+// buffered until SetAPIKey is called. In noop mode, metrics are discarded until then.
+func (c *Config) WithMissingAPIKey(mode MissingAPIKeyMode, maxBuffer int) *Config {
+	c.MissingAPIKey = mode
+	c.MissingAPIKeyBuffer = maxBuffer
+	return c
+}
+
+// WithRateLimit limits the SendSeries, ServiceCheck, and Event calls made directly by the
+// application to rate calls per second, with bursts of up to burst calls, so calls from a
+// hot path can't exceed the Datadog intake rate limits. Calls above the limit return
+// client.ErrRateLimitExceeded, or are queued and sent in the background, according to
+// mode. Flushes are not limited.
+func (c *Config) WithRateLimit(rate float64, burst int, mode RateLimitMode) *Config {
+	c.RateLimit = rate
+	c.RateLimitBurst = burst
+	c.RateLimitMode = mode
+	return c
+}
+
+// WithPrometheus sets whether the aggregated metrics are exposed in the Prometheus text
+// format by PrometheusHandler, in parallel with the Datadog flush. Histograms, and
+// distributions are exposed as Prometheus histograms with buckets, or
+// DefaultPrometheusBuckets if buckets is empty.
+func (c *Config) WithPrometheus(enabled bool, buckets []float64) *Config {
+	c.Prometheus = enabled
+	c.PrometheusBuckets = buckets
+	return c
+}
+
+// WithRuntimeMetricsPrefix sets the prefix of the metrics reported by EnableRuntimeMetrics.
+func (c *Config) WithRuntimeMetricsPrefix(prefix string) *Config {
+	c.RuntimeMetricsPrefix = prefix
+	return c
+}
+
+// WithProcessMetrics enables reporting process cpu usage, resident memory, open file
+// descriptors, and thread count on each flush. Process metrics are read from procfs, and
+// are only available on Linux.
+func (c *Config) WithProcessMetrics(enabled bool) *Config {
+	c.ProcessMetrics = enabled
+	return c
+}
+
+// WithErrorDedup enables storing identical consecutive errors once, as a RepeatedError
+// with a repeat count, so a long outage doesn't fill the errors list with copies of the
+// same error.
+func (c *Config) WithErrorDedup(enabled bool) *Config {
+	c.DedupErrors = enabled
+	return c
+}
+
+// WithClockSkewMetric enables reporting the estimated clock skew between the host, and
+// Datadog as a gauge on each flush, see Stats.ClockSkew.
+func (c *Config) WithClockSkewMetric(enabled bool) *Config {
+	c.ClockSkewMetric = enabled
+	return c
+}
+
+// WithFaultInjector sets a fault injector, which injects artificial failures into the
+// pipeline. This is intended for testing only, see RandomFaults.
+func (c *Config) WithFaultInjector(faults FaultInjector) *Config {
+	c.faults = faults
+	return c
+}
+
+// WithDisabled sets whether stats is disabled. Disabled stats discard everything, without
+// an api client, or api key, see NewNoop. Only the namespace, host, and logger are kept
+// from the config.
+func (c *Config) WithDisabled(disabled bool) *Config {
+	c.Disabled = disabled
+	return c
+}
+
+// WithClock sets the clock used to schedule flushes, and measure flush intervals, in place
+// of the system clock. Use a ManualClock in tests, to run flushes without sleeping.
+func (c *Config) WithClock(clock Clock) *Config {
+	c.clock = clock
+	return c
+}
+
+// WithLogger sets the logger stats writes its log messages to, see Logger. Without a
+// logger, stats doesn't log.
+func (c *Config) WithLogger(logger Logger) *Config {
+	c.logger = logger
+	return c
+}
+
 // WithClient set the api client to use. If api key and client have both been set,
 // the client will be used. If no client has been set, then a client will be created
 // with the api key.
@@ -1,8 +1,14 @@
 package ddstats
 
 import (
+	"io/ioutil"
+	"net/http"
 	"os"
+	"strings"
 	"testing"
+	"time"
+
+	"github.com/jmizell/ddstats/client"
 )
 
 func TestConfig_FromEnv(t *testing.T) {
@@ -17,6 +23,12 @@ func TestConfig_FromEnv(t *testing.T) {
 		{EnvMetricBuffer, "4"},
 		{EnvMaxErrorCount, "5"},
 		{EnvTags, "tag:1,tag:2"},
+		{EnvExpectedMetrics, "6"},
+		{EnvMaxFlushInterval, "7"},
+		{EnvNamespaceMode, "strict"},
+		{EnvMissingAPIKey, "buffer"},
+		{EnvSite, "datadoghq.eu"},
+		{EnvAPIBaseURL, "http://localhost:8080/api/v1"},
 	}
 	for i := range vars {
 		if err := os.Setenv(vars[i][0], vars[i][1]); err != nil {
@@ -50,6 +62,25 @@ func TestConfig_FromEnv(t *testing.T) {
 	if cfg.MaxErrors != 5 {
 		t.Fatalf("expected MaxErrors to be %d, have %d", 5, cfg.MaxErrors)
 	}
+	if cfg.ExpectedMetrics != 6 {
+		t.Fatalf("expected ExpectedMetrics to be %d, have %d", 6, cfg.ExpectedMetrics)
+	}
+	if cfg.MaxFlushIntervalSeconds != 7.0 {
+		t.Fatalf("expected MaxFlushIntervalSeconds to be %f, have %f", 7.0, cfg.MaxFlushIntervalSeconds)
+	}
+	if cfg.NamespaceMode != NamespaceModeStrict {
+		t.Fatalf("expected NamespaceMode to be %s, have %s", NamespaceModeStrict, cfg.NamespaceMode)
+	}
+
+	if cfg.MissingAPIKey != MissingAPIKeyBuffer {
+		t.Fatalf("expected MissingAPIKey to be %s, have %s", MissingAPIKeyBuffer, cfg.MissingAPIKey)
+	}
+	if cfg.Site != vars[13][1] {
+		t.Fatalf("expected Site to be %s, have %s", vars[13][1], cfg.Site)
+	}
+	if cfg.APIBaseURL != vars[14][1] {
+		t.Fatalf("expected APIBaseURL to be %s, have %s", vars[14][1], cfg.APIBaseURL)
+	}
 
 	if len(cfg.Tags) != 2 {
 		t.Fatalf("expected to have %d tags, have %d", 2, len(cfg.Tags))
@@ -70,6 +101,71 @@ func TestConfig_WithAPIKey(t *testing.T) {
 	}
 }
 
+func TestStats_APIBaseURL(t *testing.T) {
+
+	tests := []struct {
+		name     string
+		cfg      *Config
+		expected string
+	}{
+		{"default", NewConfig(), "https://api.datadoghq.com/api/v1"},
+		{"site", NewConfig().WithSite("datadoghq.eu"), "https://api.datadoghq.eu/api/v1"},
+		{"base url", NewConfig().WithSite("datadoghq.eu").WithAPIBaseURL("http://localhost:8080/api/v1/"), "http://localhost:8080/api/v1"},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(tt *testing.T) {
+			stats, err := NewStats(test.cfg.WithAPIKey("test key"))
+			if err != nil {
+				tt.Fatalf(err.Error())
+			}
+			defer stats.Close()
+			if baseURL := stats.client.(*client.DDClient).BaseURL(); baseURL != test.expected {
+				tt.Fatalf("expected base url to be %s, have %s", test.expected, baseURL)
+			}
+		})
+	}
+
+	if _, err := NewStats(NewConfig().WithAPIKey("test key").WithAPIBaseURL("localhost")); err == nil {
+		t.Fatalf("expected an invalid base url to return an error")
+	}
+}
+
+type recordingRoundTripper struct {
+	requests []*http.Request
+}
+
+func (r *recordingRoundTripper) RoundTrip(req *http.Request) (*http.Response, error) {
+	r.requests = append(r.requests, req)
+	return &http.Response{
+		StatusCode: http.StatusAccepted,
+		Body:       ioutil.NopCloser(strings.NewReader("{}")),
+		Request:    req,
+	}, nil
+}
+
+func TestStats_WithRoundTripper(t *testing.T) {
+
+	rt := &recordingRoundTripper{}
+	stats, err := NewStats(NewConfig().
+		WithAPIKey("test key").
+		WithAPIBaseURL("http://localhost/api/v1").
+		WithRoundTripper(rt).
+		WithHTTPTimeouts(time.Second, time.Second))
+	if err != nil {
+		t.Fatalf(err.Error())
+	}
+	stats.Gauge("test", 1, nil)
+	stats.Close()
+
+	if len(rt.requests) != 1 {
+		t.Fatalf("expected %d request, have %d", 1, len(rt.requests))
+	}
+	if rt.requests[0].URL.Path != "/api/v1/series" {
+		t.Fatalf("expected a series request, have %s", rt.requests[0].URL.Path)
+	}
+}
+
 func TestConfig_loadEnvFloat64(t *testing.T) {
 	testKey := "TestConfig_loadEnvFloat64"
 	t.Run("var exists", func(tt *testing.T) {
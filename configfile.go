@@ -0,0 +1,254 @@
+package ddstats
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"path/filepath"
+	"reflect"
+	"strconv"
+	"strings"
+)
+
+// ConfigFromFile loads a config from a JSON, or YAML file at path. The file format is
+// selected by the extension, .json, .yaml, or .yml, files with another extension are
+// read as JSON if they start with a brace, and YAML otherwise. Keys are the json names
+// of the Config fields, such as namespace, host, tags, flush_interval, worker_count,
+// api_key, and site. Fields missing from the file keep the defaults of NewConfig, and
+// unknown keys are an error. Environment variables are applied on top of the file, see
+// FromEnv.
+//
+// YAML support is limited to what a config needs, a mapping of keys to scalars, and
+// lists of scalars, in block, or flow style.
+//
+//	namespace: myapp
+//	flush_interval: 15
+//	tags:
+//	  - env:prod
+//	  - team:core
+func ConfigFromFile(path string) (*Config, error) {
+
+	data, err := ioutil.ReadFile(path)
+	if err != nil {
+		return nil, fmt.Errorf("could not read config file, %s", err.Error())
+	}
+
+	ext := strings.ToLower(filepath.Ext(path))
+	isJSON := ext == ".json" || ext != ".yaml" && ext != ".yml" && bytes.HasPrefix(bytes.TrimSpace(data), []byte("{"))
+	if !isJSON {
+		values, err := parseYAML(data)
+		if err != nil {
+			return nil, fmt.Errorf("could not parse config file %s, %s", path, err.Error())
+		}
+		if data, err = json.Marshal(coerceYAML(values, reflect.TypeOf(Config{}))); err != nil {
+			return nil, fmt.Errorf("could not parse config file %s, %s", path, err.Error())
+		}
+	}
+
+	cfg := NewConfig()
+	decoder := json.NewDecoder(bytes.NewReader(data))
+	decoder.DisallowUnknownFields()
+	if err := decoder.Decode(cfg); err != nil {
+		return nil, fmt.Errorf("could not parse config file %s, %s", path, err.Error())
+	}
+
+	return cfg.FromEnv(), nil
+}
+
+// parseYAML parses a YAML mapping of keys to scalars, and lists of scalars.
+func parseYAML(data []byte) (map[string]interface{}, error) {
+
+	values := map[string]interface{}{}
+	var listKey string
+	for n, line := range strings.Split(string(data), "\n") {
+
+		line = strings.TrimRight(stripYAMLComment(line), " \t\r")
+		trimmed := strings.TrimSpace(line)
+		if trimmed == "" || trimmed == "---" {
+			continue
+		}
+		indented := line[0] == ' ' || line[0] == '\t'
+
+		// Block list items belong to the last key without a value
+		if strings.HasPrefix(trimmed, "- ") || trimmed == "-" {
+			if listKey == "" {
+				return nil, fmt.Errorf("line %d, list item without a key", n+1)
+			}
+			item, err := parseYAMLScalar(strings.TrimSpace(strings.TrimPrefix(trimmed, "-")))
+			if err != nil {
+				return nil, fmt.Errorf("line %d, %s", n+1, err.Error())
+			}
+			values[listKey] = append(values[listKey].([]interface{}), item)
+			continue
+		}
+		if indented {
+			return nil, fmt.Errorf("line %d, nested mappings are not supported", n+1)
+		}
+
+		colon := strings.Index(trimmed, ":")
+		if colon <= 0 || colon+1 < len(trimmed) && trimmed[colon+1] != ' ' {
+			return nil, fmt.Errorf("line %d, expected key: value", n+1)
+		}
+		key := strings.TrimSpace(trimmed[:colon])
+		raw := strings.TrimSpace(trimmed[colon+1:])
+		if _, ok := values[key]; ok {
+			return nil, fmt.Errorf("line %d, duplicate key %s", n+1, key)
+		}
+
+		listKey = ""
+		if raw == "" {
+			// The value is a block list, or null if no items follow
+			listKey = key
+			values[key] = []interface{}{}
+			continue
+		}
+		value, err := parseYAMLScalar(raw)
+		if err != nil {
+			return nil, fmt.Errorf("line %d, %s", n+1, err.Error())
+		}
+		values[key] = value
+	}
+
+	return values, nil
+}
+
+// parseYAMLScalar parses a quoted, or plain scalar, or a flow list of scalars.
+func parseYAMLScalar(raw string) (interface{}, error) {
+
+	switch {
+	case strings.HasPrefix(raw, "["):
+		if !strings.HasSuffix(raw, "]") {
+			return nil, fmt.Errorf("unterminated list %s", raw)
+		}
+		items := []interface{}{}
+		inner := strings.TrimSpace(raw[1 : len(raw)-1])
+		if inner == "" {
+			return items, nil
+		}
+		for _, part := range splitYAMLFlow(inner) {
+			item, err := parseYAMLScalar(strings.TrimSpace(part))
+			if err != nil {
+				return nil, err
+			}
+			items = append(items, item)
+		}
+		return items, nil
+	case strings.HasPrefix(raw, `"`):
+		s, err := strconv.Unquote(raw)
+		if err != nil {
+			return nil, fmt.Errorf("invalid quoted string %s", raw)
+		}
+		return s, nil
+	case strings.HasPrefix(raw, "'"):
+		if len(raw) < 2 || !strings.HasSuffix(raw, "'") {
+			return nil, fmt.Errorf("invalid quoted string %s", raw)
+		}
+		return strings.Replace(raw[1:len(raw)-1], "''", "'", -1), nil
+	}
+	return yamlPlain(raw), nil
+}
+
+// yamlPlain is an unquoted scalar. Its type depends on the field it's decoded into, a
+// plain 123 is a string for a string field, and a number otherwise.
+type yamlPlain string
+
+// coerceYAML converts the plain scalars of values to the types of the fields of t, with
+// the same json names.
+func coerceYAML(values map[string]interface{}, t reflect.Type) map[string]interface{} {
+
+	kinds := map[string]reflect.Kind{}
+	slices := map[string]bool{}
+	for i := 0; i < t.NumField(); i++ {
+		field := t.Field(i)
+		name := strings.Split(field.Tag.Get("json"), ",")[0]
+		kind := field.Type.Kind()
+		if kind == reflect.Slice {
+			kind = field.Type.Elem().Kind()
+			slices[name] = true
+		}
+		kinds[name] = kind
+	}
+
+	coerced := make(map[string]interface{}, len(values))
+	for key, value := range values {
+		kind := kinds[key]
+		if items, ok := value.([]interface{}); ok {
+			if len(items) == 0 && !slices[key] {
+				// A key without a value, or list items is null
+				coerced[key] = nil
+				continue
+			}
+			for i := range items {
+				items[i] = coerceYAMLScalar(items[i], kind)
+			}
+			coerced[key] = items
+			continue
+		}
+		coerced[key] = coerceYAMLScalar(value, kind)
+	}
+	return coerced
+}
+
+func coerceYAMLScalar(value interface{}, kind reflect.Kind) interface{} {
+
+	plain, ok := value.(yamlPlain)
+	if !ok {
+		return value
+	}
+	raw := string(plain)
+	switch {
+	case raw == "null" || raw == "~":
+		return nil
+	case kind == reflect.String:
+		return raw
+	case raw == "true":
+		return true
+	case raw == "false":
+		return false
+	}
+	if v, err := strconv.ParseFloat(raw, 64); err == nil {
+		return v
+	}
+	return raw
+}
+
+// splitYAMLFlow splits the items of a flow list on commas outside of quotes.
+func splitYAMLFlow(s string) []string {
+	var parts []string
+	var quote byte
+	start := 0
+	for i := 0; i < len(s); i++ {
+		switch ch := s[i]; {
+		case quote != 0:
+			if ch == quote {
+				quote = 0
+			}
+		case ch == '"' || ch == '\'':
+			quote = ch
+		case ch == ',':
+			parts = append(parts, s[start:i])
+			start = i + 1
+		}
+	}
+	return append(parts, s[start:])
+}
+
+// stripYAMLComment removes a comment from line. A comment starts with a # at the start of
+// the line, or after whitespace, outside of quotes.
+func stripYAMLComment(line string) string {
+	var quote byte
+	for i := 0; i < len(line); i++ {
+		switch ch := line[i]; {
+		case quote != 0:
+			if ch == quote {
+				quote = 0
+			}
+		case ch == '"' || ch == '\'':
+			quote = ch
+		case ch == '#' && (i == 0 || line[i-1] == ' ' || line[i-1] == '\t'):
+			return line[:i]
+		}
+	}
+	return line
+}
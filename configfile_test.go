@@ -0,0 +1,140 @@
+package ddstats
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func writeConfigFile(t *testing.T, name, content string) string {
+	dir, err := ioutil.TempDir("", "ddstats-config")
+	if err != nil {
+		t.Fatalf(err.Error())
+	}
+	path := filepath.Join(dir, name)
+	if err := ioutil.WriteFile(path, []byte(content), 0600); err != nil {
+		t.Fatalf(err.Error())
+	}
+	return path
+}
+
+// clearConfigEnv unsets the config environment variables, other tests may leave set, and
+// returns a function that restores them.
+func clearConfigEnv() func() {
+	saved := map[string]string{}
+	for _, env := range os.Environ() {
+		if strings.HasPrefix(env, "DDSTATS_") {
+			kv := strings.SplitN(env, "=", 2)
+			saved[kv[0]] = kv[1]
+			_ = os.Unsetenv(kv[0])
+		}
+	}
+	return func() {
+		for key, value := range saved {
+			_ = os.Setenv(key, value)
+		}
+	}
+}
+
+func TestConfigFromFile(t *testing.T) {
+	defer clearConfigEnv()()
+
+	yaml := `# ddstats config
+namespace: myapp
+host: "web-1"   # quoted
+api_key: 0123456789
+site: datadoghq.eu
+flush_interval: 15
+worker_count: 4
+metric_buffer: 200
+api_key_header: true
+max_errors:
+tags:
+  - env:prod
+  - 'team:core # not a comment'
+`
+	json := `{
+	"namespace": "myapp",
+	"host": "web-1",
+	"api_key": "0123456789",
+	"site": "datadoghq.eu",
+	"flush_interval": 15,
+	"worker_count": 4,
+	"metric_buffer": 200,
+	"api_key_header": true,
+	"tags": ["env:prod", "team:core # not a comment"]
+}`
+
+	for name, content := range map[string]string{"config.yaml": yaml, "config.json": json, "config.conf": json} {
+		t.Run(name, func(tt *testing.T) {
+			path := writeConfigFile(tt, name, content)
+			defer os.RemoveAll(filepath.Dir(path))
+
+			cfg, err := ConfigFromFile(path)
+			if err != nil {
+				tt.Fatalf(err.Error())
+			}
+			if cfg.Namespace != "myapp" || cfg.Host != "web-1" || cfg.APIKey != "0123456789" || cfg.Site != "datadoghq.eu" {
+				tt.Fatalf("expected string fields to be loaded, have %s, %s, %s, and %s", cfg.Namespace, cfg.Host, cfg.APIKey, cfg.Site)
+			}
+			if cfg.FlushIntervalSeconds != 15 || cfg.WorkerCount != 4 || cfg.MetricBuffer != 200 || !cfg.APIKeyHeader {
+				tt.Fatalf("expected numeric, and boolean fields to be loaded, have %v", cfg)
+			}
+			if strings.Join(cfg.Tags, ",") != "env:prod,team:core # not a comment" {
+				tt.Fatalf("expected tags to be loaded, have %v", cfg.Tags)
+			}
+			if cfg.WorkerBuffer != DefaultWorkerBuffer || cfg.MaxErrors != DefaultMaxErrorCount {
+				tt.Fatalf("expected missing fields to keep the defaults, have %d, and %d", cfg.WorkerBuffer, cfg.MaxErrors)
+			}
+		})
+	}
+}
+
+func TestConfigFromFile_env(t *testing.T) {
+	defer clearConfigEnv()()
+
+	path := writeConfigFile(t, "config.yml", "namespace: file\ntags: [a:1, \"b:2\"]\n")
+	defer os.RemoveAll(filepath.Dir(path))
+
+	if err := os.Setenv(EnvNamespace, "env"); err != nil {
+		t.Fatalf(err.Error())
+	}
+	defer os.Unsetenv(EnvNamespace)
+
+	cfg, err := ConfigFromFile(path)
+	if err != nil {
+		t.Fatalf(err.Error())
+	}
+	if cfg.Namespace != "env" {
+		t.Fatalf("expected the environment to override the file, have namespace %s", cfg.Namespace)
+	}
+	if strings.Join(cfg.Tags, ",") != "a:1,b:2" {
+		t.Fatalf("expected flow list tags to be loaded, have %v", cfg.Tags)
+	}
+}
+
+func TestConfigFromFile_errors(t *testing.T) {
+
+	tests := map[string]string{
+		"unknown.yaml": "unknown_key: 1\n",
+		"nested.yaml":  "namespace:\n  inner: 1\n",
+		"list.yaml":    "- item\n",
+		"type.yaml":    "worker_count: many\n",
+		"quote.yaml":   "namespace: \"open\n",
+		"bad.json":     "{\"namespace\": }",
+	}
+	for name, content := range tests {
+		path := writeConfigFile(t, name, content)
+		_, err := ConfigFromFile(path)
+		os.RemoveAll(filepath.Dir(path))
+		if err == nil {
+			t.Fatalf("expected %s to fail", name)
+		}
+	}
+
+	if _, err := ConfigFromFile("/does/not/exist.yaml"); err == nil {
+		t.Fatalf("expected a missing file to fail")
+	}
+}
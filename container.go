@@ -0,0 +1,62 @@
+package ddstats
+
+import (
+	"bufio"
+	"os"
+	"regexp"
+)
+
+// Kubernetes downward api environment variables read by container tag detection. They
+// must be set in the pod spec, from metadata.name, metadata.namespace, and spec.nodeName.
+const (
+	EnvPodName      = "POD_NAME"
+	EnvPodNamespace = "POD_NAMESPACE"
+	EnvNodeName     = "NODE_NAME"
+)
+
+// cgroupPath is the cgroup file of the process, replaced in tests.
+var cgroupPath = "/proc/self/cgroup"
+
+// containerIDPattern matches the 64 character container ids used by Docker, containerd,
+// and CRI-O in cgroup paths, such as /docker/<id>, and /kubepods/.../cri-containerd-<id>.scope.
+var containerIDPattern = regexp.MustCompile(`(?:^|[/\-])([0-9a-f]{64})(?:\.scope)?$`)
+
+// containerTags returns the container_id tag, detected from the cgroup of the process,
+// and the pod_name, kube_namespace, and kube_node tags, from the downward api
+// environment variables. Only the values that are found are returned.
+func containerTags(containerID string) []string {
+
+	var tags []string
+	if containerID != "" {
+		tags = append(tags, "container_id:"+containerID)
+	}
+	for _, env := range []struct{ tag, key string }{
+		{"pod_name", EnvPodName},
+		{"kube_namespace", EnvPodNamespace},
+		{"kube_node", EnvNodeName},
+	} {
+		if val := os.Getenv(env.key); val != "" {
+			tags = append(tags, env.tag+":"+val)
+		}
+	}
+	return tags
+}
+
+// detectContainerID returns the id of the container the process is running in, or an
+// empty string if it's not running in a container, or the id can't be found.
+func detectContainerID() string {
+
+	f, err := os.Open(cgroupPath)
+	if err != nil {
+		return ""
+	}
+	defer func() { _ = f.Close() }()
+
+	scanner := bufio.NewScanner(f)
+	for scanner.Scan() {
+		if match := containerIDPattern.FindStringSubmatch(scanner.Text()); match != nil {
+			return match[1]
+		}
+	}
+	return ""
+}
@@ -0,0 +1,81 @@
+package ddstats
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+const testContainerID = "3726184226f5d3147c25fdeab5b60097e378e8a720503a5e19ecfdf29f869860"
+
+func TestDetectContainerID(t *testing.T) {
+
+	tests := []struct {
+		name     string
+		cgroup   string
+		expected string
+	}{
+		{
+			name:     "docker",
+			cgroup:   "12:memory:/docker/" + testContainerID + "\n1:name=systemd:/docker/" + testContainerID,
+			expected: testContainerID,
+		},
+		{
+			name:     "kubernetes",
+			cgroup:   "0::/kubepods.slice/kubepods-burstable.slice/kubepods-burstable-pod1234.slice/cri-containerd-" + testContainerID + ".scope",
+			expected: testContainerID,
+		},
+		{
+			name:   "host",
+			cgroup: "0::/user.slice/user-1000.slice/session-2.scope",
+		},
+	}
+
+	dir, err := ioutil.TempDir("", "ddstats-cgroup")
+	if err != nil {
+		t.Fatalf(err.Error())
+	}
+	defer os.RemoveAll(dir)
+	defer func(path string) { cgroupPath = path }(cgroupPath)
+
+	for _, test := range tests {
+		t.Run(test.name, func(tt *testing.T) {
+			cgroupPath = filepath.Join(dir, test.name)
+			if err := ioutil.WriteFile(cgroupPath, []byte(test.cgroup), 0600); err != nil {
+				tt.Fatalf(err.Error())
+			}
+			if id := detectContainerID(); id != test.expected {
+				tt.Fatalf("expected container id %q, have %q", test.expected, id)
+			}
+		})
+	}
+
+	t.Run("missing", func(tt *testing.T) {
+		cgroupPath = filepath.Join(dir, "missing")
+		if id := detectContainerID(); id != "" {
+			tt.Fatalf("expected no container id, have %q", id)
+		}
+	})
+}
+
+func TestContainerTags(t *testing.T) {
+
+	for key, val := range map[string]string{EnvPodName: "web-0", EnvPodNamespace: "prod", EnvNodeName: ""} {
+		defer os.Unsetenv(key)
+		if err := os.Setenv(key, val); err != nil {
+			t.Fatalf(err.Error())
+		}
+	}
+
+	tags := containerTags("abc")
+	expected := []string{"container_id:abc", "pod_name:web-0", "kube_namespace:prod"}
+	if len(tags) != len(expected) {
+		t.Fatalf("expected tags %v, have %v", expected, tags)
+	}
+	for i := range expected {
+		if tags[i] != expected[i] {
+			t.Fatalf("expected tags %v, have %v", expected, tags)
+		}
+	}
+}
@@ -0,0 +1,103 @@
+package ddstats
+
+import (
+	"fmt"
+	"sort"
+	"strings"
+	"sync"
+
+	"github.com/jmizell/ddstats/client"
+	"github.com/jmizell/ddstats/internal/engine"
+)
+
+// continuityEventMaxNames is the number of disappeared series named in a continuity event.
+const continuityEventMaxNames = 5
+
+// SeriesID identifies a series by its metric name, and tags.
+type SeriesID struct {
+	Metric string
+	Tags   []string
+}
+
+type continuityReport struct {
+	previous map[string]SeriesID
+	callback func(disappeared []SeriesID)
+	event    bool
+	lock     *sync.Mutex
+}
+
+// EnableContinuityReport compares the series sent in each flush with the series sent in the
+// previous flush, and reports series that disappeared. This helps to detect instrumentation
+// that was removed, or broken by a deploy, before a dashboard quietly goes empty. Series
+// that are only updated occasionally will also be reported, when they're missing from a
+// flush.
+//
+// Disappeared series are passed to callback, if it's not nil, in flush order. If event is
+// true, a warning event is also sent, listing the disappeared series.
+func (c *Stats) EnableContinuityReport(event bool, callback func(disappeared []SeriesID)) {
+	c.continuity = &continuityReport{
+		callback: callback,
+		event:    event,
+		lock:     &sync.Mutex{},
+	}
+}
+
+// checkContinuity reports any series from the previous flush missing from series.
+func (c *Stats) checkContinuity(series []*client.DDMetric) {
+
+	report := c.continuity
+	if report == nil {
+		return
+	}
+
+	current := make(map[string]SeriesID, len(series))
+	for _, m := range series {
+		tags := append([]string(nil), m.Tags...)
+		current[engine.Key(m.Metric, tags)] = SeriesID{Metric: m.Metric, Tags: tags}
+	}
+
+	report.lock.Lock()
+	previous := report.previous
+	report.previous = current
+	report.lock.Unlock()
+
+	var disappeared []SeriesID
+	for key, id := range previous {
+		if _, ok := current[key]; !ok {
+			disappeared = append(disappeared, id)
+		}
+	}
+	if len(disappeared) == 0 {
+		return
+	}
+	sort.Slice(disappeared, func(i, j int) bool {
+		return disappeared[i].Metric < disappeared[j].Metric
+	})
+
+	if report.callback != nil {
+		report.callback(disappeared)
+	}
+	if report.event {
+		if err := c.Event(continuityEvent(disappeared)); err != nil {
+			c.addError(ErrorClassContinuity, fmt.Errorf("could not send continuity event, %s", err.Error()))
+		}
+	}
+}
+
+func continuityEvent(disappeared []SeriesID) *client.DDEvent {
+
+	var names []string
+	for _, id := range disappeared {
+		if len(names) == continuityEventMaxNames {
+			names = append(names, fmt.Sprintf("and %d more", len(disappeared)-continuityEventMaxNames))
+			break
+		}
+		names = append(names, id.Metric)
+	}
+
+	return &client.DDEvent{
+		AlertType: client.AlertWarning,
+		Priority:  client.PriorityLow,
+		Title:     fmt.Sprintf("%d series disappeared since the last flush: %s", len(disappeared), strings.Join(names, ", ")),
+	}
+}
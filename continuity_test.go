@@ -0,0 +1,39 @@
+package ddstats
+
+import (
+	"testing"
+)
+
+func TestStats_ContinuityReport(t *testing.T) {
+
+	stats, testClient, err := NewTestStats()
+	if err != nil {
+		t.Fatalf(err.Error())
+	}
+
+	var reports [][]SeriesID
+	stats.EnableContinuityReport(true, func(disappeared []SeriesID) {
+		reports = append(reports, disappeared)
+	})
+
+	stats.Count("a", 1, nil)
+	stats.Count("b", 1, []string{"b:1"})
+	stats.Flush()
+	stats.Count("a", 1, nil)
+	stats.Flush()
+	stats.Count("a", 1, nil)
+	stats.Close()
+
+	if len(reports) != 1 || len(reports[0]) != 1 {
+		t.Fatalf("expected one report with one series, have %v", reports)
+	}
+	if id := reports[0][0]; id.Metric != "testNamespace.b" || len(id.Tags) != 2 {
+		t.Fatalf("expected testNamespace.b with tags [b:1 tag:1] to disappear, have %v", id)
+	}
+	if len(testClient.events) != 1 {
+		t.Fatalf("expected %d continuity event, have %d", 1, len(testClient.events))
+	}
+	if title := testClient.events[0].Title; title != "1 series disappeared since the last flush: testNamespace.b" {
+		t.Fatalf("unexpected event title %q", title)
+	}
+}
@@ -0,0 +1,34 @@
+package ddstatstest
+
+import (
+	"time"
+
+	"github.com/jmizell/ddstats"
+)
+
+// Clock is a manual ddstats.Clock, moved by AdvanceTime. Configure stats with it, using
+// Config.WithClock, so scheduled flushes only happen when the test advances time. Each
+// test creates its own clock, so tests using one may run in parallel.
+//
+//	clock := ddstatstest.NewClock()
+//	stats, _ := ddstats.NewStats(ddstats.NewConfig().
+//		WithClient(recorder).
+//		WithClock(clock))
+//	stats.Increment("requests", nil)
+//	flushes := recorder.Flushes()
+//	clock.AdvanceTime(ddstats.DefaultFlushInterval)
+//	recorder.WaitForFlush(ctx, flushes)
+type Clock struct {
+	*ddstats.ManualClock
+}
+
+// NewClock creates a clock set to 2020-01-01 00:00:00 UTC.
+func NewClock() *Clock {
+	return &Clock{ManualClock: ddstats.NewManualClock(time.Date(2020, 1, 1, 0, 0, 0, 0, time.UTC))}
+}
+
+// AdvanceTime moves the clock forward by d, triggering the flushes scheduled within d. The
+// flushes are sent in the background, use RecordingClient.WaitForFlush to wait for them.
+func (c *Clock) AdvanceTime(d time.Duration) {
+	c.Advance(d)
+}
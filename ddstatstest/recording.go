@@ -0,0 +1,336 @@
+// Package ddstatstest provides an in memory api client, for testing code that reports
+// metrics with ddstats.
+//
+//	recorder := ddstatstest.NewRecordingClient()
+//	stats, _ := ddstats.NewStats(ddstats.NewConfig().WithClient(recorder))
+//	handleRequest(stats)
+//	stats.Flush()
+//	recorder.AssertCount(t, "ddstats.requests", 1, "path:/login")
+package ddstatstest
+
+import (
+	"context"
+	"sort"
+	"strings"
+	"sync"
+	"testing"
+
+	"github.com/jmizell/ddstats/client"
+)
+
+// RecordingClient is a client.APIClient, and client.DistributionClient that records every
+// series, distribution, service check, and event in memory, instead of sending them. It's
+// safe for concurrent use.
+type RecordingClient struct {
+	series        []*client.DDMetric
+	distributions []*client.DDDistribution
+	checks        []*client.DDServiceCheck
+	events        []*client.DDEvent
+	err           error
+	flushes       int
+	flushed       chan bool // Closed, and replaced on each series send
+	lock          sync.Mutex
+}
+
+// NewRecordingClient creates an empty recording client.
+func NewRecordingClient() *RecordingClient {
+	return &RecordingClient{flushed: make(chan bool)}
+}
+
+func (c *RecordingClient) SendSeries(series *client.DDMetricSeries) error {
+	c.lock.Lock()
+	defer c.lock.Unlock()
+	if c.err == nil {
+		c.series = append(c.series, series.Series...)
+		c.flushes++
+	}
+	close(c.flushed)
+	c.flushed = make(chan bool)
+	return c.err
+}
+
+func (c *RecordingClient) SendDistributions(series *client.DDDistributionSeries) error {
+	c.lock.Lock()
+	defer c.lock.Unlock()
+	if c.err != nil {
+		return c.err
+	}
+	c.distributions = append(c.distributions, series.Series...)
+	return nil
+}
+
+func (c *RecordingClient) SendServiceCheck(check *client.DDServiceCheck) error {
+	c.lock.Lock()
+	defer c.lock.Unlock()
+	if c.err != nil {
+		return c.err
+	}
+	c.checks = append(c.checks, check)
+	return nil
+}
+
+func (c *RecordingClient) SendEvent(event *client.DDEvent) error {
+	c.lock.Lock()
+	defer c.lock.Unlock()
+	if c.err != nil {
+		return c.err
+	}
+	c.events = append(c.events, event)
+	return nil
+}
+
+// SetHTTPClient does nothing, the recording client doesn't send requests.
+func (c *RecordingClient) SetHTTPClient(client.HTTPClient) {}
+
+// SetError sets the error returned by every send, to test how failures are handled.
+// Nothing is recorded while the error is set, nil clears it.
+func (c *RecordingClient) SetError(err error) {
+	c.lock.Lock()
+	defer c.lock.Unlock()
+	c.err = err
+}
+
+// Series returns the recorded metrics, in the order they were sent.
+func (c *RecordingClient) Series() []*client.DDMetric {
+	c.lock.Lock()
+	defer c.lock.Unlock()
+	return append([]*client.DDMetric{}, c.series...)
+}
+
+// Distributions returns the recorded distributions, in the order they were sent.
+func (c *RecordingClient) Distributions() []*client.DDDistribution {
+	c.lock.Lock()
+	defer c.lock.Unlock()
+	return append([]*client.DDDistribution{}, c.distributions...)
+}
+
+// ServiceChecks returns the recorded service checks, in the order they were sent.
+func (c *RecordingClient) ServiceChecks() []*client.DDServiceCheck {
+	c.lock.Lock()
+	defer c.lock.Unlock()
+	return append([]*client.DDServiceCheck{}, c.checks...)
+}
+
+// Events returns the recorded events, in the order they were sent.
+func (c *RecordingClient) Events() []*client.DDEvent {
+	c.lock.Lock()
+	defer c.lock.Unlock()
+	return append([]*client.DDEvent{}, c.events...)
+}
+
+// Reset discards everything recorded.
+func (c *RecordingClient) Reset() {
+	c.lock.Lock()
+	defer c.lock.Unlock()
+	c.series = nil
+	c.flushes = 0
+	c.distributions = nil
+	c.checks = nil
+	c.events = nil
+}
+
+// Find returns the recorded metrics named name, that have all of tags. The name is the
+// name as sent, including the namespace.
+func (c *RecordingClient) Find(name string, tags ...string) []*client.DDMetric {
+	var found []*client.DDMetric
+	for _, m := range c.Series() {
+		if m.Metric == name && hasTags(m.Tags, tags) {
+			found = append(found, m)
+		}
+	}
+	return found
+}
+
+// Flushes returns the number of series sends recorded. Stats sends series once per flush,
+// flushes with nothing to send aren't recorded.
+func (c *RecordingClient) Flushes() int {
+	c.lock.Lock()
+	defer c.lock.Unlock()
+	return c.flushes
+}
+
+// Values returns the point values of the recorded metrics named name, with all of tags, in
+// the order they were sent, across all flushes.
+func (c *RecordingClient) Values(name string, tags ...string) []float64 {
+	var values []float64
+	for _, m := range c.Find(name, tags...) {
+		for _, p := range m.Points {
+			values = append(values, pointValue(p))
+		}
+	}
+	return values
+}
+
+// TagSets returns the distinct tag sets of the recorded metrics named name, in the order
+// they were first sent. The tags of each set are sorted.
+func (c *RecordingClient) TagSets(name string) [][]string {
+	var sets [][]string
+	seen := map[string]bool{}
+	for _, m := range c.Find(name) {
+		tags := append([]string{}, m.Tags...)
+		sort.Strings(tags)
+		if key := strings.Join(tags, ","); !seen[key] {
+			seen[key] = true
+			sets = append(sets, tags)
+		}
+	}
+	return sets
+}
+
+// WaitForFlush blocks until more than n flushes have been recorded, or ctx is done. Read
+// n from Flushes before triggering the flush to wait for, so a flush that is sent before
+// the wait starts isn't missed.
+func (c *RecordingClient) WaitForFlush(ctx context.Context, n int) error {
+	for {
+		c.lock.Lock()
+		flushes, flushed := c.flushes, c.flushed
+		c.lock.Unlock()
+		if flushes > n {
+			return nil
+		}
+		select {
+		case <-flushed:
+		case <-ctx.Done():
+			return ctx.Err()
+		}
+	}
+}
+
+// AssertCount fails t, if the sum of the recorded count metrics named name, with all of
+// tags, isn't value. Counts of every flush are added together.
+func (c *RecordingClient) AssertCount(t testing.TB, name string, value float64, tags ...string) {
+	t.Helper()
+	found := c.Find(name, tags...)
+	if len(found) == 0 {
+		t.Fatalf("expected count %s%v to be sent, it wasn't", name, tags)
+		return
+	}
+	var sum float64
+	for _, m := range found {
+		if m.Type != client.Count {
+			t.Fatalf("expected %s%v to be a count, have %s", name, tags, m.Type)
+			return
+		}
+		for _, p := range m.Points {
+			sum += pointValue(p)
+		}
+	}
+	if sum != value {
+		t.Fatalf("expected count %s%v to be %v, have %v", name, tags, value, sum)
+	}
+}
+
+// AssertGauge fails t, if the last recorded gauge named name, with all of tags, isn't
+// value.
+func (c *RecordingClient) AssertGauge(t testing.TB, name string, value float64, tags ...string) {
+	t.Helper()
+	found := c.Find(name, tags...)
+	if len(found) == 0 {
+		t.Fatalf("expected gauge %s%v to be sent, it wasn't", name, tags)
+		return
+	}
+	last := found[len(found)-1]
+	if last.Type != client.Gauge {
+		t.Fatalf("expected %s%v to be a gauge, have %s", name, tags, last.Type)
+		return
+	}
+	if len(last.Points) == 0 {
+		t.Fatalf("expected gauge %s%v to have a point", name, tags)
+		return
+	}
+	if have := pointValue(last.Points[len(last.Points)-1]); have != value {
+		t.Fatalf("expected gauge %s%v to be %v, have %v", name, tags, value, have)
+	}
+}
+
+// AssertFlushes fails t, if the number of recorded series sends isn't n.
+func (c *RecordingClient) AssertFlushes(t testing.TB, n int) {
+	t.Helper()
+	if have := c.Flushes(); have != n {
+		t.Fatalf("expected %d flushes, have %d", n, have)
+	}
+}
+
+// AssertSum fails t, if the sum of the point values of the recorded metrics named name,
+// with all of tags, isn't value. Unlike AssertCount, metrics of any type are summed, so
+// the aggregation of a metric across flushes can be checked.
+func (c *RecordingClient) AssertSum(t testing.TB, name string, value float64, tags ...string) {
+	t.Helper()
+	values := c.Values(name, tags...)
+	if len(values) == 0 {
+		t.Fatalf("expected %s%v to be sent, it wasn't", name, tags)
+		return
+	}
+	var sum float64
+	for _, v := range values {
+		sum += v
+	}
+	if sum != value {
+		t.Fatalf("expected the sum of %s%v to be %v, have %v", name, tags, value, sum)
+	}
+}
+
+// AssertTagSets fails t, if the distinct tag sets of the recorded metrics named name
+// aren't sets. The order of the sets, and of the tags within a set doesn't matter. The
+// tags include the global tags of stats.
+func (c *RecordingClient) AssertTagSets(t testing.TB, name string, sets ...[]string) {
+	t.Helper()
+	have := c.TagSets(name)
+	want := map[string]bool{}
+	for _, set := range sets {
+		tags := append([]string{}, set...)
+		sort.Strings(tags)
+		want[strings.Join(tags, ",")] = true
+	}
+	if len(have) != len(want) {
+		t.Fatalf("expected %s to be sent with tag sets %v, have %v", name, sets, have)
+		return
+	}
+	for _, tags := range have {
+		if !want[strings.Join(tags, ",")] {
+			t.Fatalf("expected %s to be sent with tag sets %v, have %v", name, sets, have)
+			return
+		}
+	}
+}
+
+// AssertNotSent fails t, if any metric named name, with all of tags was recorded.
+func (c *RecordingClient) AssertNotSent(t testing.TB, name string, tags ...string) {
+	t.Helper()
+	if found := c.Find(name, tags...); len(found) > 0 {
+		t.Fatalf("expected %s%v to not be sent, it was sent %d times", name, tags, len(found))
+	}
+}
+
+// hasTags returns true if all of want are in tags.
+func hasTags(tags, want []string) bool {
+	for _, w := range want {
+		found := false
+		for _, tag := range tags {
+			if tag == w {
+				found = true
+				break
+			}
+		}
+		if !found {
+			return false
+		}
+	}
+	return true
+}
+
+// pointValue returns the value of a point as a float64.
+func pointValue(p [2]interface{}) float64 {
+	switch v := p[1].(type) {
+	case float64:
+		return v
+	case float32:
+		return float64(v)
+	case int:
+		return float64(v)
+	case int64:
+		return float64(v)
+	default:
+		return 0
+	}
+}
@@ -0,0 +1,141 @@
+package ddstatstest
+
+import (
+	"context"
+	"fmt"
+	"testing"
+	"time"
+
+	"github.com/jmizell/ddstats"
+	"github.com/jmizell/ddstats/client"
+)
+
+// fakeTB records failures, instead of failing the test.
+type fakeTB struct {
+	testing.TB
+	failed string
+}
+
+func (f *fakeTB) Helper() {}
+
+func (f *fakeTB) Fatalf(format string, args ...interface{}) {
+	f.failed = fmt.Sprintf(format, args...)
+}
+
+func TestRecordingClient(t *testing.T) {
+
+	recorder := NewRecordingClient()
+	stats, err := ddstats.NewStats(ddstats.NewConfig().
+		WithNamespace("app").
+		WithHost("test-host").
+		WithClient(recorder))
+	if err != nil {
+		t.Fatalf(err.Error())
+	}
+	defer stats.Close()
+
+	stats.Increment("requests", []string{"path:/login"})
+	stats.Increment("requests", []string{"path:/login"})
+	stats.Increment("requests", []string{"path:/logout"})
+	stats.Gauge("queue.depth", 7, nil)
+	stats.Flush()
+	stats.Increment("requests", []string{"path:/login"})
+	stats.Flush()
+
+	recorder.AssertCount(t, "app.requests", 3, "path:/login")
+	recorder.AssertCount(t, "app.requests", 4)
+	recorder.AssertGauge(t, "app.queue.depth", 7)
+	recorder.AssertNotSent(t, "app.missing")
+	recorder.AssertFlushes(t, 2)
+	recorder.AssertSum(t, "app.requests", 3, "path:/login")
+	recorder.AssertTagSets(t, "app.requests", []string{"path:/logout"}, []string{"path:/login"})
+	if values := recorder.Values("app.requests", "path:/login"); len(values) != 2 || values[0] != 2 || values[1] != 1 {
+		t.Fatalf("expected values [2 1], have %v", values)
+	}
+
+	t.Run("failures", func(tt *testing.T) {
+		for name, assert := range map[string]func(tb testing.TB){
+			"count value":   func(tb testing.TB) { recorder.AssertCount(tb, "app.requests", 1, "path:/login") },
+			"count missing": func(tb testing.TB) { recorder.AssertCount(tb, "app.missing", 1) },
+			"count type":    func(tb testing.TB) { recorder.AssertCount(tb, "app.queue.depth", 7) },
+			"gauge value":   func(tb testing.TB) { recorder.AssertGauge(tb, "app.queue.depth", 1) },
+			"not sent":      func(tb testing.TB) { recorder.AssertNotSent(tb, "app.requests") },
+			"flushes":       func(tb testing.TB) { recorder.AssertFlushes(tb, 1) },
+			"sum":           func(tb testing.TB) { recorder.AssertSum(tb, "app.requests", 1) },
+			"tag sets":      func(tb testing.TB) { recorder.AssertTagSets(tb, "app.requests", []string{"path:/login"}) },
+		} {
+			tb := &fakeTB{}
+			assert(tb)
+			if tb.failed == "" {
+				tt.Fatalf("expected %s assertion to fail", name)
+			}
+		}
+	})
+
+	t.Run("wait for flush", func(tt *testing.T) {
+		ctx, cancel := context.WithTimeout(context.Background(), time.Second*5)
+		defer cancel()
+		flushes := recorder.Flushes()
+		stats.Increment("requests", nil)
+		stats.Flush()
+		if err := recorder.WaitForFlush(ctx, flushes); err != nil {
+			tt.Fatalf("expected flush, have %s", err.Error())
+		}
+
+		ctx, cancel = context.WithTimeout(context.Background(), time.Millisecond*20)
+		defer cancel()
+		if err := recorder.WaitForFlush(ctx, flushes+1); err != context.DeadlineExceeded {
+			tt.Fatalf("expected %v, have %v", context.DeadlineExceeded, err)
+		}
+	})
+
+	t.Run("error", func(tt *testing.T) {
+		recorder.Reset()
+		recorder.SetError(fmt.Errorf("api down"))
+		if err := recorder.SendEvent(&client.DDEvent{Title: "deploy"}); err == nil {
+			tt.Fatalf("expected error")
+		}
+		recorder.SetError(nil)
+		if err := recorder.SendEvent(&client.DDEvent{Title: "deploy"}); err != nil {
+			tt.Fatalf(err.Error())
+		}
+		if events := recorder.Events(); len(events) != 1 {
+			tt.Fatalf("expected %d events, have %d", 1, len(events))
+		}
+	})
+}
+
+func TestClock_AdvanceTime(t *testing.T) {
+
+	// Each test has its own clock, so these run in parallel
+	for _, name := range []string{"first", "second"} {
+		t.Run(name, func(tt *testing.T) {
+			tt.Parallel()
+
+			clock := NewClock()
+			recorder := NewRecordingClient()
+			stats, err := ddstats.NewStats(ddstats.NewConfig().
+				WithNamespace("app").
+				WithClient(recorder).
+				WithClock(clock))
+			if err != nil {
+				tt.Fatalf(err.Error())
+			}
+			defer stats.Close()
+
+			ctx, cancel := context.WithTimeout(context.Background(), time.Second*5)
+			defer cancel()
+			for i := 0; i < 2; i++ {
+				stats.Increment("requests", nil)
+				flushes := recorder.Flushes()
+				clock.AdvanceTime(ddstats.DefaultFlushInterval)
+				if err := recorder.WaitForFlush(ctx, flushes); err != nil {
+					tt.Fatalf("expected a flush after advancing time, have %s", err.Error())
+				}
+			}
+
+			recorder.AssertFlushes(tt, 2)
+			recorder.AssertCount(tt, "app.requests", 2)
+		})
+	}
+}
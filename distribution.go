@@ -0,0 +1,75 @@
+package ddstats
+
+import (
+	"context"
+	"fmt"
+
+	"github.com/jmizell/ddstats/client"
+)
+
+// distributionKeyPrefix separates distributions from other metrics with the same name, and
+// tags, so they're aggregated separately.
+const distributionKeyPrefix = "distribution|"
+
+// distributionMaxValues is the number of values a distribution stores in a flush interval.
+// Further values are dropped, and counted by GetDroppedMetricCount, so the memory used by a
+// distribution is bounded, no matter how many values are recorded.
+const distributionMaxValues = 10000
+
+// Distribution records value in a distribution metric. All values recorded in the flush
+// interval are sent to the Datadog distribution api, and aggregated server side, so
+// percentiles are accurate across hosts. Like other metrics, distributions are queued, and
+// dropped if the queue is full. Up to distributionMaxValues values are kept for each
+// distribution in a flush interval. Distributions require an api client that implements
+// client.DistributionClient, the default Datadog client does.
+func (c *Stats) Distribution(name string, value float64, tags []string, opts ...MetricOption) {
+	c.submit(name, client.Distribution, value, tags, opts...)
+}
+
+// full returns true if the metric is a distribution that has the max values for the flush
+// interval.
+func (m *metric) full() bool {
+	return m.class == client.Distribution && len(m.values) >= distributionMaxValues
+}
+
+// SendDistributions immediately posts a series of distributions to the Datadog api. If host,
+// or namespace values are missing, the values will be filled before sending to the api.
+// Global tags are added to all distributions.
+func (c *Stats) SendDistributions(series []*client.DDDistribution) error {
+	return c.sendDistributions(context.Background(), series)
+}
+
+// sendDistributions sends series, the send is cancelled when ctx is done, if the api client
+// implements client.ContextClient.
+func (c *Stats) sendDistributions(ctx context.Context, series []*client.DDDistribution) error {
+	if c.noop {
+		return nil
+	}
+	distributionClient, ok := c.client.(client.DistributionClient)
+	if !ok {
+		return fmt.Errorf("api client does not support distributions")
+	}
+	for _, d := range series {
+		if d.Host == "" {
+			d.Host = c.host
+		}
+		d.Metric = c.withNamespace(d.Metric)
+		d.Tags = combineTags(c.globalTags(), d.Tags)
+	}
+	if err := c.faultError(FaultEndpointDistribution); err != nil {
+		return err
+	}
+	return client.SendDistributionsContext(ctx, distributionClient, &client.DDDistributionSeries{Series: series})
+}
+
+// getDistribution returns the metric as a DDDistribution named name. The namespace is not
+// prepended, it's prepended when the series is sent.
+func (m *metric) getDistribution(name, host string, tags []string) *client.DDDistribution {
+	return &client.DDDistribution{
+		Host:   host,
+		Metric: name,
+		Points: [][2]interface{}{{m.pointTime(), m.values}},
+		Tags:   combineTags(m.tags, tags),
+		Type:   client.Distribution,
+	}
+}
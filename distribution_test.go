@@ -0,0 +1,121 @@
+package ddstats
+
+import (
+	"testing"
+
+	"github.com/jmizell/ddstats/client"
+)
+
+type distributionAPIClient struct {
+	*TestAPIClient
+	distributions []*client.DDDistributionSeries
+}
+
+func (d *distributionAPIClient) SendDistributions(series *client.DDDistributionSeries) error {
+	d.lock.Lock()
+	defer d.lock.Unlock()
+	d.distributions = append(d.distributions, series)
+	return nil
+}
+
+func TestStats_Distribution(t *testing.T) {
+
+	t.Run("send distributions", func(tt *testing.T) {
+		testClient := &distributionAPIClient{TestAPIClient: NewTestAPIClient()}
+		stats, err := NewStats(NewConfig().
+			WithNamespace(testNamespace).
+			WithHost(testHost).
+			WithTags(testTags).
+			WithClient(testClient))
+		if err != nil {
+			tt.Fatalf(err.Error())
+		}
+
+		stats.Distribution("test", 1, nil)
+		stats.Distribution("test", 2, nil)
+		stats.Count("test", 1, nil)
+		stats.Close()
+
+		if len(testClient.distributions) != 1 || len(testClient.distributions[0].Series) != 1 {
+			tt.Fatalf("expected one distribution, have %v", testClient.distributions)
+		}
+		d := testClient.distributions[0].Series[0]
+		if d.Metric != "testNamespace.test" || d.Host != testHost || d.Type != client.Distribution {
+			tt.Fatalf("expected distribution testNamespace.test, have %+v", d)
+		}
+		values, ok := d.Points[0][1].([]float64)
+		if !ok || len(values) != 2 || values[0] != 1 || values[1] != 2 {
+			tt.Fatalf("expected distribution values [1 2], have %v", d.Points[0][1])
+		}
+		if len(testClient.series) != 1 || len(testClient.series[0].Series) != 1 {
+			tt.Fatalf("expected count to be sent as a series, have %v", testClient.series)
+		}
+	})
+
+	t.Run("unsupported client", func(tt *testing.T) {
+		stats, testClient, err := NewTestStats()
+		if err != nil {
+			tt.Fatalf(err.Error())
+		}
+
+		stats.Distribution("test", 1, nil)
+		stats.Close()
+
+		if len(testClient.series) != 0 {
+			tt.Fatalf("expected no series calls, have %d", len(testClient.series))
+		}
+		if errs := stats.Errors(); len(errs) != 1 {
+			tt.Fatalf("expected %d error, have %v", 1, errs)
+		}
+	})
+	t.Run("max values", func(tt *testing.T) {
+		testClient := &distributionAPIClient{TestAPIClient: NewTestAPIClient()}
+		cfg := NewConfig().
+			WithNamespace(testNamespace).
+			WithHost(testHost).
+			WithClient(testClient).
+			WithBlockOnFull(true, 0)
+		stats, err := NewStats(cfg)
+		if err != nil {
+			tt.Fatalf(err.Error())
+		}
+
+		for i := 0; i < distributionMaxValues+5; i++ {
+			stats.Distribution("test", float64(i), nil)
+		}
+		stats.Close()
+
+		if dropped := stats.GetDroppedMetricCount(); dropped != 5 {
+			tt.Fatalf("expected %d dropped values, have %d", 5, dropped)
+		}
+		values, _ := testClient.distributions[0].Series[0].Points[0][1].([]float64)
+		if len(values) != distributionMaxValues {
+			tt.Fatalf("expected %d values, have %d", distributionMaxValues, len(values))
+		}
+	})
+
+	t.Run("dropped by fault injection", func(tt *testing.T) {
+		faults := NewRandomFaults(1)
+		faults.DropProbability = 1
+		testClient := &distributionAPIClient{TestAPIClient: NewTestAPIClient()}
+		cfg := NewConfig().
+			WithNamespace(testNamespace).
+			WithHost(testHost).
+			WithClient(testClient).
+			WithFaultInjector(faults)
+		stats, err := NewStats(cfg)
+		if err != nil {
+			tt.Fatalf(err.Error())
+		}
+
+		stats.Distribution("test", 1, nil)
+		stats.Close()
+
+		if dropped := stats.GetDroppedMetricCount(); dropped != 1 {
+			tt.Fatalf("expected %d dropped values, have %d", 1, dropped)
+		}
+		if len(testClient.distributions) != 0 {
+			tt.Fatalf("expected no distributions to be sent, have %d", len(testClient.distributions))
+		}
+	})
+}
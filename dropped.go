@@ -0,0 +1,77 @@
+package ddstats
+
+import (
+	"fmt"
+
+	"github.com/jmizell/ddstats/client"
+	"github.com/jmizell/ddstats/internal/engine"
+)
+
+// MetricDropped is the name of the dropped metrics count, this is prepended with the
+// namespace. It's tagged with metric_name:<name> of the dropped metric.
+const MetricDropped = "ddstats.dropped"
+
+// Dropped metric names beyond the max are counted under DroppedOtherName.
+const (
+	MaxDroppedNames  = 1000
+	DroppedOtherName = "_other"
+)
+
+// countDropped counts a dropped metric by name.
+func (c *Stats) countDropped(name string) {
+	c.droppedLock.Lock()
+	defer c.droppedLock.Unlock()
+	if c.droppedNames == nil {
+		c.droppedNames = map[string]uint64{}
+		c.droppedReported = map[string]uint64{}
+	}
+	if _, ok := c.droppedNames[name]; !ok && len(c.droppedNames) >= MaxDroppedNames {
+		name = DroppedOtherName
+	}
+	c.droppedNames[name]++
+}
+
+// DroppedMetrics returns the number of dropped metrics by metric name, since stats was
+// created. Only the first MaxDroppedNames names are tracked, drops of other names are
+// counted under DroppedOtherName. The total is GetDroppedMetricCount.
+func (c *Stats) DroppedMetrics() map[string]uint64 {
+	c.droppedLock.Lock()
+	defer c.droppedLock.Unlock()
+	dropped := make(map[string]uint64, len(c.droppedNames))
+	for name, n := range c.droppedNames {
+		dropped[name] = n
+	}
+	return dropped
+}
+
+// reportDropped queues a count of the metrics dropped since the last report, for each
+// metric name, if enabled. The counts are added to the flush directly, as the metric
+// queue may be full.
+func (c *Stats) reportDropped() {
+
+	if !c.droppedMetric {
+		return
+	}
+
+	c.droppedLock.Lock()
+	var series []*client.DDMetric
+	now := c.clock.Now().Unix()
+	interval, _ := engine.IntervalSeconds(c.EffectiveFlushInterval())
+	for name, n := range c.droppedNames {
+		if delta := n - c.droppedReported[name]; delta > 0 {
+			series = append(series, &client.DDMetric{
+				Interval: interval,
+				Metric:   MetricDropped,
+				Points:   [][2]interface{}{{now, float64(delta)}},
+				Tags:     append([]string{fmt.Sprintf("metric_name:%s", name)}, c.runtimeTags...),
+				Type:     client.Count,
+			})
+			c.droppedReported[name] = n
+		}
+	}
+	c.droppedLock.Unlock()
+
+	if len(series) > 0 {
+		_ = c.QueueSeries(series)
+	}
+}
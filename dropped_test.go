@@ -0,0 +1,74 @@
+package ddstats
+
+import (
+	"fmt"
+	"testing"
+)
+
+func TestStats_DroppedMetrics(t *testing.T) {
+
+	faults := NewRandomFaults(1)
+	faults.DropProbability = 1
+	testApi := NewTestAPIClient()
+	stats, err := NewStats(NewConfig().
+		WithNamespace(testNamespace).
+		WithHost(testHost).
+		WithClient(testApi).
+		WithFaultInjector(faults).
+		WithDroppedMetric(true))
+	if err != nil {
+		t.Fatalf(err.Error())
+	}
+
+	stats.Increment("a", nil)
+	stats.Increment("a", nil)
+	stats.Gauge("b", 1, nil)
+	dropped := stats.DroppedMetrics()
+	if dropped["a"] != 2 || dropped["b"] != 1 {
+		t.Fatalf("expected 2 a, and 1 b dropped, have %v", dropped)
+	}
+
+	stats.Flush()
+	stats.Increment("a", nil)
+	stats.Flush()
+	stats.Close()
+
+	testApi.lock.Lock()
+	defer testApi.lock.Unlock()
+	if len(testApi.series) != 2 {
+		t.Fatalf("expected %d series, have %d", 2, len(testApi.series))
+	}
+	for i, expected := range []map[string]float64{{"a": 2, "b": 1}, {"a": 1}} {
+		found := map[string]float64{}
+		for _, m := range testApi.series[i].Series {
+			if m.Metric != prependNamespace(testNamespace, MetricDropped) {
+				t.Fatalf("expected only %s metrics, have %s", MetricDropped, m.Metric)
+			}
+			for name := range expected {
+				if hasTag(m.Tags, fmt.Sprintf("metric_name:%s", name)) {
+					found[name] = m.Points[0][1].(float64)
+				}
+			}
+		}
+		if fmt.Sprint(found) != fmt.Sprint(expected) {
+			t.Fatalf("expected flush %d to report %v dropped, have %v", i, expected, found)
+		}
+	}
+}
+
+func TestStats_countDroppedOverflow(t *testing.T) {
+
+	stats, _, err := NewTestStats()
+	if err != nil {
+		t.Fatalf(err.Error())
+	}
+	defer stats.Close()
+
+	for i := 0; i < MaxDroppedNames+5; i++ {
+		stats.countDropped(fmt.Sprintf("metric.%d", i))
+	}
+	dropped := stats.DroppedMetrics()
+	if len(dropped) != MaxDroppedNames+1 || dropped[DroppedOtherName] != 5 {
+		t.Fatalf("expected %d names, and %d other, have %d names, and %d other", MaxDroppedNames+1, 5, len(dropped), dropped[DroppedOtherName])
+	}
+}
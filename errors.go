@@ -0,0 +1,184 @@
+package ddstats
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net/http"
+	"sync/atomic"
+
+	"github.com/jmizell/ddstats/client"
+)
+
+// MaxErrorsLimit is the hard limit on the number of stored errors. Config.MaxErrors
+// values above the limit, or below one are replaced.
+const MaxErrorsLimit = 10000
+
+// ErrorClass is the source of a stored error.
+type ErrorClass string
+
+// Error classes, in order of increasing priority.
+const (
+	ErrorClassWarning      = ErrorClass("warning")
+	ErrorClassContinuity   = ErrorClass("continuity")
+	ErrorClassServiceCheck = ErrorClass("service_check")
+	ErrorClassMirror       = ErrorClass("mirror")
+	ErrorClassSpool        = ErrorClass("spool")
+	ErrorClassDistribution = ErrorClass("distribution")
+	ErrorClassSeries       = ErrorClass("series")
+)
+
+var errorClassPriority = map[ErrorClass]int{
+	ErrorClassWarning:      0,
+	ErrorClassContinuity:   1,
+	ErrorClassServiceCheck: 1,
+	ErrorClassMirror:       1,
+	ErrorClassSpool:        1,
+	ErrorClassDistribution: 2,
+	ErrorClassSeries:       2,
+}
+
+// RepeatedError is stored in place of identical consecutive errors, when error
+// deduplication is enabled.
+type RepeatedError struct {
+	Err   error
+	Count int // Number of times the error occurred
+}
+
+func (e *RepeatedError) Error() string {
+	return fmt.Sprintf("%s (repeated %d times)", e.Err.Error(), e.Count)
+}
+
+// Unwrap returns the repeated error.
+func (e *RepeatedError) Unwrap() error {
+	return e.Err
+}
+
+// errorCounters counts errors by class. The counters are fixed when stats is created,
+// so they can be updated without a lock.
+type errorCounters map[ErrorClass]*uint64
+
+func newErrorCounters() errorCounters {
+	counters := errorCounters{}
+	for class := range errorClassPriority {
+		counters[class] = new(uint64)
+	}
+	return counters
+}
+
+// limitMaxErrors returns max, limited to the range one to MaxErrorsLimit. Values below
+// one are replaced with the default.
+func limitMaxErrors(max int) int {
+	switch {
+	case max <= 0:
+		return DefaultMaxErrorCount
+	case max > MaxErrorsLimit:
+		return MaxErrorsLimit
+	}
+	return max
+}
+
+// addError stores err, counts it by class, and drops the oldest lowest priority error if
+// the errors list is full. If deduplication is enabled, and err is the same as the most
+// recent error, the repeat count of the most recent error is incremented instead.
+func (c *Stats) addError(class ErrorClass, err error) {
+
+	if counter, ok := c.errorCounts[class]; ok {
+		atomic.AddUint64(counter, 1)
+	}
+
+	c.errorLock.Lock()
+	defer c.errorLock.Unlock()
+
+	if n := len(c.errors); c.dedupErrors && n > 0 && c.errorClasses[n-1] == class {
+		last, count := c.errors[n-1], 1
+		if repeated, ok := last.(*RepeatedError); ok {
+			last, count = repeated.Err, repeated.Count
+		}
+		if last.Error() == err.Error() {
+			c.errors[n-1] = &RepeatedError{Err: last, Count: count + 1}
+			return
+		}
+	}
+
+	c.errors, c.errorClasses = appendErrorsList(c.errors, c.errorClasses, err, class, c.maxErrors)
+}
+
+// GetErrorCounts returns the number of errors by class, since stats was created. Errors
+// are counted, even if they are dropped from, or deduplicated in the errors list.
+func (c *Stats) GetErrorCounts() map[ErrorClass]uint64 {
+	counts := make(map[ErrorClass]uint64, len(c.errorCounts))
+	for class, counter := range c.errorCounts {
+		counts[class] = atomic.LoadUint64(counter)
+	}
+	return counts
+}
+
+// appendErrorsList appends err of class to errors, and classes. If the list is full, the
+// oldest error of the lowest priority class is removed first.
+func appendErrorsList(errors []error, classes []ErrorClass, err error, class ErrorClass, max int) ([]error, []ErrorClass) {
+
+	if len(errors) >= max && len(errors) > 0 {
+		evict := 0
+		for i := range classes {
+			if errorClassPriority[classes[i]] < errorClassPriority[classes[evict]] {
+				evict = i
+			}
+		}
+		errors = append(errors[:evict:evict], errors[evict+1:]...)
+		classes = append(classes[:evict:evict], classes[evict+1:]...)
+	}
+
+	return append(errors, err), append(classes, class)
+}
+
+// IsAuthError returns true if err, or any error it wraps, is an api response rejecting
+// the api key, 401, or 403.
+func IsAuthError(err error) bool {
+	status := apiErrorStatus(err)
+	return status == http.StatusUnauthorized || status == http.StatusForbidden
+}
+
+// IsRateLimited returns true if err, or any error it wraps, is an api response of 429.
+// The time to wait before retrying is available from *client.RateLimitError.
+func IsRateLimited(err error) bool {
+	return apiErrorStatus(err) == http.StatusTooManyRequests
+}
+
+// IsPayloadTooLarge returns true if err, or any error it wraps, is an api response of 413.
+func IsPayloadTooLarge(err error) bool {
+	return apiErrorStatus(err) == http.StatusRequestEntityTooLarge
+}
+
+// IsTimeout returns true if err, or any error it wraps, is a network timeout, a context
+// deadline, or an api response of 408, or 504.
+func IsTimeout(err error) bool {
+
+	if errors.Is(err, context.DeadlineExceeded) {
+		return true
+	}
+	var timeout interface{ Timeout() bool }
+	if errors.As(err, &timeout) && timeout.Timeout() {
+		return true
+	}
+	status := apiErrorStatus(err)
+	return status == http.StatusRequestTimeout || status == http.StatusGatewayTimeout
+}
+
+// IsNetworkError returns true if err, or any error it wraps, is a *client.NetworkError,
+// a request that failed without a response from the api. Network errors are usually
+// transient, and the api may, or may not have received the request.
+func IsNetworkError(err error) bool {
+	var netErr *client.NetworkError
+	return errors.As(err, &netErr)
+}
+
+// apiErrorStatus returns the status code of a *client.APIError in the chain of err, or
+// zero.
+func apiErrorStatus(err error) int {
+	var apiErr *client.APIError
+	if errors.As(err, &apiErr) {
+		return apiErr.StatusCode
+	}
+	return 0
+}
@@ -0,0 +1,156 @@
+package ddstats
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"testing"
+
+	"github.com/jmizell/ddstats/client"
+)
+
+func Test_limitMaxErrors(t *testing.T) {
+	for max, expected := range map[int]int{
+		-1:                 DefaultMaxErrorCount,
+		0:                  DefaultMaxErrorCount,
+		10:                 10,
+		MaxErrorsLimit + 1: MaxErrorsLimit,
+	} {
+		if result := limitMaxErrors(max); result != expected {
+			t.Fatalf("expected max errors %d to be limited to %d, have %d", max, expected, result)
+		}
+	}
+}
+
+func Test_appendErrorsListPriority(t *testing.T) {
+
+	errs := []error{fmt.Errorf("series one"), fmt.Errorf("warning"), fmt.Errorf("series two")}
+	classes := []ErrorClass{ErrorClassSeries, ErrorClassWarning, ErrorClassSeries}
+
+	errs, classes = appendErrorsList(errs, classes, fmt.Errorf("series three"), ErrorClassSeries, 3)
+	if len(errs) != 3 || len(classes) != 3 {
+		t.Fatalf("expected to have %d errors, have %d", 3, len(errs))
+	}
+	for i, expected := range []string{"series one", "series two", "series three"} {
+		if errs[i].Error() != expected {
+			t.Fatalf("expected error %d to be %s, have %s", i, expected, errs[i].Error())
+		}
+	}
+
+	errs, _ = appendErrorsList(errs, classes, fmt.Errorf("series four"), ErrorClassSeries, 3)
+	if errs[0].Error() != "series two" {
+		t.Fatalf("expected oldest error to be removed, have %s", errs[0].Error())
+	}
+}
+
+func TestStats_addError(t *testing.T) {
+
+	t.Run("dedup", func(tt *testing.T) {
+		stats, _, err := NewTestStats()
+		if err != nil {
+			tt.Fatalf(err.Error())
+		}
+		defer stats.Close()
+		stats.dedupErrors = true
+
+		outage := fmt.Errorf("connection refused")
+		for i := 0; i < 5; i++ {
+			stats.addError(ErrorClassSeries, outage)
+		}
+		stats.addError(ErrorClassSpool, fmt.Errorf("disk full"))
+		stats.addError(ErrorClassSeries, outage)
+
+		errs := stats.Errors()
+		if len(errs) != 3 {
+			tt.Fatalf("expected to have %d errors, have %d, %v", 3, len(errs), errs)
+		}
+		repeated := &RepeatedError{}
+		if !errors.As(errs[0], &repeated) || repeated.Count != 5 {
+			tt.Fatalf("expected first error to be repeated %d times, have %s", 5, errs[0].Error())
+		}
+		if !errors.Is(errs[0], outage) {
+			tt.Fatalf("expected repeated error to unwrap to the original error")
+		}
+		if errs[2] != outage {
+			tt.Fatalf("expected non consecutive error to be stored again, have %s", errs[2].Error())
+		}
+
+		counts := stats.GetErrorCounts()
+		if counts[ErrorClassSeries] != 6 || counts[ErrorClassSpool] != 1 {
+			tt.Fatalf("expected %d series, and %d spool errors, have %v", 6, 1, counts)
+		}
+	})
+
+	t.Run("no dedup", func(tt *testing.T) {
+		stats, _, err := NewTestStats()
+		if err != nil {
+			tt.Fatalf(err.Error())
+		}
+		defer stats.Close()
+
+		for i := 0; i < 3; i++ {
+			stats.addError(ErrorClassSeries, fmt.Errorf("connection refused"))
+		}
+		if len(stats.Errors()) != 3 {
+			tt.Fatalf("expected to have %d errors, have %d", 3, len(stats.Errors()))
+		}
+	})
+}
+
+type testTimeoutError struct{}
+
+func (testTimeoutError) Error() string { return "i/o timeout" }
+func (testTimeoutError) Timeout() bool { return true }
+
+func TestErrorPredicates(t *testing.T) {
+
+	tests := []struct {
+		name    string
+		err     error
+		auth    bool
+		limited bool
+		timeout bool
+		large   bool
+	}{
+		{"unauthorized", &client.APIError{StatusCode: 401}, true, false, false, false},
+		{"forbidden", &client.APIError{StatusCode: 403}, true, false, false, false},
+		{"rate limited", &client.APIError{StatusCode: 429}, false, true, false, false},
+		{"rate limit error", &client.RateLimitError{APIError: client.APIError{StatusCode: 429}}, false, true, false, false},
+		{"too large", &client.APIError{StatusCode: 413}, false, false, false, true},
+		{"gateway timeout", &client.APIError{StatusCode: 504}, false, false, true, false},
+		{"network timeout", testTimeoutError{}, false, false, true, false},
+		{"network error timeout", &client.NetworkError{Op: "send", Err: testTimeoutError{}}, false, false, true, false},
+		{"deadline", fmt.Errorf("flush, %w", context.DeadlineExceeded), false, false, true, false},
+		{"repeated", &RepeatedError{Err: &client.APIError{StatusCode: 403}, Count: 2}, true, false, false, false},
+		{"server error", &client.APIError{StatusCode: 500}, false, false, false, false},
+		{"other", errors.New("other"), false, false, false, false},
+		{"nil", nil, false, false, false, false},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(tt *testing.T) {
+			if IsAuthError(test.err) != test.auth {
+				tt.Fatalf("expected IsAuthError to be %t", test.auth)
+			}
+			if IsRateLimited(test.err) != test.limited {
+				tt.Fatalf("expected IsRateLimited to be %t", test.limited)
+			}
+			if IsTimeout(test.err) != test.timeout {
+				tt.Fatalf("expected IsTimeout to be %t", test.timeout)
+			}
+			if IsPayloadTooLarge(test.err) != test.large {
+				tt.Fatalf("expected IsPayloadTooLarge to be %t", test.large)
+			}
+		})
+	}
+}
+
+func TestIsNetworkError(t *testing.T) {
+
+	if !IsNetworkError(&RepeatedError{Err: &client.NetworkError{Op: "send", Err: errors.New("refused")}, Count: 2}) {
+		t.Fatalf("expected a wrapped network error to be a network error")
+	}
+	if IsNetworkError(&client.APIError{StatusCode: 500}) {
+		t.Fatalf("expected an api error to not be a network error")
+	}
+}
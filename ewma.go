@@ -0,0 +1,42 @@
+package ddstats
+
+import (
+	"fmt"
+)
+
+// ewma is the class of metrics recorded with EWMA. EWMAs are sent as a gauge of the
+// smoothed value.
+const ewma = "ewma"
+
+// EWMA records value in an exponentially weighted moving average, and reports the smoothed
+// value as a gauge. This is a non-blocking method, if the channel buffer is full, then the
+// value is not recorded. Each value updates the average as alpha*value + (1-alpha)*average,
+// so a higher alpha, between zero, and one, follows changes more closely. The first value
+// recorded for a metric is used as the initial average.
+//
+// The average is kept across flush intervals, and is only sent for intervals with at least
+// one update. An average that isn't updated for a number of intervals is forgotten, see
+// Config.WithKeptValueExpiry. Values with an alpha outside of (0, 1] are dropped, and a
+// warning is added to the errors list.
+func (c *Stats) EWMA(name string, value, alpha float64, tags []string, opts ...MetricOption) {
+
+	if !(alpha > 0 && alpha <= 1) {
+		c.addError(ErrorClassWarning, fmt.Errorf("could not record ewma %s, alpha %f must be greater than 0, and at most 1", name, alpha))
+		return
+	}
+
+	tags, o, ok := c.prepareSubmission(name, tags, opts)
+	if !ok {
+		return
+	}
+
+	m := &metric{
+		name:  name,
+		class: ewma,
+		value: value,
+		alpha: alpha,
+		tags:  tags,
+	}
+	o.apply(m)
+	c.enqueue(m, o)
+}
@@ -0,0 +1,41 @@
+package ddstats
+
+import (
+	"math"
+	"testing"
+)
+
+func TestStats_EWMA(t *testing.T) {
+
+	stats, testApi, err := NewTestStats()
+	if err != nil {
+		t.Fatalf(err.Error())
+	}
+
+	stats.EWMA("test", 10, 0.5, nil)
+	stats.EWMA("test", 20, 0.5, nil)
+	stats.Flush()
+	stats.EWMA("test", 5, 0.5, nil)
+	stats.Flush()
+	stats.Flush()
+	stats.EWMA("test", 1, 2, nil)
+	stats.Close()
+
+	testApi.lock.Lock()
+	defer testApi.lock.Unlock()
+	if len(testApi.series) != 2 {
+		t.Fatalf("expected %d series, have %d", 2, len(testApi.series))
+	}
+	for i, expected := range []float64{15, 10} {
+		m := testApi.series[i].Series[0]
+		if m.Type != "gauge" {
+			t.Fatalf("expected ewma to be sent as a gauge, have %s", m.Type)
+		}
+		if value := m.Points[0][1].(float64); math.Abs(value-expected) > 1e-9 {
+			t.Fatalf("expected flush %d to have value %f, have %f", i, expected, value)
+		}
+	}
+	if len(stats.Errors()) != 1 {
+		t.Fatalf("expected an invalid alpha to add %d error, have %v", 1, stats.Errors())
+	}
+}
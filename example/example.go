@@ -8,7 +8,8 @@ import (
 func main() {
 
 	// Create a config with parameters, or use the FromEnv method to
-	// load config from environment variables.
+	// load config from environment variables. ConfigFromFile loads a
+	// config from a JSON, or YAML file, with environment overrides.
 	cfg := ddstats.NewConfig().
 		WithNamespace("my-namespace").
 		WithHost("myhost.local").
@@ -0,0 +1,92 @@
+package ddstats
+
+import (
+	"fmt"
+	"math/rand"
+	"sync"
+	"time"
+)
+
+// Api endpoints passed to FaultInjector.ClientError.
+const (
+	FaultEndpointSeries       = "series"
+	FaultEndpointServiceCheck = "check_run"
+	FaultEndpointEvent        = "events"
+	FaultEndpointDistribution = "distribution_points"
+)
+
+// FaultInjector injects artificial failures into the pipeline. It is intended for testing,
+// so alerting on dropped metrics, and error callbacks can be verified before a real
+// failure happens. Methods are called concurrently.
+type FaultInjector interface {
+
+	// DropMetric returns true if a submitted metric should be dropped, as if the metric
+	// queue were full. Dropped metrics are included in GetDroppedMetricCount.
+	DropMetric() bool
+
+	// WorkerDelay returns the time a worker sleeps before processing a metric, to simulate
+	// a slow worker.
+	WorkerDelay() time.Duration
+
+	// ClientError returns an error that is returned in place of calling the api client for
+	// endpoint, or nil to call the client.
+	ClientError(endpoint string) error
+}
+
+// RandomFaults is a FaultInjector that injects each kind of failure with a fixed
+// probability. The fields must not be changed after stats is created.
+type RandomFaults struct {
+	DropProbability  float64       // Probability a submitted metric is dropped
+	SlowProbability  float64       // Probability a worker sleeps for SlowDelay before processing a metric
+	SlowDelay        time.Duration // Time a slow worker sleeps
+	ErrorProbability float64       // Probability an api call fails
+	rand             *rand.Rand
+	lock             *sync.Mutex
+}
+
+// NewRandomFaults returns a RandomFaults, with no failures enabled. The same seed gives
+// the same sequence of failures, for the same sequence of calls.
+func NewRandomFaults(seed int64) *RandomFaults {
+	return &RandomFaults{
+		rand: rand.New(rand.NewSource(seed)),
+		lock: &sync.Mutex{},
+	}
+}
+
+func (f *RandomFaults) happens(p float64) bool {
+	if p <= 0 {
+		return false
+	}
+	f.lock.Lock()
+	defer f.lock.Unlock()
+	return f.rand.Float64() < p
+}
+
+// DropMetric returns true with DropProbability.
+func (f *RandomFaults) DropMetric() bool {
+	return f.happens(f.DropProbability)
+}
+
+// WorkerDelay returns SlowDelay with SlowProbability, otherwise zero.
+func (f *RandomFaults) WorkerDelay() time.Duration {
+	if f.happens(f.SlowProbability) {
+		return f.SlowDelay
+	}
+	return 0
+}
+
+// ClientError returns an error with ErrorProbability.
+func (f *RandomFaults) ClientError(endpoint string) error {
+	if f.happens(f.ErrorProbability) {
+		return fmt.Errorf("injected fault, could not send %s", endpoint)
+	}
+	return nil
+}
+
+// faultError returns the error injected for an api call to endpoint, if any.
+func (c *Stats) faultError(endpoint string) error {
+	if c.faults == nil {
+		return nil
+	}
+	return c.faults.ClientError(endpoint)
+}
@@ -0,0 +1,79 @@
+package ddstats
+
+import (
+	"testing"
+	"time"
+
+	"github.com/jmizell/ddstats/client"
+)
+
+func TestRandomFaults(t *testing.T) {
+
+	t.Run("disabled", func(tt *testing.T) {
+		f := NewRandomFaults(1)
+		for i := 0; i < 100; i++ {
+			if f.DropMetric() || f.WorkerDelay() != 0 || f.ClientError(FaultEndpointSeries) != nil {
+				tt.Fatalf("expected no faults with zero probabilities")
+			}
+		}
+	})
+
+	t.Run("always", func(tt *testing.T) {
+		f := NewRandomFaults(1)
+		f.DropProbability = 1
+		f.SlowProbability = 1
+		f.SlowDelay = time.Millisecond
+		f.ErrorProbability = 1
+		if !f.DropMetric() {
+			tt.Fatalf("expected metric to be dropped")
+		}
+		if f.WorkerDelay() != time.Millisecond {
+			tt.Fatalf("expected worker delay to be %s, have %s", time.Millisecond, f.WorkerDelay())
+		}
+		if f.ClientError(FaultEndpointSeries) == nil {
+			tt.Fatalf("expected client error")
+		}
+	})
+}
+
+func TestStats_FaultInjector(t *testing.T) {
+
+	faults := NewRandomFaults(1)
+	faults.DropProbability = 1
+	faults.ErrorProbability = 1
+
+	errs := make(chan error, 10)
+	testApi := NewTestAPIClient()
+	stats, err := NewStats(NewConfig().
+		WithNamespace(testNamespace).
+		WithHost(testHost).
+		WithClient(testApi).
+		WithFaultInjector(faults))
+	if err != nil {
+		t.Fatalf(err.Error())
+	}
+	stats.ErrorCallback(func(err error, _ []*client.DDMetric) { errs <- err })
+
+	stats.Increment("test", nil)
+	if stats.GetDroppedMetricCount() != 1 {
+		t.Fatalf("expected %d dropped metric, have %d", 1, stats.GetDroppedMetricCount())
+	}
+	if err := stats.ServiceCheck("test", "", client.Okay, nil); err == nil {
+		t.Fatalf("expected injected service check error")
+	}
+
+	stats.QueueSeries([]*client.DDMetric{{Metric: "queued", Type: client.Gauge, Points: [][2]interface{}{{time.Now().Unix(), 1.0}}}})
+	stats.Flush()
+	select {
+	case <-errs:
+	case <-time.After(time.Second):
+		t.Fatalf("expected error callback for injected series error")
+	}
+	stats.Close()
+
+	testApi.lock.Lock()
+	defer testApi.lock.Unlock()
+	if len(testApi.series) != 0 || len(testApi.checks) != 0 {
+		t.Fatalf("expected api client to not be called, have %d series, %d checks", len(testApi.series), len(testApi.checks))
+	}
+}
@@ -0,0 +1,71 @@
+package ddstats
+
+import (
+	"sync/atomic"
+	"time"
+
+	"github.com/jmizell/ddstats/client"
+)
+
+// DefaultFlushErrorBuffer is the default number of flush errors that can be waiting in the
+// errors channel.
+const DefaultFlushErrorBuffer = 100
+
+// FlushError is a failed api call of a flush, delivered by ErrorsChan. A series that is
+// split into multiple requests can fail partially, when only some of the requests fail,
+// Err is then a *client.PartialError, and Series only holds the series of the failed
+// requests, the rest were accepted by the api.
+type FlushError struct {
+	Err            error
+	Class          ErrorClass               // ErrorClassSeries, or ErrorClassDistribution
+	Series         []*client.DDMetric       // Series that were not sent, for series errors
+	Distributions  []*client.DDDistribution // Distributions that were not sent, for distribution errors
+	Requests       int                      // Number of requests the series, or distributions were sent in
+	FailedRequests int                      // Number of those requests that failed
+	Start          time.Time                // When the api call started
+	End            time.Time                // When the api call returned
+}
+
+// Partial returns true if only some of the requests of the api call failed.
+func (e *FlushError) Partial() bool {
+	return e.FailedRequests < e.Requests
+}
+
+func (e *FlushError) Error() string {
+	return e.Err.Error()
+}
+
+// Unwrap returns the error returned by the api client.
+func (e *FlushError) Unwrap() error {
+	return e.Err
+}
+
+// ErrorsChan returns a channel that receives each failed api call of a flush, in flush
+// order. It's an alternative to ErrorCallback, errors are sent to the channel without
+// waiting, so a slow consumer can't delay flushes. Errors are only sent after the first
+// call to ErrorsChan. When more than Config.FlushErrorBuffer errors are waiting, errors
+// are dropped, and counted by GetDroppedFlushErrorCount. The channel is closed when stats
+// is closed.
+func (c *Stats) ErrorsChan() <-chan *FlushError {
+	atomic.StoreInt32(&c.flushErrorsEnabled, 1)
+	return c.flushErrors
+}
+
+// sendFlushError sends err to the errors channel, if it's in use. The error is dropped if
+// the channel is full.
+func (c *Stats) sendFlushError(err *FlushError) {
+	if c.flushErrors == nil || atomic.LoadInt32(&c.flushErrorsEnabled) == 0 {
+		return
+	}
+	select {
+	case c.flushErrors <- err:
+	default:
+		atomic.AddUint64(&c.flushErrorsDropped, 1)
+	}
+}
+
+// GetDroppedFlushErrorCount returns the number of errors that were not sent to the errors
+// channel, because the channel was full.
+func (c *Stats) GetDroppedFlushErrorCount() uint64 {
+	return atomic.LoadUint64(&c.flushErrorsDropped)
+}
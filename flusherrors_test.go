@@ -0,0 +1,121 @@
+package ddstats
+
+import (
+	"errors"
+	"fmt"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/jmizell/ddstats/client"
+)
+
+func TestStats_ErrorsChan(t *testing.T) {
+
+	testClient := NewTestAPIClient()
+	testClient.sendSeriesError = fmt.Errorf("failed send")
+	stats, err := NewStats(NewConfig().
+		WithNamespace(testNamespace).
+		WithHost(testHost).
+		WithClient(testClient).
+		WithFlushErrorBuffer(2))
+	if err != nil {
+		t.Fatalf(err.Error())
+	}
+
+	// Errors before the channel is requested are not sent
+	stats.Gauge("test", 1, nil)
+	stats.Flush()
+
+	errs := stats.ErrorsChan()
+	for i := 0; i < 3; i++ {
+		stats.Gauge("test", 1, nil)
+		stats.Flush()
+	}
+	stats.Close()
+
+	var received []*FlushError
+	for flushErr := range errs {
+		received = append(received, flushErr)
+	}
+	if len(received) != 2 {
+		t.Fatalf("expected %d errors, have %d", 2, len(received))
+	}
+	if dropped := stats.GetDroppedFlushErrorCount(); dropped != 1 {
+		t.Fatalf("expected %d dropped errors, have %d", 1, dropped)
+	}
+
+	flushErr := received[0]
+	if !errors.Is(flushErr, testClient.sendSeriesError) {
+		t.Fatalf("expected error %v, have %v", testClient.sendSeriesError, flushErr.Err)
+	}
+	if flushErr.Class != ErrorClassSeries {
+		t.Fatalf("expected class %s, have %s", ErrorClassSeries, flushErr.Class)
+	}
+	if len(flushErr.Series) != 1 || flushErr.Series[0].Metric != prependNamespace(testNamespace, "test") {
+		t.Fatalf("expected the failed series, have %v", flushErr.Series)
+	}
+	if flushErr.Start.IsZero() || flushErr.End.Before(flushErr.Start) {
+		t.Fatalf("expected start, and end times, have %s, %s", flushErr.Start, flushErr.End)
+	}
+	if flushErr.Partial() || flushErr.Requests != 1 || flushErr.FailedRequests != 1 {
+		t.Fatalf("expected the flush to fail entirely, have %d of %d requests failed", flushErr.FailedRequests, flushErr.Requests)
+	}
+}
+
+func TestStats_ErrorsChanPartial(t *testing.T) {
+
+	// Each metric is sent in its own request, and the request with metric b fails
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		body, _ := ioutil.ReadAll(r.Body)
+		if strings.Contains(string(body), prependNamespace(testNamespace, "b")) {
+			w.WriteHeader(http.StatusBadRequest)
+			_, _ = w.Write([]byte(`{"errors":["invalid metric"]}`))
+			return
+		}
+		w.WriteHeader(http.StatusAccepted)
+		_, _ = w.Write([]byte(`{}`))
+	}))
+	defer server.Close()
+	api := client.NewDDClient("testKey")
+	if err := api.SetBaseURL(server.URL); err != nil {
+		t.Fatalf(err.Error())
+	}
+	api.SetChunking(1, 0)
+
+	stats, err := NewStats(NewConfig().
+		WithNamespace(testNamespace).
+		WithHost(testHost).
+		WithClient(api))
+	if err != nil {
+		t.Fatalf(err.Error())
+	}
+	errs := stats.ErrorsChan()
+	var result *FlushResult
+	stats.FlushResultCallback(func(r *FlushResult) {
+		result = r
+	})
+
+	for _, name := range []string{"a", "b", "c"} {
+		stats.Gauge(name, 1, nil)
+	}
+	stats.Flush()
+	stats.Close()
+
+	flushErr := <-errs
+	if flushErr == nil || !flushErr.Partial() || flushErr.Requests != 3 || flushErr.FailedRequests != 1 {
+		t.Fatalf("expected %d of %d requests to fail, have %+v", 1, 3, flushErr)
+	}
+	var partial *client.PartialError
+	if !errors.As(flushErr, &partial) {
+		t.Fatalf("expected a partial error, have %v", flushErr.Err)
+	}
+	if len(flushErr.Series) != 1 || flushErr.Series[0].Metric != prependNamespace(testNamespace, "b") {
+		t.Fatalf("expected only the series of the failed request, have %v", flushErr.Series)
+	}
+	if result == nil || result.Series != 3 || result.FailedSeries != 1 {
+		t.Fatalf("expected %d of %d series to fail, have %+v", 1, 3, result)
+	}
+}
@@ -0,0 +1,92 @@
+package ddstats
+
+import (
+	"context"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"github.com/jmizell/ddstats/internal/engine"
+)
+
+// flushAllGroups is the group of a flush job that flushes every flush interval group.
+const flushAllGroups = -1
+
+// flushGroup holds the metrics flushed at an interval other than the flush interval. The
+// metrics are aggregated in separate shards, so they can be drained on their own.
+type flushGroup struct {
+	interval  time.Duration
+	shards    *engine.Shards
+	lastFlush time.Time
+}
+
+// newFlushGroups groups the metric names by flush interval, and returns the groups, and
+// the group of each name, numbered from one. Intervals that are the same as the default
+// interval, or not positive are ignored.
+func newFlushGroups(intervals map[string]float64, defaultInterval time.Duration) ([]*flushGroup, map[string]int) {
+
+	var groups []*flushGroup
+	names := map[string]int{}
+	byInterval := map[time.Duration]int{}
+	for name, seconds := range intervals {
+		interval := time.Duration(seconds * float64(time.Second))
+		if interval <= 0 || interval == defaultInterval {
+			continue
+		}
+		group, ok := byInterval[interval]
+		if !ok {
+			groups = append(groups, &flushGroup{interval: interval})
+			group = len(groups)
+			byInterval[interval] = group
+		}
+		names[name] = group
+	}
+
+	if len(groups) == 0 {
+		return nil, nil
+	}
+	return groups, names
+}
+
+// flushGroupSignal sends a flush job for group each time timer fires, and resets it to
+// interval, until shutdown is closed.
+func (c *Stats) flushGroupSignal(group int, timer ClockTimer, interval time.Duration, shutdown chan bool, wg *sync.WaitGroup) {
+
+	defer wg.Done()
+	defer timer.Stop()
+	for {
+		select {
+		case <-timer.C():
+			timer.Reset(interval)
+			select {
+			case c.jobs <- &job{flush: true, group: group}:
+			case <-shutdown:
+				return
+			}
+		case <-shutdown:
+			return
+		}
+	}
+}
+
+// commitGroupFlush sends the metrics of a flush interval group. Only the metrics are sent,
+// queued series, distributions, and checks are sent with the default group.
+func (c *Stats) commitGroupFlush(ctx context.Context, g *flushGroup) {
+
+	metrics := make(map[string]*metric, g.shards.Len())
+	expires := c.clock.Now().Add(g.interval * time.Duration(c.keptValueExpiry))
+	g.shards.Drain(func(shard int, key string, a engine.Aggregate) {
+		m := a.(*metric)
+		c.keepValue(shard, key, m, expires)
+		metrics[key] = m
+	})
+	atomic.AddInt64(&c.aggregatedKeys, -int64(len(metrics)))
+	c.recordPrometheus(metrics)
+
+	now := c.clock.Now()
+	order := &flushOrder{prev: c.lastSend, done: make(chan bool)}
+	c.lastSend = order.done
+	c.flushWG.Add(1)
+	go c.sendMetrics(ctx, metrics, now.Sub(g.lastFlush), order, false)
+	g.lastFlush = now
+}
@@ -0,0 +1,85 @@
+package ddstats
+
+import (
+	"testing"
+	"time"
+)
+
+func TestNewFlushGroups(t *testing.T) {
+
+	groups, names := newFlushGroups(map[string]float64{
+		"heartbeat": 10,
+		"alive":     10,
+		"slow":      300,
+		"default":   60,
+		"disabled":  0,
+	}, time.Second*60)
+	if len(groups) != 2 {
+		t.Fatalf("expected %d groups, have %d", 2, len(groups))
+	}
+	if names["heartbeat"] == 0 || names["heartbeat"] != names["alive"] {
+		t.Fatalf("expected heartbeat, and alive to share a group, have %v", names)
+	}
+	if names["slow"] == 0 || names["slow"] == names["heartbeat"] {
+		t.Fatalf("expected slow to have its own group, have %v", names)
+	}
+	if names["default"] != 0 || names["disabled"] != 0 {
+		t.Fatalf("expected default, and disabled to be in the default group, have %v", names)
+	}
+	if groups[names["slow"]-1].interval != time.Second*300 {
+		t.Fatalf("expected interval to be %s, have %s", time.Second*300, groups[names["slow"]-1].interval)
+	}
+
+	if groups, names := newFlushGroups(nil, time.Second*60); groups != nil || names != nil {
+		t.Fatalf("expected no groups, have %v", groups)
+	}
+}
+
+func TestStats_WithMetricFlushInterval(t *testing.T) {
+
+	testApi := NewTestAPIClient()
+	cfg := NewConfig().
+		WithNamespace(testNamespace).
+		WithHost(testHost).
+		WithClient(testApi).
+		WithMetricFlushInterval("heartbeat", time.Millisecond*50)
+	cfg.FlushIntervalSeconds = 60
+	stats, err := NewStats(cfg)
+	if err != nil {
+		t.Fatalf(err.Error())
+	}
+
+	stats.Increment("heartbeat", nil)
+	stats.Increment("requests", nil)
+	time.Sleep(time.Millisecond * 200)
+
+	sent := func() map[string]int {
+		testApi.lock.Lock()
+		defer testApi.lock.Unlock()
+		found := map[string]int{}
+		for _, series := range testApi.series {
+			for _, m := range series.Series {
+				found[m.Metric]++
+			}
+		}
+		return found
+	}
+
+	found := sent()
+	if found[prependNamespace(testNamespace, "heartbeat")] != 1 {
+		t.Fatalf("expected heartbeat to be sent before the flush interval, have %v", found)
+	}
+	if found[prependNamespace(testNamespace, "requests")] != 0 {
+		t.Fatalf("expected requests to not be sent before the flush interval, have %v", found)
+	}
+
+	stats.Increment("heartbeat", nil)
+	stats.Close()
+	found = sent()
+	if found[prependNamespace(testNamespace, "heartbeat")] != 2 {
+		t.Fatalf("expected heartbeat to be sent on close, have %v", found)
+	}
+	if found[prependNamespace(testNamespace, "requests")] != 1 {
+		t.Fatalf("expected requests to be sent on close, have %v", found)
+	}
+}
@@ -0,0 +1,36 @@
+package ddstats
+
+// gaugeDelta is the class of metrics recorded with GaugeAdd, and GaugeSub. They're sent as
+// a gauge of the running total.
+const gaugeDelta = "gauge_delta"
+
+// GaugeAdd adds delta to the current value of a gauge metric, instead of replacing it.
+// This is a non-blocking method, if the channel buffer is full, then the delta is not
+// recorded. The value starts at zero, and is kept across flush intervals, so a gauge can
+// track in-flight requests, or a pool size, without the caller keeping its own counter.
+//
+// The value is only sent for intervals with at least one update, and is forgotten if it
+// isn't updated for a number of intervals, see Config.WithKeptValueExpiry. A gauge updated
+// with GaugeAdd is separate from gauges set with Gauge, the same name, and tags should not
+// be used with both.
+func (c *Stats) GaugeAdd(name string, delta float64, tags []string, opts ...MetricOption) {
+
+	tags, o, ok := c.prepareSubmission(name, tags, opts)
+	if !ok {
+		return
+	}
+
+	m := &metric{
+		name:  name,
+		class: gaugeDelta,
+		value: sampleValue(gaugeDelta, delta, o.sampleRate),
+		tags:  tags,
+	}
+	o.apply(m)
+	c.enqueue(m, o)
+}
+
+// GaugeSub subtracts delta from the current value of a gauge metric, see GaugeAdd.
+func (c *Stats) GaugeSub(name string, delta float64, tags []string, opts ...MetricOption) {
+	c.GaugeAdd(name, -delta, tags, opts...)
+}
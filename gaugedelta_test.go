@@ -0,0 +1,89 @@
+package ddstats
+
+import (
+	"testing"
+	"time"
+)
+
+func TestStats_GaugeAdd(t *testing.T) {
+
+	stats, testApi, err := NewTestStats()
+	if err != nil {
+		t.Fatalf(err.Error())
+	}
+
+	stats.GaugeAdd("test", 3, nil)
+	stats.GaugeSub("test", 1, nil)
+	stats.Flush()
+	stats.GaugeAdd("test", 5, nil)
+	stats.Flush()
+	stats.Flush()
+	stats.GaugeSub("test", 7, nil)
+	stats.Close()
+
+	testApi.lock.Lock()
+	defer testApi.lock.Unlock()
+	if len(testApi.series) != 3 {
+		t.Fatalf("expected %d series, have %d", 3, len(testApi.series))
+	}
+	for i, expected := range []float64{2, 7, 0} {
+		m := testApi.series[i].Series[0]
+		if m.Type != "gauge" {
+			t.Fatalf("expected gauge delta to be sent as a gauge, have %s", m.Type)
+		}
+		if value := m.Points[0][1].(float64); value != expected {
+			t.Fatalf("expected flush %d to have value %f, have %f", i, expected, value)
+		}
+	}
+}
+
+func TestStats_KeptValueExpiry(t *testing.T) {
+
+	clock := NewManualClock(time.Unix(1000, 0))
+	testApi := NewTestAPIClient()
+	cfg := NewConfig().
+		WithNamespace(testNamespace).
+		WithHost(testHost).
+		WithClient(testApi).
+		WithClock(clock).
+		WithKeptValueExpiry(2)
+	cfg.FlushIntervalSeconds = 60
+	stats, err := NewStats(cfg)
+	if err != nil {
+		t.Fatalf(err.Error())
+	}
+
+	keptValues := func() int {
+		n := 0
+		for _, kept := range stats.keptValues {
+			n += len(kept)
+		}
+		return n
+	}
+
+	stats.GaugeAdd("test", 5, nil)
+	stats.Flush()
+	clock.Advance(time.Minute * 2)
+	stats.Flush()
+	if n := keptValues(); n != 1 {
+		t.Fatalf("expected the value to be kept within the expiry, have %d kept values", n)
+	}
+
+	clock.Advance(time.Minute)
+	stats.Flush()
+	if n := keptValues(); n != 0 {
+		t.Fatalf("expected the value to expire, have %d kept values", n)
+	}
+
+	stats.GaugeAdd("test", 1, nil)
+	stats.Close()
+
+	testApi.lock.Lock()
+	defer testApi.lock.Unlock()
+	if len(testApi.series) != 2 {
+		t.Fatalf("expected %d series, have %d", 2, len(testApi.series))
+	}
+	if value := testApi.series[1].Series[0].Points[0][1].(float64); value != 1 {
+		t.Fatalf("expected an expired gauge to start over at %f, have %f", 1.0, value)
+	}
+}
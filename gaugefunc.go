@@ -0,0 +1,32 @@
+package ddstats
+
+type registeredGaugeFunc struct {
+	name string
+	tags []string
+	fn   func() float64
+}
+
+// RegisterGaugeFunc registers a function that is called on every flush, and the returned
+// value is recorded as a gauge named name, with tags, using Gauge. Use it to report values
+// such as queue depths, or pool sizes, without a ticker goroutine. The function is called
+// from the flush goroutine, before the metrics of the interval are collected, a slow
+// function delays the flush. The tags are copied, so the caller may reuse its slice.
+func (c *Stats) RegisterGaugeFunc(name string, tags []string, fn func() float64) {
+	g := &registeredGaugeFunc{name: name, tags: append([]string(nil), tags...), fn: fn}
+	c.gaugeFuncLock.Lock()
+	defer c.gaugeFuncLock.Unlock()
+	c.gaugeFuncs = append(c.gaugeFuncs, g)
+}
+
+// collectGaugeFuncs calls each registered gauge function, and records the values.
+func (c *Stats) collectGaugeFuncs() {
+
+	c.gaugeFuncLock.Lock()
+	gauges := make([]*registeredGaugeFunc, len(c.gaugeFuncs))
+	copy(gauges, c.gaugeFuncs)
+	c.gaugeFuncLock.Unlock()
+
+	for _, g := range gauges {
+		c.Gauge(g.name, g.fn(), g.tags)
+	}
+}
@@ -0,0 +1,49 @@
+package ddstats
+
+import (
+	"testing"
+)
+
+func TestStats_RegisterGaugeFunc(t *testing.T) {
+
+	stats, testApi, err := NewTestStats()
+	if err != nil {
+		t.Fatalf(err.Error())
+	}
+
+	depth := 0.0
+	tags := []string{"queue:jobs", "pool:b", "az:a"}
+	stats.RegisterGaugeFunc("queue.depth", tags, func() float64 {
+		depth++
+		return depth
+	})
+	tags[0] = "queue:changed"
+	stats.Flush()
+	stats.Flush()
+	stats.Close()
+
+	// The registered tags are submitted on every flush, and must not be sorted by the
+	// workers, or changed by the caller
+	registered := stats.gaugeFuncs[0].tags
+	if registered[0] != "queue:jobs" || registered[2] != "az:a" {
+		t.Fatalf("expected the registered tags to be a copy in their original order, have %v", registered)
+	}
+
+	testApi.lock.Lock()
+	defer testApi.lock.Unlock()
+	if len(testApi.series) != 2 {
+		t.Fatalf("expected %d series, have %d", 2, len(testApi.series))
+	}
+	for i, expected := range []float64{1, 2} {
+		m := testApi.series[i].Series[0]
+		if m.Metric != prependNamespace(testNamespace, "queue.depth") || m.Type != "gauge" {
+			t.Fatalf("expected gauge %s, have %s %s", prependNamespace(testNamespace, "queue.depth"), m.Type, m.Metric)
+		}
+		if value := m.Points[0][1].(float64); value != expected {
+			t.Fatalf("expected flush %d to have value %f, have %f", i, expected, value)
+		}
+		if !hasTag(m.Tags, "queue:jobs") {
+			t.Fatalf("expected flush %d to have the registered tags, have %v", i, m.Tags)
+		}
+	}
+}
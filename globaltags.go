@@ -0,0 +1,57 @@
+package ddstats
+
+// globalTags returns the global tags. The returned slice is replaced, never modified, when
+// the global tags change, so it's safe to read without the lock.
+func (c *Stats) globalTags() []string {
+	c.tagsLock.RLock()
+	defer c.tagsLock.RUnlock()
+	return c.tags
+}
+
+// GetGlobalTags returns a copy of the global tags.
+func (c *Stats) GetGlobalTags() []string {
+	return append([]string{}, c.globalTags()...)
+}
+
+// AddGlobalTags adds tags to the global tags. Tags are normalized with NormalizeTags, and
+// tags already present are ignored. The global tags are applied when metrics are sent,
+// so the change takes effect on the next flush, including for metrics already recorded
+// in the current flush interval.
+func (c *Stats) AddGlobalTags(tags ...string) {
+	c.tagsLock.Lock()
+	defer c.tagsLock.Unlock()
+	normalized, _ := NormalizeTags(append(append([]string{}, c.tags...), tags...))
+	c.tags = c.normalizeGlobalTags(normalized)
+}
+
+// RemoveGlobalTag removes tag from the global tags, the change takes effect on the next
+// flush.
+func (c *Stats) RemoveGlobalTag(tag string) {
+	c.tagsLock.Lock()
+	defer c.tagsLock.Unlock()
+	tags := make([]string, 0, len(c.tags))
+	for _, t := range c.tags {
+		if t != tag {
+			tags = append(tags, t)
+		}
+	}
+	c.tags = tags
+}
+
+// SetGlobalTags replaces the global tags, the change takes effect on the next flush. Tags
+// are normalized with NormalizeTags.
+func (c *Stats) SetGlobalTags(tags []string) {
+	normalized, _ := NormalizeTags(tags)
+	normalized = c.normalizeGlobalTags(normalized)
+	c.tagsLock.Lock()
+	defer c.tagsLock.Unlock()
+	c.tags = normalized
+}
+
+// normalizeGlobalTags sanitizes tags, if tag normalization is enabled.
+func (c *Stats) normalizeGlobalTags(tags []string) []string {
+	if c.tagNormalization {
+		return SanitizeTags(tags, c.maxTagLength)
+	}
+	return tags
+}
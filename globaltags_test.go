@@ -0,0 +1,45 @@
+package ddstats
+
+import (
+	"testing"
+)
+
+func TestStats_GlobalTags(t *testing.T) {
+
+	stats, testApi, err := NewTestStats()
+	if err != nil {
+		t.Fatalf(err.Error())
+	}
+
+	stats.SetGlobalTags([]string{"env:test", "role:follower"})
+	stats.Gauge("test", 1, nil)
+	stats.RemoveGlobalTag("role:follower")
+	stats.AddGlobalTags("role:leader", "env:test", " ")
+	stats.Flush()
+
+	expected := []string{"env:test", "role:leader"}
+	if tags := stats.GetGlobalTags(); len(tags) != len(expected) || tags[0] != expected[0] || tags[1] != expected[1] {
+		t.Fatalf("expected global tags %v, have %v", expected, tags)
+	}
+
+	stats.SetGlobalTags(nil)
+	stats.Gauge("test", 1, nil)
+	stats.Close()
+
+	testApi.lock.Lock()
+	defer testApi.lock.Unlock()
+	if len(testApi.series) != 2 {
+		t.Fatalf("expected %d calls to SendSeries, have %d", 2, len(testApi.series))
+	}
+	for _, tag := range expected {
+		if !hasTag(testApi.series[0].Series[0].Tags, tag) {
+			t.Fatalf("expected tag %s on the first flush, have %v", tag, testApi.series[0].Series[0].Tags)
+		}
+	}
+	if hasTag(testApi.series[0].Series[0].Tags, "role:follower") {
+		t.Fatalf("expected the removed tag to not be sent, have %v", testApi.series[0].Series[0].Tags)
+	}
+	if tags := testApi.series[1].Series[0].Tags; len(tags) != 0 {
+		t.Fatalf("expected no tags after the global tags were cleared, have %v", tags)
+	}
+}
@@ -0,0 +1,134 @@
+package ddstats
+
+import (
+	"github.com/jmizell/ddstats/client"
+)
+
+// Typed metric handles bind a metric name to its type, so a metric can only be recorded
+// with the method for its type. Handles are usually declared by code generated with
+// cmd/ddstats-gen from a metric schema, so metric names are defined in one place, and a
+// rename is a compile time change.
+//
+//	var RequestsTotal = ddstats.CountMetric{Name: "requests.total"}
+//
+//	metrics.RequestsTotal.Inc(stats, []string{"endpoint:/login"})
+//
+// The With methods take a TagSet in place of a list of tags, for tags that are submitted
+// repeatedly, such as in a request handler.
+
+// CountMetric is a typed handle for a count metric.
+type CountMetric struct {
+	Name        string
+	Description string
+}
+
+// Inc increments the count by one.
+func (m CountMetric) Inc(stats *Stats, tags []string, opts ...MetricOption) {
+	stats.Count(m.Name, 1, tags, opts...)
+}
+
+// Add adds value to the count.
+func (m CountMetric) Add(stats *Stats, value float64, tags []string, opts ...MetricOption) {
+	stats.Count(m.Name, value, tags, opts...)
+}
+
+// IncWith increments the count by one, with the tags of a tag set.
+func (m CountMetric) IncWith(stats *Stats, tags TagSet, opts ...MetricOption) {
+	stats.submitTagSet(m.Name, client.Count, 1, tags, opts)
+}
+
+// AddWith adds value to the count, with the tags of a tag set.
+func (m CountMetric) AddWith(stats *Stats, value float64, tags TagSet, opts ...MetricOption) {
+	stats.submitTagSet(m.Name, client.Count, value, tags, opts)
+}
+
+// RateMetric is a typed handle for a rate metric.
+type RateMetric struct {
+	Name        string
+	Description string
+}
+
+// Inc increments the rate by one.
+func (m RateMetric) Inc(stats *Stats, tags []string, opts ...MetricOption) {
+	stats.Rate(m.Name, 1, tags, opts...)
+}
+
+// Add adds value to the rate.
+func (m RateMetric) Add(stats *Stats, value float64, tags []string, opts ...MetricOption) {
+	stats.Rate(m.Name, value, tags, opts...)
+}
+
+// IncWith increments the rate by one, with the tags of a tag set.
+func (m RateMetric) IncWith(stats *Stats, tags TagSet, opts ...MetricOption) {
+	stats.submitTagSet(m.Name, client.Rate, 1, tags, opts)
+}
+
+// AddWith adds value to the rate, with the tags of a tag set.
+func (m RateMetric) AddWith(stats *Stats, value float64, tags TagSet, opts ...MetricOption) {
+	stats.submitTagSet(m.Name, client.Rate, value, tags, opts)
+}
+
+// GaugeMetric is a typed handle for a gauge metric.
+type GaugeMetric struct {
+	Name        string
+	Description string
+}
+
+// Set sets the gauge to value.
+func (m GaugeMetric) Set(stats *Stats, value float64, tags []string, opts ...MetricOption) {
+	stats.Gauge(m.Name, value, tags, opts...)
+}
+
+// SetWith sets the gauge to value, with the tags of a tag set.
+func (m GaugeMetric) SetWith(stats *Stats, value float64, tags TagSet, opts ...MetricOption) {
+	stats.submitTagSet(m.Name, client.Gauge, value, tags, opts)
+}
+
+// HistogramMetric is a typed handle for a histogram metric.
+type HistogramMetric struct {
+	Name        string
+	Description string
+}
+
+// Record records value in the histogram.
+func (m HistogramMetric) Record(stats *Stats, value float64, tags []string, opts ...MetricOption) {
+	stats.Histogram(m.Name, value, tags, opts...)
+}
+
+// RecordWith records value in the histogram, with the tags of a tag set.
+func (m HistogramMetric) RecordWith(stats *Stats, value float64, tags TagSet, opts ...MetricOption) {
+	stats.submitTagSet(m.Name, histogram, value, tags, opts)
+}
+
+// DistributionMetric is a typed handle for a distribution metric.
+type DistributionMetric struct {
+	Name        string
+	Description string
+}
+
+// Record records value in the distribution.
+func (m DistributionMetric) Record(stats *Stats, value float64, tags []string, opts ...MetricOption) {
+	stats.Distribution(m.Name, value, tags, opts...)
+}
+
+// RecordWith records value in the distribution, with the tags of a tag set.
+func (m DistributionMetric) RecordWith(stats *Stats, value float64, tags TagSet, opts ...MetricOption) {
+	stats.submitTagSet(m.Name, client.Distribution, value, tags, opts)
+}
+
+// SetMetric is a typed handle for a set metric.
+type SetMetric struct {
+	Name        string
+	Description string
+}
+
+// Add adds value to the set.
+func (m SetMetric) Add(stats *Stats, value string, tags []string, opts ...MetricOption) {
+	stats.Set(m.Name, value, tags, opts...)
+}
+
+// AddWith adds value to the set, with the tags of a tag set. Set members are submitted
+// with a copy of the tags.
+func (m SetMetric) AddWith(stats *Stats, value string, tags TagSet, opts ...MetricOption) {
+	stats.Set(m.Name, value, tags.Tags(), opts...)
+}
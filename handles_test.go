@@ -0,0 +1,35 @@
+package ddstats
+
+import (
+	"testing"
+)
+
+func TestMetricHandles(t *testing.T) {
+
+	stats, testClient, err := NewTestStats()
+	if err != nil {
+		t.Fatalf(err.Error())
+	}
+
+	requests := CountMetric{Name: "requests"}
+	requests.Inc(stats, nil)
+	requests.Add(stats, 2, nil)
+	GaugeMetric{Name: "depth"}.Set(stats, 5, nil)
+	SetMetric{Name: "users"}.Add(stats, "a", nil)
+	stats.Close()
+
+	values := map[string]interface{}{}
+	for _, m := range testClient.series[0].Series {
+		values[m.Metric] = m.Points[0][1]
+	}
+	expected := map[string]float64{
+		"testNamespace.requests": 3,
+		"testNamespace.depth":    5,
+		"testNamespace.users":    1,
+	}
+	for name, value := range expected {
+		if values[name] != value {
+			t.Fatalf("expected %s to be %f, have %v", name, value, values[name])
+		}
+	}
+}
@@ -0,0 +1,145 @@
+package ddstats
+
+import (
+	"encoding/json"
+	"net/http"
+	"sync/atomic"
+	"time"
+)
+
+// DefaultHealthFailureThreshold is the default number of consecutive failed flushes, at
+// which HealthHandler responds with 503.
+const DefaultHealthFailureThreshold = 3
+
+// Health is a snapshot of the queues, and flushes of stats, so applications can include
+// it in their own health checks.
+type Health struct {
+	QueueDepth            int       // Jobs waiting in the metric queue
+	QueueCapacity         int       // Size of the metric queue
+	WorkerDepths          []int     // Jobs waiting in the buffer of each worker
+	WorkerCapacity        int       // Size of the buffer of each worker
+	AggregatedKeys        int       // Unique metrics aggregated since the last flush
+	LastFlush             time.Time // Time the last flush completed, zero until the first flush
+	LastFlushError        error     // The series, or distribution error of the last flush
+	LastSuccessfulFlush   time.Time // Time the last successful flush completed
+	ConsecutiveFailures   int       // Number of flushes that failed since the last success
+	PendingErrors         int       // Number of errors stored, see Errors
+	Submitted             uint64    // Metrics submitted, including dropped metrics
+	Dropped               uint64    // Metrics dropped because the queue was full
+	DroppedErrorCallbacks uint64    // Errors not delivered to the error callback
+	DroppedFlushErrors    uint64    // Flush errors not delivered to the errors channel
+	DroppedSubscriptions  uint64    // Series not delivered to subscribers
+	Proxy                 string    // Proxy decision of the api client, empty if it doesn't report one
+}
+
+// proxyDescriber is implemented by api clients that report how they reach the api, such
+// as client.DDClient.
+type proxyDescriber interface {
+	ProxyDecision() string
+}
+
+// Health returns the current queue depths, the number of aggregated metrics, the result
+// of the last flush, and the dropped counts. A queue that stays full, or a last flush
+// that is older than a few flush intervals, means stats can't keep up, or can't reach
+// the api.
+func (c *Stats) Health() *Health {
+
+	h := &Health{
+		QueueDepth:            len(c.jobs),
+		QueueCapacity:         cap(c.jobs),
+		WorkerDepths:          make([]int, len(c.workers)),
+		WorkerCapacity:        c.workerBuffer,
+		AggregatedKeys:        int(atomic.LoadInt64(&c.aggregatedKeys)),
+		Dropped:               c.GetDroppedMetricCount(),
+		DroppedErrorCallbacks: c.GetDroppedErrorCallbackCount(),
+		DroppedFlushErrors:    c.GetDroppedFlushErrorCount(),
+		DroppedSubscriptions:  c.GetDroppedSubscriptionCount(),
+		PendingErrors:         len(c.Errors()),
+	}
+	for i, w := range c.workers {
+		h.WorkerDepths[i] = len(w)
+	}
+	if p, ok := c.client.(proxyDescriber); ok {
+		h.Proxy = p.ProxyDecision()
+	}
+	for _, class := range c.Accounting() {
+		h.Submitted += class.Submitted
+	}
+
+	c.healthLock.Lock()
+	defer c.healthLock.Unlock()
+	h.LastFlush = c.lastFlushDone
+	h.LastFlushError = c.lastFlushErr
+	h.LastSuccessfulFlush = c.lastFlushSuccess
+	h.ConsecutiveFailures = c.consecutiveFailures
+
+	return h
+}
+
+// recordFlushHealth stores the result of a completed flush.
+func (c *Stats) recordFlushHealth(err error) {
+	c.healthLock.Lock()
+	defer c.healthLock.Unlock()
+	c.lastFlushDone = c.clock.Now()
+	c.lastFlushErr = err
+	if err != nil {
+		c.consecutiveFailures++
+	} else {
+		c.lastFlushSuccess = c.lastFlushDone
+		c.consecutiveFailures = 0
+	}
+}
+
+// healthDocument is the JSON document served by HealthHandler.
+type healthDocument struct {
+	Status              string   `json:"status"`
+	LastSuccessAge      *float64 `json:"last_success_age_seconds"`
+	LastError           string   `json:"last_error,omitempty"`
+	ConsecutiveFailures int      `json:"consecutive_failures"`
+	PendingErrors       int      `json:"pending_errors"`
+	QueueDepth          int      `json:"queue_depth"`
+	Dropped             uint64   `json:"dropped"`
+	DropRate            float64  `json:"drop_rate"`
+	Proxy               string   `json:"proxy,omitempty"`
+}
+
+// HealthHandler returns an http handler for liveness checks of the metrics pipeline. It
+// serves a JSON document with the age in seconds of the last successful flush, null until
+// the first, the number of stored errors, the fraction of submitted metrics that were
+// dropped, and the proxy decision of the api client, for troubleshooting connectivity. The
+// status is "ok", or "failing" with a 503 response, once the number of consecutive failed
+// flushes reaches the threshold, see Config.WithHealthFailureThreshold.
+func (c *Stats) HealthHandler() http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+
+		h := c.Health()
+		doc := &healthDocument{
+			Status:              "ok",
+			ConsecutiveFailures: h.ConsecutiveFailures,
+			PendingErrors:       h.PendingErrors,
+			QueueDepth:          h.QueueDepth,
+			Dropped:             h.Dropped,
+			Proxy:               h.Proxy,
+		}
+		if !h.LastSuccessfulFlush.IsZero() {
+			age := c.clock.Now().Sub(h.LastSuccessfulFlush).Seconds()
+			doc.LastSuccessAge = &age
+		}
+		if h.LastFlushError != nil {
+			doc.LastError = h.LastFlushError.Error()
+		}
+		if h.Submitted > 0 {
+			doc.DropRate = float64(h.Dropped) / float64(h.Submitted)
+		}
+
+		status := http.StatusOK
+		if h.ConsecutiveFailures >= c.healthThreshold {
+			doc.Status = "failing"
+			status = http.StatusServiceUnavailable
+		}
+
+		w.Header().Set("Content-Type", "application/json")
+		w.WriteHeader(status)
+		_ = json.NewEncoder(w).Encode(doc)
+	})
+}
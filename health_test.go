@@ -0,0 +1,149 @@
+package ddstats
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/jmizell/ddstats/client"
+)
+
+func TestStats_Health(t *testing.T) {
+
+	stats, testApi, err := NewTestStats()
+	if err != nil {
+		t.Fatalf(err.Error())
+	}
+	defer stats.Close()
+
+	h := stats.Health()
+	if h.QueueCapacity != 20 || h.WorkerCapacity != 10 || len(h.WorkerDepths) != 2 {
+		t.Fatalf("expected queue capacity 20, worker capacity 10, and 2 workers, have %+v", h)
+	}
+	if !h.LastFlush.IsZero() {
+		t.Fatalf("expected no last flush, have %s", h.LastFlush)
+	}
+
+	stats.Increment("first", nil)
+	stats.Increment("second", nil)
+	stats.Increment("second", nil)
+	time.Sleep(time.Millisecond * 50)
+	if h := stats.Health(); h.AggregatedKeys != 2 {
+		t.Fatalf("expected %d aggregated keys, have %d", 2, h.AggregatedKeys)
+	}
+
+	stats.Flush()
+	h = stats.Health()
+	if h.AggregatedKeys != 0 {
+		t.Fatalf("expected %d aggregated keys after flush, have %d", 0, h.AggregatedKeys)
+	}
+	if h.LastFlush.IsZero() || h.LastFlushError != nil {
+		t.Fatalf("expected a successful last flush, have %s, %v", h.LastFlush, h.LastFlushError)
+	}
+
+	testApi.lock.Lock()
+	testApi.sendSeriesError = fmt.Errorf("api down")
+	testApi.lock.Unlock()
+	stats.Increment("first", nil)
+	stats.Flush()
+	if h := stats.Health(); h.LastFlushError == nil {
+		t.Fatalf("expected the last flush error")
+	}
+}
+
+func TestStats_HealthHandler(t *testing.T) {
+
+	testApi := NewTestAPIClient()
+	cfg := NewConfig().
+		WithNamespace(testNamespace).
+		WithHost(testHost).
+		WithClient(testApi).
+		WithHealthFailureThreshold(2)
+	stats, err := NewStats(cfg)
+	if err != nil {
+		t.Fatalf(err.Error())
+	}
+	defer stats.Close()
+
+	get := func() (int, *healthDocument) {
+		w := httptest.NewRecorder()
+		stats.HealthHandler().ServeHTTP(w, httptest.NewRequest(http.MethodGet, "/health", nil))
+		doc := &healthDocument{}
+		if err := json.Unmarshal(w.Body.Bytes(), doc); err != nil {
+			t.Fatalf(err.Error())
+		}
+		return w.Code, doc
+	}
+
+	code, doc := get()
+	if code != http.StatusOK || doc.Status != "ok" || doc.LastSuccessAge != nil {
+		t.Fatalf("expected ok with no successful flush, have %d, %+v", code, doc)
+	}
+
+	stats.Increment("test", nil)
+	stats.Flush()
+	code, doc = get()
+	if code != http.StatusOK || doc.LastSuccessAge == nil || *doc.LastSuccessAge > 5 {
+		t.Fatalf("expected ok with a recent successful flush, have %d, %+v", code, doc)
+	}
+
+	testApi.lock.Lock()
+	testApi.sendSeriesError = fmt.Errorf("api down")
+	testApi.lock.Unlock()
+	for i := 0; i < 2; i++ {
+		stats.Increment("test", nil)
+		stats.Flush()
+		code, doc = get()
+		if i == 0 && code != http.StatusOK {
+			t.Fatalf("expected ok below the failure threshold, have %d, %+v", code, doc)
+		}
+	}
+	if code != http.StatusServiceUnavailable || doc.Status != "failing" || doc.ConsecutiveFailures != 2 {
+		t.Fatalf("expected failing at the failure threshold, have %d, %+v", code, doc)
+	}
+	if doc.LastError != "api down" || doc.PendingErrors == 0 {
+		t.Fatalf("expected the last error, and pending errors, have %+v", doc)
+	}
+
+	testApi.lock.Lock()
+	testApi.sendSeriesError = nil
+	testApi.lock.Unlock()
+	stats.Increment("test", nil)
+	stats.Flush()
+	if code, doc = get(); code != http.StatusOK || doc.ConsecutiveFailures != 0 {
+		t.Fatalf("expected ok after a successful flush, have %d, %+v", code, doc)
+	}
+}
+
+func TestStats_HealthHandlerProxy(t *testing.T) {
+
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusAccepted)
+	}))
+	defer server.Close()
+	api := client.NewDDClient("testKey")
+	if err := api.SetBaseURL(server.URL); err != nil {
+		t.Fatalf(err.Error())
+	}
+	stats, err := NewStats(NewConfig().
+		WithNamespace(testNamespace).
+		WithHost(testHost).
+		WithClient(api))
+	if err != nil {
+		t.Fatalf(err.Error())
+	}
+	defer stats.Close()
+
+	w := httptest.NewRecorder()
+	stats.HealthHandler().ServeHTTP(w, httptest.NewRequest(http.MethodGet, "/health", nil))
+	doc := &healthDocument{}
+	if err := json.Unmarshal(w.Body.Bytes(), doc); err != nil {
+		t.Fatalf(err.Error())
+	}
+	if doc.Proxy == "" || doc.Proxy != api.ProxyDecision() {
+		t.Fatalf("expected proxy decision %q, have %q", api.ProxyDecision(), doc.Proxy)
+	}
+}
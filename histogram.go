@@ -0,0 +1,72 @@
+package ddstats
+
+import (
+	"fmt"
+
+	"github.com/jmizell/ddstats/internal/engine"
+)
+
+// Histogram aggregates, see Config.WithHistogramAggregates. Each aggregate is sent as a
+// gauge with the aggregate name as a suffix, such as .max, except count, which is sent as
+// a rate.
+const (
+	HistogramMax    = engine.AggregateMax
+	HistogramMin    = engine.AggregateMin
+	HistogramAvg    = engine.AggregateAvg
+	HistogramMedian = engine.AggregateMedian
+	HistogramSum    = engine.AggregateSum
+	HistogramCount  = "count"
+)
+
+// Default histogram aggregates, and percentiles, used when none are configured.
+var (
+	DefaultHistogramAggregates  = []string{HistogramMax, HistogramMedian, HistogramAvg, HistogramCount, HistogramMin}
+	DefaultHistogramPercentiles = []float64{0.95}
+)
+
+// histogramAggregation holds the aggregates, and percentiles sent for each histogram.
+type histogramAggregation struct {
+	aggregates  []string // Aggregates other than count
+	count       bool
+	percentiles []float64
+}
+
+var defaultHistogramAggregation, _ = newHistogramAggregation(DefaultHistogramAggregates, DefaultHistogramPercentiles)
+
+// newHistogramAggregation validates aggregates, and percentiles. A nil aggregates, or
+// percentiles is replaced with the default, an empty slice sends none.
+func newHistogramAggregation(aggregates []string, percentiles []float64) (*histogramAggregation, error) {
+
+	if aggregates == nil {
+		aggregates = DefaultHistogramAggregates
+	}
+	if percentiles == nil {
+		percentiles = DefaultHistogramPercentiles
+	}
+
+	h := &histogramAggregation{percentiles: percentiles}
+	seen := map[string]bool{}
+	for _, aggregate := range aggregates {
+		switch aggregate {
+		case HistogramMax, HistogramMin, HistogramAvg, HistogramMedian, HistogramSum, HistogramCount:
+		default:
+			return nil, fmt.Errorf("invalid histogram aggregate %q, expected max, min, avg, median, sum, or count", aggregate)
+		}
+		if seen[aggregate] {
+			continue
+		}
+		seen[aggregate] = true
+		if aggregate == HistogramCount {
+			h.count = true
+			continue
+		}
+		h.aggregates = append(h.aggregates, aggregate)
+	}
+	for _, p := range percentiles {
+		if !(p > 0 && p < 1) {
+			return nil, fmt.Errorf("invalid histogram percentile %v, expected a value between 0, and 1", p)
+		}
+	}
+
+	return h, nil
+}
@@ -0,0 +1,77 @@
+package ddstats
+
+import (
+	"testing"
+)
+
+func TestStats_HistogramAggregates(t *testing.T) {
+
+	testApi := NewTestAPIClient()
+	stats, err := NewStats(NewConfig().
+		WithNamespace(testNamespace).
+		WithHost(testHost).
+		WithClient(testApi).
+		WithHistogramAggregates(HistogramMax, HistogramSum, HistogramCount).
+		WithHistogramPercentiles(0.5, 0.99))
+	if err != nil {
+		t.Fatalf(err.Error())
+	}
+
+	for _, v := range []float64{1, 2, 3, 4} {
+		stats.Histogram("test", v, nil)
+	}
+	stats.Close()
+
+	metrics := map[string]float64{}
+	testApi.lock.Lock()
+	defer testApi.lock.Unlock()
+	for _, series := range testApi.series {
+		for _, m := range series.Series {
+			metrics[m.Metric] = m.Points[0][1].(float64)
+		}
+	}
+	expected := []string{"test.max", "test.sum", "test.count", "test.50percentile", "test.99percentile"}
+	if len(metrics) != len(expected) {
+		t.Fatalf("expected metrics %v, have %v", expected, metrics)
+	}
+	for _, name := range expected {
+		if _, ok := metrics[prependNamespace(testNamespace, name)]; !ok {
+			t.Fatalf("expected metric %s, have %v", name, metrics)
+		}
+	}
+	if sum := metrics[prependNamespace(testNamespace, "test.sum")]; sum != 10 {
+		t.Fatalf("expected sum %f, have %f", 10.0, sum)
+	}
+}
+
+func TestNewHistogramAggregation(t *testing.T) {
+
+	t.Run("defaults", func(tt *testing.T) {
+		h, err := newHistogramAggregation(nil, nil)
+		if err != nil {
+			tt.Fatalf(err.Error())
+		}
+		if !h.count || len(h.aggregates) != 4 || len(h.percentiles) != 1 {
+			tt.Fatalf("expected the default aggregates, have %+v", h)
+		}
+	})
+
+	t.Run("none", func(tt *testing.T) {
+		h, err := newHistogramAggregation([]string{}, []float64{})
+		if err != nil {
+			tt.Fatalf(err.Error())
+		}
+		if h.count || len(h.aggregates) != 0 || len(h.percentiles) != 0 {
+			tt.Fatalf("expected no aggregates, have %+v", h)
+		}
+	})
+
+	t.Run("invalid", func(tt *testing.T) {
+		if _, err := newHistogramAggregation([]string{"p99"}, nil); err == nil {
+			tt.Fatalf("expected an error for an unknown aggregate")
+		}
+		if _, err := newHistogramAggregation(nil, []float64{95}); err == nil {
+			tt.Fatalf("expected an error for an invalid percentile")
+		}
+	})
+}
@@ -0,0 +1,132 @@
+package ddstats
+
+import (
+	"context"
+	"os"
+	"os/signal"
+	"sync"
+	"syscall"
+	"time"
+)
+
+// DefaultExitFlushTimeout is the default time allowed for the final flush when the
+// process is terminating.
+const DefaultExitFlushTimeout = time.Second * 5
+
+// Hooks used to terminate the process, replaced in tests.
+var (
+	osExit = os.Exit
+	raise  = func(sig os.Signal) {
+		if p, err := os.FindProcess(os.Getpid()); err == nil {
+			_ = p.Signal(sig)
+		}
+	}
+)
+
+// HookOnExit installs a signal handler that attempts a best-effort final flush when the
+// process receives one of signals, SIGINT and SIGTERM by default. The flush is bounded
+// by timeout, and the functions registered with AtExit are called before it. After the
+// flush, the handler is removed, and the signal is raised again, so a process with no
+// other signal handlers terminates the same way it would have without the hook. Handlers
+// the application installed with signal.Notify are left in place, and receive the signal
+// a second time. The returned function removes the handler without flushing.
+func HookOnExit(stats *Stats, timeout time.Duration, signals ...os.Signal) (unhook func()) {
+	return hookOnExit(stats, timeout, true, signals)
+}
+
+// HookOnExitNoRaise is the same as HookOnExit, but the signal isn't raised again after
+// the flush. Use it when the application handles the signal itself with signal.Notify, and
+// is responsible for terminating, a process with no handler of its own keeps running.
+func HookOnExitNoRaise(stats *Stats, timeout time.Duration, signals ...os.Signal) (unhook func()) {
+	return hookOnExit(stats, timeout, false, signals)
+}
+
+func hookOnExit(stats *Stats, timeout time.Duration, reraise bool, signals []os.Signal) (unhook func()) {
+
+	if len(signals) == 0 {
+		signals = []os.Signal{os.Interrupt, syscall.SIGTERM}
+	}
+
+	sigs := make(chan os.Signal, 1)
+	done := make(chan bool)
+	signal.Notify(sigs, signals...)
+
+	go func() {
+		select {
+		case sig := <-sigs:
+			closeWithin(stats, timeout)
+			// Only stop our own channel, signal.Reset would also remove the
+			// application's handlers. With no handlers left, the runtime restores
+			// the default action, so the raised signal terminates the process.
+			signal.Stop(sigs)
+			if reraise {
+				raise(sig)
+			}
+		case <-done:
+			signal.Stop(sigs)
+		}
+	}()
+
+	once := &sync.Once{}
+	return func() {
+		once.Do(func() { close(done) })
+	}
+}
+
+var exitFuncs struct {
+	funcs []func()
+	lock  sync.Mutex
+}
+
+// AtExit registers fn to be called before the final flush of HookOnExit, FlushOnPanic, and
+// Exit, so an application can record metrics describing how it's terminating, such as the
+// exit reason. Functions are called once, in the reverse order they were registered, and
+// should return quickly, the final flush is bounded by its timeout after they return.
+func AtExit(fn func()) {
+	exitFuncs.lock.Lock()
+	defer exitFuncs.lock.Unlock()
+	exitFuncs.funcs = append(exitFuncs.funcs, fn)
+}
+
+// runExitFuncs calls the functions registered with AtExit, and removes them.
+func runExitFuncs() {
+	exitFuncs.lock.Lock()
+	funcs := exitFuncs.funcs
+	exitFuncs.funcs = nil
+	exitFuncs.lock.Unlock()
+
+	for i := len(funcs) - 1; i >= 0; i-- {
+		funcs[i]()
+	}
+}
+
+// FlushOnPanic attempts a final flush bounded by timeout, if the calling goroutine is
+// panicking. The panic is resumed after the flush. FlushOnPanic must be called directly
+// by defer.
+//
+//	defer ddstats.FlushOnPanic(stats, ddstats.DefaultExitFlushTimeout)
+func FlushOnPanic(stats *Stats, timeout time.Duration) {
+	if r := recover(); r != nil {
+		closeWithin(stats, timeout)
+		panic(r)
+	}
+}
+
+// Exit calls the functions registered with AtExit, attempts a final flush bounded by
+// timeout, and then calls os.Exit with code. Use Exit in place of os.Exit, as os.Exit does
+// not run deferred functions.
+func Exit(stats *Stats, code int, timeout time.Duration) {
+	closeWithin(stats, timeout)
+	osExit(code)
+}
+
+// closeWithin calls the functions registered with AtExit, closes stats, and returns true
+// if the close completed before timeout.
+func closeWithin(stats *Stats, timeout time.Duration) bool {
+
+	runExitFuncs()
+
+	ctx, cancel := context.WithTimeout(context.Background(), timeout)
+	defer cancel()
+	return stats.CloseContext(ctx) == nil
+}
@@ -0,0 +1,175 @@
+package ddstats
+
+import (
+	"os"
+	"os/signal"
+	"syscall"
+	"testing"
+	"time"
+)
+
+func TestHookOnExitNoRaise(t *testing.T) {
+
+	stats, testApi, err := NewTestStats()
+	if err != nil {
+		t.Fatalf(err.Error())
+	}
+
+	raised := make(chan os.Signal, 1)
+	defaultRaise := raise
+	raise = func(sig os.Signal) { raised <- sig }
+	defer func() { raise = defaultRaise }()
+
+	HookOnExit(stats, time.Second, syscall.SIGUSR1)
+	stats.Increment("test", nil)
+	if err := syscall.Kill(os.Getpid(), syscall.SIGUSR1); err != nil {
+		t.Fatalf(err.Error())
+	}
+
+	select {
+	case sig := <-raised:
+		if sig != syscall.SIGUSR1 {
+			t.Fatalf("expected signal %s to be raised, have %s", syscall.SIGUSR1, sig)
+		}
+	case <-time.After(time.Second * 2):
+		t.Fatalf("expected signal to be raised after flush")
+	}
+
+	testApi.lock.Lock()
+	defer testApi.lock.Unlock()
+	if len(testApi.series) != 1 {
+		t.Fatalf("expected %d calls to SendSeries, have %d", 1, len(testApi.series))
+	}
+}
+
+func TestHookOnExit(t *testing.T) {
+
+	stats, testApi, err := NewTestStats()
+	if err != nil {
+		t.Fatalf(err.Error())
+	}
+
+	raised := make(chan os.Signal, 1)
+	defaultRaise := raise
+	raise = func(sig os.Signal) { raised <- sig }
+	defer func() { raise = defaultRaise }()
+
+	// The application's own handler must keep receiving the signal after the hook
+	appSigs := make(chan os.Signal, 2)
+	signal.Notify(appSigs, syscall.SIGUSR2)
+	defer signal.Stop(appSigs)
+
+	HookOnExitNoRaise(stats, time.Second, syscall.SIGUSR2)
+	stats.Increment("test", nil)
+	if err := syscall.Kill(os.Getpid(), syscall.SIGUSR2); err != nil {
+		t.Fatalf(err.Error())
+	}
+	<-appSigs
+
+	deadline := time.After(time.Second * 2)
+	for {
+		testApi.lock.Lock()
+		sent := len(testApi.series)
+		testApi.lock.Unlock()
+		if sent == 1 {
+			break
+		}
+		select {
+		case <-deadline:
+			t.Fatalf("expected %d calls to SendSeries, have %d", 1, sent)
+		case <-time.After(time.Millisecond * 10):
+		}
+	}
+
+	if err := syscall.Kill(os.Getpid(), syscall.SIGUSR2); err != nil {
+		t.Fatalf(err.Error())
+	}
+	select {
+	case <-appSigs:
+	case <-time.After(time.Second * 2):
+		t.Fatalf("expected the application's handler to still receive the signal")
+	}
+	select {
+	case sig := <-raised:
+		t.Fatalf("expected signal not to be raised again, have %s", sig)
+	default:
+	}
+}
+
+func TestFlushOnPanic(t *testing.T) {
+
+	stats, testApi, err := NewTestStats()
+	if err != nil {
+		t.Fatalf(err.Error())
+	}
+
+	func() {
+		defer func() {
+			if r := recover(); r != "test panic" {
+				t.Fatalf("expected panic to be resumed, have %v", r)
+			}
+		}()
+		defer FlushOnPanic(stats, time.Second)
+		stats.Increment("test", nil)
+		panic("test panic")
+	}()
+
+	testApi.lock.Lock()
+	defer testApi.lock.Unlock()
+	if len(testApi.series) != 1 {
+		t.Fatalf("expected %d calls to SendSeries, have %d", 1, len(testApi.series))
+	}
+}
+
+func TestExit(t *testing.T) {
+
+	stats, testApi, err := NewTestStats()
+	if err != nil {
+		t.Fatalf(err.Error())
+	}
+
+	exitCode := -1
+	osExit = func(code int) { exitCode = code }
+	defer func() { osExit = os.Exit }()
+
+	stats.Increment("test", nil)
+	Exit(stats, 3, time.Second)
+
+	if exitCode != 3 {
+		t.Fatalf("expected exit code %d, have %d", 3, exitCode)
+	}
+	testApi.lock.Lock()
+	defer testApi.lock.Unlock()
+	if len(testApi.series) != 1 {
+		t.Fatalf("expected %d calls to SendSeries, have %d", 1, len(testApi.series))
+	}
+}
+
+func TestAtExit(t *testing.T) {
+
+	stats, testApi, err := NewTestStats()
+	if err != nil {
+		t.Fatalf(err.Error())
+	}
+
+	osExit = func(code int) {}
+	defer func() { osExit = os.Exit }()
+
+	var order []int
+	AtExit(func() { order = append(order, 1) })
+	AtExit(func() {
+		order = append(order, 2)
+		stats.Increment("exit", nil)
+	})
+	Exit(stats, 1, time.Second)
+	runExitFuncs()
+
+	if len(order) != 2 || order[0] != 2 || order[1] != 1 {
+		t.Fatalf("expected exit functions to be called once, in reverse order, have %v", order)
+	}
+	testApi.lock.Lock()
+	defer testApi.lock.Unlock()
+	if len(testApi.series) != 1 || testApi.series[0].Series[0].Metric != prependNamespace(testNamespace, "exit") {
+		t.Fatalf("expected the metric recorded at exit to be flushed, have %v", testApi.series)
+	}
+}
@@ -0,0 +1,187 @@
+package ddstats
+
+import (
+	"context"
+	"fmt"
+	"io/ioutil"
+	"net/http"
+	"os"
+	"strings"
+	"sync"
+	"time"
+)
+
+// DefaultCloudDetectionTimeout is the max time NewStats waits for the cloud metadata
+// endpoints, when cloud host detection is enabled.
+const DefaultCloudDetectionTimeout = time.Millisecond * 500
+
+// Cloud providers detected by cloud host detection
+const (
+	CloudProviderAWS   = "aws"
+	CloudProviderGCP   = "gcp"
+	CloudProviderAzure = "azure"
+)
+
+// Metadata endpoints, replaced in tests.
+var (
+	ec2MetadataURL   = "http://169.254.169.254/latest"
+	gceMetadataURL   = "http://metadata.google.internal/computeMetadata/v1"
+	azureMetadataURL = "http://169.254.169.254/metadata"
+)
+
+// cloudHost is the instance a process is running on, as reported by the cloud metadata
+// endpoint.
+type cloudHost struct {
+	provider   string
+	instanceID string
+	hostname   string // Hostname reported by the provider, if any
+}
+
+// tags returns the cloud_provider, and instance_id tags of the instance.
+func (h *cloudHost) tags() []string {
+	return []string{"cloud_provider:" + h.provider, "instance_id:" + h.instanceID}
+}
+
+// resolveHost returns the host to report, from the configured host, and the detected
+// instance, following the Datadog Agent. The configured host is used if set, except for
+// the default EC2 hostnames, which are replaced by the instance id. An empty host is
+// replaced by the provider hostname, or instance id, and then the os hostname.
+func resolveHost(configured string, cloud *cloudHost) string {
+	if cloud != nil {
+		switch {
+		case configured == "" && cloud.hostname != "":
+			return cloud.hostname
+		case configured == "", cloud.provider == CloudProviderAWS && isDefaultEC2Hostname(configured):
+			return cloud.instanceID
+		}
+	}
+	if configured == "" {
+		configured, _ = os.Hostname()
+	}
+	return configured
+}
+
+// isDefaultEC2Hostname returns true if hostname is one of the hostnames EC2 assigns by
+// default, which aren't unique across regions.
+func isDefaultEC2Hostname(hostname string) bool {
+	hostname = strings.ToLower(hostname)
+	return strings.HasPrefix(hostname, "ip-") || strings.HasPrefix(hostname, "domu")
+}
+
+// cloudDetector queries the metadata endpoint at baseURL of one cloud provider.
+type cloudDetector func(ctx context.Context, httpClient *http.Client, baseURL string) *cloudHost
+
+// detectCloudHost queries the metadata endpoints of each supported cloud provider
+// concurrently, and returns the first instance found, or nil if none responded within
+// timeout. The remaining detectors are cancelled, and waited for before it returns.
+func detectCloudHost(timeout time.Duration) *cloudHost {
+
+	ctx, cancel := context.WithTimeout(context.Background(), timeout)
+
+	// Metadata endpoints are link local, and must not be sent through a proxy
+	httpClient := &http.Client{Transport: &http.Transport{}}
+
+	detectors := []struct {
+		detect  cloudDetector
+		baseURL string
+	}{
+		{detectEC2, ec2MetadataURL},
+		{detectGCE, gceMetadataURL},
+		{detectAzure, azureMetadataURL},
+	}
+	found := make(chan *cloudHost, len(detectors))
+	wg := &sync.WaitGroup{}
+	defer func() {
+		cancel()
+		wg.Wait()
+		httpClient.CloseIdleConnections()
+	}()
+	for _, d := range detectors {
+		wg.Add(1)
+		go func(detect cloudDetector, baseURL string) {
+			defer wg.Done()
+			found <- detect(ctx, httpClient, baseURL)
+		}(d.detect, d.baseURL)
+	}
+
+	for range detectors {
+		select {
+		case host := <-found:
+			if host != nil {
+				return host
+			}
+		case <-ctx.Done():
+			return nil
+		}
+	}
+	return nil
+}
+
+// detectEC2 reads the instance id with IMDSv2, falling back to IMDSv1.
+func detectEC2(ctx context.Context, httpClient *http.Client, baseURL string) *cloudHost {
+
+	headers := map[string]string{}
+	token, err := metadataRequest(ctx, httpClient, http.MethodPut, baseURL+"/api/token",
+		map[string]string{"X-aws-ec2-metadata-token-ttl-seconds": "60"})
+	if err == nil && token != "" {
+		headers["X-aws-ec2-metadata-token"] = token
+	}
+
+	id, err := metadataRequest(ctx, httpClient, http.MethodGet, baseURL+"/meta-data/instance-id", headers)
+	if err != nil || id == "" {
+		return nil
+	}
+	return &cloudHost{provider: CloudProviderAWS, instanceID: id}
+}
+
+// detectGCE reads the instance id, and hostname.
+func detectGCE(ctx context.Context, httpClient *http.Client, baseURL string) *cloudHost {
+
+	headers := map[string]string{"Metadata-Flavor": "Google"}
+	id, err := metadataRequest(ctx, httpClient, http.MethodGet, baseURL+"/instance/id", headers)
+	if err != nil || id == "" {
+		return nil
+	}
+	hostname, _ := metadataRequest(ctx, httpClient, http.MethodGet, baseURL+"/instance/hostname", headers)
+	return &cloudHost{provider: CloudProviderGCP, instanceID: id, hostname: hostname}
+}
+
+// detectAzure reads the vm id.
+func detectAzure(ctx context.Context, httpClient *http.Client, baseURL string) *cloudHost {
+
+	id, err := metadataRequest(ctx, httpClient, http.MethodGet,
+		baseURL+"/instance/compute/vmId?api-version=2021-02-01&format=text",
+		map[string]string{"Metadata": "true"})
+	if err != nil || id == "" {
+		return nil
+	}
+	return &cloudHost{provider: CloudProviderAzure, instanceID: id}
+}
+
+// metadataRequest sends a request to a metadata endpoint, and returns the response body.
+func metadataRequest(ctx context.Context, httpClient *http.Client, method, url string, headers map[string]string) (string, error) {
+
+	req, err := http.NewRequest(method, url, nil)
+	if err != nil {
+		return "", err
+	}
+	req = req.WithContext(ctx)
+	for key, value := range headers {
+		req.Header.Set(key, value)
+	}
+
+	resp, err := httpClient.Do(req)
+	if err != nil {
+		return "", err
+	}
+	defer func() { _ = resp.Body.Close() }()
+
+	body, err := ioutil.ReadAll(resp.Body)
+	if err != nil {
+		return "", err
+	}
+	if resp.StatusCode != http.StatusOK {
+		return "", fmt.Errorf("metadata response %d", resp.StatusCode)
+	}
+	return strings.TrimSpace(string(body)), nil
+}
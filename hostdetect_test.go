@@ -0,0 +1,169 @@
+package ddstats
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"testing"
+	"time"
+)
+
+// setMetadataURLs points the metadata endpoints at server, and returns a function that
+// restores them.
+func setMetadataURLs(url string) func() {
+	ec2, gce, azure := ec2MetadataURL, gceMetadataURL, azureMetadataURL
+	ec2MetadataURL, gceMetadataURL, azureMetadataURL = url+"/ec2", url+"/gce", url+"/azure"
+	return func() {
+		ec2MetadataURL, gceMetadataURL, azureMetadataURL = ec2, gce, azure
+	}
+}
+
+func TestDetectCloudHost(t *testing.T) {
+
+	tests := []struct {
+		name     string
+		handler  http.HandlerFunc
+		expected *cloudHost
+	}{
+		{
+			name: "aws",
+			handler: func(w http.ResponseWriter, r *http.Request) {
+				switch {
+				case r.Method == http.MethodPut && r.URL.Path == "/ec2/api/token":
+					_, _ = w.Write([]byte("token"))
+				case r.URL.Path == "/ec2/meta-data/instance-id" && r.Header.Get("X-aws-ec2-metadata-token") == "token":
+					_, _ = w.Write([]byte("i-0123456789\n"))
+				default:
+					http.NotFound(w, r)
+				}
+			},
+			expected: &cloudHost{provider: CloudProviderAWS, instanceID: "i-0123456789"},
+		},
+		{
+			name: "gcp",
+			handler: func(w http.ResponseWriter, r *http.Request) {
+				switch {
+				case r.Header.Get("Metadata-Flavor") != "Google":
+					http.NotFound(w, r)
+				case r.URL.Path == "/gce/instance/id":
+					_, _ = w.Write([]byte("12345"))
+				case r.URL.Path == "/gce/instance/hostname":
+					_, _ = w.Write([]byte("vm.c.project.internal"))
+				default:
+					http.NotFound(w, r)
+				}
+			},
+			expected: &cloudHost{provider: CloudProviderGCP, instanceID: "12345", hostname: "vm.c.project.internal"},
+		},
+		{
+			name: "azure",
+			handler: func(w http.ResponseWriter, r *http.Request) {
+				if r.URL.Path == "/azure/instance/compute/vmId" && r.Header.Get("Metadata") == "true" {
+					_, _ = w.Write([]byte("vm-id"))
+					return
+				}
+				http.NotFound(w, r)
+			},
+			expected: &cloudHost{provider: CloudProviderAzure, instanceID: "vm-id"},
+		},
+		{
+			name:    "none",
+			handler: http.NotFound,
+		},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(tt *testing.T) {
+			server := httptest.NewServer(test.handler)
+			defer server.Close()
+			defer setMetadataURLs(server.URL)()
+
+			host := detectCloudHost(time.Second)
+			if test.expected == nil && host != nil || test.expected != nil && (host == nil || *host != *test.expected) {
+				tt.Fatalf("expected %v, have %v", test.expected, host)
+			}
+		})
+	}
+
+	t.Run("slow detectors cancelled", func(tt *testing.T) {
+		server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			switch r.URL.Path {
+			case "/ec2/meta-data/instance-id":
+				_, _ = w.Write([]byte("i-1"))
+				return
+			case "/ec2/api/token":
+				http.NotFound(w, r)
+				return
+			}
+			// The other providers don't respond until the request is cancelled
+			<-r.Context().Done()
+		}))
+		defer server.Close()
+		defer setMetadataURLs(server.URL)()
+
+		start := time.Now()
+		host := detectCloudHost(time.Second * 10)
+		if host == nil || host.instanceID != "i-1" {
+			tt.Fatalf("expected instance %s, have %v", "i-1", host)
+		}
+		if elapsed := time.Since(start); elapsed > time.Second*5 {
+			tt.Fatalf("expected the slow detectors to be cancelled, detection took %s", elapsed)
+		}
+	})
+}
+
+func TestResolveHost(t *testing.T) {
+
+	hostname, _ := os.Hostname()
+	aws := &cloudHost{provider: CloudProviderAWS, instanceID: "i-1"}
+	gcp := &cloudHost{provider: CloudProviderGCP, instanceID: "2", hostname: "vm.internal"}
+	tests := []struct {
+		configured string
+		cloud      *cloudHost
+		expected   string
+	}{
+		{"", nil, hostname},
+		{"web-1", nil, "web-1"},
+		{"ip-10-0-0-1", aws, "i-1"},
+		{"web-1", aws, "web-1"},
+		{"", aws, "i-1"},
+		{"", gcp, "vm.internal"},
+		{"ip-10-0-0-1", gcp, "ip-10-0-0-1"},
+	}
+	for _, test := range tests {
+		if host := resolveHost(test.configured, test.cloud); host != test.expected {
+			t.Fatalf("expected host %q with %v to resolve to %q, have %q", test.configured, test.cloud, test.expected, host)
+		}
+	}
+}
+
+func TestStats_WithCloudHostDetection(t *testing.T) {
+
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path == "/ec2/meta-data/instance-id" {
+			_, _ = w.Write([]byte("i-1"))
+			return
+		}
+		http.NotFound(w, r)
+	}))
+	defer server.Close()
+	defer setMetadataURLs(server.URL)()
+
+	cfg := NewConfig().
+		WithHost("ip-10-0-0-1").
+		WithClient(NewTestAPIClient()).
+		WithCloudHostDetection(true)
+	stats, err := NewStats(cfg)
+	if err != nil {
+		t.Fatalf(err.Error())
+	}
+	defer stats.Close()
+
+	if stats.host != "i-1" {
+		t.Fatalf("expected host %s, have %s", "i-1", stats.host)
+	}
+	tags := stats.GetGlobalTags()
+	if !hasTag(tags, "cloud_provider:aws") || !hasTag(tags, "instance_id:i-1") {
+		t.Fatalf("expected cloud tags, have %v", tags)
+	}
+}
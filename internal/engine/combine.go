@@ -0,0 +1,33 @@
+package engine
+
+// Op is how updates to a metric are combined within a flush interval.
+type Op int
+
+// Combine operations
+const (
+	OpSum  Op = iota // Counts, and rates
+	OpLast           // Gauges
+	OpMax            // Gauges aggregated by max
+	OpMin            // Gauges aggregated by min
+	OpEWMA           // Exponentially weighted moving averages
+)
+
+// Combine returns the result of applying the update v to current with op. Alpha is the
+// smoothing factor of OpEWMA, it's ignored by other operations.
+func Combine(op Op, current, v, alpha float64) float64 {
+	switch op {
+	case OpSum:
+		return current + v
+	case OpMax:
+		if current > v {
+			return current
+		}
+	case OpMin:
+		if current < v {
+			return current
+		}
+	case OpEWMA:
+		return alpha*v + (1-alpha)*current
+	}
+	return v
+}
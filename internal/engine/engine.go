@@ -0,0 +1,87 @@
+// Package engine is the aggregation core of ddstats. It has no dependency on the Datadog
+// api types, so the aggregation, interval, and sharding logic can be tested, and
+// benchmarked on its own, and shared by the stats client, relay, and listeners.
+//
+// Sharding
+//
+// Updates are aggregated by a fixed number of workers, each owning one shard. Updates
+// are assigned to a shard by a hash of the metric name, so the same metric is always
+// aggregated by the same worker, and the shards don't need to be locked. Shards must
+// only be drained while no worker is updating them.
+package engine
+
+// Aggregate is an aggregated metric value held by a shard.
+type Aggregate interface {
+	// Merge applies update, an aggregate for the same key, to the aggregate.
+	Merge(update Aggregate)
+}
+
+// Shards holds the aggregates of each worker, indexed by key.
+type Shards struct {
+	shards []map[string]Aggregate
+}
+
+// NewShards creates n shards, each sized for hint aggregates.
+func NewShards(n, hint int) *Shards {
+	if n < 1 {
+		n = 1
+	}
+	s := &Shards{shards: make([]map[string]Aggregate, n)}
+	for i := range s.shards {
+		s.shards[i] = make(map[string]Aggregate, hint)
+	}
+	return s
+}
+
+// Count returns the number of shards.
+func (s *Shards) Count() int {
+	return len(s.shards)
+}
+
+// Shard returns the shard that updates for name are assigned to.
+func (s *Shards) Shard(name string) int {
+	return Shard(name, len(s.shards))
+}
+
+// Get returns the aggregate for key in shard.
+func (s *Shards) Get(shard int, key string) (Aggregate, bool) {
+	a, ok := s.shards[shard][key]
+	return a, ok
+}
+
+// Put stores the aggregate for key in shard.
+func (s *Shards) Put(shard int, key string, a Aggregate) {
+	s.shards[shard][key] = a
+}
+
+// Add merges update into the aggregate for key in shard, or stores it if there is none.
+// It returns true if the update was merged.
+func (s *Shards) Add(shard int, key string, update Aggregate) bool {
+	if a, ok := s.shards[shard][key]; ok {
+		a.Merge(update)
+		return true
+	}
+	s.shards[shard][key] = update
+	return false
+}
+
+// Len returns the number of aggregates in all shards.
+func (s *Shards) Len() int {
+	n := 0
+	for _, shard := range s.shards {
+		n += len(shard)
+	}
+	return n
+}
+
+// Drain calls fn for each aggregate, and removes it. The shards are cleared in place,
+// keeping their allocated buckets, so steady state services don't regrow the maps on
+// every flush.
+func (s *Shards) Drain(fn func(shard int, key string, a Aggregate)) {
+	for i, shard := range s.shards {
+		for key, a := range shard {
+			fn(i, key, a)
+			delete(shard, key)
+		}
+	}
+}
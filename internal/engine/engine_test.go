@@ -0,0 +1,97 @@
+package engine
+
+import (
+	"fmt"
+	"sync"
+	"testing"
+)
+
+type sum struct {
+	value float64
+}
+
+func (s *sum) Merge(update Aggregate) {
+	s.value += update.(*sum).value
+}
+
+func TestShards(t *testing.T) {
+
+	shards := NewShards(4, 0)
+	for i := 0; i < 10; i++ {
+		name := fmt.Sprintf("metric.%d", i%3)
+		shard := shards.Shard(name)
+		merged := shards.Add(shard, name, &sum{value: 1})
+		if merged != (i >= 3) {
+			t.Fatalf("expected update %d merged to be %t", i, i >= 3)
+		}
+	}
+	if shards.Len() != 3 {
+		t.Fatalf("expected %d aggregates, have %d", 3, shards.Len())
+	}
+
+	drained := map[string]float64{}
+	shards.Drain(func(shard int, key string, a Aggregate) {
+		if shard != shards.Shard(key) {
+			t.Fatalf("expected %s in shard %d, have %d", key, shards.Shard(key), shard)
+		}
+		drained[key] = a.(*sum).value
+	})
+	expected := map[string]float64{"metric.0": 4, "metric.1": 3, "metric.2": 3}
+	if fmt.Sprint(drained) != fmt.Sprint(expected) {
+		t.Fatalf("expected %v, have %v", expected, drained)
+	}
+	if shards.Len() != 0 {
+		t.Fatalf("expected shards to be empty after drain, have %d", shards.Len())
+	}
+}
+
+// TestShards_workers runs a worker per shard, the race detector reports any shared access.
+func TestShards_workers(t *testing.T) {
+
+	shards := NewShards(8, 0)
+	queues := make([]chan string, shards.Count())
+	wg := &sync.WaitGroup{}
+	for i := range queues {
+		queues[i] = make(chan string, 100)
+		wg.Add(1)
+		go func(shard int, queue chan string) {
+			defer wg.Done()
+			for name := range queue {
+				shards.Add(shard, name, &sum{value: 1})
+			}
+		}(i, queues[i])
+	}
+
+	for i := 0; i < 10000; i++ {
+		name := fmt.Sprintf("metric.%d", i%100)
+		queues[shards.Shard(name)] <- name
+	}
+	for _, queue := range queues {
+		close(queue)
+	}
+	wg.Wait()
+
+	total := 0.0
+	shards.Drain(func(_ int, _ string, a Aggregate) {
+		total += a.(*sum).value
+	})
+	if total != 10000 {
+		t.Fatalf("expected a total of %d, have %f", 10000, total)
+	}
+}
+
+func BenchmarkShards_Add(b *testing.B) {
+
+	shards := NewShards(4, 1000)
+	names := make([]string, 1000)
+	for i := range names {
+		names[i] = fmt.Sprintf("metric.%d", i)
+	}
+
+	b.ReportAllocs()
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		name := names[i%len(names)]
+		shards.Add(shards.Shard(name), name, &sum{value: 1})
+	}
+}
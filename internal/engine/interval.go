@@ -0,0 +1,19 @@
+package engine
+
+import (
+	"math"
+	"time"
+)
+
+// IntervalSeconds returns the length of a flush interval, as the whole number of seconds
+// sent as the metric interval, and the exact number of seconds used to calculate rates.
+// Flushes may be triggered manually between scheduled flushes, so intervals are often
+// not a whole number of seconds, truncating them would overstate the rate. Both values
+// are at least one second.
+func IntervalSeconds(interval time.Duration) (int64, float64) {
+	seconds := interval.Seconds()
+	if seconds < 1 {
+		return 1, 1
+	}
+	return int64(math.Round(seconds)), seconds
+}
@@ -0,0 +1,26 @@
+package engine
+
+import (
+	"hash/fnv"
+	"sort"
+	"strings"
+)
+
+// Key returns the key a metric is aggregated by, a combination of the name, and tags.
+// The tags are sorted in place, so the order tags are given in doesn't create a new
+// metric.
+func Key(name string, tags []string) string {
+	sort.Strings(tags)
+	return name + strings.Join(tags, "")
+}
+
+// Shard returns the shard, of n shards, that updates for name are assigned to, using an
+// FNV-1a hash of the name.
+func Shard(name string, n int) int {
+	if n <= 1 {
+		return 0
+	}
+	h := fnv.New32a()
+	_, _ = h.Write([]byte(name))
+	return int(h.Sum32() % uint32(n))
+}
@@ -0,0 +1,82 @@
+package engine
+
+import (
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestKey(t *testing.T) {
+	if Key("test", []string{"b:2", "a:1"}) != Key("test", []string{"a:1", "b:2"}) {
+		t.Fatalf("expected the key to not depend on the order of the tags")
+	}
+	if Key("test", nil) == Key("test2", nil) {
+		t.Fatalf("expected different names to have different keys")
+	}
+}
+
+func TestShard(t *testing.T) {
+	for _, n := range []int{0, 1, 3, 16} {
+		shard := Shard("test", n)
+		if shard < 0 || n > 0 && shard >= n {
+			t.Fatalf("expected shard of %d to be in range, have %d", n, shard)
+		}
+		if shard != Shard("test", n) {
+			t.Fatalf("expected the same name to have the same shard")
+		}
+	}
+}
+
+func TestIntervalSeconds(t *testing.T) {
+	tests := []struct {
+		interval time.Duration
+		whole    int64
+		exact    float64
+	}{
+		{time.Millisecond, 1, 1},
+		{time.Millisecond * 2500, 3, 2.5},
+		{time.Second * 10, 10, 10},
+	}
+	for _, test := range tests {
+		whole, exact := IntervalSeconds(test.interval)
+		if whole != test.whole || exact != test.exact {
+			t.Fatalf("expected %s to be %d, and %f, have %d, and %f", test.interval, test.whole, test.exact, whole, exact)
+		}
+	}
+}
+
+func TestCombine(t *testing.T) {
+	tests := []struct {
+		op       Op
+		expected float64
+	}{
+		{OpSum, 5},
+		{OpLast, 3},
+		{OpMax, 3},
+		{OpMin, 2},
+		{OpEWMA, 2.5},
+	}
+	for _, test := range tests {
+		if v := Combine(test.op, 2, 3, 0.5); v != test.expected {
+			t.Fatalf("expected op %d to be %f, have %f", test.op, test.expected, v)
+		}
+	}
+}
+
+func TestNewTagSet(t *testing.T) {
+
+	tags := []string{"b:2", "a:1", "b:2"}
+	set := NewTagSet(tags)
+	if strings.Join(set.Tags(), ",") != "a:1,b:2" {
+		t.Fatalf("expected sorted, unique tags, have %v", set.Tags())
+	}
+	if tags[0] != "b:2" {
+		t.Fatalf("expected the tags to be copied, have %v", tags)
+	}
+	if set.Key("test") != Key("test", []string{"b:2", "a:1"}) {
+		t.Fatalf("expected the set key %s to match the metric key %s", set.Key("test"), Key("test", []string{"b:2", "a:1"}))
+	}
+	if NewTagSet(nil).Key("test") != Key("test", nil) {
+		t.Fatalf("expected an empty set to have the key of a metric without tags")
+	}
+}
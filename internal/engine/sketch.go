@@ -0,0 +1,182 @@
+package engine
+
+import (
+	"math"
+	"sort"
+)
+
+// Default sketch settings. With a relative accuracy of 1%, 2048 bins cover values from
+// 1e-9 to 1e9 without collapsing.
+const (
+	DefaultSketchAccuracy = 0.01
+	DefaultSketchMaxBins  = 2048
+)
+
+// Sketch is a DDSketch, a quantile sketch with relative accuracy. Values are counted in
+// logarithmically sized bins, so memory is bounded by the number of bins, no matter how
+// many values are added. A quantile is within the relative accuracy of the exact value,
+// as long as the bins haven't been collapsed. When there are more than the max bins, the
+// lowest bins are collapsed, which only affects the accuracy of the lowest quantiles.
+// The count, sum, min, and max are exact.
+type Sketch struct {
+	gamma    float64
+	logGamma float64
+	maxBins  int
+	positive map[int]uint64
+	negative map[int]uint64 // Bins of the absolute value of negative values
+	zeros    uint64
+	count    uint64
+	sum      float64
+	min      float64
+	max      float64
+}
+
+// NewSketch creates a sketch with relative accuracy, between 0, and 1, and at most maxBins
+// bins for each of the positive, and negative values. Invalid settings are replaced with
+// the defaults.
+func NewSketch(accuracy float64, maxBins int) *Sketch {
+	if !(accuracy > 0 && accuracy < 1) {
+		accuracy = DefaultSketchAccuracy
+	}
+	if maxBins <= 0 {
+		maxBins = DefaultSketchMaxBins
+	}
+	gamma := (1 + accuracy) / (1 - accuracy)
+	return &Sketch{
+		gamma:    gamma,
+		logGamma: math.Log(gamma),
+		maxBins:  maxBins,
+		positive: map[int]uint64{},
+		negative: map[int]uint64{},
+	}
+}
+
+// Add adds v to the sketch. NaN, and infinite values are ignored.
+func (s *Sketch) Add(v float64) {
+
+	if math.IsNaN(v) || math.IsInf(v, 0) {
+		return
+	}
+
+	if s.count == 0 || v < s.min {
+		s.min = v
+	}
+	if s.count == 0 || v > s.max {
+		s.max = v
+	}
+	s.count++
+	s.sum += v
+
+	switch {
+	case v > 0:
+		s.positive[s.index(v)]++
+		s.collapse(s.positive)
+	case v < 0:
+		s.negative[s.index(-v)]++
+		s.collapse(s.negative)
+	default:
+		s.zeros++
+	}
+}
+
+// Count returns the number of values added.
+func (s *Sketch) Count() uint64 {
+	return s.count
+}
+
+// Sum returns the sum of the values added.
+func (s *Sketch) Sum() float64 {
+	return s.sum
+}
+
+// Min returns the smallest value added, or zero if the sketch is empty.
+func (s *Sketch) Min() float64 {
+	return s.min
+}
+
+// Max returns the largest value added, or zero if the sketch is empty.
+func (s *Sketch) Max() float64 {
+	return s.max
+}
+
+// Quantile returns the nearest rank quantile q, in the range 0 to 1, the same rank as
+// Percentile. It returns zero if the sketch is empty.
+func (s *Sketch) Quantile(q float64) float64 {
+
+	if s.count == 0 {
+		return 0
+	}
+	rank := uint64(0)
+	if r := int64(q*float64(s.count)+0.5) - 1; r > 0 {
+		rank = uint64(r)
+	}
+	if rank >= s.count {
+		rank = s.count - 1
+	}
+
+	var value float64
+	var seen uint64
+	s.ForEach(func(v float64, n uint64) bool {
+		value = v
+		seen += n
+		return seen <= rank
+	})
+
+	// The bin value can be outside the exact range of the values
+	return math.Max(s.min, math.Min(s.max, value))
+}
+
+// ForEach calls fn with the value, and count of each bin, in increasing order of value,
+// until fn returns false.
+func (s *Sketch) ForEach(fn func(value float64, count uint64) bool) {
+
+	negative := sortedIndexes(s.negative)
+	for i := len(negative) - 1; i >= 0; i-- {
+		if !fn(-s.value(negative[i]), s.negative[negative[i]]) {
+			return
+		}
+	}
+	if s.zeros > 0 && !fn(0, s.zeros) {
+		return
+	}
+	for _, i := range sortedIndexes(s.positive) {
+		if !fn(s.value(i), s.positive[i]) {
+			return
+		}
+	}
+}
+
+// index returns the bin of the positive value v.
+func (s *Sketch) index(v float64) int {
+	return int(math.Ceil(math.Log(v) / s.logGamma))
+}
+
+// value returns the value of bin i, the value with the lowest relative error to all values
+// in the bin.
+func (s *Sketch) value(i int) float64 {
+	return 2 * math.Pow(s.gamma, float64(i)) / (s.gamma + 1)
+}
+
+// collapse merges the lowest bins, if there are more than the max bins.
+func (s *Sketch) collapse(bins map[int]uint64) {
+
+	if len(bins) <= s.maxBins {
+		return
+	}
+	indexes := sortedIndexes(bins)
+	excess := len(indexes) - s.maxBins
+	target := indexes[excess]
+	for _, i := range indexes[:excess] {
+		bins[target] += bins[i]
+		delete(bins, i)
+	}
+}
+
+func sortedIndexes(bins map[int]uint64) []int {
+	indexes := make([]int, 0, len(bins))
+	for i := range bins {
+		indexes = append(indexes, i)
+	}
+	sort.Ints(indexes)
+	return indexes
+}
@@ -0,0 +1,71 @@
+package engine
+
+import (
+	"math"
+	"math/rand"
+	"sort"
+	"testing"
+)
+
+func TestSketch_Quantile(t *testing.T) {
+
+	r := rand.New(rand.NewSource(1))
+	s := NewSketch(DefaultSketchAccuracy, DefaultSketchMaxBins)
+	values := make([]float64, 0, 100000)
+	for i := 0; i < 100000; i++ {
+		v := math.Exp(r.NormFloat64() * 3)
+		if i%10 == 0 {
+			v = -v
+		}
+		values = append(values, v)
+		s.Add(v)
+	}
+	sort.Float64s(values)
+
+	for _, q := range []float64{0.01, 0.25, 0.5, 0.75, 0.95, 0.99, 0.999} {
+		exact, approx := Percentile(values, q), s.Quantile(q)
+		if math.Abs(approx-exact) > math.Abs(exact)*DefaultSketchAccuracy {
+			t.Fatalf("expected quantile %f to be within 1%% of %f, have %f", q, exact, approx)
+		}
+	}
+	if s.Count() != uint64(len(values)) || s.Min() != values[0] || s.Max() != values[len(values)-1] {
+		t.Fatalf("expected exact count, min, and max, have %d, %f, %f", s.Count(), s.Min(), s.Max())
+	}
+}
+
+func TestSketch_collapse(t *testing.T) {
+
+	s := NewSketch(DefaultSketchAccuracy, 10)
+	for i := 1; i <= 1000; i++ {
+		s.Add(float64(i))
+	}
+	if len(s.positive) != 10 {
+		t.Fatalf("expected %d bins, have %d", 10, len(s.positive))
+	}
+	if max := s.Quantile(1); max != 1000 {
+		t.Fatalf("expected max quantile %f, have %f", 1000.0, max)
+	}
+	if p99 := s.Quantile(0.99); math.Abs(p99-990) > 990*DefaultSketchAccuracy {
+		t.Fatalf("expected p99 to be within 1%% of %f, have %f", 990.0, p99)
+	}
+}
+
+func TestSketch_empty(t *testing.T) {
+
+	s := NewSketch(0, 0)
+	s.Add(math.NaN())
+	if s.Count() != 0 || s.Quantile(0.5) != 0 {
+		t.Fatalf("expected an empty sketch")
+	}
+	s.Add(0)
+	if s.Count() != 1 || s.Quantile(0.5) != 0 {
+		t.Fatalf("expected a sketch with one zero, have %d values, median %f", s.Count(), s.Quantile(0.5))
+	}
+}
+
+func BenchmarkSketch_Add(b *testing.B) {
+	s := NewSketch(DefaultSketchAccuracy, DefaultSketchMaxBins)
+	for i := 0; i < b.N; i++ {
+		s.Add(float64(i%10000) + 0.5)
+	}
+}
@@ -0,0 +1,96 @@
+package engine
+
+import (
+	"sort"
+	"strconv"
+)
+
+// Summary is one value of a summarized set of values, identified by the suffix appended
+// to the metric name.
+type Summary struct {
+	Suffix string
+	Value  float64
+}
+
+// Aggregates supported by SummarizeAggregates.
+const (
+	AggregateMax    = "max"
+	AggregateMin    = "min"
+	AggregateAvg    = "avg"
+	AggregateMedian = "median"
+	AggregateSum    = "sum"
+)
+
+// Summarize returns the max, avg, median, and the requested percentiles of values.
+// Values is sorted in place, and must not be empty.
+func Summarize(values []float64, percentiles ...float64) []Summary {
+	return SummarizeAggregates(values, []string{AggregateMax, AggregateAvg, AggregateMedian}, percentiles)
+}
+
+// SummarizeAggregates returns the requested aggregates, and percentiles of values, in
+// order. Unknown aggregates are ignored. Values is sorted in place, and must not be empty.
+func SummarizeAggregates(values []float64, aggregates []string, percentiles []float64) []Summary {
+
+	sort.Float64s(values)
+	sum := 0.0
+	for _, v := range values {
+		sum += v
+	}
+
+	return summarize(aggregates, percentiles, values[0], values[len(values)-1], sum, float64(len(values)),
+		func(p float64) float64 { return Percentile(values, p) })
+}
+
+// SummarizeSketch returns the requested aggregates, and percentiles of the values in a
+// sketch, in order. Unknown aggregates are ignored. The sketch must not be empty.
+func SummarizeSketch(s *Sketch, aggregates []string, percentiles []float64) []Summary {
+	return summarize(aggregates, percentiles, s.Min(), s.Max(), s.Sum(), float64(s.Count()), s.Quantile)
+}
+
+func summarize(aggregates []string, percentiles []float64, min, max, sum, count float64, quantile func(float64) float64) []Summary {
+
+	summary := make([]Summary, 0, len(aggregates)+len(percentiles))
+	for _, aggregate := range aggregates {
+		var value float64
+		switch aggregate {
+		case AggregateMax:
+			value = max
+		case AggregateMin:
+			value = min
+		case AggregateAvg:
+			value = sum / count
+		case AggregateMedian:
+			value = quantile(0.5)
+		case AggregateSum:
+			value = sum
+		default:
+			continue
+		}
+		summary = append(summary, Summary{"." + aggregate, value})
+	}
+	for _, p := range percentiles {
+		summary = append(summary, Summary{PercentileSuffix(p), quantile(p)})
+	}
+
+	return summary
+}
+
+// Percentile returns the nearest rank percentile p, in the range 0 to 1, of sorted.
+func Percentile(sorted []float64, p float64) float64 {
+	if len(sorted) == 0 {
+		return 0
+	}
+	idx := int(p*float64(len(sorted))+0.5) - 1
+	if idx < 0 {
+		idx = 0
+	} else if idx >= len(sorted) {
+		idx = len(sorted) - 1
+	}
+	return sorted[idx]
+}
+
+// PercentileSuffix returns the dogstatsd style suffix for percentile p, 0.95 is
+// returned as .95percentile.
+func PercentileSuffix(p float64) string {
+	return "." + strconv.FormatFloat(p*100, 'f', -1, 64) + "percentile"
+}
@@ -0,0 +1,75 @@
+package engine
+
+import (
+	"math/rand"
+	"testing"
+)
+
+func TestSummarize(t *testing.T) {
+
+	values := []float64{10, 1, 9, 2, 8, 3, 7, 4, 6, 5}
+	expected := map[string]float64{
+		".max":          10,
+		".avg":          5.5,
+		".median":       5,
+		".95percentile": 10,
+		".99percentile": 10,
+	}
+
+	summary := Summarize(values, 0.95, 0.99)
+	if len(summary) != len(expected) {
+		t.Fatalf("expected %d values, have %d", len(expected), len(summary))
+	}
+	for _, s := range summary {
+		if expected[s.Suffix] != s.Value {
+			t.Fatalf("expected %s to be %f, have %f", s.Suffix, expected[s.Suffix], s.Value)
+		}
+	}
+}
+
+func TestPercentile(t *testing.T) {
+
+	sorted := []float64{1, 2, 3, 4}
+	for p, expected := range map[float64]float64{0: 1, 0.25: 1, 0.5: 2, 0.75: 3, 1: 4} {
+		if v := Percentile(sorted, p); v != expected {
+			t.Fatalf("expected percentile %f to be %f, have %f", p, expected, v)
+		}
+	}
+	if v := Percentile(nil, 0.5); v != 0 {
+		t.Fatalf("expected percentile of no values to be 0, have %f", v)
+	}
+	if suffix := PercentileSuffix(0.999); suffix != ".99.9percentile" {
+		t.Fatalf("expected suffix %s, have %s", ".99.9percentile", suffix)
+	}
+}
+
+func BenchmarkSummarize(b *testing.B) {
+
+	values := make([]float64, 1000)
+	for i := range values {
+		values[i] = rand.Float64()
+	}
+	buf := make([]float64, len(values))
+
+	b.ReportAllocs()
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		copy(buf, values)
+		Summarize(buf, 0.95, 0.99)
+	}
+}
+
+func TestSummarizeAggregates(t *testing.T) {
+
+	values := []float64{4, 1, 3, 2}
+	summary := SummarizeAggregates(values, []string{AggregateSum, AggregateMin, "unknown"}, []float64{0.5})
+	expected := []Summary{{".sum", 10}, {".min", 1}, {".50percentile", 2}}
+	if len(summary) != len(expected) {
+		t.Fatalf("expected %v, have %v", expected, summary)
+	}
+	for i := range expected {
+		if summary[i] != expected[i] {
+			t.Fatalf("expected %v, have %v", expected, summary)
+		}
+	}
+}
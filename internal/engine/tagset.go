@@ -0,0 +1,41 @@
+package engine
+
+import (
+	"sort"
+	"strings"
+)
+
+// TagSet is a canonical set of tags, sorted, and without duplicates, with the part of the
+// aggregation key made from the tags computed once, when the set is created. A TagSet is
+// immutable, and can be shared by concurrent submissions, which skip sorting, and joining
+// the tags.
+type TagSet struct {
+	tags []string
+	key  string
+}
+
+// NewTagSet creates a tag set from a copy of tags.
+func NewTagSet(tags []string) TagSet {
+
+	sorted := append([]string(nil), tags...)
+	sort.Strings(sorted)
+	unique := sorted[:0]
+	for i, tag := range sorted {
+		if i == 0 || tag != sorted[i-1] {
+			unique = append(unique, tag)
+		}
+	}
+
+	return TagSet{tags: unique, key: strings.Join(unique, "")}
+}
+
+// Tags returns the sorted tags of the set. The slice is shared, and must not be modified.
+func (s TagSet) Tags() []string {
+	return s.tags
+}
+
+// Key returns the aggregation key of a metric named name with the tags of the set, the
+// same key Key returns for the tags.
+func (s TagSet) Key(name string) string {
+	return name + s.key
+}
@@ -0,0 +1,88 @@
+package ddstats
+
+import (
+	"fmt"
+	"log"
+	"sync/atomic"
+)
+
+// Logger receives the library's log messages. It's satisfied by most leveled loggers, or
+// can be adapted from a standard library logger with NewStdLogger. Stats doesn't log
+// unless a logger is set with Config.WithLogger.
+//
+// Messages are logged for dropped metrics, flush results, spool retries, and shutdown.
+// Dropped metrics are summarized once per flush, not logged individually. A logger must
+// be safe for concurrent use.
+type Logger interface {
+	Debugf(format string, args ...interface{})
+	Infof(format string, args ...interface{})
+	Warnf(format string, args ...interface{})
+	Errorf(format string, args ...interface{})
+}
+
+// noopLogger discards all messages, it's used when no logger is set.
+type noopLogger struct{}
+
+func (noopLogger) Debugf(string, ...interface{}) {}
+func (noopLogger) Infof(string, ...interface{})  {}
+func (noopLogger) Warnf(string, ...interface{})  {}
+func (noopLogger) Errorf(string, ...interface{}) {}
+
+// StdLogger adapts a standard library logger to Logger. Each message is prefixed with
+// its level, and debug messages are discarded unless Debug is true.
+type StdLogger struct {
+	Logger *log.Logger
+	Debug  bool
+}
+
+// NewStdLogger returns a Logger writing to l, or the standard logger if l is nil. Debug
+// messages are only written when debug is true.
+func NewStdLogger(l *log.Logger, debug bool) *StdLogger {
+	return &StdLogger{Logger: l, Debug: debug}
+}
+
+func (l *StdLogger) Debugf(format string, args ...interface{}) {
+	if l.Debug {
+		l.output("DEBUG", format, args)
+	}
+}
+
+func (l *StdLogger) Infof(format string, args ...interface{}) {
+	l.output("INFO", format, args)
+}
+
+func (l *StdLogger) Warnf(format string, args ...interface{}) {
+	l.output("WARN", format, args)
+}
+
+func (l *StdLogger) Errorf(format string, args ...interface{}) {
+	l.output("ERROR", format, args)
+}
+
+func (l *StdLogger) output(level, format string, args []interface{}) {
+	msg := fmt.Sprintf("ddstats %s: %s", level, fmt.Sprintf(format, args...))
+	if l.Logger == nil {
+		_ = log.Output(3, msg)
+		return
+	}
+	_ = l.Logger.Output(3, msg)
+}
+
+// log returns the configured logger, or a logger that discards all messages.
+func (c *Stats) log() Logger {
+	if c.logger == nil {
+		return noopLogger{}
+	}
+	return c.logger
+}
+
+// logDropped logs the number of metrics dropped since the last call.
+func (c *Stats) logDropped() {
+	if c.logger == nil {
+		return
+	}
+	dropped := atomic.LoadUint64(&c.dropped)
+	if last := atomic.SwapUint64(&c.droppedLogged, dropped); dropped > last {
+		c.logger.Warnf("dropped %d metrics since the last flush, the metric queue is full", dropped-last)
+	}
+}
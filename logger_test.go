@@ -0,0 +1,90 @@
+package ddstats
+
+import (
+	"bytes"
+	"fmt"
+	"log"
+	"strings"
+	"sync"
+	"testing"
+)
+
+type testLogger struct {
+	lines []string
+	lock  sync.Mutex
+}
+
+func (l *testLogger) add(level, format string, args []interface{}) {
+	l.lock.Lock()
+	defer l.lock.Unlock()
+	l.lines = append(l.lines, level+" "+fmt.Sprintf(format, args...))
+}
+
+func (l *testLogger) Debugf(format string, args ...interface{}) { l.add("debug", format, args) }
+func (l *testLogger) Infof(format string, args ...interface{})  { l.add("info", format, args) }
+func (l *testLogger) Warnf(format string, args ...interface{})  { l.add("warn", format, args) }
+func (l *testLogger) Errorf(format string, args ...interface{}) { l.add("error", format, args) }
+
+func (l *testLogger) find(prefix string) bool {
+	l.lock.Lock()
+	defer l.lock.Unlock()
+	for _, line := range l.lines {
+		if strings.HasPrefix(line, prefix) {
+			return true
+		}
+	}
+	return false
+}
+
+func TestStats_WithLogger(t *testing.T) {
+
+	logger := &testLogger{}
+	testApi := NewTestAPIClient()
+	testApi.sendSeriesError = fmt.Errorf("api down")
+	cfg := NewConfig().
+		WithNamespace(testNamespace).
+		WithHost(testHost).
+		WithClient(testApi).
+		WithLogger(logger)
+	cfg.MetricBuffer = 1
+	cfg.FlushIntervalSeconds = 60
+	stats, err := NewStats(cfg)
+	if err != nil {
+		t.Fatalf(err.Error())
+	}
+
+	for i := 0; i < 1000; i++ {
+		stats.Increment("test", nil)
+	}
+	stats.Flush()
+	stats.Close()
+
+	for _, prefix := range []string{
+		"warn dropped",
+		"error could not send 1 series, api down",
+		"info closing",
+		"info closed",
+	} {
+		if !logger.find(prefix) {
+			t.Fatalf("expected a log line starting with %q, have %v", prefix, logger.lines)
+		}
+	}
+}
+
+func TestStdLogger(t *testing.T) {
+
+	buf := &bytes.Buffer{}
+	logger := NewStdLogger(log.New(buf, "", 0), false)
+	logger.Debugf("hidden %d", 1)
+	logger.Warnf("shown %d", 2)
+	if expected := "ddstats WARN: shown 2\n"; buf.String() != expected {
+		t.Fatalf("expected %q, have %q", expected, buf.String())
+	}
+
+	buf.Reset()
+	logger.Debug = true
+	logger.Debugf("shown %d", 3)
+	if expected := "ddstats DEBUG: shown 3\n"; buf.String() != expected {
+		t.Fatalf("expected %q, have %q", expected, buf.String())
+	}
+}
@@ -1,49 +1,390 @@
 package ddstats
 
 import (
+	"sort"
 	"time"
 
 	"github.com/jmizell/ddstats/client"
+	"github.com/jmizell/ddstats/internal/engine"
 )
 
+// histogram is the class of metrics recorded with Histogram. It's not an api metric type,
+// histograms are sent as a set of gauges, and a rate.
+const histogram = "histogram"
+
+// histogramExactValues is the number of values a histogram stores in a flush interval,
+// before the values are moved to a sketch. Up to the limit, aggregates, and percentiles
+// are exact.
+const histogramExactValues = 1024
+
+// set is the class of metrics recorded with Set. Sets are sent as a gauge of the number of
+// unique members.
+const set = "set"
+
 type metric struct {
-	name  string
-	class string
+	name        string
+	class       string
+	value       float64
+	tags        []string
+	tagKey      *engine.TagSet // Tag set the tags were taken from, nil if the tags are unsorted
+	host        string         // Host of the metric, empty for the global host
+	timestamp   int64          // Unix time of the update, only set when downsampling
+	observed    int64          // Unix time set with WithTimestamp, zero to use the flush time
+	flushed     int64          // Unix time of the flush, set when the metric is sent
+	window      int64          // Downsampling window in seconds, zero when disabled
+	windows     []windowValue
+	values      []float64             // Histogram, and distribution values recorded in the flush interval
+	members     map[string]bool       // Set members recorded in the flush interval
+	sequence    uint64                // Submission order of the last gauge update
+	aggregation GaugeAggregation      // Gauge aggregation
+	alpha       float64               // Smoothing factor of an ewma
+	last        float64               // Last absolute value of a monotonic count
+	histogram   *histogramAggregation // Histogram aggregates, nil for the defaults
+	sketch      *engine.Sketch        // Histogram values, once there are more than the exact limit
+	group       int                   // Flush interval group, zero for the default interval
+}
+
+// key returns the key the metric is aggregated by. Metrics are indexed by a combination
+// of the name, tags, and host. Distributions are indexed separately.
+func (m *metric) key() string {
+	var key string
+	if m.tagKey != nil {
+		key = m.tagKey.Key(m.name)
+	} else {
+		key = engine.Key(m.name, m.tags)
+	}
+	if m.host != "" {
+		key += "|host:" + m.host
+	}
+	if m.class == client.Distribution {
+		key = distributionKeyPrefix + key
+	}
+	return key
+}
+
+// hostOr returns the host of the metric, or host if the metric uses the global host.
+func (m *metric) hostOr(host string) string {
+	if m.host != "" {
+		return m.host
+	}
+	return host
+}
+
+// pointTime returns the timestamp of the metric's point, the latest time set with
+// WithTimestamp, or the time of the flush, read from the stats clock.
+func (m *metric) pointTime() int64 {
+	if m.observed > 0 {
+		return m.observed
+	}
+	return m.flushed
+}
+
+// windowValue is the aggregated value of a metric within one downsampling window.
+type windowValue struct {
+	start int64
 	value float64
-	tags  []string
+}
+
+// updateFrom applies the update u, submitted for the same metric.
+func (m *metric) updateFrom(u *metric) {
+	if u.observed > m.observed {
+		m.observed = u.observed
+	}
+	switch {
+	case m.class == set:
+		m.addMembers(u.members)
+	case m.class == ewma:
+		m.alpha = u.alpha
+		m.update(u.value)
+	case m.class == monotonicCount:
+		m.value += monotonicDelta(m.last, u.value)
+		m.last = u.value
+	case m.class == client.Gauge && m.aggregation == GaugeLast && u.sequence < m.sequence:
+		// An update submitted before the current value, that was processed after it
+	default:
+		if u.sequence > m.sequence {
+			m.sequence = u.sequence
+		}
+		m.updateAt(u.value, u.timestamp)
+	}
 }
 
 func (m *metric) update(v float64) {
+	if m.class == histogram {
+		m.addHistogramValue(v)
+		return
+	}
+	if m.class == client.Distribution {
+		m.values = append(m.values, v)
+		return
+	}
+	m.value = m.combine(m.value, v)
+}
+
+// addHistogramValue records v in the histogram. Values are stored until there are more
+// than histogramExactValues, then they're moved to a sketch, so the memory used by a
+// histogram is bounded, no matter how many values are recorded.
+func (m *metric) addHistogramValue(v float64) {
+	if m.sketch == nil && len(m.values) < histogramExactValues {
+		m.values = append(m.values, v)
+		return
+	}
+	if m.sketch == nil {
+		m.sketch = engine.NewSketch(engine.DefaultSketchAccuracy, engine.DefaultSketchMaxBins)
+		for _, value := range m.values {
+			m.sketch.Add(value)
+		}
+		m.values = nil
+	}
+	m.sketch.Add(v)
+}
+
+// histogramCount returns the number of values recorded in the histogram.
+func (m *metric) histogramCount() int {
+	if m.sketch != nil {
+		return int(m.sketch.Count())
+	}
+	return len(m.values)
+}
+
+// combine returns the result of applying the update v to current.
+func (m *metric) combine(current, v float64) float64 {
 	switch m.class {
 	case client.Gauge:
-		m.value = v
-	case client.Count, client.Rate:
-		m.value += v
+		switch m.aggregation {
+		case GaugeMax:
+			return engine.Combine(engine.OpMax, current, v, 0)
+		case GaugeMin:
+			return engine.Combine(engine.OpMin, current, v, 0)
+		}
+		return engine.Combine(engine.OpLast, current, v, 0)
+	case client.Count, client.Rate, gaugeDelta:
+		return engine.Combine(engine.OpSum, current, v, 0)
+	case ewma:
+		return engine.Combine(engine.OpEWMA, current, v, m.alpha)
 	}
+	return current
+}
+
+// Merge applies update, an update submitted for the same metric.
+func (m *metric) Merge(update engine.Aggregate) {
+	m.updateFrom(update.(*metric))
 }
 
-func (m *metric) getMetric(namespace, host string, tags []string, interval time.Duration) *client.DDMetric {
+// addMembers adds members to the set.
+func (m *metric) addMembers(members map[string]bool) {
+	for member := range members {
+		m.members[member] = true
+	}
+}
+
+// startWindow initializes the first downsampling window with the metric's value.
+func (m *metric) startWindow() {
+	if m.window > 0 {
+		m.windows = []windowValue{{start: m.timestamp - m.timestamp%m.window, value: m.value}}
+	}
+}
+
+// updateAt updates the metric with value v, submitted at unix time ts. When downsampling
+// is enabled, the window containing ts is also updated. Windows are kept sorted by start,
+// timestamps set with WithTimestamp may arrive out of order.
+func (m *metric) updateAt(v float64, ts int64) {
+
+	m.update(v)
+	if m.window <= 0 {
+		return
+	}
+
+	start := ts - ts%m.window
+	i := sort.Search(len(m.windows), func(i int) bool { return m.windows[i].start >= start })
+	if i < len(m.windows) && m.windows[i].start == start {
+		m.windows[i].value = m.combine(m.windows[i].value, v)
+		return
+	}
+	m.windows = append(m.windows, windowValue{})
+	copy(m.windows[i+1:], m.windows[i:])
+	m.windows[i] = windowValue{start: start, value: v}
+}
+
+// negative returns true if the metric is a count, or rate with a negative value.
+func (m *metric) negative() bool {
+	if m.class != client.Count && m.class != client.Rate {
+		return false
+	}
+	if m.value < 0 {
+		return true
+	}
+	for _, w := range m.windows {
+		if w.value < 0 {
+			return true
+		}
+	}
+	return false
+}
+
+// clampNegative sets any negative value, or window value to zero.
+func (m *metric) clampNegative() {
+	if m.value < 0 {
+		m.value = 0
+	}
+	for i := range m.windows {
+		if m.windows[i].value < 0 {
+			m.windows[i].value = 0
+		}
+	}
+}
+
+// getMetric returns the metric as a DDMetric named name. The namespace is not prepended,
+// it's prepended when the series is sent.
+func (m *metric) getMetric(name, host string, tags []string, interval time.Duration) *client.DDMetric {
 	metric := &client.DDMetric{
 		Host:   host,
-		Metric: prependNamespace(namespace, m.name),
+		Metric: name,
 		Tags:   combineTags(m.tags, tags),
 		Type:   m.class,
 	}
+	if len(m.windows) > 1 {
+		m.windowPoints(metric)
+		return metric
+	}
 	switch m.class {
 	case client.Gauge:
-		metric.Points = [][2]interface{}{{time.Now().Unix(), m.value}}
+		metric.Points = [][2]interface{}{{m.pointTime(), m.value}}
+	case ewma, gaugeDelta:
+		metric.Type = client.Gauge
+		metric.Points = [][2]interface{}{{m.pointTime(), m.value}}
+	case set:
+		metric.Type = client.Gauge
+		metric.Points = [][2]interface{}{{m.pointTime(), float64(len(m.members))}}
 	case client.Rate:
-		metric.Interval = int64(interval.Seconds())
-		if metric.Interval == 0 {
-			metric.Interval = 1
+		var seconds float64
+		metric.Interval, seconds = engine.IntervalSeconds(interval)
+		metric.Points = [][2]interface{}{{m.pointTime(), m.value / seconds}}
+	case client.Count, monotonicCount:
+		metric.Type = client.Count
+		metric.Interval, _ = engine.IntervalSeconds(interval)
+		metric.Points = [][2]interface{}{{m.pointTime(), m.value}}
+	}
+	return metric
+}
+
+// windowPoints sets one point per downsampling window. Rates are calculated over the
+// window length, and the window start is used as the point timestamp.
+func (m *metric) windowPoints(metric *client.DDMetric) {
+	if m.class != client.Gauge {
+		metric.Interval = m.window
+	}
+	metric.Points = make([][2]interface{}, 0, len(m.windows))
+	for _, w := range m.windows {
+		value := w.value
+		if m.class == client.Rate {
+			value = w.value / float64(m.window)
+		}
+		metric.Points = append(metric.Points, [2]interface{}{w.start, value})
+	}
+}
+
+// histogramMetrics returns the histogram as a set of DDMetrics named name, with a suffix for
+// each configured aggregate, and percentile, by default .max, .min, .avg, .median,
+// .95percentile, and .count. The count is sent as a rate, the same as dogstatsd. Histograms
+// with more than histogramExactValues values are summarized from a sketch, percentiles,
+// and the median are accurate to within 1% of the exact value.
+func (m *metric) histogramMetrics(name, host string, tags []string, interval time.Duration) []*client.DDMetric {
+
+	if m.histogramCount() == 0 {
+		return nil
+	}
+	tags = combineTags(m.tags, tags)
+	now := m.pointTime()
+
+	h := m.histogram
+	if h == nil {
+		h = defaultHistogramAggregation
+	}
+	var summary []engine.Summary
+	if m.sketch != nil {
+		summary = engine.SummarizeSketch(m.sketch, h.aggregates, h.percentiles)
+	} else {
+		summary = engine.SummarizeAggregates(m.values, h.aggregates, h.percentiles)
+	}
+	metrics := make([]*client.DDMetric, 0, len(summary)+1)
+	for _, s := range summary {
+		metrics = append(metrics, &client.DDMetric{
+			Host:   host,
+			Metric: name + s.Suffix,
+			Points: [][2]interface{}{{now, s.Value}},
+			Tags:   tags,
+			Type:   client.Gauge,
+		})
+	}
+
+	if !h.count {
+		return metrics
+	}
+	interval64, seconds := engine.IntervalSeconds(interval)
+	metrics = append(metrics, &client.DDMetric{
+		Host:     host,
+		Interval: interval64,
+		Metric:   name + ".count",
+		Points:   [][2]interface{}{{now, float64(m.histogramCount()) / seconds}},
+		Tags:     tags,
+		Type:     client.Rate,
+	})
+
+	return metrics
+}
+
+// DefaultKeptValueExpiry is the default number of flush intervals without an update, after
+// which a value kept across intervals is forgotten.
+const DefaultKeptValueExpiry = 60
+
+// keptValue is a value kept across flush intervals, and the time it's forgotten at if the
+// metric isn't updated again.
+type keptValue struct {
+	value   float64
+	expires time.Time
+}
+
+// resumeValue applies the first update of an interval to the value kept from previous
+// intervals, for ewma, gauge delta, and monotonic count metrics. Each metric is always
+// handled by the same worker, so the values are kept per worker, and aren't locked.
+func (c *Stats) resumeValue(worker int, key string, m *metric) {
+	switch m.class {
+	case ewma, gaugeDelta:
+		if kept, ok := c.keptValues[worker][key]; ok {
+			m.value = m.combine(kept.value, m.value)
 		}
-		metric.Points = [][2]interface{}{{time.Now().Unix(), m.value / float64(metric.Interval)}}
-	case client.Count:
-		metric.Interval = int64(interval.Seconds())
-		if metric.Interval == 0 {
-			metric.Interval = 1
+	case monotonicCount:
+		// The first value of a monotonic count is the baseline, and has no delta
+		m.last, m.value = m.value, 0
+		if kept, ok := c.keptValues[worker][key]; ok {
+			m.value = monotonicDelta(kept.value, m.last)
 		}
-		metric.Points = [][2]interface{}{{time.Now().Unix(), m.value}}
 	}
-	return metric
+}
+
+// keepValue stores the value of an ewma, or gauge delta metric, or the last absolute value
+// of a monotonic count at the end of an interval, until expires.
+func (c *Stats) keepValue(worker int, key string, m *metric, expires time.Time) {
+	switch m.class {
+	case ewma, gaugeDelta:
+		c.keptValues[worker][key] = keptValue{value: m.value, expires: expires}
+	case monotonicCount:
+		c.keptValues[worker][key] = keptValue{value: m.last, expires: expires}
+	}
+}
+
+// expireKeptValues removes the kept values that have expired, and returns the expiry time
+// of values kept at the end of an interval. It's called while the workers are idle, as
+// the values aren't locked.
+func (c *Stats) expireKeptValues(interval time.Duration) time.Time {
+	now := c.clock.Now()
+	for _, kept := range c.keptValues {
+		for key, v := range kept {
+			if now.After(v.expires) {
+				delete(kept, key)
+			}
+		}
+	}
+	return now.Add(interval * time.Duration(c.keptValueExpiry))
 }
@@ -1,6 +1,7 @@
 package ddstats
 
 import (
+	"math"
 	"testing"
 	"time"
 
@@ -146,3 +147,204 @@ func TestMetricGetMetric(t *testing.T) {
 		}
 	})
 }
+
+func TestMetricPartialInterval(t *testing.T) {
+
+	t.Run("rate", func(tt *testing.T) {
+		m := &metric{class: client.Rate, value: 10}
+		ddm := m.getMetric("", "", nil, time.Millisecond*2500)
+		if ddm.Points[0][1] != 4.0 {
+			tt.Fatalf("expected rate to be %f, have %v", 4.0, ddm.Points[0][1])
+		}
+		if ddm.Interval != 3 {
+			tt.Fatalf("expected interval to be %d, have %d", 3, ddm.Interval)
+		}
+	})
+
+	t.Run("count", func(tt *testing.T) {
+		m := &metric{class: client.Count, value: 10}
+		ddm := m.getMetric("", "", nil, time.Millisecond*1400)
+		if ddm.Points[0][1] != 10.0 {
+			tt.Fatalf("expected count to be %f, have %v", 10.0, ddm.Points[0][1])
+		}
+		if ddm.Interval != 1 {
+			tt.Fatalf("expected interval to be %d, have %d", 1, ddm.Interval)
+		}
+	})
+
+	t.Run("short", func(tt *testing.T) {
+		m := &metric{class: client.Rate, value: 10}
+		ddm := m.getMetric("", "", nil, time.Millisecond*100)
+		if ddm.Points[0][1] != 10.0 || ddm.Interval != 1 {
+			tt.Fatalf("expected rate of %f over %d second, have %v over %d", 10.0, 1, ddm.Points[0][1], ddm.Interval)
+		}
+	})
+}
+
+func TestMetricDownsample(t *testing.T) {
+
+	t.Run("count windows", func(tt *testing.T) {
+		m := &metric{class: client.Count, value: 1, window: 60, timestamp: 125}
+		m.startWindow()
+		m.updateAt(2, 170)
+		m.updateAt(3, 185)
+		m.updateAt(4, 300)
+
+		ddm := m.getMetric("test", "", nil, time.Minute*5)
+		expected := [][2]interface{}{{int64(120), 3.0}, {int64(180), 3.0}, {int64(300), 4.0}}
+		if len(ddm.Points) != len(expected) {
+			tt.Fatalf("expected to have %d points, have %d", len(expected), len(ddm.Points))
+		}
+		for i := range expected {
+			if ddm.Points[i] != expected[i] {
+				tt.Fatalf("expected point %d to be %v, have %v", i, expected[i], ddm.Points[i])
+			}
+		}
+		if ddm.Interval != 60 {
+			tt.Fatalf("expected interval to be %d, have %d", 60, ddm.Interval)
+		}
+		if m.value != 10 {
+			tt.Fatalf("expected total value to be %f, have %f", 10.0, m.value)
+		}
+	})
+
+	t.Run("out of order windows", func(tt *testing.T) {
+		m := &metric{class: client.Count, value: 1, window: 60, timestamp: 185}
+		m.startWindow()
+		m.updateAt(2, 125)
+		m.updateAt(3, 190)
+		m.updateAt(4, 130)
+
+		ddm := m.getMetric("test", "", nil, time.Minute*5)
+		expected := [][2]interface{}{{int64(120), 6.0}, {int64(180), 4.0}}
+		if len(ddm.Points) != len(expected) {
+			tt.Fatalf("expected to have %d points, have %v", len(expected), ddm.Points)
+		}
+		for i := range expected {
+			if ddm.Points[i] != expected[i] {
+				tt.Fatalf("expected point %d to be %v, have %v", i, expected[i], ddm.Points[i])
+			}
+		}
+	})
+
+	t.Run("rate windows", func(tt *testing.T) {
+		m := &metric{class: client.Rate, value: 60, window: 60, timestamp: 0}
+		m.startWindow()
+		m.updateAt(120, 60)
+
+		ddm := m.getMetric("test", "", nil, time.Minute*2)
+		if ddm.Points[0][1] != 1.0 || ddm.Points[1][1] != 2.0 {
+			tt.Fatalf("expected rates to be [1 2], have %v", ddm.Points)
+		}
+	})
+
+	t.Run("gauge windows", func(tt *testing.T) {
+		m := &metric{class: client.Gauge, value: 1, window: 60, timestamp: 0}
+		m.startWindow()
+		m.updateAt(2, 10)
+		m.updateAt(3, 60)
+
+		ddm := m.getMetric("test", "", nil, time.Minute*2)
+		if ddm.Points[0][1] != 2.0 || ddm.Points[1][1] != 3.0 {
+			tt.Fatalf("expected gauge values to be [2 3], have %v", ddm.Points)
+		}
+		if ddm.Interval != 0 {
+			tt.Fatalf("expected gauge interval to be %d, have %d", 0, ddm.Interval)
+		}
+	})
+
+	t.Run("single window", func(tt *testing.T) {
+		m := &metric{class: client.Count, value: 1, window: 60, timestamp: 0}
+		m.startWindow()
+		m.updateAt(2, 10)
+
+		ddm := m.getMetric("test", "", nil, time.Second*5)
+		if len(ddm.Points) != 1 || ddm.Points[0][1] != 3.0 {
+			tt.Fatalf("expected a single point with value 3, have %v", ddm.Points)
+		}
+	})
+}
+
+func TestMetricHistogram(t *testing.T) {
+
+	m := &metric{class: histogram, values: []float64{5}}
+	for _, v := range []float64{1, 4, 2, 3} {
+		m.update(v)
+	}
+
+	expected := map[string]interface{}{
+		"test.max":          5.0,
+		"test.min":          1.0,
+		"test.avg":          3.0,
+		"test.median":       3.0,
+		"test.95percentile": 5.0,
+		"test.count":        0.5,
+	}
+	metrics := m.histogramMetrics("test", "host", nil, time.Second*10)
+	if len(metrics) != len(expected) {
+		t.Fatalf("expected %d metrics, have %d", len(expected), len(metrics))
+	}
+	for _, ddm := range metrics {
+		value, ok := expected[ddm.Metric]
+		if !ok {
+			t.Fatalf("unexpected metric %s", ddm.Metric)
+		}
+		if ddm.Points[0][1] != value {
+			t.Fatalf("expected %s to be %v, have %v", ddm.Metric, value, ddm.Points[0][1])
+		}
+		if ddm.Metric == "test.count" && (ddm.Type != client.Rate || ddm.Interval != 10) {
+			t.Fatalf("expected count to be a rate with interval %d, have %s %d", 10, ddm.Type, ddm.Interval)
+		}
+	}
+}
+
+func TestMetricHistogram_sketch(t *testing.T) {
+
+	m := &metric{class: histogram}
+	n := histogramExactValues * 100
+	for i := 1; i <= n; i++ {
+		m.update(float64(i))
+	}
+	if m.sketch == nil || m.values != nil {
+		t.Fatalf("expected the values to be moved to a sketch")
+	}
+
+	metrics := map[string]float64{}
+	for _, ddm := range m.histogramMetrics("test", "host", nil, time.Second) {
+		metrics[ddm.Metric] = ddm.Points[0][1].(float64)
+	}
+	for name, expected := range map[string]float64{
+		"test.max":          float64(n),
+		"test.min":          1,
+		"test.avg":          float64(n+1) / 2,
+		"test.count":        float64(n),
+		"test.95percentile": float64(n) * 0.95,
+		"test.median":       float64(n) / 2,
+	} {
+		if value := metrics[name]; math.Abs(value-expected) > expected*0.01 {
+			t.Fatalf("expected %s to be within 1%% of %f, have %f", name, expected, value)
+		}
+	}
+}
+
+func TestMetricGaugeAggregation(t *testing.T) {
+
+	tests := []struct {
+		aggregation GaugeAggregation
+		expected    float64
+	}{
+		{GaugeLast, 2},
+		{GaugeMax, 3},
+		{GaugeMin, 1},
+	}
+	for _, test := range tests {
+		m := &metric{class: client.Gauge, aggregation: test.aggregation, value: 1, sequence: 1}
+
+		// Updates are processed out of submission order, 3 was submitted before 2
+		m.updateFrom(&metric{value: 2, sequence: 3})
+		m.updateFrom(&metric{value: 3, sequence: 2})
+		if m.value != test.expected {
+			t.Fatalf("expected %q gauge to be %f, have %f", test.aggregation, test.expected, m.value)
+		}
+	}
+}
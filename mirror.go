@@ -0,0 +1,213 @@
+package ddstats
+
+import (
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/jmizell/ddstats/client"
+)
+
+// Default values for the flush mirror file
+const (
+	DefaultMirrorMaxBytes   = 100 * 1024 * 1024
+	DefaultMirrorMaxBackups = 5
+)
+
+// mirrorBackupLayout is the time layout of the suffix of rotated mirror files.
+const mirrorBackupLayout = "20060102T150405.000000000"
+
+// renameFile renames the mirror file on rotation, replaced in tests.
+var renameFile = os.Rename
+
+// mirrorRecord is a single line of the mirror file.
+type mirrorRecord struct {
+	Timestamp int64              `json:"timestamp"`
+	Error     string             `json:"error,omitempty"`
+	Series    []*client.DDMetric `json:"series"`
+}
+
+// fileMirror appends every flushed series to a JSONL file, rotating the file when it
+// exceeds a max size, or max age.
+type fileMirror struct {
+	path       string
+	maxBytes   int64
+	maxAge     time.Duration
+	maxBackups int
+	file       *os.File
+	size       int64
+	opened     time.Time
+	lock       *sync.Mutex
+}
+
+func newFileMirror(path string, maxBytes int64, maxAge time.Duration, maxBackups int) (*fileMirror, error) {
+
+	if maxBackups <= 0 {
+		maxBackups = DefaultMirrorMaxBackups
+	}
+	m := &fileMirror{
+		path:       path,
+		maxBytes:   maxBytes,
+		maxAge:     maxAge,
+		maxBackups: maxBackups,
+		lock:       &sync.Mutex{},
+	}
+	if err := m.open(); err != nil {
+		return nil, err
+	}
+
+	return m, nil
+}
+
+// write appends a record for a flushed series, and the error returned by the api
+// client, if any.
+func (m *fileMirror) write(series []*client.DDMetric, sendErr error) error {
+
+	record := &mirrorRecord{Timestamp: time.Now().Unix(), Series: series}
+	if sendErr != nil {
+		record.Error = sendErr.Error()
+	}
+	data, err := json.Marshal(record)
+	if err != nil {
+		return fmt.Errorf("could not marshal mirror record, %s", err.Error())
+	}
+	data = append(data, '\n')
+
+	m.lock.Lock()
+	defer m.lock.Unlock()
+
+	if m.file == nil {
+		return fmt.Errorf("mirror file %s is closed", m.path)
+	}
+	// A failed rotation leaves the current file open, the record is still written, and
+	// the rotation is retried on the next write.
+	var rotateErr error
+	if m.shouldRotate(int64(len(data))) {
+		rotateErr = m.rotate()
+	}
+
+	n, err := m.file.Write(data)
+	m.size += int64(n)
+	if err != nil {
+		return fmt.Errorf("could not write mirror file, %s", err.Error())
+	}
+
+	return rotateErr
+}
+
+func (m *fileMirror) close() error {
+	m.lock.Lock()
+	defer m.lock.Unlock()
+	if m.file == nil {
+		return nil
+	}
+	err := m.file.Close()
+	m.file = nil
+	return err
+}
+
+func (m *fileMirror) shouldRotate(n int64) bool {
+	if m.size == 0 {
+		return false
+	}
+	if m.maxBytes > 0 && m.size+n > m.maxBytes {
+		return true
+	}
+	return m.maxAge > 0 && time.Since(m.opened) > m.maxAge
+}
+
+func (m *fileMirror) open() error {
+
+	file, size, err := openMirrorFile(m.path)
+	if err != nil {
+		return err
+	}
+
+	m.file = file
+	m.size = size
+	m.opened = time.Now()
+	return nil
+}
+
+func openMirrorFile(path string) (*os.File, int64, error) {
+
+	file, err := os.OpenFile(path, os.O_CREATE|os.O_WRONLY|os.O_APPEND, 0644)
+	if err != nil {
+		return nil, 0, fmt.Errorf("could not open mirror file, %s", err.Error())
+	}
+	info, err := file.Stat()
+	if err != nil {
+		_ = file.Close()
+		return nil, 0, fmt.Errorf("could not open mirror file, %s", err.Error())
+	}
+	return file, info.Size(), nil
+}
+
+// rotate renames the current file with a timestamp suffix, opens a new file, and
+// removes the oldest backups beyond max backups. The current file is kept open until
+// the new file is open, so if the rotation fails, the mirror continues writing to the
+// current file.
+func (m *fileMirror) rotate() error {
+
+	backup := fmt.Sprintf("%s.%s", m.path, time.Now().UTC().Format(mirrorBackupLayout))
+	if err := renameFile(m.path, backup); err != nil {
+		return fmt.Errorf("could not rotate mirror file, %s", err.Error())
+	}
+	file, size, err := openMirrorFile(m.path)
+	if err != nil {
+		// Move the current file back, so it's still found at the mirror path
+		_ = renameFile(backup, m.path)
+		return err
+	}
+	var closeErr error
+	if err := m.file.Close(); err != nil {
+		closeErr = fmt.Errorf("could not close mirror file, %s", err.Error())
+	}
+	m.file = file
+	m.size = size
+	m.opened = time.Now()
+
+	backups, err := m.backups()
+	if err != nil {
+		return closeErr
+	}
+	for len(backups) > m.maxBackups {
+		_ = os.Remove(backups[0])
+		backups = backups[1:]
+	}
+
+	return closeErr
+}
+
+// backups returns the paths of the rotated mirror files, oldest first. Only files named
+// with the mirror path, and a rotation timestamp suffix are included, so other files that
+// share the prefix are never removed.
+func (m *fileMirror) backups() ([]string, error) {
+
+	entries, err := ioutil.ReadDir(filepath.Dir(m.path))
+	if err != nil {
+		return nil, err
+	}
+
+	prefix := filepath.Base(m.path) + "."
+	var backups []string
+	for _, entry := range entries {
+		name := entry.Name()
+		if !entry.Mode().IsRegular() || !strings.HasPrefix(name, prefix) {
+			continue
+		}
+		if _, err := time.Parse(mirrorBackupLayout, strings.TrimPrefix(name, prefix)); err != nil {
+			continue
+		}
+		backups = append(backups, filepath.Join(filepath.Dir(m.path), name))
+	}
+	sort.Strings(backups)
+
+	return backups, nil
+}
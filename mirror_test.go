@@ -0,0 +1,187 @@
+package ddstats
+
+import (
+	"bufio"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/jmizell/ddstats/client"
+)
+
+func readMirrorRecords(t *testing.T, path string) []*mirrorRecord {
+
+	file, err := os.Open(path)
+	if err != nil {
+		t.Fatalf("could not open mirror file, %s", err.Error())
+	}
+	defer func() { _ = file.Close() }()
+
+	var records []*mirrorRecord
+	scanner := bufio.NewScanner(file)
+	for scanner.Scan() {
+		record := &mirrorRecord{}
+		if err := json.Unmarshal(scanner.Bytes(), record); err != nil {
+			t.Fatalf("could not read mirror record, %s", err.Error())
+		}
+		records = append(records, record)
+	}
+	return records
+}
+
+func TestStats_MirrorFile(t *testing.T) {
+
+	dir, err := ioutil.TempDir("", "ddstats")
+	if err != nil {
+		t.Fatalf(err.Error())
+	}
+	defer func() { _ = os.RemoveAll(dir) }()
+	path := filepath.Join(dir, "mirror.jsonl")
+
+	testApi := NewTestAPIClient()
+	testApi.sendSeriesError = fmt.Errorf("test error")
+	cfg := NewConfig().
+		WithNamespace(testNamespace).
+		WithHost(testHost).
+		WithClient(testApi).
+		WithMirrorFile(path, 0, 0)
+	stats, err := NewStats(cfg)
+	if err != nil {
+		t.Fatalf(err.Error())
+	}
+
+	stats.Gauge("test", 1, nil)
+	stats.Flush()
+	stats.Gauge("test", 2, nil)
+	stats.Close()
+
+	records := readMirrorRecords(t, path)
+	if len(records) != 2 {
+		t.Fatalf("expected %d records, have %d", 2, len(records))
+	}
+	for i, record := range records {
+		if record.Error != "test error" {
+			t.Fatalf("expected record %d error to be %s, have %s", i, "test error", record.Error)
+		}
+		if len(record.Series) != 1 || record.Series[0].Metric != "testNamespace.test" {
+			t.Fatalf("expected record %d to have metric %s", i, "testNamespace.test")
+		}
+	}
+}
+
+func TestFileMirror_Rotate(t *testing.T) {
+
+	dir, err := ioutil.TempDir("", "ddstats")
+	if err != nil {
+		t.Fatalf(err.Error())
+	}
+	defer func() { _ = os.RemoveAll(dir) }()
+	path := filepath.Join(dir, "mirror.jsonl")
+
+	t.Run("max bytes", func(tt *testing.T) {
+		m, err := newFileMirror(path, 10, 0, 2)
+		if err != nil {
+			tt.Fatalf(err.Error())
+		}
+		series := []*client.DDMetric{{Metric: "test"}}
+		for i := 0; i < 5; i++ {
+			if err := m.write(series, nil); err != nil {
+				tt.Fatalf("expected no error, have %s", err.Error())
+			}
+			time.Sleep(time.Millisecond)
+		}
+		_ = m.close()
+
+		backups, _ := filepath.Glob(path + ".*")
+		if len(backups) != 2 {
+			tt.Fatalf("expected %d backups, have %d", 2, len(backups))
+		}
+		if records := readMirrorRecords(tt, path); len(records) != 1 {
+			tt.Fatalf("expected current file to have %d record, have %d", 1, len(records))
+		}
+	})
+
+	t.Run("default max backups", func(tt *testing.T) {
+		backupPath := filepath.Join(dir, "backups.jsonl")
+		other := backupPath + ".keep"
+		if err := ioutil.WriteFile(other, []byte("{}"), 0600); err != nil {
+			tt.Fatalf(err.Error())
+		}
+		m, err := newFileMirror(backupPath, 10, 0, 0)
+		if err != nil {
+			tt.Fatalf(err.Error())
+		}
+		series := []*client.DDMetric{{Metric: "test"}}
+		for i := 0; i < DefaultMirrorMaxBackups+3; i++ {
+			if err := m.write(series, nil); err != nil {
+				tt.Fatalf("expected no error, have %s", err.Error())
+			}
+			time.Sleep(time.Millisecond)
+		}
+		_ = m.close()
+
+		// Files sharing the mirror path, without a rotation timestamp aren't backups
+		backups, err := m.backups()
+		if err != nil {
+			tt.Fatalf(err.Error())
+		}
+		if len(backups) != DefaultMirrorMaxBackups {
+			tt.Fatalf("expected %d backups, have %d", DefaultMirrorMaxBackups, len(backups))
+		}
+		if _, err := os.Stat(other); err != nil {
+			tt.Fatalf("expected %s to be kept, have %s", other, err.Error())
+		}
+	})
+
+	t.Run("failed rotation", func(tt *testing.T) {
+		rotatePath := filepath.Join(dir, "failed.jsonl")
+		m, err := newFileMirror(rotatePath, 10, 0, 0)
+		if err != nil {
+			tt.Fatalf(err.Error())
+		}
+		defer func() { _ = m.close() }()
+
+		defer func() { renameFile = os.Rename }()
+		renameFile = func(string, string) error { return errors.New("rename failed") }
+		series := []*client.DDMetric{{Metric: "test"}}
+		for i := 0; i < 2; i++ {
+			_ = m.write(series, nil)
+		}
+		if err := m.write(series, nil); err == nil {
+			tt.Fatalf("expected rotation error")
+		}
+		if records := readMirrorRecords(tt, rotatePath); len(records) != 3 {
+			tt.Fatalf("expected records to be written to the current file, have %d", len(records))
+		}
+
+		renameFile = os.Rename
+		if err := m.write(series, nil); err != nil {
+			tt.Fatalf("expected rotation to recover, have %s", err.Error())
+		}
+		if records := readMirrorRecords(tt, rotatePath); len(records) != 1 {
+			tt.Fatalf("expected current file to have %d record, have %d", 1, len(records))
+		}
+	})
+
+	t.Run("write after close", func(tt *testing.T) {
+		m, err := newFileMirror(path, 0, 0, 0)
+		if err != nil {
+			tt.Fatalf(err.Error())
+		}
+		_ = m.close()
+		if err := m.write(nil, nil); err == nil {
+			tt.Fatalf("expected error writing to closed mirror")
+		}
+	})
+
+	t.Run("invalid path", func(tt *testing.T) {
+		if _, err := newFileMirror(filepath.Join(dir, "missing", "mirror.jsonl"), 0, 0, 0); err == nil {
+			tt.Fatalf("expected error opening mirror in missing directory")
+		}
+	})
+}
@@ -0,0 +1,41 @@
+package ddstats
+
+// monotonicCount is the class of metrics recorded with MonotonicCount. They're sent as a
+// count of the increase in the absolute value.
+const monotonicCount = "monotonic_count"
+
+// MonotonicCount records the absolute value of a cumulative counter, such as a counter
+// exported by another system, and sends the increase since the previous value as a count.
+// This is a non-blocking method, if the channel buffer is full, then the value is not
+// recorded. The previous value is kept across flush intervals, until it isn't updated for
+// a number of intervals, see Config.WithKeptValueExpiry. The first value recorded for a
+// metric is the baseline, and isn't counted.
+//
+// A value lower than the previous value is treated as a counter reset, the counter is
+// assumed to have restarted from zero, and the new value is counted. Sampled out values
+// are skipped, the increase is counted with the next value recorded.
+func (c *Stats) MonotonicCount(name string, value float64, tags []string, opts ...MetricOption) {
+
+	tags, o, ok := c.prepareSubmission(name, tags, opts)
+	if !ok {
+		return
+	}
+
+	m := &metric{
+		name:  name,
+		class: monotonicCount,
+		value: value,
+		tags:  tags,
+	}
+	o.apply(m)
+	c.enqueue(m, o)
+}
+
+// monotonicDelta returns the increase from last to value, or value, if the counter was
+// reset.
+func monotonicDelta(last, value float64) float64 {
+	if value < last {
+		return value
+	}
+	return value - last
+}
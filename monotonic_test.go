@@ -0,0 +1,50 @@
+package ddstats
+
+import (
+	"testing"
+)
+
+func TestStats_MonotonicCount(t *testing.T) {
+
+	stats, testApi, err := NewTestStats()
+	if err != nil {
+		t.Fatalf(err.Error())
+	}
+
+	stats.MonotonicCount("test", 100, nil)
+	stats.MonotonicCount("test", 110, nil)
+	stats.Flush()
+	stats.MonotonicCount("test", 125, nil)
+	stats.MonotonicCount("test", 130, nil)
+	stats.Flush()
+	stats.Flush()
+	stats.MonotonicCount("test", 4, nil)
+	stats.Close()
+
+	testApi.lock.Lock()
+	defer testApi.lock.Unlock()
+	if len(testApi.series) != 3 {
+		t.Fatalf("expected %d series, have %d", 3, len(testApi.series))
+	}
+	for i, expected := range []float64{10, 20, 4} {
+		m := testApi.series[i].Series[0]
+		if m.Type != "count" {
+			t.Fatalf("expected monotonic count to be sent as a count, have %s", m.Type)
+		}
+		if value := m.Points[0][1].(float64); value != expected {
+			t.Fatalf("expected flush %d to have value %f, have %f", i, expected, value)
+		}
+	}
+}
+
+func TestMonotonicDelta(t *testing.T) {
+	for _, test := range []struct{ last, value, expected float64 }{
+		{10, 15, 5},
+		{10, 10, 0},
+		{10, 3, 3},
+	} {
+		if delta := monotonicDelta(test.last, test.value); delta != test.expected {
+			t.Fatalf("expected delta from %f to %f to be %f, have %f", test.last, test.value, test.expected, delta)
+		}
+	}
+}
@@ -0,0 +1,103 @@
+package ddstats
+
+import (
+	"fmt"
+	"sync"
+
+	"github.com/jmizell/ddstats/client"
+)
+
+// Mux merges the flushes of other stats into the flushes of a primary stats, so metrics
+// created by embedded libraries are shipped with the application's metrics, over a single
+// api connection, see NewMux.
+type Mux struct {
+	primary *Stats
+	others  []*Stats
+	lock    sync.Mutex
+}
+
+// NewMux creates a mux that merges the flushes of the stats created with Mux.NewStats
+// into the flushes of primary.
+func NewMux(primary *Stats) *Mux {
+	return &Mux{primary: primary}
+}
+
+// NewStats creates stats from cfg, with an api client that merges flushed series into the
+// next flush of the primary stats. Merged series keep their namespace, and host, and the
+// global tags of primary are added. Service checks, events, and distributions are sent
+// immediately with the api client of primary. Any api client set on cfg is ignored, cfg
+// itself isn't modified.
+//
+//	mux := ddstats.NewMux(app)
+//	lib, err := mux.NewStats(ddstats.NewConfig().WithNamespace("lib"))
+func (m *Mux) NewStats(cfg *Config) (*Stats, error) {
+
+	merged := *cfg
+	merged.client = &muxClient{primary: m.primary}
+	other, err := NewStats(&merged)
+	if err != nil {
+		return nil, err
+	}
+
+	m.lock.Lock()
+	defer m.lock.Unlock()
+	m.others = append(m.others, other)
+
+	return other, nil
+}
+
+// Flush flushes all other stats, and then the primary stats, so everything recorded
+// before the call is sent in the primary flush.
+func (m *Mux) Flush() {
+	for _, other := range m.stats() {
+		other.Flush()
+	}
+	m.primary.Flush()
+}
+
+// Close closes all other stats, and then the primary stats, so the final flushes of the
+// other stats are sent with the final primary flush.
+func (m *Mux) Close() {
+	for _, other := range m.stats() {
+		other.Close()
+	}
+	m.primary.Close()
+}
+
+// stats returns the stats merged into the primary stats.
+func (m *Mux) stats() []*Stats {
+	m.lock.Lock()
+	defer m.lock.Unlock()
+	return append([]*Stats{}, m.others...)
+}
+
+// muxClient is the api client of stats merged by a Mux.
+type muxClient struct {
+	primary *Stats
+}
+
+func (c *muxClient) SendSeries(series *client.DDMetricSeries) error {
+	c.primary.metricQueueLock.Lock()
+	defer c.primary.metricQueueLock.Unlock()
+	c.primary.mergedQueue = append(c.primary.mergedQueue, series.Series...)
+	return nil
+}
+
+func (c *muxClient) SendServiceCheck(check *client.DDServiceCheck) error {
+	return c.primary.client.SendServiceCheck(check)
+}
+
+func (c *muxClient) SendEvent(event *client.DDEvent) error {
+	return c.primary.client.SendEvent(event)
+}
+
+func (c *muxClient) SendDistributions(series *client.DDDistributionSeries) error {
+	distributionClient, ok := c.primary.client.(client.DistributionClient)
+	if !ok {
+		return fmt.Errorf("api client does not support distributions")
+	}
+	return distributionClient.SendDistributions(series)
+}
+
+// SetHTTPClient does nothing, requests are sent with the api client of the primary stats.
+func (c *muxClient) SetHTTPClient(client.HTTPClient) {}
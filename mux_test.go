@@ -0,0 +1,70 @@
+package ddstats
+
+import (
+	"testing"
+
+	"github.com/jmizell/ddstats/client"
+)
+
+func TestMux(t *testing.T) {
+
+	primary, testApi, err := NewTestStats()
+	if err != nil {
+		t.Fatalf(err.Error())
+	}
+	mux := NewMux(primary)
+	libraryApi := NewTestAPIClient()
+	cfg := NewConfig().
+		WithNamespace("lib").
+		WithHost("library-host").
+		WithTags([]string{"lib:true"}).
+		WithClient(libraryApi)
+	library, err := mux.NewStats(cfg)
+	if err != nil {
+		t.Fatalf(err.Error())
+	}
+	if cfg.client != libraryApi {
+		t.Fatalf("expected the config passed to the mux to be unchanged")
+	}
+
+	primary.Increment("app.count", nil)
+	library.Increment("count", nil)
+	if err := library.ServiceCheck("check", "", client.Okay, nil); err != nil {
+		t.Fatalf(err.Error())
+	}
+	mux.Flush()
+	mux.Close()
+
+	libraryApi.lock.Lock()
+	if len(libraryApi.series) != 0 || len(libraryApi.checks) != 0 {
+		t.Fatalf("expected nothing to be sent with the library client")
+	}
+	libraryApi.lock.Unlock()
+
+	testApi.lock.Lock()
+	defer testApi.lock.Unlock()
+	if len(testApi.checks) != 1 {
+		t.Fatalf("expected library service check to be sent with the primary client, have %d checks", len(testApi.checks))
+	}
+	if len(testApi.series) != 1 {
+		t.Fatalf("expected %d series, have %d", 1, len(testApi.series))
+	}
+
+	found := map[string]*client.DDMetric{}
+	for _, m := range testApi.series[0].Series {
+		found[m.Metric] = m
+	}
+	if _, ok := found[prependNamespace(testNamespace, "app.count")]; !ok {
+		t.Fatalf("expected primary metric to be sent, have %v", found)
+	}
+	m, ok := found["lib.count"]
+	if !ok {
+		t.Fatalf("expected library metric to be sent with its own namespace, have %v", found)
+	}
+	if m.Host != "library-host" {
+		t.Fatalf("expected library host to be %s, have %s", "library-host", m.Host)
+	}
+	if !hasTag(m.Tags, "lib:true") || !hasTag(m.Tags, testTags[0]) {
+		t.Fatalf("expected library, and primary global tags, have %v", m.Tags)
+	}
+}
@@ -0,0 +1,147 @@
+package ddstats
+
+import (
+	"fmt"
+	"strings"
+	"sync/atomic"
+
+	"github.com/jmizell/ddstats/client"
+)
+
+// MetricNamePolicy controls how metric names that would be rejected by Datadog are
+// handled. Names must be ASCII, start with a letter, contain only letters, digits,
+// underscores, and periods, and be no longer than client.MaxMetricNameLength. Names are
+// checked with the namespace prepended.
+type MetricNamePolicy string
+
+// Metric name policies
+const (
+	// MetricNameAllow sends names as is. This is the default.
+	MetricNameAllow = MetricNamePolicy("")
+
+	// MetricNameSanitize replaces invalid characters with underscores, removes leading
+	// characters that aren't a letter, and truncates long names, before the metric is
+	// aggregated.
+	MetricNameSanitize = MetricNamePolicy("sanitize")
+
+	// MetricNameReject drops metrics with an invalid name. A *MetricNameError is added
+	// to the errors list, and passed to the error callback, the first time each name is
+	// seen.
+	MetricNameReject = MetricNamePolicy("reject")
+)
+
+// maxNameChecks is the max number of names the results of name checks are kept for.
+const maxNameChecks = 10000
+
+// MetricNameError is reported when a metric is rejected for an invalid name.
+type MetricNameError struct {
+	Name    string
+	Problem string
+}
+
+func (e *MetricNameError) Error() string {
+	return fmt.Sprintf("invalid metric name %q, %s", e.Name, e.Problem)
+}
+
+// nameCheck is the result of checking a metric name.
+type nameCheck struct {
+	name   string // Name to use, empty if the metric is rejected
+	reject bool
+}
+
+// checkName applies the metric name policy to m, and returns false if m should be
+// dropped. It's only called by the main worker, so the results are cached without a
+// lock.
+func (c *Stats) checkName(m *metric) bool {
+
+	if c.metricNamePolicy == MetricNameAllow {
+		return true
+	}
+
+	check, ok := c.nameChecks[m.name]
+	if !ok {
+		check = c.newNameCheck(m.name)
+		if c.nameChecks == nil || len(c.nameChecks) >= maxNameChecks {
+			c.nameChecks = make(map[string]nameCheck)
+		}
+		c.nameChecks[m.name] = check
+	}
+
+	if check.reject {
+		atomic.AddUint64(&c.invalidNames, 1)
+		return false
+	}
+	m.name = check.name
+	return true
+}
+
+// newNameCheck checks name, and reports a rejected name.
+func (c *Stats) newNameCheck(name string) nameCheck {
+
+	problem := metricNameProblem(c.withNamespace(name))
+	if problem == "" {
+		return nameCheck{name: name}
+	}
+
+	if c.metricNamePolicy == MetricNameReject {
+		err := &MetricNameError{Name: c.withNamespace(name), Problem: problem}
+		c.addError(ErrorClassWarning, err)
+		c.queueErrorCallback(err, nil)
+		return nameCheck{reject: true}
+	}
+
+	sanitized := sanitizeMetricName(name)
+	if c.namespace == "" || c.withNamespace(sanitized) == sanitized {
+		sanitized = strings.TrimLeftFunc(sanitized, func(r rune) bool { return !isASCIILetter(r) })
+	}
+	if extra := len(c.withNamespace(sanitized)) - client.MaxMetricNameLength; extra > 0 && extra < len(sanitized) {
+		sanitized = sanitized[:len(sanitized)-extra]
+	}
+	if sanitized == "" {
+		sanitized = "invalid"
+	}
+	return nameCheck{name: sanitized}
+}
+
+// GetInvalidMetricNameCount returns the number of metrics dropped for an invalid name.
+func (c *Stats) GetInvalidMetricNameCount() uint64 {
+	return atomic.LoadUint64(&c.invalidNames)
+}
+
+// metricNameProblem returns the reason name would be rejected by Datadog, or an empty
+// string if it's valid.
+func metricNameProblem(name string) string {
+	switch {
+	case name == "":
+		return "empty name"
+	case len(name) > client.MaxMetricNameLength:
+		return fmt.Sprintf("name longer than %d characters", client.MaxMetricNameLength)
+	case !isASCIILetter(rune(name[0])):
+		return "name does not start with a letter"
+	}
+	for _, r := range name {
+		if !validMetricNameRune(r) {
+			return fmt.Sprintf("invalid character %q", r)
+		}
+	}
+	return ""
+}
+
+// sanitizeMetricName replaces the characters of name that aren't valid in a metric name
+// with underscores.
+func sanitizeMetricName(name string) string {
+	return strings.Map(func(r rune) rune {
+		if validMetricNameRune(r) {
+			return r
+		}
+		return '_'
+	}, name)
+}
+
+func validMetricNameRune(r rune) bool {
+	return isASCIILetter(r) || r >= '0' && r <= '9' || r == '_' || r == '.'
+}
+
+func isASCIILetter(r rune) bool {
+	return r >= 'a' && r <= 'z' || r >= 'A' && r <= 'Z'
+}
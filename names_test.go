@@ -0,0 +1,104 @@
+package ddstats
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/jmizell/ddstats/client"
+)
+
+func TestMetricNameProblem(t *testing.T) {
+	tests := []struct {
+		name    string
+		invalid bool
+	}{
+		{"valid.name_1", false},
+		{"", true},
+		{"1name", true},
+		{"_name", true},
+		{"name-with-dash", true},
+		{"nämé", true},
+		{"name " + "x", true},
+		{"n" + strings.Repeat("x", client.MaxMetricNameLength), true},
+	}
+	for _, test := range tests {
+		if problem := metricNameProblem(test.name); (problem != "") != test.invalid {
+			t.Fatalf("expected %q invalid to be %t, have problem %q", test.name, test.invalid, problem)
+		}
+	}
+}
+
+func TestStats_WithMetricNamePolicy(t *testing.T) {
+
+	newStats := func(tt *testing.T, namespace string, policy MetricNamePolicy) (*Stats, *TestAPIClient) {
+		testApi := NewTestAPIClient()
+		cfg := NewConfig().
+			WithNamespace(namespace).
+			WithHost(testHost).
+			WithClient(testApi).
+			WithMetricNamePolicy(policy)
+		stats, err := NewStats(cfg)
+		if err != nil {
+			tt.Fatalf(err.Error())
+		}
+		return stats, testApi
+	}
+	names := func(testApi *TestAPIClient) map[string]bool {
+		testApi.lock.Lock()
+		defer testApi.lock.Unlock()
+		found := map[string]bool{}
+		for _, series := range testApi.series {
+			for _, m := range series.Series {
+				found[m.Metric] = true
+			}
+		}
+		return found
+	}
+
+	t.Run("sanitize", func(tt *testing.T) {
+		stats, testApi := newStats(tt, "", MetricNameSanitize)
+		stats.Increment("1-request count", nil)
+		stats.Increment("valid.name", nil)
+		stats.Close()
+
+		found := names(testApi)
+		if !found["request_count"] || !found["valid.name"] || len(found) != 2 {
+			tt.Fatalf("expected metrics request_count, and valid.name, have %v", found)
+		}
+	})
+
+	t.Run("sanitize with namespace", func(tt *testing.T) {
+		stats, testApi := newStats(tt, "app", MetricNameSanitize)
+		stats.Increment("1-requests", nil)
+		stats.Close()
+
+		if found := names(testApi); !found["app.1_requests"] {
+			tt.Fatalf("expected metric app.1_requests, have %v", found)
+		}
+	})
+
+	t.Run("reject", func(tt *testing.T) {
+		stats, testApi := newStats(tt, "", MetricNameReject)
+		var callbackErrs []error
+		stats.ErrorCallback(func(err error, _ []*client.DDMetric) {
+			callbackErrs = append(callbackErrs, err)
+		})
+		stats.Increment("bad-name", nil)
+		stats.Increment("bad-name", nil)
+		stats.Increment("good.name", nil)
+		stats.Close()
+
+		if found := names(testApi); !found["good.name"] || len(found) != 1 {
+			tt.Fatalf("expected only good.name to be sent, have %v", found)
+		}
+		if stats.GetInvalidMetricNameCount() != 2 {
+			tt.Fatalf("expected %d invalid names, have %d", 2, stats.GetInvalidMetricNameCount())
+		}
+		if len(callbackErrs) != 1 {
+			tt.Fatalf("expected the rejected name to be reported once, have %v", callbackErrs)
+		}
+		if _, ok := callbackErrs[0].(*MetricNameError); !ok {
+			tt.Fatalf("expected a *MetricNameError, have %T", callbackErrs[0])
+		}
+	})
+}
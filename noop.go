@@ -0,0 +1,42 @@
+package ddstats
+
+import (
+	"github.com/jmizell/ddstats/client"
+)
+
+// NewNoop returns stats that discard everything, so libraries can accept stats
+// unconditionally, and applications can disable metrics without nil checks. Submissions
+// return immediately, series, service checks, and events aren't sent, Flush does nothing,
+// and collectors aren't started. It's the same as NewStats with a disabled config, see
+// Config.WithDisabled.
+func NewNoop() *Stats {
+	s, _ := NewStats(&Config{Disabled: true})
+	return s
+}
+
+// disabledConfig returns the config of noop stats. Only the resources needed for the
+// methods of stats to be safe to call are kept, a single worker, and no api client.
+func disabledConfig(cfg *Config) *Config {
+	return &Config{
+		Disabled:             true,
+		Namespace:            cfg.Namespace,
+		NamespaceMode:        cfg.NamespaceMode,
+		Host:                 cfg.Host,
+		FlushIntervalSeconds: DefaultFlushInterval.Seconds(),
+		WorkerCount:          1,
+		WorkerBuffer:         1,
+		MetricBuffer:         1,
+		MaxErrors:            DefaultMaxErrorCount,
+		client:               noopClient{},
+		logger:               cfg.logger,
+	}
+}
+
+// noopClient discards everything sent to it.
+type noopClient struct{}
+
+func (noopClient) SendSeries(*client.DDMetricSeries) error              { return nil }
+func (noopClient) SendDistributions(*client.DDDistributionSeries) error { return nil }
+func (noopClient) SendServiceCheck(*client.DDServiceCheck) error        { return nil }
+func (noopClient) SendEvent(*client.DDEvent) error                      { return nil }
+func (noopClient) SetHTTPClient(client.HTTPClient)                      {}
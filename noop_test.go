@@ -0,0 +1,65 @@
+package ddstats
+
+import (
+	"os"
+	"testing"
+	"time"
+
+	"github.com/jmizell/ddstats/client"
+)
+
+func TestNewNoop(t *testing.T) {
+
+	stats := NewNoop()
+	stats.Increment("requests", []string{"path:/"})
+	stats.Gauge("queue.depth", 1, nil)
+	stats.Histogram("latency", 1, nil)
+	stats.Distribution("latency.dist", 1, nil)
+	stats.Set("users", "a", nil)
+	stats.GaugeAdd("in_flight", 1, nil)
+	stats.MonotonicCount("bytes", 10, nil)
+	stats.NewTimer("work", nil).Stop()
+	stats.EnableRuntimeMetrics(time.Millisecond)
+	if err := stats.QueueSeries([]*client.DDMetric{{Metric: "queued"}}); err != nil {
+		t.Fatalf(err.Error())
+	}
+	if err := stats.ServiceCheck("check", "", client.Okay, nil); err != nil {
+		t.Fatalf(err.Error())
+	}
+	if err := stats.Event(&client.DDEvent{Title: "deploy"}); err != nil {
+		t.Fatalf(err.Error())
+	}
+	stats.Flush()
+	stats.Close()
+
+	for class, a := range stats.Accounting() {
+		if a.Submitted != 0 {
+			t.Fatalf("expected no %s submissions, have %d", class, a.Submitted)
+		}
+	}
+	if n := len(stats.metricsQueue); n != 0 {
+		t.Fatalf("expected no queued series, have %d", n)
+	}
+}
+
+func TestConfig_WithDisabled(t *testing.T) {
+
+	t.Run("no client", func(tt *testing.T) {
+		stats, err := NewStats(NewConfig().WithNamespace("app").WithDisabled(true))
+		if err != nil {
+			tt.Fatalf(err.Error())
+		}
+		defer stats.Close()
+		if !stats.noop || stats.namespace != "app" {
+			tt.Fatalf("expected noop stats with namespace app, have %v, %s", stats.noop, stats.namespace)
+		}
+	})
+
+	t.Run("env", func(tt *testing.T) {
+		defer os.Unsetenv(EnvDisabled)
+		os.Setenv(EnvDisabled, "true")
+		if cfg := NewConfig().FromEnv(); !cfg.Disabled {
+			tt.Fatalf("expected config to be disabled")
+		}
+	})
+}
@@ -0,0 +1,81 @@
+package ddstats
+
+import (
+	"time"
+)
+
+// MetricOption sets an optional property of a single metric submission.
+type MetricOption func(*metricOptions)
+
+type metricOptions struct {
+	host         string
+	tags         []string
+	timestamp    time.Time
+	sampleRate   float64
+	block        bool
+	blockTimeout time.Duration
+}
+
+// WithHost attributes the metric to host, in place of the global host. Metrics with a
+// different host are aggregated separately.
+func WithHost(host string) MetricOption {
+	return func(o *metricOptions) {
+		o.host = host
+	}
+}
+
+// WithTags adds tags to the metric, in addition to the tags argument.
+func WithTags(tags ...string) MetricOption {
+	return func(o *metricOptions) {
+		o.tags = append(o.tags, tags...)
+	}
+}
+
+// WithTimestamp sets the time the value was observed, in place of the time it was
+// submitted. The flushed point of an aggregated metric has the latest timestamp of its
+// values. When downsampling is enabled, the timestamp selects the window the value is
+// aggregated in.
+func WithTimestamp(t time.Time) MetricOption {
+	return func(o *metricOptions) {
+		o.timestamp = t
+	}
+}
+
+// WithSampleRate records only the given fraction of submissions, the rest are dropped
+// before they are queued, the same as statsd sample rates. Count, and rate values are
+// scaled by 1/rate, so the reported value is an estimate of the total. Rates outside of
+// (0, 1) record all submissions.
+func WithSampleRate(rate float64) MetricOption {
+	return func(o *metricOptions) {
+		o.sampleRate = rate
+	}
+}
+
+// WithBlocking blocks the caller while the metric queue is full, instead of dropping the
+// metric, for up to timeout, or indefinitely if timeout is zero. This overrides the
+// blocking config, see Config.WithBlockOnFull.
+func WithBlocking(timeout time.Duration) MetricOption {
+	return func(o *metricOptions) {
+		o.block = true
+		o.blockTimeout = timeout
+	}
+}
+
+// applyMetricOptions returns the result of applying opts.
+func applyMetricOptions(opts []MetricOption) metricOptions {
+	o := metricOptions{}
+	for _, opt := range opts {
+		if opt != nil {
+			opt(&o)
+		}
+	}
+	return o
+}
+
+// apply sets the host, and timestamp options of m.
+func (o metricOptions) apply(m *metric) {
+	m.host = o.host
+	if !o.timestamp.IsZero() {
+		m.observed = o.timestamp.Unix()
+	}
+}
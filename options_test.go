@@ -0,0 +1,131 @@
+package ddstats
+
+import (
+	"testing"
+	"time"
+
+	"github.com/jmizell/ddstats/client"
+)
+
+func TestWithHost(t *testing.T) {
+
+	stats, testApi, err := NewTestStats()
+	if err != nil {
+		t.Fatalf(err.Error())
+	}
+	stats.Increment("requests", nil)
+	stats.Count("requests", 2, nil, WithHost("tenant-a"))
+	stats.Count("requests", 3, nil, WithHost("tenant-a"))
+	stats.Gauge("queue", 5, nil, WithHost("tenant-b"))
+	stats.Close()
+
+	testApi.lock.Lock()
+	defer testApi.lock.Unlock()
+	values := map[string]interface{}{}
+	for _, m := range testApi.series[0].Series {
+		values[m.Host+"/"+m.Metric] = m.Points[0][1]
+	}
+	expected := map[string]float64{
+		testHost + "/" + prependNamespace(testNamespace, "requests"): 1,
+		"tenant-a/" + prependNamespace(testNamespace, "requests"):    5,
+		"tenant-b/" + prependNamespace(testNamespace, "queue"):       5,
+	}
+	if len(values) != len(expected) {
+		t.Fatalf("expected %d metrics, have %v", len(expected), values)
+	}
+	for key, value := range expected {
+		if values[key] != value {
+			t.Fatalf("expected %s to be %f, have %v", key, value, values[key])
+		}
+	}
+}
+
+func TestMetricOptions(t *testing.T) {
+
+	defaultSampleFloat64 := sampleFloat64
+	sampleFloat64 = func() float64 { return 0.5 }
+	defer func() {
+		sampleFloat64 = defaultSampleFloat64
+	}()
+
+	stats, testApi, err := NewTestStats()
+	if err != nil {
+		t.Fatalf(err.Error())
+	}
+	observed := time.Unix(1600000000, 0)
+	stats.Gauge("tagged", 1, []string{"a:1"}, WithTags("b:2"))
+	stats.Gauge("timestamped", 1, nil, WithTimestamp(observed))
+	stats.Gauge("dropped", 1, nil, WithSampleRate(0.25))
+	stats.Count("scaled", 1, nil, WithSampleRate(0.75))
+	stats.Histogram("histogram", 3, nil, WithSampleRate(0.75))
+	stats.Close()
+
+	testApi.lock.Lock()
+	defer testApi.lock.Unlock()
+	found := map[string]*client.DDMetric{}
+	for _, m := range testApi.series[0].Series {
+		found[m.Metric] = m
+	}
+
+	tagged := found[prependNamespace(testNamespace, "tagged")]
+	if tagged == nil || !hasTag(tagged.Tags, "a:1") || !hasTag(tagged.Tags, "b:2") {
+		t.Fatalf("expected gauge with tags a:1, and b:2, have %v", tagged)
+	}
+	timestamped := found[prependNamespace(testNamespace, "timestamped")]
+	if timestamped == nil || timestamped.Points[0][0] != observed.Unix() {
+		t.Fatalf("expected gauge with timestamp %d, have %v", observed.Unix(), timestamped)
+	}
+	if _, ok := found[prependNamespace(testNamespace, "dropped")]; ok {
+		t.Fatalf("expected sampled out gauge to be dropped")
+	}
+	scaled := found[prependNamespace(testNamespace, "scaled")]
+	if scaled == nil || scaled.Points[0][1] != 1/0.75 {
+		t.Fatalf("expected count to be scaled to %f, have %v", 1/0.75, scaled)
+	}
+	histogram := found[prependNamespace(testNamespace, "histogram.max")]
+	if histogram == nil || histogram.Points[0][1] != 3.0 {
+		t.Fatalf("expected histogram value to not be scaled, have %v", histogram)
+	}
+}
+
+func TestMetricOptions_AllMethods(t *testing.T) {
+
+	testClient := &distributionAPIClient{TestAPIClient: NewTestAPIClient()}
+	stats, err := NewStats(NewConfig().
+		WithNamespace(testNamespace).
+		WithHost(testHost).
+		WithClient(testClient))
+	if err != nil {
+		t.Fatalf(err.Error())
+	}
+
+	opts := []MetricOption{WithHost("other"), WithTags("opt:true")}
+	stats.Increment("increment", nil, opts...)
+	stats.DecrementRate("decrement_rate", nil, opts...)
+	stats.Set("set", "a", nil, opts...)
+	stats.GaugeBytes("bytes", 1, nil, opts...)
+	stats.NewTimer("timer", nil, opts...).Stop()
+	CountMetric{Name: "handle"}.Inc(stats, nil, opts...)
+	stats.Distribution("distribution", 1, nil, opts...)
+	stats.Distribution("distribution", 2, nil)
+	stats.Close()
+
+	testClient.lock.Lock()
+	defer testClient.lock.Unlock()
+	found := map[string]bool{}
+	for _, m := range testClient.series[0].Series {
+		if m.Host != "other" || !hasTag(m.Tags, "opt:true") {
+			t.Fatalf("expected %s to have host, and tag options applied, have %s %v", m.Metric, m.Host, m.Tags)
+		}
+		found[m.Metric] = true
+	}
+	for _, name := range []string{"increment", "decrement_rate", "set", "bytes", "timer.max", "handle"} {
+		if !found[prependNamespace(testNamespace, name)] {
+			t.Fatalf("expected %s metric to be sent, have %v", name, found)
+		}
+	}
+
+	if len(testClient.distributions) != 1 || len(testClient.distributions[0].Series) != 2 {
+		t.Fatalf("expected distributions to be aggregated by host, have %v", testClient.distributions)
+	}
+}
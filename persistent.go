@@ -0,0 +1,75 @@
+package ddstats
+
+import (
+	"sync"
+
+	"github.com/jmizell/ddstats/client"
+)
+
+// PersistentGauge is a gauge whose latest value is sent on every flush, until the gauge
+// is deleted, so the gauge doesn't disappear in intervals where it isn't set. Create
+// one with Stats.PersistentGauge. PersistentGauge is safe for concurrent use.
+type PersistentGauge struct {
+	stats *Stats
+	name  string
+	tags  TagSet
+	value float64
+	set   bool
+	lock  sync.Mutex
+}
+
+// PersistentGauge returns a persistent gauge named name, with tags. Nothing is sent until
+// the first call to Set. The gauge is sent with Gauge, so the namespace, global tags, and
+// gauge aggregation apply. The tags are prepared once as a TagSet, so the caller may reuse
+// its slice, and the gauge is sent without sorting the tags again.
+func (c *Stats) PersistentGauge(name string, tags []string) *PersistentGauge {
+	g := &PersistentGauge{stats: c, name: name, tags: NewTagSet(tags...)}
+	c.persistentLock.Lock()
+	defer c.persistentLock.Unlock()
+	if c.persistentGauges == nil {
+		c.persistentGauges = map[*PersistentGauge]bool{}
+	}
+	c.persistentGauges[g] = true
+	return g
+}
+
+// Set sets the gauge to value, and records it for the current flush interval. The value is
+// sent again on every following flush, until Set is called with a new value, or the gauge
+// is deleted.
+func (g *PersistentGauge) Set(value float64) {
+	g.lock.Lock()
+	g.value, g.set = value, true
+	g.lock.Unlock()
+	g.stats.submitTagSet(g.name, client.Gauge, value, g.tags, nil)
+}
+
+// Value returns the latest value of the gauge, and false if Set hasn't been called.
+func (g *PersistentGauge) Value() (float64, bool) {
+	g.lock.Lock()
+	defer g.lock.Unlock()
+	return g.value, g.set
+}
+
+// Delete stops sending the gauge. A value set in the current flush interval is still sent.
+func (g *PersistentGauge) Delete() {
+	g.stats.persistentLock.Lock()
+	defer g.stats.persistentLock.Unlock()
+	delete(g.stats.persistentGauges, g)
+}
+
+// reportPersistentGauges records the latest value of each persistent gauge.
+func (c *Stats) reportPersistentGauges() {
+
+	c.persistentLock.Lock()
+	gauges := make([]*PersistentGauge, 0, len(c.persistentGauges))
+	for g := range c.persistentGauges {
+		gauges = append(gauges, g)
+	}
+	c.persistentLock.Unlock()
+
+	for _, g := range gauges {
+		if value, ok := g.Value(); ok {
+			c.submitTagSet(g.name, client.Gauge, value, g.tags, nil)
+		}
+	}
+}
@@ -0,0 +1,74 @@
+package ddstats
+
+import (
+	"sync"
+	"testing"
+)
+
+func TestStats_PersistentGauge(t *testing.T) {
+
+	stats, testApi, err := NewTestStats()
+	if err != nil {
+		t.Fatalf(err.Error())
+	}
+
+	gauge := stats.PersistentGauge("test", []string{"pool:db"})
+	stats.Flush()
+	gauge.Set(5)
+	stats.Flush()
+	stats.Flush()
+	gauge.Set(7)
+	stats.Flush()
+	gauge.Delete()
+	stats.Flush()
+	stats.Close()
+
+	if value, ok := gauge.Value(); !ok || value != 7 {
+		t.Fatalf("expected value %f, have %f", 7.0, value)
+	}
+
+	testApi.lock.Lock()
+	defer testApi.lock.Unlock()
+	if len(testApi.series) != 3 {
+		t.Fatalf("expected %d series, have %d", 3, len(testApi.series))
+	}
+	for i, expected := range []float64{5, 5, 7} {
+		m := testApi.series[i].Series[0]
+		if value := m.Points[0][1].(float64); value != expected {
+			t.Fatalf("expected flush %d to have value %f, have %f", i, expected, value)
+		}
+	}
+}
+
+func TestStats_PersistentGaugeConcurrentSet(t *testing.T) {
+
+	stats, _, err := NewTestStats()
+	if err != nil {
+		t.Fatalf(err.Error())
+	}
+
+	tags := []string{"pool:db", "host:b", "az:a"}
+	gauge := stats.PersistentGauge("test", tags)
+	tags[0] = "pool:changed"
+
+	// Set is called while flushes send the stored tags again, run with -race
+	wg := &sync.WaitGroup{}
+	for i := 0; i < 4; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for j := 0; j < 100; j++ {
+				gauge.Set(float64(j))
+			}
+		}()
+	}
+	for i := 0; i < 10; i++ {
+		stats.Flush()
+	}
+	wg.Wait()
+	stats.Close()
+
+	if tags := gauge.tags.Tags(); len(tags) != 3 || tags[0] != "az:a" || tags[2] != "pool:db" {
+		t.Fatalf("expected the gauge tags to be a sorted copy, have %v", tags)
+	}
+}
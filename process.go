@@ -0,0 +1,130 @@
+package ddstats
+
+import (
+	"fmt"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+// Process metric names, these are prepended with the namespace. CPU time is reported in
+// seconds, and memory in bytes.
+const (
+	MetricProcessCPUUser    = "process.cpu.user"
+	MetricProcessCPUSystem  = "process.cpu.system"
+	MetricProcessCPUPercent = "process.cpu.percent"
+	MetricProcessRSS        = "process.mem.rss"
+	MetricProcessFDs        = "process.fds"
+	MetricProcessThreads    = "process.threads"
+)
+
+// clockTicks is the number of clock ticks per second used by /proc/[pid]/stat. This is
+// USER_HZ, which is 100 on all supported Linux platforms.
+const clockTicks = 100
+
+// processCollector reports process metrics read from procfs. Process metrics are only
+// available on Linux, on other platforms nothing is reported.
+type processCollector struct {
+	dir      string // procfs directory of the process, /proc/self
+	lock     *sync.Mutex
+	lastCPU  processCPU
+	lastTime time.Time
+}
+
+// processCPU is the cpu time used by a process, in clock ticks.
+type processCPU struct {
+	user   uint64
+	system uint64
+}
+
+// processStat is the subset of /proc/[pid]/stat reported by the collector.
+type processStat struct {
+	cpu     processCPU
+	threads int64
+	rss     int64 // Resident set size in pages
+}
+
+func newProcessCollector(dir string) *processCollector {
+	p := &processCollector{dir: dir, lock: &sync.Mutex{}, lastTime: time.Now()}
+	if stat, err := p.stat(); err == nil {
+		p.lastCPU = stat.cpu
+	}
+	return p
+}
+
+// collectProcessMetrics reports process cpu usage since the last collection, resident
+// memory, open file descriptors, and thread count.
+func (c *Stats) collectProcessMetrics() {
+
+	p := c.processCollector
+	if p == nil {
+		return
+	}
+	p.lock.Lock()
+	defer p.lock.Unlock()
+
+	stat, err := p.stat()
+	if err != nil {
+		return
+	}
+
+	now := time.Now()
+	user := float64(stat.cpu.user-p.lastCPU.user) / clockTicks
+	system := float64(stat.cpu.system-p.lastCPU.system) / clockTicks
+	if elapsed := now.Sub(p.lastTime).Seconds(); elapsed > 0 {
+		c.Gauge(MetricProcessCPUPercent, (user+system)/elapsed*100, c.runtimeTags)
+	}
+	c.Count(MetricProcessCPUUser, user, c.runtimeTags)
+	c.Count(MetricProcessCPUSystem, system, c.runtimeTags)
+	p.lastCPU, p.lastTime = stat.cpu, now
+
+	c.Gauge(MetricProcessRSS, float64(stat.rss*int64(os.Getpagesize())), c.runtimeTags)
+	c.Gauge(MetricProcessThreads, float64(stat.threads), c.runtimeTags)
+	if fds, err := ioutil.ReadDir(filepath.Join(p.dir, "fd")); err == nil {
+		c.Gauge(MetricProcessFDs, float64(len(fds)), c.runtimeTags)
+	}
+}
+
+func (p *processCollector) stat() (*processStat, error) {
+	data, err := ioutil.ReadFile(filepath.Join(p.dir, "stat"))
+	if err != nil {
+		return nil, fmt.Errorf("could not read process stat, %s", err.Error())
+	}
+	return parseProcessStat(string(data))
+}
+
+// parseProcessStat parses the contents of /proc/[pid]/stat. The second field is the
+// command name in parentheses, which may contain spaces, so fields are counted from the
+// last closing parenthesis.
+func parseProcessStat(data string) (*processStat, error) {
+
+	i := strings.LastIndexByte(data, ')')
+	if i < 0 {
+		return nil, fmt.Errorf("invalid process stat, missing command")
+	}
+
+	// Fields after the command start at field 3, state
+	fields := strings.Fields(data[i+1:])
+	if len(fields) < 22 {
+		return nil, fmt.Errorf("invalid process stat, expected at least 24 fields, have %d", len(fields)+2)
+	}
+
+	var values [4]int64
+	for n, field := range []int{14, 15, 20, 24} {
+		v, err := strconv.ParseInt(fields[field-3], 10, 64)
+		if err != nil {
+			return nil, fmt.Errorf("invalid process stat field %d, %s", field, err.Error())
+		}
+		values[n] = v
+	}
+
+	return &processStat{
+		cpu:     processCPU{user: uint64(values[0]), system: uint64(values[1])},
+		threads: values[2],
+		rss:     values[3],
+	}, nil
+}
@@ -0,0 +1,92 @@
+package ddstats
+
+import (
+	"fmt"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+func TestParseProcessStat(t *testing.T) {
+
+	t.Run("valid", func(tt *testing.T) {
+		data := "1234 (my (odd) cmd) S 1 1234 1234 0 -1 4194560 2000 0 0 0 250 75 0 0 20 0 12 0 100 1000000 512 18446744073709551615 1 1 0 0 0 0 0 0 0 0 0 0 17 0 0 0 0 0 0\n"
+		stat, err := parseProcessStat(data)
+		if err != nil {
+			tt.Fatalf(err.Error())
+		}
+		if stat.cpu.user != 250 || stat.cpu.system != 75 {
+			tt.Fatalf("expected cpu to be 250 user, 75 system, have %d user, %d system", stat.cpu.user, stat.cpu.system)
+		}
+		if stat.threads != 12 {
+			tt.Fatalf("expected %d threads, have %d", 12, stat.threads)
+		}
+		if stat.rss != 512 {
+			tt.Fatalf("expected rss to be %d pages, have %d", 512, stat.rss)
+		}
+	})
+
+	t.Run("truncated", func(tt *testing.T) {
+		if _, err := parseProcessStat("1234 (cmd) S 1 1234"); err == nil {
+			tt.Fatalf("expected error for truncated stat")
+		}
+	})
+}
+
+func TestStats_ProcessMetrics(t *testing.T) {
+
+	dir, err := ioutil.TempDir("", "ddstats-process")
+	if err != nil {
+		t.Fatalf(err.Error())
+	}
+	defer os.RemoveAll(dir)
+	stat := "1 (test) S 1 1 1 0 -1 0 0 0 0 0 %d 0 0 0 20 0 4 0 100 1000 10 0\n"
+	writeStat := func(user int) {
+		if err := ioutil.WriteFile(filepath.Join(dir, "stat"), []byte(fmt.Sprintf(stat, user)), 0644); err != nil {
+			t.Fatalf(err.Error())
+		}
+	}
+	writeStat(100)
+	if err := os.MkdirAll(filepath.Join(dir, "fd", "0"), 0755); err != nil {
+		t.Fatalf(err.Error())
+	}
+
+	stats, testApi, err := NewTestStats()
+	if err != nil {
+		t.Fatalf(err.Error())
+	}
+	stats.processCollector = newProcessCollector(dir)
+	writeStat(150)
+	stats.Flush()
+	stats.Close()
+
+	expected := map[string]float64{
+		MetricProcessCPUUser:    0.5,
+		MetricProcessThreads:    4,
+		MetricProcessRSS:        float64(10 * os.Getpagesize()),
+		MetricProcessFDs:        1,
+		MetricProcessCPUPercent: -1,
+	}
+	testApi.lock.Lock()
+	defer testApi.lock.Unlock()
+	found := map[string]bool{}
+	for _, series := range testApi.series {
+		for _, m := range series.Series {
+			for name, value := range expected {
+				if m.Metric != prependNamespace(testNamespace, name) {
+					continue
+				}
+				found[name] = true
+				if value >= 0 && m.Points[0][1] != value {
+					t.Fatalf("expected %s to be %f, have %v", name, value, m.Points[0][1])
+				}
+			}
+		}
+	}
+	for name := range expected {
+		if !found[name] {
+			t.Fatalf("expected %s metric to be sent", name)
+		}
+	}
+}
@@ -0,0 +1,323 @@
+package ddstats
+
+import (
+	"bytes"
+	"compress/gzip"
+	"fmt"
+	"io/ioutil"
+	"runtime/pprof"
+	"sort"
+	"time"
+)
+
+// Default values for the CPU profile collector
+const (
+	DefaultProfileInterval = time.Minute
+	DefaultProfileDuration = time.Second * 10
+	DefaultProfileTopN     = 10
+)
+
+// CPU profile metric names, these are prepended with the namespace.
+const (
+	MetricProfileSelfTime    = "profile.cpu.self_time"
+	MetricProfileSelfPercent = "profile.cpu.self_percent"
+)
+
+// EnableCPUProfileMetrics starts a collector that, every interval, records a CPU profile
+// for duration using runtime/pprof. The top n functions by self time are reported as
+// gauges tagged with function:<name>. Self time is reported in seconds, and as a percent
+// of all samples in the profile.
+//
+// CPU profiling is process wide, and only one profile can run at a time. If another CPU
+// profile is running when the collector starts a profile, that collection is skipped.
+// The collector stops when stats is closed. An interval, duration, or n that isn't
+// positive is replaced with the default.
+func (c *Stats) EnableCPUProfileMetrics(interval, duration time.Duration, n int) {
+	if interval <= 0 {
+		interval = DefaultProfileInterval
+	}
+	if duration <= 0 {
+		duration = DefaultProfileDuration
+	}
+	if n <= 0 {
+		n = DefaultProfileTopN
+	}
+	if duration > interval {
+		duration = interval
+	}
+	c.startCollector(interval, func() {
+		c.collectCPUProfile(duration, n)
+	})
+}
+
+func (c *Stats) collectCPUProfile(duration time.Duration, n int) {
+
+	buf := &bytes.Buffer{}
+	if err := pprof.StartCPUProfile(buf); err != nil {
+		return
+	}
+	select {
+	case <-time.After(duration):
+	case <-c.stopCollectors:
+	}
+	pprof.StopCPUProfile()
+
+	profile, err := parseProfile(buf.Bytes())
+	if err != nil {
+		return
+	}
+
+	functions, total := profile.selfTime("cpu")
+	if total == 0 {
+		return
+	}
+	for _, f := range topFunctions(functions, n) {
+		tags := append([]string{fmt.Sprintf("function:%s", f.name)}, c.runtimeTags...)
+		c.Gauge(MetricProfileSelfTime, time.Duration(f.value).Seconds(), tags)
+		c.Gauge(MetricProfileSelfPercent, float64(f.value)/float64(total)*100, tags)
+	}
+}
+
+type functionValue struct {
+	name  string
+	value int64
+}
+
+// topFunctions returns the n functions with the highest value.
+func topFunctions(functions map[string]int64, n int) []functionValue {
+
+	top := make([]functionValue, 0, len(functions))
+	for name, value := range functions {
+		top = append(top, functionValue{name: name, value: value})
+	}
+	sort.Slice(top, func(i, j int) bool {
+		if top[i].value == top[j].value {
+			return top[i].name < top[j].name
+		}
+		return top[i].value > top[j].value
+	})
+
+	if n > 0 && len(top) > n {
+		top = top[:n]
+	}
+	return top
+}
+
+// profile is the subset of the pprof profile.proto message required to calculate
+// function self time.
+type profile struct {
+	sampleTypes []int64 // string table index of each sample value type
+	samples     []profileSample
+	locations   map[uint64][]uint64 // location id, to function ids, leaf first
+	functions   map[uint64]int64    // function id, to string table index of the name
+	strings     []string
+}
+
+type profileSample struct {
+	locationIDs []uint64
+	values      []int64
+}
+
+// selfTime returns the sum of the sample values of sampleType for each leaf function,
+// and the total of all samples.
+func (p *profile) selfTime(sampleType string) (map[string]int64, int64) {
+
+	index := -1
+	for i, t := range p.sampleTypes {
+		if p.str(t) == sampleType {
+			index = i
+		}
+	}
+	if index < 0 {
+		return nil, 0
+	}
+
+	var total int64
+	functions := make(map[string]int64)
+	for _, s := range p.samples {
+		if index >= len(s.values) || len(s.locationIDs) == 0 {
+			continue
+		}
+		total += s.values[index]
+
+		// The first location is the leaf of the stack, and the first line of a location
+		// is the innermost inlined function.
+		lines := p.locations[s.locationIDs[0]]
+		if len(lines) == 0 {
+			continue
+		}
+		functions[p.str(p.functions[lines[0]])] += s.values[index]
+	}
+
+	return functions, total
+}
+
+func (p *profile) str(i int64) string {
+	if i < 0 || int(i) >= len(p.strings) {
+		return ""
+	}
+	return p.strings[i]
+}
+
+// parseProfile decodes a gzip compressed pprof profile.
+func parseProfile(data []byte) (*profile, error) {
+
+	gz, err := gzip.NewReader(bytes.NewReader(data))
+	if err != nil {
+		return nil, fmt.Errorf("could not read profile, %s", err.Error())
+	}
+	data, err = ioutil.ReadAll(gz)
+	if err != nil {
+		return nil, fmt.Errorf("could not read profile, %s", err.Error())
+	}
+
+	p := &profile{
+		locations: map[uint64][]uint64{},
+		functions: map[uint64]int64{},
+	}
+	err = decodeMessage(data, func(field int, wire int, v uint64, b []byte) error {
+		switch field {
+		case 1: // sample_type
+			return decodeMessage(b, func(field int, _ int, v uint64, _ []byte) error {
+				if field == 1 {
+					p.sampleTypes = append(p.sampleTypes, int64(v))
+				}
+				return nil
+			})
+		case 2: // sample
+			s := profileSample{}
+			err := decodeMessage(b, func(field int, wire int, v uint64, b []byte) error {
+				switch field {
+				case 1:
+					return decodeRepeated(wire, v, b, func(v uint64) { s.locationIDs = append(s.locationIDs, v) })
+				case 2:
+					return decodeRepeated(wire, v, b, func(v uint64) { s.values = append(s.values, int64(v)) })
+				}
+				return nil
+			})
+			p.samples = append(p.samples, s)
+			return err
+		case 4: // location
+			var id uint64
+			var lines []uint64
+			err := decodeMessage(b, func(field int, _ int, v uint64, b []byte) error {
+				switch field {
+				case 1:
+					id = v
+				case 4:
+					return decodeMessage(b, func(field int, _ int, v uint64, _ []byte) error {
+						if field == 1 {
+							lines = append(lines, v)
+						}
+						return nil
+					})
+				}
+				return nil
+			})
+			p.locations[id] = lines
+			return err
+		case 5: // function
+			var id uint64
+			var name int64
+			err := decodeMessage(b, func(field int, _ int, v uint64, _ []byte) error {
+				switch field {
+				case 1:
+					id = v
+				case 2:
+					name = int64(v)
+				}
+				return nil
+			})
+			p.functions[id] = name
+			return err
+		case 6: // string_table
+			p.strings = append(p.strings, string(b))
+		}
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	return p, nil
+}
+
+// decodeMessage walks the fields of a protobuf message. For varint fields v is the
+// value, for length delimited fields b is the field data.
+func decodeMessage(data []byte, fn func(field int, wire int, v uint64, b []byte) error) error {
+
+	for len(data) > 0 {
+		key, n := decodeVarint(data)
+		if n == 0 {
+			return fmt.Errorf("invalid profile, bad field key")
+		}
+		data = data[n:]
+
+		field, wire := int(key>>3), int(key&7)
+		var v uint64
+		var b []byte
+		switch wire {
+		case 0:
+			v, n = decodeVarint(data)
+			if n == 0 {
+				return fmt.Errorf("invalid profile, bad varint")
+			}
+			data = data[n:]
+		case 1:
+			if len(data) < 8 {
+				return fmt.Errorf("invalid profile, truncated fixed64")
+			}
+			data = data[8:]
+		case 2:
+			l, n := decodeVarint(data)
+			if n == 0 || uint64(len(data)-n) < l {
+				return fmt.Errorf("invalid profile, truncated field")
+			}
+			b = data[n : n+int(l)]
+			data = data[n+int(l):]
+		case 5:
+			if len(data) < 4 {
+				return fmt.Errorf("invalid profile, truncated fixed32")
+			}
+			data = data[4:]
+		default:
+			return fmt.Errorf("invalid profile, unknown wire type %d", wire)
+		}
+
+		if err := fn(field, wire, v, b); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+// decodeRepeated decodes a repeated varint field, which may be packed.
+func decodeRepeated(wire int, v uint64, b []byte, fn func(v uint64)) error {
+
+	if wire == 0 {
+		fn(v)
+		return nil
+	}
+
+	for len(b) > 0 {
+		v, n := decodeVarint(b)
+		if n == 0 {
+			return fmt.Errorf("invalid profile, bad packed varint")
+		}
+		fn(v)
+		b = b[n:]
+	}
+	return nil
+}
+
+func decodeVarint(data []byte) (uint64, int) {
+	var v uint64
+	for i := 0; i < len(data) && i < 10; i++ {
+		v |= uint64(data[i]&0x7f) << (7 * uint(i))
+		if data[i] < 0x80 {
+			return v, i + 1
+		}
+	}
+	return 0, 0
+}
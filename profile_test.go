@@ -0,0 +1,88 @@
+package ddstats
+
+import (
+	"bytes"
+	"runtime/pprof"
+	"strings"
+	"testing"
+	"time"
+)
+
+func burnCPU(d time.Duration) float64 {
+	x := 0.0
+	for start := time.Now(); time.Since(start) < d; {
+		for i := 0; i < 100000; i++ {
+			x += float64(i) * 0.5
+		}
+	}
+	return x
+}
+
+func TestParseProfile(t *testing.T) {
+
+	buf := &bytes.Buffer{}
+	if err := pprof.StartCPUProfile(buf); err != nil {
+		t.Skipf("could not start cpu profile, %s", err.Error())
+	}
+	burnCPU(time.Millisecond * 300)
+	pprof.StopCPUProfile()
+
+	p, err := parseProfile(buf.Bytes())
+	if err != nil {
+		t.Fatalf("expected no error, have %s", err.Error())
+	}
+
+	functions, total := p.selfTime("cpu")
+	if total == 0 {
+		t.Fatalf("expected profile to have samples")
+	}
+	found := false
+	for name := range functions {
+		if strings.HasSuffix(name, "burnCPU") {
+			found = true
+		}
+	}
+	if !found {
+		t.Fatalf("expected burnCPU in profile functions, have %v", functions)
+	}
+
+	if _, err := parseProfile([]byte("not a profile")); err == nil {
+		t.Fatalf("expected error parsing invalid profile")
+	}
+}
+
+func TestTopFunctions(t *testing.T) {
+
+	top := topFunctions(map[string]int64{"a": 1, "b": 3, "c": 2, "d": 3}, 3)
+	if len(top) != 3 {
+		t.Fatalf("expected %d functions, have %d", 3, len(top))
+	}
+	for i, name := range []string{"b", "d", "c"} {
+		if top[i].name != name {
+			t.Fatalf("expected function %d to be %s, have %s", i, name, top[i].name)
+		}
+	}
+}
+
+func TestStats_EnableCPUProfileMetrics(t *testing.T) {
+
+	stats, testApi, err := NewTestStats()
+	if err != nil {
+		t.Fatalf(err.Error())
+	}
+
+	stats.EnableCPUProfileMetrics(time.Millisecond*10, time.Millisecond*300, 5)
+	burnCPU(time.Millisecond * 500)
+	stats.Close()
+
+	testApi.lock.Lock()
+	defer testApi.lock.Unlock()
+	for _, series := range testApi.series {
+		for _, m := range series.Series {
+			if m.Metric == prependNamespace(testNamespace, MetricProfileSelfTime) {
+				return
+			}
+		}
+	}
+	t.Fatalf("expected %s metric to be sent", MetricProfileSelfTime)
+}
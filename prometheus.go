@@ -0,0 +1,286 @@
+package ddstats
+
+import (
+	"bytes"
+	"fmt"
+	"math"
+	"net/http"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+
+	"github.com/jmizell/ddstats/client"
+)
+
+// DefaultPrometheusBuckets are the default upper bounds of the Prometheus histogram
+// buckets, the same as the Prometheus client libraries.
+var DefaultPrometheusBuckets = []float64{.005, .01, .025, .05, .1, .25, .5, 1, 2.5, 5, 10}
+
+// prometheusContentType is the content type of the Prometheus text exposition format.
+const prometheusContentType = "text/plain; version=0.0.4; charset=utf-8"
+
+// Prometheus metric types
+const (
+	promCounter   = "counter"
+	promGauge     = "gauge"
+	promHistogram = "histogram"
+)
+
+// prometheusBridge holds the aggregated metrics exposed by PrometheusHandler. It's
+// updated with the metrics of each flush interval. Counts, rates, and monotonic counts are
+// exposed as counters, the cumulative sum since stats was created. Gauges, gauge deltas,
+// ewmas, and sets are exposed as gauges with the value of the last flush interval.
+// Histograms, and distributions are exposed as cumulative histograms.
+type prometheusBridge struct {
+	buckets  []float64
+	families map[string]*promFamily
+	lock     sync.Mutex
+}
+
+type promFamily struct {
+	typ    string
+	series map[string]*promSeries
+}
+
+type promSeries struct {
+	labels  string
+	value   float64
+	buckets []uint64 // Cumulative count of each bucket
+	sum     float64
+	count   uint64
+}
+
+func newPrometheusBridge(buckets []float64) *prometheusBridge {
+	if len(buckets) == 0 {
+		buckets = DefaultPrometheusBuckets
+	}
+	buckets = append([]float64{}, buckets...)
+	sort.Float64s(buckets)
+	return &prometheusBridge{
+		buckets:  buckets,
+		families: map[string]*promFamily{},
+	}
+}
+
+// recordPrometheus adds the metrics of a flush interval to the Prometheus bridge.
+func (c *Stats) recordPrometheus(metrics map[string]*metric) {
+
+	if c.prometheus == nil {
+		return
+	}
+
+	p := c.prometheus
+	p.lock.Lock()
+	defer p.lock.Unlock()
+	for _, m := range metrics {
+		tags := combineTags(c.globalTags(), m.tags)
+		if m.host != "" {
+			tags = append(tags, "host:"+m.host)
+		}
+		p.record(promName(c.withNamespace(m.name)), promLabels(tags), m)
+	}
+}
+
+// record adds m to the series with name, and labels. Updates are ignored if the metric
+// name was first recorded with a different type.
+func (p *prometheusBridge) record(name, labels string, m *metric) {
+
+	var typ string
+	switch m.class {
+	case client.Count, client.Rate, monotonicCount:
+		typ = promCounter
+		if !strings.HasSuffix(name, "_total") {
+			name += "_total"
+		}
+	case histogram, client.Distribution:
+		typ = promHistogram
+	default:
+		typ = promGauge
+	}
+
+	family, ok := p.families[name]
+	if !ok {
+		family = &promFamily{typ: typ, series: map[string]*promSeries{}}
+		p.families[name] = family
+	} else if family.typ != typ {
+		return
+	}
+	series, ok := family.series[labels]
+	if !ok {
+		series = &promSeries{labels: labels}
+		if typ == promHistogram {
+			series.buckets = make([]uint64, len(p.buckets))
+		}
+		family.series[labels] = series
+	}
+
+	switch {
+	case typ == promCounter:
+		// Counters can't decrease, negative counts are ignored
+		if m.value > 0 {
+			series.value += m.value
+		}
+	case typ == promHistogram && m.sketch != nil:
+		// Values moved to a sketch are counted in the bucket of their bin value
+		m.sketch.ForEach(func(v float64, n uint64) bool {
+			series.observe(p.buckets, v, n)
+			return true
+		})
+		series.sum += m.sketch.Sum()
+	case typ == promHistogram:
+		for _, v := range m.values {
+			series.observe(p.buckets, v, 1)
+			series.sum += v
+		}
+	case m.class == set:
+		series.value = float64(len(m.members))
+	default:
+		series.value = m.value
+	}
+}
+
+// observe counts n values of v in the histogram buckets. The sum isn't updated.
+func (s *promSeries) observe(buckets []float64, v float64, n uint64) {
+	for i, bound := range buckets {
+		if v <= bound {
+			s.buckets[i] += n
+		}
+	}
+	s.count += n
+}
+
+// write writes the metrics in the Prometheus text exposition format.
+func (p *prometheusBridge) write(buf *bytes.Buffer) {
+
+	p.lock.Lock()
+	defer p.lock.Unlock()
+
+	names := make([]string, 0, len(p.families))
+	for name := range p.families {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+
+	for _, name := range names {
+		family := p.families[name]
+		fmt.Fprintf(buf, "# TYPE %s %s\n", name, family.typ)
+
+		keys := make([]string, 0, len(family.series))
+		for key := range family.series {
+			keys = append(keys, key)
+		}
+		sort.Strings(keys)
+		for _, key := range keys {
+			series := family.series[key]
+			if family.typ != promHistogram {
+				fmt.Fprintf(buf, "%s%s %s\n", name, braces(series.labels), promFloat(series.value))
+				continue
+			}
+			for i, bound := range p.buckets {
+				fmt.Fprintf(buf, "%s_bucket%s %d\n", name, braces(joinLabels(series.labels, `le="`+promFloat(bound)+`"`)), series.buckets[i])
+			}
+			fmt.Fprintf(buf, "%s_bucket%s %d\n", name, braces(joinLabels(series.labels, `le="+Inf"`)), series.count)
+			fmt.Fprintf(buf, "%s_sum%s %s\n", name, braces(series.labels), promFloat(series.sum))
+			fmt.Fprintf(buf, "%s_count%s %d\n", name, braces(series.labels), series.count)
+		}
+	}
+}
+
+// PrometheusHandler returns an http handler that serves the aggregated metrics in the
+// Prometheus text exposition format, for services that publish to both Datadog, and
+// Prometheus. The metrics are updated on each flush, see Config.WithPrometheus. If the
+// Prometheus bridge isn't enabled, the handler responds with 404.
+func (c *Stats) PrometheusHandler() http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if c.prometheus == nil {
+			http.Error(w, "prometheus metrics are not enabled", http.StatusNotFound)
+			return
+		}
+		buf := &bytes.Buffer{}
+		c.prometheus.write(buf)
+		w.Header().Set("Content-Type", prometheusContentType)
+		_, _ = w.Write(buf.Bytes())
+	})
+}
+
+// promEscaper escapes a Prometheus label value.
+var promEscaper = strings.NewReplacer(`\`, `\\`, `"`, `\"`, "\n", `\n`)
+
+// promName returns name with any character not valid in a Prometheus metric name
+// replaced with an underscore.
+func promName(name string) string {
+	return sanitizePromName(name, true)
+}
+
+func sanitizePromName(name string, colons bool) string {
+	b := []byte(name)
+	for i, ch := range b {
+		valid := ch == '_' || ch >= 'a' && ch <= 'z' || ch >= 'A' && ch <= 'Z' ||
+			ch >= '0' && ch <= '9' && i > 0 || ch == ':' && colons
+		if !valid {
+			b[i] = '_'
+		}
+	}
+	if len(b) == 0 {
+		return "_"
+	}
+	return string(b)
+}
+
+// promLabels converts tags to a sorted Prometheus label set, without braces. Tags without
+// a value are set to true, and multiple values of the same key are joined with a comma.
+func promLabels(tags []string) string {
+
+	values := map[string][]string{}
+	for _, tag := range tags {
+		key, value := tag, "true"
+		if i := strings.Index(tag, ":"); i >= 0 {
+			key, value = tag[:i], tag[i+1:]
+		}
+		key = sanitizePromName(key, false)
+		if strings.HasPrefix(key, "__") {
+			key = "tag" + key
+		}
+		values[key] = append(values[key], value)
+	}
+
+	keys := make([]string, 0, len(values))
+	for key := range values {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+
+	labels := make([]string, 0, len(keys))
+	for _, key := range keys {
+		sort.Strings(values[key])
+		labels = append(labels, key+`="`+promEscaper.Replace(strings.Join(values[key], ","))+`"`)
+	}
+	return strings.Join(labels, ",")
+}
+
+func joinLabels(labels, label string) string {
+	if labels == "" {
+		return label
+	}
+	return labels + "," + label
+}
+
+func braces(labels string) string {
+	if labels == "" {
+		return ""
+	}
+	return "{" + labels + "}"
+}
+
+func promFloat(v float64) string {
+	switch {
+	case math.IsInf(v, 1):
+		return "+Inf"
+	case math.IsInf(v, -1):
+		return "-Inf"
+	case math.IsNaN(v):
+		return "NaN"
+	}
+	return strconv.FormatFloat(v, 'g', -1, 64)
+}
@@ -0,0 +1,87 @@
+package ddstats
+
+import (
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+)
+
+func TestStats_PrometheusHandler(t *testing.T) {
+
+	testApi := NewTestAPIClient()
+	cfg := NewConfig().
+		WithNamespace("ns").
+		WithHost(testHost).
+		WithClient(testApi).
+		WithTags([]string{"env:test"}).
+		WithPrometheus(true, []float64{1, 5})
+	stats, err := NewStats(cfg)
+	if err != nil {
+		t.Fatalf(err.Error())
+	}
+	defer stats.Close()
+
+	for i := 0; i < 2; i++ {
+		stats.Count("requests", 2, []string{"path:/a\"b"})
+		stats.Count("requests", -1, []string{"path:/a\"b"})
+		stats.Gauge("queue.depth", float64(i+3), nil)
+		stats.Histogram("latency", 0.5, nil)
+		stats.Histogram("latency", 4, nil)
+		stats.Histogram("latency", 10, nil)
+		stats.Flush()
+	}
+
+	server := httptest.NewServer(stats.PrometheusHandler())
+	defer server.Close()
+	resp, err := http.Get(server.URL)
+	if err != nil {
+		t.Fatalf(err.Error())
+	}
+	defer resp.Body.Close()
+	body, _ := ioutil.ReadAll(resp.Body)
+	if !strings.HasPrefix(resp.Header.Get("Content-Type"), "text/plain; version=0.0.4") {
+		t.Fatalf("expected the prometheus content type, have %s", resp.Header.Get("Content-Type"))
+	}
+
+	for _, line := range []string{
+		`# TYPE ns_requests_total counter`,
+		`ns_requests_total{env="test",path="/a\"b"} 2`,
+		`# TYPE ns_queue_depth gauge`,
+		`ns_queue_depth{env="test"} 4`,
+		`# TYPE ns_latency histogram`,
+		`ns_latency_bucket{env="test",le="1"} 2`,
+		`ns_latency_bucket{env="test",le="5"} 4`,
+		`ns_latency_bucket{env="test",le="+Inf"} 6`,
+		`ns_latency_sum{env="test"} 29`,
+		`ns_latency_count{env="test"} 6`,
+	} {
+		if !strings.Contains(string(body), line+"\n") {
+			t.Fatalf("expected line %s, have\n%s", line, body)
+		}
+	}
+}
+
+func TestStats_PrometheusHandlerDisabled(t *testing.T) {
+
+	stats, _, err := NewTestStats()
+	if err != nil {
+		t.Fatalf(err.Error())
+	}
+	defer stats.Close()
+
+	w := httptest.NewRecorder()
+	stats.PrometheusHandler().ServeHTTP(w, httptest.NewRequest(http.MethodGet, "/metrics", nil))
+	if w.Code != http.StatusNotFound {
+		t.Fatalf("expected status %d, have %d", http.StatusNotFound, w.Code)
+	}
+}
+
+func TestPromLabels(t *testing.T) {
+	labels := promLabels([]string{"b:2", "a", "b:1", "__name:x", "bad-key:v\n"})
+	expected := `a="true",b="1,2",bad_key="v\n",tag__name="x"`
+	if labels != expected {
+		t.Fatalf("expected labels %s, have %s", expected, labels)
+	}
+}
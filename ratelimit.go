@@ -0,0 +1,118 @@
+package ddstats
+
+import (
+	"errors"
+	"sync"
+	"time"
+
+	"github.com/jmizell/ddstats/client"
+)
+
+// RateLimitMode controls what happens to a SendSeries, ServiceCheck, or Event call that
+// exceeds the client rate limit.
+type RateLimitMode string
+
+// Rate limit modes
+const (
+	// RateLimitError rejects the call with client.ErrRateLimitExceeded. This is the
+	// default.
+	RateLimitError = RateLimitMode("")
+
+	// RateLimitQueue queues the call, and sends it in the background once the rate limit
+	// allows. Errors of queued calls are added to the errors list. If the queue is full,
+	// the call is rejected with client.ErrRateLimitExceeded.
+	RateLimitQueue = RateLimitMode("queue")
+)
+
+// ErrRateLimitClosed is the error of a call that exceeded the rate limit in queue mode,
+// after the stats were closed, and the queue no longer accepts calls.
+var ErrRateLimitClosed = errors.New("rate limit queue is closed")
+
+// DefaultRateLimitQueue is the default number of calls that can be waiting for the rate
+// limit in queue mode.
+const DefaultRateLimitQueue = 1000
+
+// rateLimitedCall is a call waiting in the rate limit queue.
+type rateLimitedCall struct {
+	class ErrorClass
+	send  func() error
+}
+
+// rateLimit limits the rate of the api calls made directly by the caller. Flushes are
+// not limited, they're already bounded by the flush interval.
+type rateLimit struct {
+	limiter *client.RateLimiter
+	mode    RateLimitMode
+	queue   chan *rateLimitedCall
+	wg      sync.WaitGroup
+	lock    sync.Mutex // Guards closed, and sends to the queue
+	closed  bool
+}
+
+func newRateLimit(rate float64, burst int, mode RateLimitMode, queueSize int) *rateLimit {
+	if queueSize <= 0 {
+		queueSize = DefaultRateLimitQueue
+	}
+	r := &rateLimit{
+		limiter: client.NewRateLimiter(rate, burst),
+		mode:    mode,
+	}
+	if mode == RateLimitQueue {
+		r.queue = make(chan *rateLimitedCall, queueSize)
+	}
+	return r
+}
+
+// rateLimited calls send if the rate limit allows it. Otherwise, the call is rejected,
+// or queued according to the rate limit mode. Queued calls return nil, and calls that
+// would be queued after the queue is closed return ErrRateLimitClosed.
+func (c *Stats) rateLimited(class ErrorClass, send func() error) error {
+
+	r := c.rateLimit
+	if r == nil || r.limiter.Allow() {
+		return send()
+	}
+	if r.queue == nil {
+		return client.ErrRateLimitExceeded
+	}
+
+	r.lock.Lock()
+	defer r.lock.Unlock()
+	if r.closed {
+		return ErrRateLimitClosed
+	}
+	select {
+	case r.queue <- &rateLimitedCall{class: class, send: send}:
+		return nil
+	default:
+		return client.ErrRateLimitExceeded
+	}
+}
+
+// rateLimitWorker sends the queued calls at the limited rate, until the queue is closed.
+func (c *Stats) rateLimitWorker() {
+	r := c.rateLimit
+	defer r.wg.Done()
+	for call := range r.queue {
+		time.Sleep(r.limiter.Reserve())
+		if err := call.send(); err != nil {
+			c.addError(call.class, err)
+		}
+	}
+}
+
+// closeRateLimit stops the rate limit queue from accepting calls, and waits for the
+// calls already queued to be sent.
+func (c *Stats) closeRateLimit() {
+	r := c.rateLimit
+	if r == nil || r.queue == nil {
+		return
+	}
+	r.lock.Lock()
+	if !r.closed {
+		r.closed = true
+		close(r.queue)
+	}
+	r.lock.Unlock()
+	r.wg.Wait()
+}
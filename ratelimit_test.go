@@ -0,0 +1,111 @@
+package ddstats
+
+import (
+	"errors"
+	"testing"
+
+	"github.com/jmizell/ddstats/client"
+)
+
+func TestStats_WithRateLimit(t *testing.T) {
+
+	t.Run("error", func(tt *testing.T) {
+		testApi := NewTestAPIClient()
+		cfg := NewConfig().
+			WithNamespace(testNamespace).
+			WithHost(testHost).
+			WithClient(testApi).
+			WithRateLimit(0.001, 2, RateLimitError)
+		stats, err := NewStats(cfg)
+		if err != nil {
+			tt.Fatalf(err.Error())
+		}
+		defer stats.Close()
+
+		for i := 0; i < 2; i++ {
+			if err := stats.ServiceCheck("check", "", client.Okay, nil); err != nil {
+				tt.Fatalf("expected call %d within the burst to succeed, have %s", i, err.Error())
+			}
+		}
+		if err := stats.Event(&client.DDEvent{Title: "event"}); !errors.Is(err, client.ErrRateLimitExceeded) {
+			tt.Fatalf("expected error %v, have %v", client.ErrRateLimitExceeded, err)
+		}
+		if err := stats.SendSeries([]*client.DDMetric{{Metric: "test"}}); !errors.Is(err, client.ErrRateLimitExceeded) {
+			tt.Fatalf("expected error %v, have %v", client.ErrRateLimitExceeded, err)
+		}
+
+		testApi.lock.Lock()
+		defer testApi.lock.Unlock()
+		if len(testApi.checks) != 2 || len(testApi.events) != 0 || len(testApi.series) != 0 {
+			tt.Fatalf("expected only the calls within the burst to be sent, have %d checks, %d events, and %d series",
+				len(testApi.checks), len(testApi.events), len(testApi.series))
+		}
+	})
+
+	t.Run("queue", func(tt *testing.T) {
+		testApi := NewTestAPIClient()
+		cfg := NewConfig().
+			WithNamespace(testNamespace).
+			WithHost(testHost).
+			WithClient(testApi).
+			WithRateLimit(100, 1, RateLimitQueue)
+		cfg.RateLimitQueue = 2
+		stats, err := NewStats(cfg)
+		if err != nil {
+			tt.Fatalf(err.Error())
+		}
+
+		// Block the queue worker on the first queued call, so the queue fills up
+		errs := make([]error, 5)
+		errs[0] = stats.Event(&client.DDEvent{Title: "event"})
+		testApi.lock.Lock()
+		for i := 1; i < len(errs); i++ {
+			errs[i] = stats.Event(&client.DDEvent{Title: "event"})
+		}
+		testApi.lock.Unlock()
+		stats.Close()
+
+		rejected := 0
+		for _, err := range errs {
+			if errors.Is(err, client.ErrRateLimitExceeded) {
+				rejected++
+			} else if err != nil {
+				tt.Fatalf("unexpected error %s", err.Error())
+			}
+		}
+		testApi.lock.Lock()
+		defer testApi.lock.Unlock()
+		if len(testApi.events)+rejected != len(errs) {
+			tt.Fatalf("expected every call to be sent, or rejected, have %d sent, and %d rejected", len(testApi.events), rejected)
+		}
+		if rejected == 0 || len(testApi.events) < 3 {
+			tt.Fatalf("expected calls to be queued, until the queue was full, have %d sent, and %d rejected", len(testApi.events), rejected)
+		}
+	})
+	t.Run("queue after close", func(tt *testing.T) {
+		testApi := NewTestAPIClient()
+		cfg := NewConfig().
+			WithNamespace(testNamespace).
+			WithHost(testHost).
+			WithClient(testApi).
+			WithRateLimit(0.001, 1, RateLimitQueue)
+		stats, err := NewStats(cfg)
+		if err != nil {
+			tt.Fatalf(err.Error())
+		}
+		if err := stats.ServiceCheck("check", "", client.Okay, nil); err != nil {
+			tt.Fatalf("expected call within the burst to succeed, have %s", err.Error())
+		}
+		stats.Close()
+
+		if err := stats.Event(&client.DDEvent{Title: "event"}); !errors.Is(err, ErrRateLimitClosed) {
+			tt.Fatalf("expected error %v, have %v", ErrRateLimitClosed, err)
+		}
+		if err := stats.ServiceCheck("check", "", client.Okay, nil); !errors.Is(err, ErrRateLimitClosed) {
+			tt.Fatalf("expected error %v, have %v", ErrRateLimitClosed, err)
+		}
+		if err := stats.SendSeries([]*client.DDMetric{{Metric: "test"}}); !errors.Is(err, ErrRateLimitClosed) {
+			tt.Fatalf("expected error %v, have %v", ErrRateLimitClosed, err)
+		}
+	})
+}
@@ -0,0 +1,195 @@
+package ddstats
+
+import (
+	"fmt"
+	"net"
+	"os"
+	"strings"
+	"sync"
+
+	"github.com/jmizell/ddstats/client"
+)
+
+// RelayServer accepts series, service checks, and events from sibling processes over a
+// Unix socket, and ships them through a single Stats. This allows prefork servers, and
+// short lived worker processes to share one api connection, and flush.
+//
+// Sibling processes send to the relay by configuring their Stats with a client created by
+// client.NewRelayClient. Points of series received from siblings are aggregated with the
+// relay's own metrics, so the same series from several siblings is sent as one series in
+// the relay's next flush. Counts, and rates are summed, and gauges keep the last value.
+// Series of other types are queued as received. Service checks, events, and
+// distributions are sent immediately.
+type RelayServer struct {
+	stats    *Stats
+	listener net.Listener
+	conns    map[net.Conn]bool
+	lock     *sync.Mutex
+	wg       *sync.WaitGroup
+	closed   bool
+}
+
+// NewRelayServer listens on a Unix socket at path, and relays received messages to stats.
+// Any existing file at path is removed.
+func NewRelayServer(stats *Stats, path string) (*RelayServer, error) {
+
+	if err := os.Remove(path); err != nil && !os.IsNotExist(err) {
+		return nil, fmt.Errorf("could not remove existing relay socket, %s", err.Error())
+	}
+	listener, err := net.Listen("unix", path)
+	if err != nil {
+		return nil, fmt.Errorf("could not listen on relay socket, %s", err.Error())
+	}
+
+	r := &RelayServer{
+		stats:    stats,
+		listener: listener,
+		conns:    map[net.Conn]bool{},
+		lock:     &sync.Mutex{},
+		wg:       &sync.WaitGroup{},
+	}
+	r.wg.Add(1)
+	go r.accept()
+
+	return r, nil
+}
+
+// Close stops accepting connections, closes all open connections, and waits for in flight
+// messages to be handled. Close does not close the Stats.
+func (r *RelayServer) Close() error {
+
+	r.lock.Lock()
+	r.closed = true
+	err := r.listener.Close()
+	for conn := range r.conns {
+		_ = conn.Close()
+	}
+	r.lock.Unlock()
+
+	r.wg.Wait()
+	return err
+}
+
+func (r *RelayServer) accept() {
+	defer r.wg.Done()
+	for {
+		conn, err := r.listener.Accept()
+		if err != nil {
+			return
+		}
+
+		r.lock.Lock()
+		if r.closed {
+			r.lock.Unlock()
+			_ = conn.Close()
+			return
+		}
+		r.conns[conn] = true
+		r.wg.Add(1)
+		r.lock.Unlock()
+
+		go r.serve(conn)
+	}
+}
+
+func (r *RelayServer) serve(conn net.Conn) {
+
+	defer r.wg.Done()
+	defer func() {
+		r.lock.Lock()
+		delete(r.conns, conn)
+		r.lock.Unlock()
+		_ = conn.Close()
+	}()
+
+	for {
+		msg, err := client.ReadRelayMessage(conn)
+		if err != nil {
+			return
+		}
+
+		response := &client.RelayMessage{}
+		if err := r.handle(msg); err != nil {
+			response.Error = err.Error()
+		}
+		if err := client.WriteRelayMessage(conn, response); err != nil {
+			return
+		}
+	}
+}
+
+func (r *RelayServer) handle(msg *client.RelayMessage) error {
+	switch {
+	case msg.Type == client.RelaySeries && msg.Series != nil:
+		return r.aggregate(msg.Series.Series)
+	case msg.Type == client.RelayServiceCheck && msg.ServiceCheck != nil:
+		check := msg.ServiceCheck
+		check.Check = r.stats.withNamespace(check.Check)
+		if check.Hostname == "" {
+			check.Hostname = r.stats.host
+		}
+		check.Tags = combineTags(r.stats.globalTags(), check.Tags)
+		return r.stats.client.SendServiceCheck(check)
+	case msg.Type == client.RelayEvent && msg.Event != nil:
+		return r.stats.Event(msg.Event)
+	case msg.Type == client.RelayDistribution && msg.Distribution != nil:
+		return r.stats.SendDistributions(msg.Distribution.Series)
+	default:
+		return fmt.Errorf("unknown relay message type %q", msg.Type)
+	}
+}
+
+// aggregate submits the points of sibling series to the relay's stats, so they're merged
+// with the same series from other siblings, and the relay itself.
+func (r *RelayServer) aggregate(series []*client.DDMetric) error {
+
+	var queued []*client.DDMetric
+	for _, m := range series {
+		var submit func(name string, value float64, tags []string, opts ...MetricOption)
+		scale := 1.0
+		switch m.Type {
+		case client.Count:
+			submit = r.stats.Count
+		case client.Gauge:
+			submit = r.stats.Gauge
+		case client.Rate:
+			// A rate point is per second, over the sibling's interval, the relay sums
+			// the counts, and divides by its own interval
+			submit = r.stats.Rate
+			if m.Interval > 0 {
+				scale = float64(m.Interval)
+			}
+		default:
+			queued = append(queued, m)
+			continue
+		}
+
+		var opts []MetricOption
+		if m.Host != "" && m.Host != r.stats.host {
+			opts = append(opts, WithHost(m.Host))
+		}
+		name := r.localName(m.Metric)
+		for _, p := range m.Points {
+			if value, ok := p[1].(float64); ok {
+				submit(name, value*scale, m.Tags, opts...)
+			}
+		}
+	}
+	if len(queued) == 0 {
+		return nil
+	}
+	return r.stats.QueueSeries(queued)
+}
+
+// localName returns name without the relay's namespace, if the sibling already prepended
+// it, so the series is aggregated with the relay's own metric of the same name.
+func (r *RelayServer) localName(name string) string {
+	namespace := strings.TrimRight(r.stats.namespace, ".")
+	if namespace == "" || !strings.HasPrefix(name, namespace+".") {
+		return name
+	}
+	if local := name[len(namespace)+1:]; r.stats.withNamespace(local) == name {
+		return local
+	}
+	return name
+}
@@ -0,0 +1,184 @@
+package ddstats
+
+import (
+	"bytes"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/jmizell/ddstats/client"
+)
+
+func TestRelayServer(t *testing.T) {
+
+	dir, err := ioutil.TempDir("", "ddstats-relay")
+	if err != nil {
+		t.Fatalf(err.Error())
+	}
+	defer os.RemoveAll(dir)
+	path := filepath.Join(dir, "relay.sock")
+
+	t.Run("merge sibling series", func(tt *testing.T) {
+		relay, testClient, err := NewTestStats()
+		if err != nil {
+			tt.Fatalf(err.Error())
+		}
+		server, err := NewRelayServer(relay, path)
+		if err != nil {
+			tt.Fatalf(err.Error())
+		}
+
+		relayClient := client.NewRelayClient(path)
+		sibling, err := NewStats(NewConfig().
+			WithNamespace(testNamespace).
+			WithHost("siblingHost").
+			WithClient(relayClient))
+		if err != nil {
+			tt.Fatalf(err.Error())
+		}
+
+		sibling.Count("sibling", 2, nil)
+		sibling.Close()
+		if errs := sibling.Errors(); len(errs) > 0 {
+			tt.Fatalf("expected no sibling errors, have %v", errs)
+		}
+		relay.Count("relay", 1, nil)
+		relay.Close()
+		_ = relayClient.Close()
+		if err := server.Close(); err != nil {
+			tt.Fatalf(err.Error())
+		}
+
+		if len(testClient.series) != 1 {
+			tt.Fatalf("expected %d series calls, have %d", 1, len(testClient.series))
+		}
+		if err := testClient.FindMetric(0, &client.DDMetric{
+			Host:     "siblingHost",
+			Metric:   "testNamespace.sibling",
+			Points:   [][2]interface{}{{0, float64(2)}},
+			Interval: 1,
+			Tags:     testTags,
+			Type:     client.Count,
+		}); err != nil {
+			tt.Fatalf(err.Error())
+		}
+		if err := testClient.FindMetric(0, &client.DDMetric{
+			Host:     testHost,
+			Metric:   "testNamespace.relay",
+			Points:   [][2]interface{}{{0, float64(1)}},
+			Interval: 1,
+			Tags:     testTags,
+			Type:     client.Count,
+		}); err != nil {
+			tt.Fatalf(err.Error())
+		}
+	})
+
+	t.Run("merge colliding series", func(tt *testing.T) {
+		relay, testClient, err := NewTestStats()
+		if err != nil {
+			tt.Fatalf(err.Error())
+		}
+		server, err := NewRelayServer(relay, path)
+		if err != nil {
+			tt.Fatalf(err.Error())
+		}
+
+		for i := 0; i < 2; i++ {
+			relayClient := client.NewRelayClient(path)
+			sibling, err := NewStats(NewConfig().
+				WithNamespace(testNamespace).
+				WithHost(testHost).
+				WithClient(relayClient))
+			if err != nil {
+				tt.Fatalf(err.Error())
+			}
+			sibling.Count("requests", 2, nil)
+			sibling.Close()
+			_ = relayClient.Close()
+		}
+		relay.Count("requests", 1, nil)
+		relay.Close()
+		if err := server.Close(); err != nil {
+			tt.Fatalf(err.Error())
+		}
+
+		if len(testClient.series) != 1 || len(testClient.series[0].Series) != 1 {
+			tt.Fatalf("expected a single merged series, have %v", testClient.series)
+		}
+		if err := testClient.FindMetric(0, &client.DDMetric{
+			Host:     testHost,
+			Metric:   "testNamespace.requests",
+			Points:   [][2]interface{}{{0, float64(5)}},
+			Interval: 1,
+			Tags:     testTags,
+			Type:     client.Count,
+		}); err != nil {
+			tt.Fatalf(err.Error())
+		}
+	})
+
+	t.Run("service checks and events", func(tt *testing.T) {
+		relay, testClient, err := NewTestStats()
+		if err != nil {
+			tt.Fatalf(err.Error())
+		}
+		server, err := NewRelayServer(relay, path)
+		if err != nil {
+			tt.Fatalf(err.Error())
+		}
+		defer relay.Close()
+		defer server.Close()
+
+		relayClient := client.NewRelayClient(path)
+		defer relayClient.Close()
+		if err := relayClient.SendServiceCheck(&client.DDServiceCheck{Check: "check", Status: client.Okay}); err != nil {
+			tt.Fatalf(err.Error())
+		}
+		if err := relayClient.SendEvent(&client.DDEvent{Title: "event"}); err != nil {
+			tt.Fatalf(err.Error())
+		}
+		if len(testClient.checks) != 1 || testClient.checks[0].Check != "testNamespace.check" {
+			tt.Fatalf("expected relayed service check testNamespace.check, have %v", testClient.checks)
+		}
+		if len(testClient.events) != 1 || testClient.events[0].Host != testHost {
+			tt.Fatalf("expected relayed event with host %s, have %v", testHost, testClient.events)
+		}
+	})
+
+	t.Run("unknown message", func(tt *testing.T) {
+		relay, _, err := NewTestStats()
+		if err != nil {
+			tt.Fatalf(err.Error())
+		}
+		server, err := NewRelayServer(relay, path)
+		if err != nil {
+			tt.Fatalf(err.Error())
+		}
+		defer relay.Close()
+		defer server.Close()
+
+		if err := server.handle(&client.RelayMessage{Type: "unknown"}); err == nil {
+			tt.Fatalf("expected error for unknown message type")
+		}
+	})
+}
+
+func TestRelayMessage(t *testing.T) {
+
+	buf := &bytes.Buffer{}
+	msg := &client.RelayMessage{Type: client.RelaySeries, Series: &client.DDMetricSeries{
+		Series: []*client.DDMetric{{Metric: "test"}},
+	}}
+	if err := client.WriteRelayMessage(buf, msg); err != nil {
+		t.Fatalf(err.Error())
+	}
+	read, err := client.ReadRelayMessage(buf)
+	if err != nil {
+		t.Fatalf(err.Error())
+	}
+	if read.Type != client.RelaySeries || read.Series.Series[0].Metric != "test" {
+		t.Fatalf("expected series message, have %+v", read)
+	}
+}
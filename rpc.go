@@ -0,0 +1,103 @@
+package ddstats
+
+import (
+	"fmt"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"time"
+)
+
+// RPC metric names, these are prepended with the namespace. Latency is recorded with
+// Timing, in seconds, or the configured duration unit.
+const (
+	MetricRPCLatency  = "rpc.latency"
+	MetricRPCRequests = "rpc.requests"
+	MetricRPCInFlight = "rpc.in_flight"
+)
+
+// RPC roles, the rpc_role tag of RPC metrics.
+const (
+	RPCServer = "server"
+	RPCClient = "client"
+)
+
+// RPC is an in progress call, started with StartRPC.
+type RPC struct {
+	stats    *Stats
+	tags     []string
+	inFlight *int64
+	start    time.Time
+	once     *sync.Once
+}
+
+// rpcInFlight holds the number of in progress calls for each role, and method.
+type rpcInFlight struct {
+	counts map[string]*int64
+	lock   *sync.Mutex
+}
+
+// StartRPC records the start of a call to fullMethod, in the gRPC form
+// /package.Service/Method, by role, either RPCServer, or RPCClient. The in flight gauge
+// is updated immediately, and latency, and the request count are recorded when the call
+// is finished with Finish.
+//
+// StartRPC has no dependency on a gRPC implementation, interceptors are thin wrappers
+// around it.
+//
+//	func UnaryServerInterceptor(stats *ddstats.Stats) grpc.UnaryServerInterceptor {
+//		return func(ctx context.Context, req interface{}, info *grpc.UnaryServerInfo, handler grpc.UnaryHandler) (interface{}, error) {
+//			rpc := stats.StartRPC(ddstats.RPCServer, info.FullMethod)
+//			resp, err := handler(ctx, req)
+//			rpc.Finish(status.Code(err).String())
+//			return resp, err
+//		}
+//	}
+func (c *Stats) StartRPC(role, fullMethod string) *RPC {
+
+	service, method := splitRPCMethod(fullMethod)
+	tags := []string{
+		fmt.Sprintf("rpc_role:%s", role),
+		fmt.Sprintf("rpc_service:%s", service),
+		fmt.Sprintf("rpc_method:%s", method),
+	}
+
+	key := role + fullMethod
+	c.rpcInFlight.lock.Lock()
+	inFlight, ok := c.rpcInFlight.counts[key]
+	if !ok {
+		inFlight = new(int64)
+		c.rpcInFlight.counts[key] = inFlight
+	}
+	c.rpcInFlight.lock.Unlock()
+	c.Gauge(MetricRPCInFlight, float64(atomic.AddInt64(inFlight, 1)), tags)
+
+	return &RPC{
+		stats:    c,
+		tags:     tags,
+		inFlight: inFlight,
+		start:    time.Now(),
+		once:     &sync.Once{},
+	}
+}
+
+// Finish records the end of the call, with the status code, such as OK, or NotFound.
+// Only the first call to Finish is recorded.
+func (r *RPC) Finish(code string) {
+	r.once.Do(func() {
+		r.stats.Gauge(MetricRPCInFlight, float64(atomic.AddInt64(r.inFlight, -1)), r.tags)
+		tags := append([]string{fmt.Sprintf("rpc_code:%s", code)}, r.tags...)
+		r.stats.Timing(MetricRPCLatency, time.Since(r.start), tags)
+		r.stats.Increment(MetricRPCRequests, tags)
+	})
+}
+
+// splitRPCMethod splits a full gRPC method name, /package.Service/Method, into the
+// service, and method.
+func splitRPCMethod(fullMethod string) (string, string) {
+	fullMethod = strings.TrimPrefix(fullMethod, "/")
+	if i := strings.LastIndexByte(fullMethod, '/'); i >= 0 {
+		return fullMethod[:i], fullMethod[i+1:]
+	}
+	return "unknown", fullMethod
+}
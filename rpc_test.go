@@ -0,0 +1,83 @@
+package ddstats
+
+import (
+	"testing"
+)
+
+func Test_splitRPCMethod(t *testing.T) {
+	for fullMethod, expected := range map[string][2]string{
+		"/helloworld.Greeter/SayHello": {"helloworld.Greeter", "SayHello"},
+		"/Service/Method":              {"Service", "Method"},
+		"Method":                       {"unknown", "Method"},
+	} {
+		service, method := splitRPCMethod(fullMethod)
+		if service != expected[0] || method != expected[1] {
+			t.Fatalf("expected %s to split into %v, have [%s %s]", fullMethod, expected, service, method)
+		}
+	}
+}
+
+func TestStats_StartRPC(t *testing.T) {
+
+	stats, testApi, err := NewTestStats()
+	if err != nil {
+		t.Fatalf(err.Error())
+	}
+
+	first := stats.StartRPC(RPCServer, "/test.Service/Get")
+	second := stats.StartRPC(RPCServer, "/test.Service/Get")
+	first.Finish("OK")
+	first.Finish("OK")
+	stats.Flush()
+	second.Finish("NotFound")
+	stats.Close()
+
+	testApi.lock.Lock()
+	defer testApi.lock.Unlock()
+	if len(testApi.series) != 2 {
+		t.Fatalf("expected %d series, have %d", 2, len(testApi.series))
+	}
+
+	values := func(series int) map[string]float64 {
+		found := map[string]float64{}
+		for _, m := range testApi.series[series].Series {
+			key := m.Metric
+			for _, tag := range m.Tags {
+				if tag == "rpc_code:OK" || tag == "rpc_code:NotFound" {
+					key += "," + tag
+				}
+			}
+			found[key] = m.Points[0][1].(float64)
+		}
+		return found
+	}
+
+	inFlight := prependNamespace(testNamespace, MetricRPCInFlight)
+	requests := prependNamespace(testNamespace, MetricRPCRequests)
+	latency := prependNamespace(testNamespace, MetricRPCLatency+".count")
+
+	found := values(0)
+	if found[inFlight] != 1 {
+		t.Fatalf("expected %d in flight, have %f", 1, found[inFlight])
+	}
+	if found[requests+",rpc_code:OK"] != 1 {
+		t.Fatalf("expected %d OK request, have %v", 1, found)
+	}
+	if _, ok := found[latency+",rpc_code:OK"]; !ok {
+		t.Fatalf("expected latency to be recorded, have %v", found)
+	}
+
+	found = values(1)
+	if found[inFlight] != 0 {
+		t.Fatalf("expected %d in flight, have %f", 0, found[inFlight])
+	}
+	if found[requests+",rpc_code:NotFound"] != 1 {
+		t.Fatalf("expected %d NotFound request, have %v", 1, found)
+	}
+
+	// The tags kept by the call are submitted more than once, and must not be sorted by
+	// the workers
+	if second.tags[0] != "rpc_role:server" {
+		t.Fatalf("expected the call tags to keep their order, have %v", second.tags)
+	}
+}
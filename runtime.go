@@ -0,0 +1,170 @@
+package ddstats
+
+import (
+	"fmt"
+	"runtime"
+	"strings"
+	"time"
+)
+
+// DefaultRuntimeMetricsInterval is the collection interval of EnableGCPauseMetrics, and
+// EnableRuntimeMetrics, used when the interval isn't positive.
+const DefaultRuntimeMetricsInterval = time.Second * 10
+
+// GC pause metric names, these are prepended with the namespace. Pause values are
+// reported in seconds. MetricGCPauseCount is the count of the pause histogram, sent as a
+// rate, the same as any histogram.
+const (
+	MetricGCPause      = "runtime.gc.pause"
+	MetricGCPauseCount = "runtime.gc.pause.count"
+)
+
+// EnableGCPauseMetrics starts a collector that, every interval, records the GC pauses
+// since the last collection in the MetricGCPause histogram. The pause distribution covers
+// every collection of the flush interval, and is sent with the histogram aggregates, and
+// percentiles, set with Config.WithHistogramAggregates, and WithHistogramPercentiles, by
+// default .max, .min, .avg, .median, .95percentile, and .count.
+//
+// Pauses are read from runtime.MemStats, which holds the most recent 256 pauses. If more
+// than 256 collections happen in an interval, only the most recent 256 are recorded. The
+// collector stops when stats is closed. An interval that isn't positive is replaced with
+// DefaultRuntimeMetricsInterval.
+func (c *Stats) EnableGCPauseMetrics(interval time.Duration) {
+
+	if interval <= 0 {
+		interval = DefaultRuntimeMetricsInterval
+	}
+
+	ms := &runtime.MemStats{}
+	runtime.ReadMemStats(ms)
+	g := &gcPauseCollector{lastNumGC: ms.NumGC}
+
+	c.startCollector(interval, func() {
+		runtime.ReadMemStats(ms)
+		_, pauses := g.pauses(ms)
+		for _, pause := range pauses {
+			c.Histogram(MetricGCPause, pause, c.runtimeTags)
+		}
+	})
+}
+
+// DefaultRuntimeMetricsPrefix is the default prefix of the metrics reported by
+// EnableRuntimeMetrics.
+const DefaultRuntimeMetricsPrefix = "runtime.go"
+
+// EnableRuntimeMetrics starts a collector that, every interval, samples the Go runtime,
+// and reports the results under the runtime metrics prefix, runtime.go by default.
+//
+// Gauges are reported for the number of goroutines, and memory statistics from
+// runtime.MemStats, such as mem.heap_alloc, and mem.heap_objects, in bytes, and objects.
+// Counts are reported for the number of mallocs, frees, GC cycles, and cgo calls since the
+// last sample. GC pauses in seconds are recorded in the gc.pause histogram, the same as
+// EnableGCPauseMetrics.
+//
+// Sampling runtime.MemStats briefly stops the world, intervals shorter than a few seconds
+// are not recommended. The collector stops when stats is closed. An interval that isn't
+// positive is replaced with DefaultRuntimeMetricsInterval.
+func (c *Stats) EnableRuntimeMetrics(interval time.Duration) {
+
+	if interval <= 0 {
+		interval = DefaultRuntimeMetricsInterval
+	}
+
+	prefix := c.runtimeMetricsPrefix
+	if prefix == "" {
+		prefix = DefaultRuntimeMetricsPrefix
+	}
+	prefix = strings.TrimRight(prefix, ".") + "."
+
+	ms := &runtime.MemStats{}
+	runtime.ReadMemStats(ms)
+	g := &gcPauseCollector{lastNumGC: ms.NumGC}
+	last := runtimeCounters{mallocs: ms.Mallocs, frees: ms.Frees, cgoCalls: runtime.NumCgoCall()}
+
+	c.startCollector(interval, func() {
+		runtime.ReadMemStats(ms)
+		tags := c.runtimeTags
+
+		c.Gauge(prefix+"goroutines", float64(runtime.NumGoroutine()), tags)
+		for _, v := range memStatsGauges(ms) {
+			c.Gauge(prefix+v.name, v.value, tags)
+		}
+
+		current := runtimeCounters{mallocs: ms.Mallocs, frees: ms.Frees, cgoCalls: runtime.NumCgoCall()}
+		c.Count(prefix+"mem.mallocs", float64(current.mallocs-last.mallocs), tags)
+		c.Count(prefix+"mem.frees", float64(current.frees-last.frees), tags)
+		c.Count(prefix+"cgo.calls", float64(current.cgoCalls-last.cgoCalls), tags)
+		last = current
+
+		count, pauses := g.pauses(ms)
+		c.Count(prefix+"gc.count", float64(count), tags)
+		for _, pause := range pauses {
+			c.Histogram(prefix+"gc.pause", pause, tags)
+		}
+	})
+}
+
+type runtimeCounters struct {
+	mallocs  uint64
+	frees    uint64
+	cgoCalls int64
+}
+
+type runtimeGauge struct {
+	name  string
+	value float64
+}
+
+// memStatsGauges returns the gauges reported from ms.
+func memStatsGauges(ms *runtime.MemStats) []runtimeGauge {
+	return []runtimeGauge{
+		{"mem.alloc", float64(ms.Alloc)},
+		{"mem.sys", float64(ms.Sys)},
+		{"mem.heap_alloc", float64(ms.HeapAlloc)},
+		{"mem.heap_sys", float64(ms.HeapSys)},
+		{"mem.heap_idle", float64(ms.HeapIdle)},
+		{"mem.heap_inuse", float64(ms.HeapInuse)},
+		{"mem.heap_released", float64(ms.HeapReleased)},
+		{"mem.heap_objects", float64(ms.HeapObjects)},
+		{"mem.stack_inuse", float64(ms.StackInuse)},
+		{"mem.stack_sys", float64(ms.StackSys)},
+		{"gc.next", float64(ms.NextGC)},
+		{"gc.cpu_fraction", ms.GCCPUFraction},
+	}
+}
+
+// runtimeTags returns tags describing the Go runtime, and platform.
+func runtimeTags() []string {
+	return []string{
+		fmt.Sprintf("go_version:%s", runtime.Version()),
+		fmt.Sprintf("goos:%s", runtime.GOOS),
+		fmt.Sprintf("goarch:%s", runtime.GOARCH),
+		fmt.Sprintf("num_cpu:%d", runtime.NumCPU()),
+	}
+}
+
+type gcPauseCollector struct {
+	lastNumGC uint32
+}
+
+// pauses returns the number of GC cycles since the last call, and the pause times in
+// seconds of the cycles that are still in the MemStats pause buffer.
+func (g *gcPauseCollector) pauses(ms *runtime.MemStats) (uint32, []float64) {
+
+	count := ms.NumGC - g.lastNumGC
+	g.lastNumGC = ms.NumGC
+
+	n := count
+	if n > uint32(len(ms.PauseNs)) {
+		n = uint32(len(ms.PauseNs))
+	}
+
+	// The most recent pause is at PauseNs[(NumGC+255)%256]
+	pauses := make([]float64, 0, n)
+	for i := uint32(0); i < n; i++ {
+		idx := (ms.NumGC - 1 - i) % uint32(len(ms.PauseNs))
+		pauses = append(pauses, time.Duration(ms.PauseNs[idx]).Seconds())
+	}
+
+	return count, pauses
+}
@@ -0,0 +1,196 @@
+package ddstats
+
+import (
+	"fmt"
+	"runtime"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestGCPauseCollector_pauses(t *testing.T) {
+
+	t.Run("new pauses", func(tt *testing.T) {
+		ms := &runtime.MemStats{NumGC: 3}
+		ms.PauseNs[0] = uint64(time.Second)
+		ms.PauseNs[1] = uint64(time.Second * 2)
+		ms.PauseNs[2] = uint64(time.Second * 3)
+
+		g := &gcPauseCollector{lastNumGC: 1}
+		count, pauses := g.pauses(ms)
+		if count != 2 {
+			tt.Fatalf("expected count to be %d, have %d", 2, count)
+		}
+		if len(pauses) != 2 || pauses[0] != 3 || pauses[1] != 2 {
+			tt.Fatalf("expected pauses to be [3 2], have %v", pauses)
+		}
+		if g.lastNumGC != 3 {
+			tt.Fatalf("expected last num gc to be %d, have %d", 3, g.lastNumGC)
+		}
+	})
+
+	t.Run("buffer wrapped", func(tt *testing.T) {
+		ms := &runtime.MemStats{NumGC: 1000}
+		g := &gcPauseCollector{lastNumGC: 0}
+		count, pauses := g.pauses(ms)
+		if count != 1000 {
+			tt.Fatalf("expected count to be %d, have %d", 1000, count)
+		}
+		if len(pauses) != len(ms.PauseNs) {
+			tt.Fatalf("expected %d pauses, have %d", len(ms.PauseNs), len(pauses))
+		}
+	})
+}
+
+func TestStats_EnableGCPauseMetrics(t *testing.T) {
+
+	stats, testApi, err := NewTestStats()
+	if err != nil {
+		t.Fatalf(err.Error())
+	}
+
+	stats.EnableGCPauseMetrics(time.Millisecond * 50)
+	runtime.GC()
+	time.Sleep(time.Millisecond * 100)
+	stats.Close()
+
+	found := map[string]bool{}
+	testApi.lock.Lock()
+	defer testApi.lock.Unlock()
+	for _, series := range testApi.series {
+		for _, m := range series.Series {
+			found[m.Metric] = true
+		}
+	}
+	for _, name := range []string{MetricGCPauseCount, MetricGCPause + ".max", MetricGCPause + ".95percentile"} {
+		if !found[prependNamespace(testNamespace, name)] {
+			t.Fatalf("expected %s metric to be sent", name)
+		}
+	}
+}
+
+func TestStats_EnableGCPauseMetricsInterval(t *testing.T) {
+
+	stats, testApi, err := NewTestStats()
+	if err != nil {
+		t.Fatalf(err.Error())
+	}
+
+	// Pauses collected over several ticks are all part of the flushed distribution
+	stats.EnableGCPauseMetrics(time.Millisecond * 10)
+	for i := 0; i < 5; i++ {
+		runtime.GC()
+		time.Sleep(time.Millisecond * 20)
+	}
+	stats.Close()
+
+	var recorded float64
+	testApi.lock.Lock()
+	defer testApi.lock.Unlock()
+	for _, series := range testApi.series {
+		for _, m := range series.Series {
+			if m.Metric != prependNamespace(testNamespace, MetricGCPauseCount) {
+				continue
+			}
+			for _, p := range m.Points {
+				recorded += p[1].(float64) * float64(m.Interval)
+			}
+		}
+	}
+	if recorded < 5 {
+		t.Fatalf("expected at least %d pauses in the distribution, have %.0f", 5, recorded)
+	}
+}
+
+func TestStats_RuntimeTags(t *testing.T) {
+
+	testApi := NewTestAPIClient()
+	cfg := NewConfig().
+		WithNamespace(testNamespace).
+		WithHost(testHost).
+		WithClient(testApi).
+		WithRuntimeTags(true)
+	stats, err := NewStats(cfg)
+	if err != nil {
+		t.Fatalf(err.Error())
+	}
+
+	stats.EnableGCPauseMetrics(time.Millisecond * 10)
+	stats.EnableRuntimeMetrics(time.Millisecond * 10)
+	stats.Gauge("test", 1, nil)
+	time.Sleep(time.Millisecond * 50)
+	stats.Close()
+
+	// The runtime tags are shared by every collector, and must not be sorted by the workers
+	if tags := runtimeTags(); strings.Join(stats.runtimeTags, ",") != strings.Join(tags, ",") {
+		t.Fatalf("expected the runtime tags to keep their order %v, have %v", tags, stats.runtimeTags)
+	}
+
+	expected := fmt.Sprintf("go_version:%s", runtime.Version())
+	testApi.lock.Lock()
+	defer testApi.lock.Unlock()
+	for _, series := range testApi.series {
+		for _, m := range series.Series {
+			tagged := false
+			for _, tag := range m.Tags {
+				if tag == expected {
+					tagged = true
+				}
+			}
+			isRuntime := strings.HasPrefix(m.Metric, prependNamespace(testNamespace, "runtime."))
+			if isRuntime && !tagged {
+				t.Fatalf("expected runtime metric %s to have tag %s, have %v", m.Metric, expected, m.Tags)
+			}
+			if !isRuntime && tagged {
+				t.Fatalf("expected metric %s to not have runtime tags, have %v", m.Metric, m.Tags)
+			}
+		}
+	}
+}
+
+func TestStats_EnableRuntimeMetrics(t *testing.T) {
+
+	testApi := NewTestAPIClient()
+	cfg := NewConfig().
+		WithNamespace(testNamespace).
+		WithHost(testHost).
+		WithClient(testApi).
+		WithRuntimeMetricsPrefix("rt.")
+	stats, err := NewStats(cfg)
+	if err != nil {
+		t.Fatalf(err.Error())
+	}
+
+	stats.EnableRuntimeMetrics(time.Millisecond * 20)
+	runtime.GC()
+	time.Sleep(time.Millisecond * 100)
+	stats.Close()
+
+	found := map[string]bool{}
+	testApi.lock.Lock()
+	defer testApi.lock.Unlock()
+	for _, series := range testApi.series {
+		for _, m := range series.Series {
+			found[m.Metric] = true
+		}
+	}
+	for _, name := range []string{"rt.goroutines", "rt.mem.heap_alloc", "rt.mem.mallocs", "rt.gc.count", "rt.cgo.calls"} {
+		if !found[prependNamespace(testNamespace, name)] {
+			t.Fatalf("expected %s metric to be sent, have %v", name, found)
+		}
+	}
+}
+
+func TestStats_CollectorInvalidInterval(t *testing.T) {
+
+	stats, _, err := NewTestStats()
+	if err != nil {
+		t.Fatalf(err.Error())
+	}
+
+	// A non-positive interval would panic the collector's ticker
+	stats.EnableGCPauseMetrics(0)
+	stats.EnableRuntimeMetrics(-time.Second)
+	stats.EnableCPUProfileMetrics(0, 0, 0)
+	stats.Close()
+}
@@ -0,0 +1,57 @@
+package ddstats
+
+import (
+	"math/rand"
+
+	"github.com/jmizell/ddstats/client"
+)
+
+// sampleFloat64 returns a random number in [0, 1), replaced in tests.
+var sampleFloat64 = rand.Float64
+
+// sampled returns true if a submission with sample rate should be recorded. Rates outside
+// of (0, 1) are not sampled, all submissions are recorded.
+func sampled(rate float64) bool {
+	if rate <= 0 || rate >= 1 {
+		return true
+	}
+	return sampleFloat64() < rate
+}
+
+// CountWithRate adds value to a count metric, with the sample rate rate. Only the given
+// fraction of submissions are recorded, the rest are dropped before they are queued, and
+// recorded values are scaled by 1/rate, so the reported count is an estimate of the
+// total. This is the same as statsd sample rates, and makes recording metrics in hot code
+// paths cheap. A rate of 1 is the same as Count.
+func (c *Stats) CountWithRate(name string, value, rate float64, tags []string, opts ...MetricOption) {
+	c.Count(name, value, tags, append(opts, WithSampleRate(rate))...)
+}
+
+// IncrementWithRate increments a count metric by +1, with the sample rate rate, see
+// CountWithRate.
+func (c *Stats) IncrementWithRate(name string, rate float64, tags []string, opts ...MetricOption) {
+	c.CountWithRate(name, 1, rate, tags, opts...)
+}
+
+// DecrementWithRate subtracts 1 from a count metric, with the sample rate rate, see
+// CountWithRate.
+func (c *Stats) DecrementWithRate(name string, rate float64, tags []string, opts ...MetricOption) {
+	c.CountWithRate(name, -1, rate, tags, opts...)
+}
+
+// RateWithSampleRate adds value to a rate metric, with the sample rate rate, see
+// CountWithRate.
+func (c *Stats) RateWithSampleRate(name string, value, rate float64, tags []string, opts ...MetricOption) {
+	c.Rate(name, value, tags, append(opts, WithSampleRate(rate))...)
+}
+
+// sampleValue returns value scaled by the sample rate, for counts, rates, and gauge deltas.
+func sampleValue(class string, value, rate float64) float64 {
+	if rate <= 0 || rate >= 1 {
+		return value
+	}
+	if class == client.Count || class == client.Rate || class == gaugeDelta {
+		return value / rate
+	}
+	return value
+}
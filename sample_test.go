@@ -0,0 +1,45 @@
+package ddstats
+
+import (
+	"testing"
+)
+
+func TestStats_CountWithRate(t *testing.T) {
+
+	samples := []float64{0.1, 0.9, 0.2, 0.6}
+	defaultSampleFloat64 := sampleFloat64
+	sampleFloat64 = func() float64 {
+		v := samples[0]
+		samples = samples[1:]
+		return v
+	}
+	defer func() {
+		sampleFloat64 = defaultSampleFloat64
+	}()
+
+	stats, testApi, err := NewTestStats()
+	if err != nil {
+		t.Fatalf(err.Error())
+	}
+	for i := 0; i < 4; i++ {
+		stats.IncrementWithRate("sampled", 0.5, nil)
+	}
+	stats.CountWithRate("unsampled", 3, 1, nil)
+	stats.Close()
+
+	testApi.lock.Lock()
+	defer testApi.lock.Unlock()
+	values := map[string]interface{}{}
+	for _, m := range testApi.series[0].Series {
+		values[m.Metric] = m.Points[0][1]
+	}
+	if values[prependNamespace(testNamespace, "sampled")] != 4.0 {
+		t.Fatalf("expected sampled count to be %f, have %v", 4.0, values)
+	}
+	if values[prependNamespace(testNamespace, "unsampled")] != 3.0 {
+		t.Fatalf("expected unsampled count to be %f, have %v", 3.0, values)
+	}
+	if len(samples) != 0 {
+		t.Fatalf("expected every sampled submission to draw a sample, have %d left", len(samples))
+	}
+}
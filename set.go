@@ -0,0 +1,21 @@
+package ddstats
+
+// Set adds value to a set metric. This is a non-blocking method, if the channel buffer is
+// full, then the value is not recorded. Sets count the unique values recorded in the flush
+// interval, and are sent as a gauge of the count, the same as statsd sets.
+func (c *Stats) Set(name string, value string, tags []string, opts ...MetricOption) {
+
+	tags, o, ok := c.prepareSubmission(name, tags, opts)
+	if !ok {
+		return
+	}
+
+	m := &metric{
+		name:    name,
+		class:   set,
+		tags:    tags,
+		members: map[string]bool{value: true},
+	}
+	o.apply(m)
+	c.enqueue(m, o)
+}
@@ -0,0 +1,34 @@
+package ddstats
+
+import (
+	"testing"
+
+	"github.com/jmizell/ddstats/client"
+)
+
+func TestStats_Set(t *testing.T) {
+
+	stats, testClient, err := NewTestStats()
+	if err != nil {
+		t.Fatalf(err.Error())
+	}
+
+	for _, user := range []string{"a", "b", "a", "c", "b"} {
+		stats.Set("users", user, nil)
+	}
+	stats.Flush()
+	stats.Set("users", "a", nil)
+	stats.Close()
+
+	for i, expected := range []float64{3, 1} {
+		if err := testClient.FindMetric(i, &client.DDMetric{
+			Host:   testHost,
+			Metric: "testNamespace.users",
+			Points: [][2]interface{}{{0, expected}},
+			Tags:   testTags,
+			Type:   client.Gauge,
+		}); err != nil {
+			t.Fatalf("flush %d: %s", i, err.Error())
+		}
+	}
+}
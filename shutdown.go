@@ -0,0 +1,43 @@
+package ddstats
+
+import (
+	"errors"
+	"time"
+)
+
+// ErrShutdownTimeout is the error of a send that was abandoned, because the shutdown
+// timeout expired before the api returned.
+var ErrShutdownTimeout = errors.New("shutdown timeout expired before the send completed")
+
+// startShutdownTimeout closes shutdownExpired once the shutdown timeout expires, and
+// returns a function that stops the timer. It does nothing if no timeout is set.
+func (c *Stats) startShutdownTimeout() (stop func()) {
+	if c.shutdownTimeout <= 0 {
+		return func() {}
+	}
+	timer := time.AfterFunc(c.shutdownTimeout, func() {
+		c.log().Warnf("shutdown timeout of %s expired, abandoning pending sends", c.shutdownTimeout)
+		close(c.shutdownExpired)
+	})
+	return func() { timer.Stop() }
+}
+
+// withinShutdownTimeout calls send, and returns its error. If the shutdown timeout expires
+// first, ErrShutdownTimeout is returned without waiting for send, which is left to complete
+// in the background.
+func (c *Stats) withinShutdownTimeout(send func() error) error {
+	if c.shutdownTimeout <= 0 {
+		return send()
+	}
+
+	result := make(chan error, 1)
+	go func() {
+		result <- send()
+	}()
+	select {
+	case err := <-result:
+		return err
+	case <-c.shutdownExpired:
+		return ErrShutdownTimeout
+	}
+}
@@ -0,0 +1,84 @@
+package ddstats
+
+import (
+	"io/ioutil"
+	"os"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/jmizell/ddstats/client"
+)
+
+// blockingAPIClient blocks sending series until unblock is closed.
+type blockingAPIClient struct {
+	*TestAPIClient
+	unblock chan bool
+}
+
+func (b *blockingAPIClient) SendSeries(series *client.DDMetricSeries) error {
+	<-b.unblock
+	return b.TestAPIClient.SendSeries(series)
+}
+
+func TestStats_WithShutdownTimeout(t *testing.T) {
+
+	dir, err := ioutil.TempDir("", "ddstats-shutdown")
+	if err != nil {
+		t.Fatalf(err.Error())
+	}
+	defer os.RemoveAll(dir)
+
+	testApi := &blockingAPIClient{TestAPIClient: NewTestAPIClient(), unblock: make(chan bool)}
+	defer close(testApi.unblock)
+	cfg := NewConfig().
+		WithNamespace(testNamespace).
+		WithHost(testHost).
+		WithClient(testApi).
+		WithSpool(dir, DefaultSpoolMaxBytes, DefaultSpoolMaxAge).
+		WithShutdownTimeout(time.Millisecond * 50)
+	stats, err := NewStats(cfg)
+	if err != nil {
+		t.Fatalf(err.Error())
+	}
+
+	var lock sync.Mutex
+	var callbackErr error
+	var unsent []*client.DDMetric
+	stats.ErrorCallback(func(err error, metricSeries []*client.DDMetric) {
+		lock.Lock()
+		defer lock.Unlock()
+		callbackErr = err
+		unsent = metricSeries
+	})
+
+	stats.Gauge("test", 1, nil)
+	closed := make(chan bool)
+	go func() {
+		stats.Close()
+		close(closed)
+	}()
+	select {
+	case <-closed:
+	case <-time.After(time.Second * 5):
+		t.Fatalf("expected close to return after the shutdown timeout")
+	}
+
+	lock.Lock()
+	defer lock.Unlock()
+	if callbackErr != ErrShutdownTimeout {
+		t.Fatalf("expected error callback with %v, have %v", ErrShutdownTimeout, callbackErr)
+	}
+	if len(unsent) != 1 || unsent[0].Metric != prependNamespace(testNamespace, "test") {
+		t.Fatalf("expected unsent series to be passed to the error callback, have %v", unsent)
+	}
+
+	spool, err := newDirSpool(dir, DefaultSpoolMaxBytes, DefaultSpoolMaxAge)
+	if err != nil {
+		t.Fatalf(err.Error())
+	}
+	key, spooled := nextSpooled(t, spool)
+	if key == "" || len(spooled) != 1 {
+		t.Fatalf("expected unsent series to be spooled, have %v", spooled)
+	}
+}
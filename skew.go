@@ -0,0 +1,40 @@
+package ddstats
+
+import (
+	"time"
+
+	"github.com/jmizell/ddstats/client"
+)
+
+// MetricClockSkew is the name of the clock skew gauge, this is prepended with the
+// namespace. Skew is reported in seconds.
+const MetricClockSkew = "ddstats.clock_skew"
+
+// ClockSkew returns the estimated clock skew between the host, and Datadog, as Datadog
+// time minus host time. A positive skew means the host clock is behind. Points too far
+// in the future, or past are silently rejected by the api, so a large skew means metrics
+// may be lost. The estimate is taken from the Date header of api responses, and has a
+// resolution of about a second. The second value is false if the api client doesn't
+// support skew estimates, or no response has been received.
+func (c *Stats) ClockSkew() (time.Duration, bool) {
+	apiClient := c.client
+	if pending, ok := apiClient.(*pendingClient); ok {
+		apiClient = pending.getClient()
+	}
+	reporter, ok := apiClient.(client.ClockSkewReporter)
+	if !ok {
+		return 0, false
+	}
+	return reporter.ClockSkew()
+}
+
+// collectClockSkew reports the clock skew as a gauge, if enabled, and an estimate is
+// available.
+func (c *Stats) collectClockSkew() {
+	if !c.clockSkewMetric {
+		return
+	}
+	if skew, ok := c.ClockSkew(); ok {
+		c.Gauge(MetricClockSkew, skew.Seconds(), c.runtimeTags)
+	}
+}
@@ -0,0 +1,61 @@
+package ddstats
+
+import (
+	"testing"
+	"time"
+)
+
+type testSkewClient struct {
+	*TestAPIClient
+	skew time.Duration
+}
+
+func (t *testSkewClient) ClockSkew() (time.Duration, bool) {
+	return t.skew, true
+}
+
+func TestStats_ClockSkew(t *testing.T) {
+
+	t.Run("unsupported", func(tt *testing.T) {
+		stats, _, err := NewTestStats()
+		if err != nil {
+			tt.Fatalf(err.Error())
+		}
+		defer stats.Close()
+		if _, ok := stats.ClockSkew(); ok {
+			tt.Fatalf("expected no skew estimate from client without support")
+		}
+	})
+
+	t.Run("metric", func(tt *testing.T) {
+		testApi := &testSkewClient{TestAPIClient: NewTestAPIClient(), skew: -time.Second * 3}
+		stats, err := NewStats(NewConfig().
+			WithNamespace(testNamespace).
+			WithHost(testHost).
+			WithClient(testApi).
+			WithClockSkewMetric(true))
+		if err != nil {
+			tt.Fatalf(err.Error())
+		}
+
+		if skew, ok := stats.ClockSkew(); !ok || skew != -time.Second*3 {
+			tt.Fatalf("expected skew to be %s, have %s", -time.Second*3, skew)
+		}
+		stats.Flush()
+		stats.Close()
+
+		testApi.lock.Lock()
+		defer testApi.lock.Unlock()
+		for _, series := range testApi.series {
+			for _, m := range series.Series {
+				if m.Metric == prependNamespace(testNamespace, MetricClockSkew) {
+					if m.Points[0][1] != -3.0 {
+						tt.Fatalf("expected skew gauge to be %f, have %v", -3.0, m.Points[0][1])
+					}
+					return
+				}
+			}
+		}
+		tt.Fatalf("expected %s metric to be sent", MetricClockSkew)
+	})
+}
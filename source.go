@@ -0,0 +1,63 @@
+package ddstats
+
+import (
+	"fmt"
+	"path/filepath"
+	"runtime"
+	"strings"
+	"sync"
+)
+
+// SourceTagKey is the key of the tag added with the call site of each update, when source
+// tags are enabled.
+const SourceTagKey = "source"
+
+// packagePrefix is the function name prefix of the functions in this package, frames of
+// these functions are skipped when finding the call site.
+const packagePrefix = "github.com/jmizell/ddstats."
+
+// maxSourceDepth is the max number of frames searched for the call site.
+const maxSourceDepth = 16
+
+// sourceTags maps a program counter to the source tag of its call site. Program counters
+// of functions in this package map to an empty string.
+var sourceTags sync.Map
+
+// sourceTag returns the source:<dir>/<file>:<line> tag of the first caller outside of this
+// package, or an empty string if none is found. Each program counter is resolved once, and
+// cached, so the cost after the first update from a call site is a stack walk, and a few
+// map lookups.
+func sourceTag() string {
+
+	var pcs [maxSourceDepth]uintptr
+	n := runtime.Callers(2, pcs[:])
+	for _, pc := range pcs[:n] {
+		tag, ok := sourceTags.Load(pc)
+		if !ok {
+			tag = resolveSourceTag(pc)
+			sourceTags.Store(pc, tag)
+		}
+		if tag != "" {
+			return tag.(string)
+		}
+	}
+	return ""
+}
+
+// resolveSourceTag returns the source tag of pc, or an empty string if pc is in this
+// package. Tests of this package count as callers.
+func resolveSourceTag(pc uintptr) string {
+
+	frames := runtime.CallersFrames([]uintptr{pc})
+	for {
+		frame, more := frames.Next()
+		internal := strings.HasPrefix(frame.Function, packagePrefix) && !strings.HasSuffix(frame.File, "_test.go")
+		if !internal && frame.File != "" {
+			file := filepath.Join(filepath.Base(filepath.Dir(frame.File)), filepath.Base(frame.File))
+			return fmt.Sprintf("%s:%s:%d", SourceTagKey, filepath.ToSlash(file), frame.Line)
+		}
+		if !more {
+			return ""
+		}
+	}
+}
@@ -0,0 +1,39 @@
+package ddstats
+
+import (
+	"fmt"
+	"runtime"
+	"strings"
+	"testing"
+)
+
+func TestStats_SourceTags(t *testing.T) {
+
+	testApi := NewTestAPIClient()
+	stats, err := NewStats(NewConfig().
+		WithNamespace(testNamespace).
+		WithHost(testHost).
+		WithClient(testApi).
+		WithSourceTags(true))
+	if err != nil {
+		t.Fatalf(err.Error())
+	}
+
+	_, _, line, _ := runtime.Caller(0)
+	stats.Increment("test", nil)
+	stats.Close()
+
+	// The tag has the directory of the file, which depends on where the module is
+	suffix := fmt.Sprintf("/source_test.go:%d", line+1)
+	testApi.lock.Lock()
+	defer testApi.lock.Unlock()
+	if len(testApi.series) != 1 || len(testApi.series[0].Series) != 1 {
+		t.Fatalf("expected %d metric to be sent", 1)
+	}
+	for _, tag := range testApi.series[0].Series[0].Tags {
+		if strings.HasPrefix(tag, SourceTagKey+":") && strings.HasSuffix(tag, suffix) {
+			return
+		}
+	}
+	t.Fatalf("expected metric to have a source tag ending in %s, have %v", suffix, testApi.series[0].Series[0].Tags)
+}
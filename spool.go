@@ -0,0 +1,239 @@
+package ddstats
+
+import (
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/jmizell/ddstats/client"
+)
+
+// Default values for the failed flush spool
+const (
+	DefaultSpoolMaxBytes = 64 * 1024 * 1024
+	DefaultSpoolMaxAge   = time.Hour // Datadog rejects points more than an hour old
+)
+
+// spoolReplayMax is the number of spooled flushes replayed after each successful flush.
+const spoolReplayMax = 10
+
+// SpoolStore stores the series of failed flushes, so they can be sent on a later flush.
+// The spool directory is the default store, a custom store, such as one backed by S3, or
+// Redis, can be set with Config.WithSpoolStore for containers without a writable disk.
+type SpoolStore interface {
+	// Put stores a series.
+	Put(series []*client.DDMetric) error
+
+	// Iterate calls fn with each stored series, and the key to delete it with, oldest
+	// first, until fn returns false. fn may call Delete with the key.
+	Iterate(fn func(key string, series []*client.DDMetric) bool) error
+
+	// Delete removes the series stored with key.
+	Delete(key string) error
+}
+
+// dirSpool is a SpoolStore that writes each series to a JSON file in a directory. The
+// oldest files are removed to keep the directory under a max size, and files older than
+// a max age are discarded.
+type dirSpool struct {
+	dir      string
+	maxBytes int64
+	maxAge   time.Duration
+	sequence int64
+	lock     *sync.Mutex
+}
+
+func newDirSpool(dir string, maxBytes int64, maxAge time.Duration) (*dirSpool, error) {
+	if err := os.MkdirAll(dir, 0755); err != nil {
+		return nil, fmt.Errorf("could not create spool directory, %s", err.Error())
+	}
+	return &dirSpool{
+		dir:      dir,
+		maxBytes: maxBytes,
+		maxAge:   maxAge,
+		lock:     &sync.Mutex{},
+	}, nil
+}
+
+func (s *dirSpool) Put(series []*client.DDMetric) error {
+
+	data, err := json.Marshal(series)
+	if err != nil {
+		return fmt.Errorf("could not marshal spooled series, %s", err.Error())
+	}
+
+	s.lock.Lock()
+	defer s.lock.Unlock()
+
+	// Files are named with the time they were spooled, and a sequence number, so they
+	// sort oldest first
+	s.sequence++
+	name := fmt.Sprintf("%020d-%06d.json", time.Now().UnixNano(), s.sequence%1000000)
+	tmp := filepath.Join(s.dir, "."+name)
+	if err := ioutil.WriteFile(tmp, data, 0644); err != nil {
+		return fmt.Errorf("could not write spool file, %s", err.Error())
+	}
+	if err := os.Rename(tmp, filepath.Join(s.dir, name)); err != nil {
+		return fmt.Errorf("could not write spool file, %s", err.Error())
+	}
+
+	return s.prune()
+}
+
+func (s *dirSpool) Iterate(fn func(key string, series []*client.DDMetric) bool) error {
+
+	s.lock.Lock()
+	files, err := s.files()
+	s.lock.Unlock()
+	if err != nil {
+		return err
+	}
+
+	for _, f := range files {
+		path := filepath.Join(s.dir, f.Name())
+		if s.expired(f.Name()) {
+			_ = os.Remove(path)
+			continue
+		}
+
+		data, err := ioutil.ReadFile(path)
+		if os.IsNotExist(err) {
+			// Removed by prune since the directory was read
+			continue
+		} else if err != nil {
+			return fmt.Errorf("could not read spool file, %s", err.Error())
+		}
+		var series []*client.DDMetric
+		if err := json.Unmarshal(data, &series); err != nil {
+			// A corrupt file would block the spool, it's discarded
+			_ = os.Remove(path)
+			continue
+		}
+		if !fn(f.Name(), series) {
+			return nil
+		}
+	}
+
+	return nil
+}
+
+func (s *dirSpool) Delete(key string) error {
+	if err := os.Remove(filepath.Join(s.dir, filepath.Base(key))); err != nil && !os.IsNotExist(err) {
+		return fmt.Errorf("could not delete spool file, %s", err.Error())
+	}
+	return nil
+}
+
+// files returns the spool files, oldest first.
+func (s *dirSpool) files() ([]os.FileInfo, error) {
+
+	entries, err := ioutil.ReadDir(s.dir)
+	if err != nil {
+		return nil, fmt.Errorf("could not read spool directory, %s", err.Error())
+	}
+
+	files := entries[:0]
+	for _, f := range entries {
+		if f.Mode().IsRegular() && !strings.HasPrefix(f.Name(), ".") && strings.HasSuffix(f.Name(), ".json") {
+			files = append(files, f)
+		}
+	}
+	sort.Slice(files, func(i, j int) bool {
+		return files[i].Name() < files[j].Name()
+	})
+
+	return files, nil
+}
+
+// prune removes the oldest files until the spool is under the max size.
+func (s *dirSpool) prune() error {
+
+	if s.maxBytes <= 0 {
+		return nil
+	}
+	files, err := s.files()
+	if err != nil {
+		return err
+	}
+
+	var size int64
+	for _, f := range files {
+		size += f.Size()
+	}
+	for i := 0; size > s.maxBytes && i < len(files); i++ {
+		if err := os.Remove(filepath.Join(s.dir, files[i].Name())); err != nil {
+			return fmt.Errorf("could not remove spool file, %s", err.Error())
+		}
+		size -= files[i].Size()
+	}
+
+	return nil
+}
+
+// expired returns true if the file name is older than the max age.
+func (s *dirSpool) expired(name string) bool {
+	if s.maxAge <= 0 {
+		return false
+	}
+	i := strings.Index(name, "-")
+	if i < 0 {
+		return false
+	}
+	spooled, err := strconv.ParseInt(name[:i], 10, 64)
+	if err != nil {
+		return false
+	}
+	return time.Since(time.Unix(0, spooled)) > s.maxAge
+}
+
+// spoolFlush stores the series of a failed flush, or after a successful flush, replays
+// stored series. Series are replayed oldest first, until a send fails. It returns the
+// number of replay attempts.
+func (c *Stats) spoolFlush(series []*client.DDMetric, sendErr error) (retries int, err error) {
+
+	if sendErr != nil {
+		if len(series) == 0 {
+			return 0, nil
+		}
+		c.log().Infof("spooling %d series from the failed flush", len(series))
+		return 0, c.spool.Put(series)
+	}
+
+	replayed := 0
+	iterErr := c.spool.Iterate(func(key string, spooled []*client.DDMetric) bool {
+		if replayed >= spoolReplayMax {
+			return false
+		}
+		replayed++
+		if len(spooled) > 0 {
+			retries++
+			c.recordRetry()
+			c.log().Debugf("resending %d spooled series", len(spooled))
+			err := c.withinShutdownTimeout(func() error {
+				return c.client.SendSeries(&client.DDMetricSeries{Series: spooled})
+			})
+			if err != nil {
+				c.recordAPIError()
+				c.log().Warnf("could not resend spooled series, %s", err.Error())
+				// The series stays spooled, and is retried after the next successful flush
+				return false
+			}
+		}
+		if err = c.spool.Delete(key); err != nil {
+			return false
+		}
+		return true
+	})
+	if err == nil {
+		err = iterErr
+	}
+
+	return retries, err
+}
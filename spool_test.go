@@ -0,0 +1,206 @@
+package ddstats
+
+import (
+	"fmt"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/jmizell/ddstats/client"
+)
+
+func TestStats_Spool(t *testing.T) {
+
+	dir, err := ioutil.TempDir("", "ddstats-spool")
+	if err != nil {
+		t.Fatalf(err.Error())
+	}
+	defer os.RemoveAll(dir)
+
+	testClient := NewTestAPIClient()
+	stats, err := NewStats(NewConfig().
+		WithNamespace(testNamespace).
+		WithHost(testHost).
+		WithClient(testClient).
+		WithSpool(dir, DefaultSpoolMaxBytes, DefaultSpoolMaxAge))
+	if err != nil {
+		t.Fatalf(err.Error())
+	}
+
+	testClient.sendSeriesError = fmt.Errorf("api unreachable")
+	stats.Count("failed", 1, nil)
+	stats.Flush()
+
+	files, _ := ioutil.ReadDir(dir)
+	if len(files) != 1 {
+		t.Fatalf("expected %d spool file, have %d", 1, len(files))
+	}
+
+	testClient.lock.Lock()
+	testClient.sendSeriesError = nil
+	testClient.lock.Unlock()
+	stats.Count("succeeded", 1, nil)
+	stats.Close()
+
+	if len(testClient.series) != 3 {
+		t.Fatalf("expected %d series calls, have %d", 3, len(testClient.series))
+	}
+	if replayed := testClient.series[2].Series[0].Metric; replayed != "testNamespace.failed" {
+		t.Fatalf("expected replayed metric %s, have %s", "testNamespace.failed", replayed)
+	}
+	if files, _ := ioutil.ReadDir(dir); len(files) != 0 {
+		t.Fatalf("expected spool to be empty, have %d files", len(files))
+	}
+}
+
+func TestDirSpool(t *testing.T) {
+
+	dir, err := ioutil.TempDir("", "ddstats-spool")
+	if err != nil {
+		t.Fatalf(err.Error())
+	}
+	defer os.RemoveAll(dir)
+
+	series := func(name string) []*client.DDMetric {
+		return []*client.DDMetric{{Metric: name, Points: [][2]interface{}{{1, 1}}}}
+	}
+
+	t.Run("max bytes", func(tt *testing.T) {
+		spool, err := newDirSpool(filepath.Join(dir, "max_bytes"), 200, 0)
+		if err != nil {
+			tt.Fatalf(err.Error())
+		}
+		for _, name := range []string{"first", "second", "third"} {
+			if err := spool.Put(series(name)); err != nil {
+				tt.Fatalf(err.Error())
+			}
+		}
+
+		key, next := nextSpooled(tt, spool)
+		if next[0].Metric != "second" {
+			tt.Fatalf("expected oldest series to be removed, have %s", next[0].Metric)
+		}
+		if err := spool.Delete(key); err != nil {
+			tt.Fatalf(err.Error())
+		}
+		if _, next := nextSpooled(tt, spool); next[0].Metric != "third" {
+			tt.Fatalf("expected %s, have %s", "third", next[0].Metric)
+		}
+	})
+
+	t.Run("max age", func(tt *testing.T) {
+		spool, err := newDirSpool(filepath.Join(dir, "max_age"), 0, time.Millisecond)
+		if err != nil {
+			tt.Fatalf(err.Error())
+		}
+		if err := spool.Put(series("expired")); err != nil {
+			tt.Fatalf(err.Error())
+		}
+		time.Sleep(time.Millisecond * 5)
+
+		if key, _ := nextSpooled(tt, spool); key != "" {
+			tt.Fatalf("expected expired series to be discarded, have %s", key)
+		}
+	})
+}
+
+// nextSpooled returns the oldest series in store, and its key, or an empty key if the
+// store is empty.
+func nextSpooled(tb testing.TB, store SpoolStore) (string, []*client.DDMetric) {
+	var key string
+	var series []*client.DDMetric
+	err := store.Iterate(func(k string, s []*client.DDMetric) bool {
+		key, series = k, s
+		return false
+	})
+	if err != nil {
+		tb.Fatalf(err.Error())
+	}
+	return key, series
+}
+
+// memorySpool is a SpoolStore kept in memory, standing in for a custom store.
+type memorySpool struct {
+	keys   []string
+	series map[string][]*client.DDMetric
+	lock   sync.Mutex
+}
+
+func (s *memorySpool) Put(series []*client.DDMetric) error {
+	s.lock.Lock()
+	defer s.lock.Unlock()
+	key := fmt.Sprintf("%d", len(s.keys))
+	s.keys = append(s.keys, key)
+	s.series[key] = series
+	return nil
+}
+
+func (s *memorySpool) Iterate(fn func(key string, series []*client.DDMetric) bool) error {
+	s.lock.Lock()
+	keys := append([]string(nil), s.keys...)
+	s.lock.Unlock()
+	for _, key := range keys {
+		s.lock.Lock()
+		series, ok := s.series[key]
+		s.lock.Unlock()
+		if ok && !fn(key, series) {
+			return nil
+		}
+	}
+	return nil
+}
+
+func (s *memorySpool) Delete(key string) error {
+	s.lock.Lock()
+	defer s.lock.Unlock()
+	delete(s.series, key)
+	return nil
+}
+
+func TestStats_SpoolStore(t *testing.T) {
+
+	store := &memorySpool{series: map[string][]*client.DDMetric{}}
+	testClient := NewTestAPIClient()
+	stats, err := NewStats(NewConfig().
+		WithNamespace(testNamespace).
+		WithHost(testHost).
+		WithClient(testClient).
+		WithSpoolStore(store))
+	if err != nil {
+		t.Fatalf(err.Error())
+	}
+
+	testClient.lock.Lock()
+	testClient.sendSeriesError = fmt.Errorf("api unreachable")
+	testClient.lock.Unlock()
+	for _, name := range []string{"first", "second"} {
+		stats.Count(name, 1, nil)
+		stats.Flush()
+	}
+	if key, _ := nextSpooled(t, store); key == "" {
+		t.Fatalf("expected the failed flushes to be spooled")
+	}
+
+	testClient.lock.Lock()
+	testClient.sendSeriesError = nil
+	testClient.lock.Unlock()
+	stats.Count("succeeded", 1, nil)
+	stats.Close()
+
+	testClient.lock.Lock()
+	defer testClient.lock.Unlock()
+	if len(testClient.series) != 5 {
+		t.Fatalf("expected %d series calls, have %d", 5, len(testClient.series))
+	}
+	for i, name := range []string{"first", "second"} {
+		if replayed := testClient.series[3+i].Series[0].Metric; replayed != prependNamespace(testNamespace, name) {
+			t.Fatalf("expected replayed metric %s, have %s", prependNamespace(testNamespace, name), replayed)
+		}
+	}
+	if key, _ := nextSpooled(t, store); key != "" {
+		t.Fatalf("expected the store to be empty, have %s", key)
+	}
+}
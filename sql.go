@@ -0,0 +1,103 @@
+package ddstats
+
+import (
+	"database/sql"
+	"fmt"
+	"time"
+)
+
+// Database metric names, these are prepended with the namespace. Wait duration, and query
+// duration are reported in seconds, or the configured duration unit.
+const (
+	MetricSQLMaxOpen           = "sql.pool.max_open"
+	MetricSQLOpen              = "sql.pool.open"
+	MetricSQLInUse             = "sql.pool.in_use"
+	MetricSQLIdle              = "sql.pool.idle"
+	MetricSQLWaitCount         = "sql.pool.wait_count"
+	MetricSQLWaitDuration      = "sql.pool.wait_duration"
+	MetricSQLMaxIdleClosed     = "sql.pool.max_idle_closed"
+	MetricSQLMaxLifetimeClosed = "sql.pool.max_lifetime_closed"
+	MetricSQLQuery             = "sql.query"
+	MetricSQLQueryErrors       = "sql.query.errors"
+)
+
+// DBStatser is implemented by *sql.DB.
+type DBStatser interface {
+	Stats() sql.DBStats
+}
+
+type registeredDB struct {
+	db   DBStatser
+	tags []string
+	last sql.DBStats
+}
+
+// RegisterDB registers a database connection pool, whose stats are reported on every
+// flush. The number of open, in use, and idle connections, and the max open connections
+// are reported as gauges. The number of waits for a connection, the time spent waiting,
+// and the number of connections closed by the idle, and lifetime limits are reported as
+// counts, of the change since the last flush. All metrics are tagged with db:<name>, and
+// tags.
+func (c *Stats) RegisterDB(name string, db DBStatser, tags []string) {
+	c.dbLock.Lock()
+	defer c.dbLock.Unlock()
+	c.dbs = append(c.dbs, &registeredDB{
+		db:   db,
+		tags: append([]string{fmt.Sprintf("db:%s", name)}, tags...),
+		last: db.Stats(),
+	})
+}
+
+// collectDBStats reports the stats of all registered databases.
+func (c *Stats) collectDBStats() {
+
+	c.dbLock.Lock()
+	defer c.dbLock.Unlock()
+
+	for _, r := range c.dbs {
+		s := r.db.Stats()
+		c.Gauge(MetricSQLMaxOpen, float64(s.MaxOpenConnections), r.tags)
+		c.Gauge(MetricSQLOpen, float64(s.OpenConnections), r.tags)
+		c.Gauge(MetricSQLInUse, float64(s.InUse), r.tags)
+		c.Gauge(MetricSQLIdle, float64(s.Idle), r.tags)
+		c.Count(MetricSQLWaitCount, float64(s.WaitCount-r.last.WaitCount), r.tags)
+		c.Count(MetricSQLWaitDuration, durationValue(s.WaitDuration-r.last.WaitDuration, c.durationUnit), r.tags)
+		c.Count(MetricSQLMaxIdleClosed, float64(s.MaxIdleClosed-r.last.MaxIdleClosed), r.tags)
+		c.Count(MetricSQLMaxLifetimeClosed, float64(s.MaxLifetimeClosed-r.last.MaxLifetimeClosed), r.tags)
+		r.last = s
+	}
+}
+
+// QueryTimer measures the duration of a database query, see NewQueryTimer.
+type QueryTimer struct {
+	stats *Stats
+	tags  []string
+	start time.Time
+}
+
+// NewQueryTimer starts a timer for a query identified by label. When the timer is stopped
+// the duration is recorded with Timing, and failed queries are counted, tagged with
+// query:<label>, and tags. Labels should be low cardinality names, such as get_user, not
+// the query text.
+//
+//	timer := stats.NewQueryTimer("get_user", nil)
+//	err := db.QueryRowContext(ctx, query, id).Scan(&user.Name)
+//	timer.Stop(err)
+func (c *Stats) NewQueryTimer(label string, tags []string) *QueryTimer {
+	return &QueryTimer{
+		stats: c,
+		tags:  append([]string{fmt.Sprintf("query:%s", label)}, tags...),
+		start: time.Now(),
+	}
+}
+
+// Stop records the time elapsed since the timer was started, and returns it. If err is
+// not nil, or sql.ErrNoRows, the query is counted as an error.
+func (t *QueryTimer) Stop(err error) time.Duration {
+	d := time.Since(t.start)
+	t.stats.Timing(MetricSQLQuery, d, t.tags)
+	if err != nil && err != sql.ErrNoRows {
+		t.stats.Increment(MetricSQLQueryErrors, t.tags)
+	}
+	return d
+}
@@ -0,0 +1,116 @@
+package ddstats
+
+import (
+	"database/sql"
+	"fmt"
+	"testing"
+	"time"
+)
+
+type testDB struct {
+	stats sql.DBStats
+}
+
+func (t *testDB) Stats() sql.DBStats {
+	return t.stats
+}
+
+func TestStats_RegisterDB(t *testing.T) {
+
+	stats, testApi, err := NewTestStats()
+	if err != nil {
+		t.Fatalf(err.Error())
+	}
+
+	db := &testDB{stats: sql.DBStats{WaitCount: 10, WaitDuration: time.Second}}
+	stats.RegisterDB("users", db, []string{"env:test", "app:api"})
+	db.stats = sql.DBStats{
+		MaxOpenConnections: 20,
+		OpenConnections:    8,
+		InUse:              5,
+		Idle:               3,
+		WaitCount:          14,
+		WaitDuration:       time.Second * 3,
+	}
+	stats.Flush()
+	stats.Close()
+
+	expected := map[string]float64{
+		MetricSQLMaxOpen:      20,
+		MetricSQLOpen:         8,
+		MetricSQLInUse:        5,
+		MetricSQLIdle:         3,
+		MetricSQLWaitCount:    4,
+		MetricSQLWaitDuration: 2,
+	}
+	testApi.lock.Lock()
+	defer testApi.lock.Unlock()
+	found := map[string]bool{}
+	for _, m := range testApi.series[0].Series {
+		for name, value := range expected {
+			if m.Metric != prependNamespace(testNamespace, name) {
+				continue
+			}
+			found[name] = true
+			if m.Points[0][1] != value {
+				t.Fatalf("expected %s to be %f, have %v", name, value, m.Points[0][1])
+			}
+			if !hasTag(m.Tags, "db:users") || !hasTag(m.Tags, "env:test") {
+				t.Fatalf("expected %s to be tagged with db, and env, have %v", name, m.Tags)
+			}
+		}
+	}
+	for name := range expected {
+		if !found[name] {
+			t.Fatalf("expected %s metric to be sent", name)
+		}
+	}
+
+	// The registered tags are submitted for each metric, and must not be sorted by the
+	// workers
+	if tags := stats.dbs[0].tags; tags[0] != "db:users" || tags[2] != "app:api" {
+		t.Fatalf("expected the registered tags to keep their order, have %v", tags)
+	}
+}
+
+func TestQueryTimer(t *testing.T) {
+
+	stats, testApi, err := NewTestStats()
+	if err != nil {
+		t.Fatalf(err.Error())
+	}
+
+	stats.NewQueryTimer("get_user", nil).Stop(nil)
+	stats.NewQueryTimer("get_user", nil).Stop(sql.ErrNoRows)
+	timer := stats.NewQueryTimer("get_user", []string{"app:api"})
+	timer.Stop(fmt.Errorf("connection reset"))
+	stats.Close()
+
+	// The timer tags are submitted with the timing, and the error count, and must not be
+	// sorted by the workers
+	if timer.tags[0] != "query:get_user" || timer.tags[1] != "app:api" {
+		t.Fatalf("expected the timer tags to keep their order, have %v", timer.tags)
+	}
+
+	testApi.lock.Lock()
+	defer testApi.lock.Unlock()
+	values := map[string]float64{}
+	for _, m := range testApi.series[0].Series {
+		values[m.Metric] += m.Points[0][1].(float64)
+	}
+	if values[prependNamespace(testNamespace, MetricSQLQuery+".count")] != 3.0 {
+		t.Fatalf("expected %d queries to be timed, have %v", 3, values)
+	}
+	if values[prependNamespace(testNamespace, MetricSQLQueryErrors)] != 1.0 {
+		t.Fatalf("expected %d query error, have %v", 1, values)
+	}
+}
+
+func hasTag(tags []string, tag string) bool {
+	for _, t := range tags {
+		if t == tag {
+			return true
+		}
+	}
+	return false
+}
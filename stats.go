@@ -1,75 +1,382 @@
 package ddstats
 
 import (
+	"context"
 	"fmt"
-	"hash/fnv"
-	"sort"
 	"strings"
 	"sync"
 	"sync/atomic"
 	"time"
 
 	"github.com/jmizell/ddstats/client"
+	"github.com/jmizell/ddstats/internal/engine"
 )
 
 type job struct {
 	metric   *metric
 	shutdown bool
 	flush    bool
+	group    int             // Flush interval group to flush, see flushAllGroups
+	done     chan bool       // Closed once the sends of a flush, or shutdown job complete
+	ctx      context.Context // Cancels the sends of a flush, or shutdown job, nil if they can't be cancelled
+}
+
+// context returns the context of the job's sends.
+func (j *job) context() context.Context {
+	if j.ctx == nil {
+		return context.Background()
+	}
+	return j.ctx
+}
+
+// flushOrder chains the sends of consecutive flushes. A send waits for the previous
+// send to complete before handling errors, and invoking callbacks.
+type flushOrder struct {
+	prev chan bool
+	done chan bool
+}
+
+func (o *flushOrder) wait() {
+	if o != nil && o.prev != nil {
+		<-o.prev
+	}
+}
+
+func (o *flushOrder) complete() {
+	if o != nil {
+		o.wait()
+		close(o.done)
+	}
 }
 
 type Stats struct {
-	namespace       string
-	host            string
-	tags            []string
-	flushInterval   time.Duration
-	workerCount     int
-	workerBuffer    int
-	metricBuffer    int
-	client          client.APIClient
-	metrics         []map[string]*metric
-	metricsQueue    []*client.DDMetric
-	metricQueueLock *sync.Mutex
-	jobs            chan *job
-	workers         []chan *job
-	shutdown        bool
-	shutdownLock    *sync.Mutex
-	workerWG        *sync.WaitGroup
-	flushWG         *sync.WaitGroup
-	ready           chan bool
-	flushCallback   func(metricSeries []*client.DDMetric)
-	errorCallback   func(err error, metricSeries []*client.DDMetric)
-	errors          []error
-	maxErrors       int
-	errorLock       *sync.RWMutex
-	dropped         uint64
-	lastFlush       time.Time
+	namespace             string
+	namespaceMode         NamespaceMode
+	host                  string
+	tags                  []string
+	tagsLock              sync.RWMutex
+	flushInterval         time.Duration
+	maxInterval           time.Duration
+	downsample            int64
+	flushFailures         int32
+	workerCount           int
+	workerBuffer          int
+	metricBuffer          int
+	metricsHint           int
+	client                client.APIClient
+	shards                *engine.Shards
+	keptValues            []map[string]keptValue
+	keptValueExpiry       int
+	metricsQueue          []*client.DDMetric
+	metricQueueLock       *sync.Mutex
+	mergedQueue           []*client.DDMetric
+	jobs                  chan *job
+	workers               []chan *job
+	shutdown              bool
+	shutdownLock          *sync.Mutex
+	workerWG              *sync.WaitGroup
+	flushWG               *sync.WaitGroup
+	ready                 chan bool
+	flushCallback         func(metricSeries []*client.DDMetric)
+	filteredCallbacks     []*filteredCallback
+	filteredCallbackLock  sync.Mutex
+	errorCallback         func(err error, metricSeries []*client.DDMetric)
+	errorCallbacks        chan *errorCallbackJob
+	errorCallbackWG       *sync.WaitGroup
+	errorCallbackDropped  uint64
+	negativeCounts        NegativeCountPolicy
+	negativeCountCallback func(name string, tags []string, value float64)
+	errors                []error
+	maxErrors             int
+	dedupErrors           bool
+	errorClasses          []ErrorClass
+	errorCounts           errorCounters
+	errorLock             *sync.RWMutex
+	dropped               uint64
+	lastFlush             time.Time
+	lastSend              chan bool
+	runtimeTags           []string
+	stopCollectors        chan bool
+	collectorWG           *sync.WaitGroup
+	mirror                *fileMirror
+	subscribers           []*subscriber
+	subscriberLock        *sync.Mutex
+	subscribersClosed     bool
+	subscriberDropped     uint64
+	checks                []*registeredCheck
+	checkLock             *sync.Mutex
+	dbs                   []*registeredDB
+	dbLock                *sync.Mutex
+	tenants               *tenantLimiter
+	durationUnit          DurationUnit
+	flushOnStart          bool
+	gaugeAggregation      GaugeAggregation
+	sequence              uint64
+	continuity            *continuityReport
+	maxTagLength          int
+	tagLengthPolicy       TagLengthPolicy
+	tagNormalization      bool
+	metricNamePolicy      MetricNamePolicy
+	nameChecks            map[string]nameCheck
+	invalidNames          uint64
+	longTags              uint64
+	spool                 SpoolStore
+	debugCaptureDir       string
+	site                  string
+	apiBaseURL            string
+	apiKeyHeader          bool
+	transport             client.TransportConfig
+	compression           client.Compression
+	compressionLevel      int
+	compressionThreshold  int
+	maxSeriesPerRequest   int
+	maxPayloadBytes       int
+	debugCaptureMax       int
+	runtimeMetricsPrefix  string
+	processCollector      *processCollector
+	faults                FaultInjector
+	clockSkewMetric       bool
+	rpcInFlight           *rpcInFlight
+	accounting            accounting
+	blockOnFull           bool
+	blockTimeout          time.Duration
+	shutdownTimeout       time.Duration
+	shutdownExpired       chan bool
+	strictSeries          bool
+	droppedMetric         bool
+	telemetryEnabled      bool
+	sourceTags            bool
+	telemetry             telemetry
+	droppedNames          map[string]uint64
+	droppedReported       map[string]uint64
+	droppedLock           sync.Mutex
+	aggregatedKeys        int64
+	healthLock            sync.Mutex
+	lastFlushDone         time.Time
+	lastFlushErr          error
+	lastFlushSuccess      time.Time
+	consecutiveFailures   int
+	healthThreshold       int
+	rateLimit             *rateLimit
+	prometheus            *prometheusBridge
+	logger                Logger
+	clock                 Clock
+	flushErrors           chan *FlushError
+	flushResultCallback   func(result *FlushResult)
+	droppedResult         uint64
+	persistentGauges      map[*PersistentGauge]bool
+	persistentLock        sync.Mutex
+	gaugeFuncs            []*registeredGaugeFunc
+	gaugeFuncLock         sync.Mutex
+	histogramAggregation  *histogramAggregation
+	flushGroups           []*flushGroup
+	flushGroupNames       map[string]int
+	flushErrorsEnabled    int32
+	flushErrorsDropped    uint64
+	droppedLogged         uint64
+	noop                  bool
 }
 
 func NewStats(cfg *Config) (*Stats, error) {
 
+	if cfg.Disabled {
+		cfg = disabledConfig(cfg)
+	}
+
 	s := &Stats{
-		namespace:     cfg.Namespace,
-		host:          cfg.Host,
-		tags:          cfg.Tags,
-		flushInterval: time.Duration(cfg.FlushIntervalSeconds) * time.Second,
-		workerCount:   cfg.WorkerCount,
-		workerBuffer:  cfg.WorkerBuffer,
-		metricBuffer:  cfg.MetricBuffer,
-		maxErrors:     cfg.MaxErrors,
-		ready:         make(chan bool, 1),
+		noop:                 cfg.Disabled,
+		namespace:            cfg.Namespace,
+		namespaceMode:        cfg.NamespaceMode,
+		host:                 cfg.Host,
+		flushInterval:        time.Duration(cfg.FlushIntervalSeconds) * time.Second,
+		maxInterval:          time.Duration(cfg.MaxFlushIntervalSeconds * float64(time.Second)),
+		downsample:           int64(cfg.DownsampleSeconds),
+		workerCount:          cfg.WorkerCount,
+		workerBuffer:         cfg.WorkerBuffer,
+		metricBuffer:         cfg.MetricBuffer,
+		metricsHint:          cfg.ExpectedMetrics,
+		negativeCounts:       cfg.NegativeCounts,
+		durationUnit:         cfg.DurationUnit,
+		flushOnStart:         cfg.FlushOnStart,
+		gaugeAggregation:     cfg.GaugeAggregation,
+		maxTagLength:         cfg.MaxTagLength,
+		tagLengthPolicy:      cfg.TagLengthPolicy,
+		tagNormalization:     cfg.TagNormalization,
+		metricNamePolicy:     cfg.MetricNamePolicy,
+		debugCaptureDir:      cfg.DebugCaptureDir,
+		site:                 cfg.Site,
+		apiBaseURL:           cfg.APIBaseURL,
+		apiKeyHeader:         cfg.APIKeyHeader,
+		transport:            cfg.transportConfig(),
+		compression:          cfg.Compression,
+		compressionLevel:     cfg.CompressionLevel,
+		compressionThreshold: cfg.CompressionThreshold,
+		maxSeriesPerRequest:  cfg.MaxSeriesPerRequest,
+		maxPayloadBytes:      cfg.MaxPayloadBytes,
+		debugCaptureMax:      cfg.DebugCaptureMax,
+		runtimeMetricsPrefix: cfg.RuntimeMetricsPrefix,
+		faults:               cfg.faults,
+		clockSkewMetric:      cfg.ClockSkewMetric,
+		rpcInFlight:          &rpcInFlight{counts: map[string]*int64{}, lock: &sync.Mutex{}},
+		accounting:           newAccounting(),
+		blockOnFull:          cfg.BlockOnFull,
+		blockTimeout:         time.Duration(cfg.BlockTimeoutSeconds * float64(time.Second)),
+		shutdownTimeout:      time.Duration(cfg.ShutdownTimeoutSeconds * float64(time.Second)),
+		shutdownExpired:      make(chan bool),
+		strictSeries:         cfg.StrictSeries,
+		droppedMetric:        cfg.DroppedMetric,
+		telemetryEnabled:     cfg.ClientTelemetry,
+		sourceTags:           cfg.SourceTags,
+		maxErrors:            limitMaxErrors(cfg.MaxErrors),
+		dedupErrors:          cfg.DedupErrors,
+		errorCounts:          newErrorCounters(),
+		ready:                make(chan bool, 1),
+		stopCollectors:       make(chan bool),
+		collectorWG:          &sync.WaitGroup{},
+		subscriberLock:       &sync.Mutex{},
+		checkLock:            &sync.Mutex{},
+		dbLock:               &sync.Mutex{},
+		errorCallbackWG:      &sync.WaitGroup{},
+		logger:               cfg.logger,
+		clock:                cfg.clock,
+	}
+	if s.clock == nil {
+		s.clock = systemClock{}
+	}
+
+	s.flushGroups, s.flushGroupNames = newFlushGroups(cfg.FlushIntervals, s.flushInterval)
+
+	if cfg.HistogramAggregates != nil || cfg.HistogramPercentiles != nil {
+		h, err := newHistogramAggregation(cfg.HistogramAggregates, cfg.HistogramPercentiles)
+		if err != nil {
+			return nil, err
+		}
+		s.histogramAggregation = h
+	}
+
+	if s.maxTagLength <= 0 {
+		s.maxTagLength = DefaultMaxTagLength
+	}
+
+	tags, tagErrs := NormalizeTags(cfg.Tags)
+	if cfg.StrictTags && len(tagErrs) > 0 {
+		return nil, fmt.Errorf("invalid global tags, %s", joinErrors(tagErrs))
+	}
+	var cloud *cloudHost
+	if cfg.CloudHostDetection {
+		if cloud = detectCloudHost(DefaultCloudDetectionTimeout); cloud != nil {
+			tags = append(tags, cloud.tags()...)
+		}
+	}
+	s.host = resolveHost(s.host, cloud)
+	var containerID string
+	if cfg.ContainerTags {
+		containerID = detectContainerID()
+		tags = append(tags, containerTags(containerID)...)
+	}
+	s.tags = s.normalizeGlobalTags(tags)
+
+	if cfg.RuntimeTags {
+		s.runtimeTags = runtimeTags()
+	}
+	if cfg.ProcessMetrics {
+		s.processCollector = newProcessCollector("/proc/self")
+	}
+
+	if cfg.Prometheus {
+		s.prometheus = newPrometheusBridge(cfg.PrometheusBuckets)
+	}
+
+	if cfg.TenantMaxSeries > 0 || cfg.TenantMaxPoints > 0 {
+		s.tenants = newTenantLimiter(cfg.TenantTagKey, cfg.TenantMaxSeries, cfg.TenantMaxPoints)
 	}
 
 	if cfg.client != nil {
 		s.client = cfg.client
 	} else if cfg.APIKey != "" {
-		s.client = client.NewDDClient(cfg.APIKey)
+		ddClient, err := s.newDDClient(cfg.APIKey)
+		if err != nil {
+			return nil, err
+		}
+		s.client = ddClient
+	} else if cfg.MissingAPIKey == MissingAPIKeyBuffer || cfg.MissingAPIKey == MissingAPIKeyNoop {
+		s.client = newPendingClient(cfg.MissingAPIKey, cfg.MissingAPIKeyBuffer)
 	} else {
 		return nil, fmt.Errorf("no client configured")
 	}
 
+	if containerID != "" {
+		if origin, ok := s.client.(interface{ SetContainerID(string) }); ok {
+			origin.SetContainerID(containerID)
+		}
+	}
+
+	if cfg.DebugCaptureDir != "" {
+		if capture, ok := s.client.(interface{ SetDebugCapture(string, int) }); ok {
+			capture.SetDebugCapture(cfg.DebugCaptureDir, cfg.DebugCaptureMax)
+		}
+	}
+
+	if cfg.spool != nil {
+		s.spool = cfg.spool
+	} else if cfg.SpoolDir != "" {
+		spool, err := newDirSpool(
+			cfg.SpoolDir,
+			cfg.SpoolMaxBytes,
+			time.Duration(cfg.SpoolMaxAgeSeconds*float64(time.Second)))
+		if err != nil {
+			return nil, err
+		}
+		s.spool = spool
+	}
+
+	if cfg.MirrorFile != "" {
+		mirror, err := newFileMirror(
+			cfg.MirrorFile,
+			cfg.MirrorMaxBytes,
+			time.Duration(cfg.MirrorMaxAgeSeconds*float64(time.Second)),
+			cfg.MirrorMaxBackups)
+		if err != nil {
+			return nil, err
+		}
+		s.mirror = mirror
+	}
+
+	errorCallbackBuffer := cfg.ErrorCallbackBuffer
+	if errorCallbackBuffer <= 0 {
+		errorCallbackBuffer = DefaultErrorCallbackBuffer
+	}
+	s.errorCallbacks = make(chan *errorCallbackJob, errorCallbackBuffer)
+	flushErrorBuffer := cfg.FlushErrorBuffer
+	if flushErrorBuffer <= 0 {
+		flushErrorBuffer = DefaultFlushErrorBuffer
+	}
+	s.flushErrors = make(chan *FlushError, flushErrorBuffer)
+	s.healthThreshold = cfg.HealthFailureThreshold
+	if s.healthThreshold <= 0 {
+		s.healthThreshold = DefaultHealthFailureThreshold
+	}
+	s.keptValueExpiry = cfg.KeptValueExpiry
+	if s.keptValueExpiry <= 0 {
+		s.keptValueExpiry = DefaultKeptValueExpiry
+	}
+	s.errorCallbackWG.Add(1)
+	go s.errorCallbackWorker()
+
+	if cfg.RateLimit > 0 {
+		s.rateLimit = newRateLimit(cfg.RateLimit, cfg.RateLimitBurst, cfg.RateLimitMode, cfg.RateLimitQueue)
+		if s.rateLimit.queue != nil {
+			s.rateLimit.wg.Add(1)
+			go s.rateLimitWorker()
+		}
+	}
+
 	go s.start()
 	s.blockReady()
+
+	if cfg.client == nil && cfg.APIKey == "" && cfg.MissingAPIKey == MissingAPIKeyNoop {
+		s.addError(ErrorClassWarning, fmt.Errorf("no api key configured, metrics are discarded until SetAPIKey is called"))
+	}
 	return s, nil
 }
 
@@ -82,20 +389,26 @@ func (c *Stats) start() {
 
 	// Setup wait group for workers. Flush wait group is separate as
 	// we don't want to block processing new stats, if a flush worker
-	// is running slow.
+	// is running slow. Both are only added to, and waited on by the main
+	// worker thread, other callers wait on the done channel of their job.
 	c.workerWG = &sync.WaitGroup{}
 	c.flushWG = &sync.WaitGroup{}
 
 	// Here we're tracking our errors
 	c.errors = []error{}
+	c.errorClasses = []ErrorClass{}
 	c.errorLock = &sync.RWMutex{}
 
-	// Setup our slice of map metrics. There is a separate map for each worker
-	// so we can avoid locking on storing metrics. This will be zeroed out at
+	// Setup our metric shards. There is a separate shard for each worker
+	// so we can avoid locking on storing metrics. This will be cleared at
 	// each flush cycle.
-	c.metrics = make([]map[string]*metric, c.workerCount)
-	for i := range c.metrics {
-		c.metrics[i] = map[string]*metric{}
+	c.shards = engine.NewShards(c.workerCount, c.workerMetricsHint())
+	for _, g := range c.flushGroups {
+		g.shards = engine.NewShards(c.workerCount, 0)
+	}
+	c.keptValues = make([]map[string]keptValue, c.workerCount)
+	for i := range c.keptValues {
+		c.keptValues[i] = make(map[string]keptValue)
 	}
 
 	// Setup our raw metrics publish queue
@@ -110,18 +423,28 @@ func (c *Stats) start() {
 	}
 
 	// Start the flush worker. This will send a flush signal until given
-	// a shutdown signal.
+	// a shutdown signal. The timers are created before stats is ready, so a
+	// manual clock can't be advanced before they start. Timers are reset
+	// before the flush job is sent, so the next flush is scheduled by the
+	// time the flush completes.
 	shutdownFlushSignalWorker := make(chan bool)
 	flushSignalWorkerWG := &sync.WaitGroup{}
 	flushSignalWorkerWG.Add(1)
+	first := c.EffectiveFlushInterval()
+	if c.flushOnStart && first > DefaultStartFlushInterval {
+		first = DefaultStartFlushInterval
+	}
+	flush := c.clock.NewTimer(first)
+	if c.noop {
+		flush.Stop()
+	}
 	go func() {
 		defer flushSignalWorkerWG.Done()
-		flush := time.NewTicker(c.flushInterval)
 		for {
 			select {
-			case <-flush.C:
-				// Add a job to the flush wait group
-				c.flushWG.Add(1)
+			case <-flush.C():
+				flush.Reset(c.EffectiveFlushInterval())
+				c.beforeFlush()
 				c.jobs <- &job{flush: true}
 			case <-shutdownFlushSignalWorker:
 				flush.Stop()
@@ -129,12 +452,20 @@ func (c *Stats) start() {
 			}
 		}
 	}()
-	c.ready <- true
+	for i, g := range c.flushGroups {
+		flushSignalWorkerWG.Add(1)
+		go c.flushGroupSignal(i+1, c.clock.NewTimer(g.interval), g.interval, shutdownFlushSignalWorker, flushSignalWorkerWG)
+	}
 
 	// We need to track time between flushes. If a flush is called before the scheduled
 	// interval, we will need to know exactly how much time has passed, so we can calculate
 	// our rate metrics.
-	c.lastFlush = time.Now()
+	c.lastFlush = c.clock.Now()
+	for _, g := range c.flushGroups {
+		g.lastFlush = c.lastFlush
+	}
+	c.ready <- true
+
 	for {
 		j, ok := <-c.jobs
 		if !ok {
@@ -146,7 +477,7 @@ func (c *Stats) start() {
 
 			// Perform a final flush of all stats. Anything buffered in the updates channel
 			// will be dropped.
-			c.commitFlush()
+			c.commitFlush(j.context(), flushAllGroups)
 
 			// On shutdown, we'll signal all the workers to exit after completing the current job
 			for i := range c.workers {
@@ -154,52 +485,114 @@ func (c *Stats) start() {
 				c.workers[i] <- &job{shutdown: true}
 			}
 
-			// Signal to the flush worker to shutdown, wait before returning
-			shutdownFlushSignalWorker <- true
+			// Signal to the flush workers to shutdown, wait before returning
+			close(shutdownFlushSignalWorker)
 			flushSignalWorkerWG.Wait()
 
 			// Wait for all workers, and flush to complete
 			c.workerWG.Wait()
 			c.flushWG.Wait()
+			close(j.done)
 
 			return
 		case j.flush:
-			// Copy out the metrics for this interval, and send them
-			c.commitFlush()
+			// Copy out the metrics for this interval, and send them. Sends are chained,
+			// once the last send completes, so have all previous sends.
+			c.commitFlush(j.context(), j.group)
+			if j.done != nil {
+				go func(last, done chan bool) {
+					<-last
+					close(done)
+				}(c.lastSend, j.done)
+			}
 		case j.metric != nil:
+			if !c.checkName(j.metric) {
+				continue
+			}
+			if c.flushGroupNames != nil {
+				j.metric.group = c.flushGroupNames[j.metric.name]
+			}
+
 			// New metric has been sent, we want to add a job to the wait group, and
 			// then we assign it to the worker by using a FNV-1a hash. This should ensure
 			// that the same worker always sees the same metric.
 			c.workerWG.Add(1)
-			c.workers[fnv1a(j.metric.name)%uint32(len(c.workers))] <- j
+			c.workers[c.shards.Shard(j.metric.name)] <- j
 		}
 	}
 }
 
-func (c *Stats) commitFlush() {
+// beforeFlush reports metrics collected on each flush. They are queued ahead of the flush
+// job, so they are included in it.
+func (c *Stats) beforeFlush() {
+	c.collectProcessMetrics()
+	c.collectClockSkew()
+	c.collectDBStats()
+	c.reportPersistentGauges()
+	c.collectGaugeFuncs()
+	c.reportDropped()
+	c.reportTelemetry()
+	c.logDropped()
+}
+
+// commitFlush sends the metrics of flush interval group, or all groups. The default group
+// is zero. The sends are cancelled when ctx is done.
+func (c *Stats) commitFlush(ctx context.Context, group int) {
 
 	// On a flush signal we need to wait for all current metrics to be processed
 	// by the workers
 	c.workerWG.Wait()
 
-	// We need to make a copy of all the metrics to a new data structure
-	flattenedMetrics := make(map[string]*metric)
-	for _, m := range c.metrics {
-		for k, v := range m {
-			flattenedMetrics[k] = v
+	if group > 0 {
+		c.commitGroupFlush(ctx, c.flushGroups[group-1])
+		return
+	}
+	if group == flushAllGroups {
+		// The groups are sent before the default group
+		for _, g := range c.flushGroups {
+			c.commitGroupFlush(ctx, g)
 		}
 	}
 
-	// Then we zero out all of the metrics, and start with new values for the
-	// next flush interval.
-	for i := range c.metrics {
-		c.metrics[i] = map[string]*metric{}
+	// We need to move all the metrics to a new data structure, this clears the
+	// shards, so we start with new values for the next flush interval.
+	size := c.shards.Len()
+	if size < c.metricsHint {
+		size = c.metricsHint
+	}
+	flattenedMetrics := make(map[string]*metric, size)
+	expires := c.expireKeptValues(c.flushInterval)
+	c.shards.Drain(func(shard int, key string, a engine.Aggregate) {
+		m := a.(*metric)
+		c.keepValue(shard, key, m, expires)
+		flattenedMetrics[key] = m
+	})
+	atomic.AddInt64(&c.aggregatedKeys, -int64(len(flattenedMetrics)))
+
+	c.recordPrometheus(flattenedMetrics)
+
+	// Update the flush interval, and send the metrics to the flush worker. Each
+	// send is chained to the previous one, so callbacks are invoked in order. The
+	// next interval starts at the same instant this one ends, so manual flushes
+	// between scheduled flushes split the time, without losing any of it.
+	now := c.clock.Now()
+	interval := now.Sub(c.lastFlush)
+	order := &flushOrder{prev: c.lastSend, done: make(chan bool)}
+	c.lastSend = order.done
+	c.flushWG.Add(1)
+	go c.send(ctx, flattenedMetrics, interval, order)
+	c.lastFlush = now
+	if c.tenants != nil {
+		c.tenants.reset()
 	}
+}
 
-	// Update the flush interval, and send the metrics to the flush worker.
-	interval := time.Since(c.lastFlush)
-	go c.send(flattenedMetrics, interval)
-	c.lastFlush = time.Now()
+// workerMetricsHint returns the expected number of unique metrics per worker.
+func (c *Stats) workerMetricsHint() int {
+	if c.metricsHint <= 0 || c.workerCount <= 0 {
+		return 0
+	}
+	return c.metricsHint/c.workerCount + 1
 }
 
 func (c *Stats) blockReady() {
@@ -214,16 +607,39 @@ func (c *Stats) worker(jobs chan *job, id int) {
 			return
 		}
 
-		// Metrics are indexed by a combination of the metric name, and the list
-		// of tags. Order of the tags sent to the job shouldn't matter, as we
+		if c.faults != nil {
+			if delay := c.faults.WorkerDelay(); delay > 0 {
+				time.Sleep(delay)
+			}
+		}
+
+		// Metrics are indexed by a combination of the metric name, the list of
+		// tags, and the host. Order of the tags sent to the job shouldn't matter, as we
 		// sort them, before creating the index key.
-		key := metricKey(job.metric.name, job.metric.tags)
+		key := job.metric.key()
 
 		// Store or update the metric
-		if _, ok := c.metrics[id][key]; ok {
-			c.metrics[id][key].update(job.metric.value)
+		shards := c.shards
+		if job.metric.group > 0 {
+			shards = c.flushGroups[job.metric.group-1].shards
+		}
+		if m, ok := shards.Get(id, key); ok && m.(*metric).full() {
+			// Distribution values are bounded in each flush interval
+			c.drop(job.metric)
+		} else if ok {
+			c.accounting.add(job.metric.class, stageAggregated, 1)
+			m.Merge(job.metric)
 		} else {
-			c.metrics[id][key] = job.metric
+			c.accounting.add(job.metric.class, stageAggregated, 1)
+			if job.metric.tagKey != nil {
+				// Tag set tags are shared by every submission, the stored metric gets its
+				// own copy, as the tags are passed on to the series, and callbacks
+				job.metric.tags = append([]string(nil), job.metric.tags...)
+			}
+			c.resumeValue(id, key, job.metric)
+			job.metric.startWindow()
+			shards.Put(id, key, job.metric)
+			atomic.AddInt64(&c.aggregatedKeys, 1)
 		}
 
 		// Signalling done, allows us to track if any jobs are being worked on,
@@ -233,18 +649,38 @@ func (c *Stats) worker(jobs chan *job, id int) {
 	}
 }
 
-func (c *Stats) send(metrics map[string]*metric, flushTime time.Duration) {
+func (c *Stats) send(ctx context.Context, metrics map[string]*metric, flushTime time.Duration, order *flushOrder) {
+	c.sendMetrics(ctx, metrics, flushTime, order, true)
+}
+
+// sendMetrics sends metrics aggregated over flushTime. Queued series, and checks are only
+// sent with the default flush interval group, when all is true.
+func (c *Stats) sendMetrics(ctx context.Context, metrics map[string]*metric, flushTime time.Duration, order *flushOrder, all bool) {
 
+	var flushErr error
 	defer c.flushWG.Done()
+	defer func() {
+		// Registered checks are evaluated once per flush, in flush order
+		order.wait()
+		c.recordFlushHealth(flushErr)
+		if all {
+			c.runChecks()
+		}
+		order.complete()
+	}()
 
-	var metricsQueue []*client.DDMetric
-	c.metricQueueLock.Lock()
-	if len(c.metricsQueue) > 0 {
-		metricsQueue = c.metricsQueue
-		c.metricsQueue = make([]*client.DDMetric, 0)
+	var metricsQueue, merged []*client.DDMetric
+	if all {
+		c.metricQueueLock.Lock()
+		if len(c.metricsQueue) > 0 {
+			metricsQueue = c.metricsQueue
+			c.metricsQueue = make([]*client.DDMetric, 0)
+		}
+		merged = c.mergedQueue
+		c.mergedQueue = nil
+		c.metricQueueLock.Unlock()
 	}
-	c.metricQueueLock.Unlock()
-	if len(metrics) == 0 && metricsQueue == nil {
+	if len(metrics) == 0 && metricsQueue == nil && merged == nil {
 		return
 	}
 
@@ -258,156 +694,541 @@ func (c *Stats) send(metrics map[string]*metric, flushTime time.Duration) {
 	} else {
 		metricsSeries = make([]*client.DDMetric, 0, len(metrics))
 	}
+	tags := c.globalTags()
+	var negatives []metric
+	var distributions []*client.DDDistribution
+	points := make(map[string]uint64)
+	now := c.clock.Now().Unix()
 	for _, m := range metrics {
-		metricsSeries = append(metricsSeries, m.getMetric(c.namespace, c.host, c.tags, flushTime))
+		m.flushed = now
+		if m.class == client.Distribution {
+			distributions = append(distributions, m.getDistribution(m.name, m.hostOr(c.host), tags))
+			points[m.class]++
+			continue
+		}
+		if c.negativeCounts != NegativeCountAllow && m.negative() {
+			negatives = append(negatives, *m)
+			if c.negativeCounts == NegativeCountClamp {
+				m.clampNegative()
+			}
+		}
+		if m.class == histogram {
+			series := m.histogramMetrics(m.name, m.hostOr(c.host), tags, flushTime)
+			metricsSeries = append(metricsSeries, series...)
+			points[m.class] += countPoints(series)
+			continue
+		}
+		ddm := m.getMetric(m.name, m.hostOr(c.host), tags, flushTime)
+		metricsSeries = append(metricsSeries, ddm)
+		points[m.class] += uint64(len(ddm.Points))
+	}
+	for class, n := range points {
+		c.accounting.add(class, stageFlushed, n)
+	}
+
+	// Series merged from other stats by a Mux already have their own namespace, and host,
+	// only the global tags are added.
+	c.prepareSeries(metricsSeries)
+	for _, m := range merged {
+		m.Tags = combineTags(tags, m.Tags)
+	}
+	metricsSeries = append(metricsSeries, merged...)
+
+	var err error
+	var start, end time.Time
+	if len(metricsSeries) > 0 {
+		start = time.Now()
+		err = c.withinShutdownTimeout(func() error {
+			return c.sendSeries(ctx, metricsSeries)
+		})
+		end = time.Now()
+		c.recordSend(metricsSeries, start, err)
+		c.recordFlushResult(err == nil)
+		if err == nil {
+			c.log().Debugf("sent %d series in %s", len(metricsSeries), end.Sub(start))
+		}
+	}
+	var distributionErr error
+	var distributionStart, distributionEnd time.Time
+	if len(distributions) > 0 {
+		distributionStart = time.Now()
+		distributionErr = c.withinShutdownTimeout(func() error {
+			return c.sendDistributions(ctx, distributions)
+		})
+		if distributionErr != nil {
+			c.recordAPIError()
+		}
+		distributionEnd = time.Now()
+	}
+	for class, n := range points {
+		if class == client.Distribution && distributionErr == nil || class != client.Distribution && err == nil {
+			c.accounting.add(class, stageSent, n)
+		}
+	}
+
+	// The api call can run concurrently with other flushes, but errors, and callbacks
+	// are handled in flush order.
+	order.wait()
+	if flushErr = err; flushErr == nil {
+		flushErr = distributionErr
+	}
+	if c.negativeCountCallback != nil {
+		for _, m := range negatives {
+			c.negativeCountCallback(c.withNamespace(m.name), m.tags, m.value)
+		}
+	}
+	if distributionErr != nil {
+		c.log().Errorf("could not send %d distributions, %s", len(distributions), distributionErr.Error())
+		c.addError(ErrorClassDistribution, fmt.Errorf("could not send distributions, %s", distributionErr.Error()))
+		c.sendFlushError(&FlushError{
+			Err:            distributionErr,
+			Class:          ErrorClassDistribution,
+			Distributions:  distributions,
+			Requests:       1,
+			FailedRequests: 1,
+			Start:          distributionStart,
+			End:            distributionEnd,
+		})
 	}
 
-	if err := c.SendSeries(metricsSeries); err != nil {
-		c.errorLock.Lock()
-		c.errors = appendErrorsList(c.errors, err, c.maxErrors)
-		c.errorLock.Unlock()
-		if c.errorCallback != nil {
-			c.errorCallback(err, metricsSeries)
+	// When the series was split into multiple requests, only the series of the failed
+	// requests are spooled, and passed to the error callback
+	failed := metricsSeries
+	requests, failedRequests := 1, 1
+	if partial, ok := err.(*client.PartialError); ok {
+		failed = partial.Failed
+		requests, failedRequests = partial.Requests, len(partial.Errors)
+	}
+	var retries int
+	if c.spool != nil && len(metricsSeries) > 0 {
+		var spoolErr error
+		if retries, spoolErr = c.spoolFlush(failed, err); spoolErr != nil {
+			c.log().Warnf("could not spool series, %s", spoolErr.Error())
+			c.addError(ErrorClassSpool, spoolErr)
 		}
 	}
+	if c.mirror != nil {
+		if mirrorErr := c.mirror.write(metricsSeries, err); mirrorErr != nil {
+			c.addError(ErrorClassMirror, mirrorErr)
+		}
+	}
+	failedSeries := 0
+	if err != nil {
+		failedSeries = len(failed)
+		c.log().Errorf("could not send %d series, %s", len(failed), err.Error())
+		c.addError(ErrorClassSeries, err)
+		c.queueErrorCallback(err, failed)
+		c.sendFlushError(&FlushError{
+			Err:            err,
+			Class:          ErrorClassSeries,
+			Series:         failed,
+			Requests:       requests,
+			FailedRequests: failedRequests,
+			Start:          start,
+			End:            end,
+		})
+	}
 
 	if c.flushCallback != nil {
 		c.flushCallback(metricsSeries)
 	}
+	if c.flushResultCallback != nil {
+		result := &FlushResult{
+			Series:        len(metricsSeries),
+			FailedSeries:  failedSeries,
+			Distributions: len(distributions),
+			Duration:      end.Sub(start) + distributionEnd.Sub(distributionStart),
+			Retries:       retries,
+			Success:       err == nil && distributionErr == nil,
+			Err:           err,
+			Dropped:       c.droppedSinceResult(),
+		}
+		if result.Err == nil {
+			result.Err = distributionErr
+		}
+		if len(metricsSeries) > 0 {
+			result.Bytes = seriesPayloadSize(metricsSeries)
+		}
+		c.flushResultCallback(result)
+	}
+	c.runFilteredCallbacks(metricsSeries)
+	c.checkContinuity(metricsSeries)
+	c.publish(metricsSeries)
+}
+
+// recordFlushResult tracks consecutive flush failures, which are used to widen the
+// flush interval when adaptive flushing is enabled. Each success shrinks the interval
+// by one step, so the interval returns to normal gradually.
+func (c *Stats) recordFlushResult(ok bool) {
+	if c.maxInterval <= c.flushInterval || c.flushInterval <= 0 {
+		return
+	}
+
+	// Failures beyond the number of doublings needed to reach the max interval aren't
+	// counted, otherwise a long outage would need as many successes to recover.
+	var steps int32
+	for interval := c.flushInterval; interval < c.maxInterval; interval *= 2 {
+		steps++
+	}
+
+	for {
+		failures := atomic.LoadInt32(&c.flushFailures)
+		next := failures + 1
+		if ok {
+			next = failures - 1
+		}
+		if next < 0 || next > steps {
+			return
+		}
+		if atomic.CompareAndSwapInt32(&c.flushFailures, failures, next) {
+			return
+		}
+	}
+}
+
+// EffectiveFlushInterval returns the current interval between scheduled flushes. This
+// is the configured flush interval, unless adaptive flushing is enabled, and flushes
+// have been failing. For each consecutive failed flush the interval is doubled, up to
+// the configured max interval.
+func (c *Stats) EffectiveFlushInterval() time.Duration {
+	interval := c.flushInterval
+	for i := atomic.LoadInt32(&c.flushFailures); i > 0 && interval < c.maxInterval; i-- {
+		interval *= 2
+	}
+	if c.maxInterval > c.flushInterval && interval > c.maxInterval {
+		interval = c.maxInterval
+	}
+	return interval
 }
 
 // SendSeries immediately posts an DDMetric series to the Datadog api. Each metric in the series
 // is checked for an host name, and the correct namespace. If host, or namespace vales are missing,
 // the values will be filled before sending to the api. Global tags are added to all metrics.
+// In strict series mode, the series is validated, and if any metric is invalid, nothing is
+// sent, and a *client.SeriesError is returned. The call is subject to the rate limit, see
+// Config.WithRateLimit.
 func (c *Stats) SendSeries(series []*client.DDMetric) error {
+	if c.noop {
+		return nil
+	}
+	c.prepareSeries(series)
+	if err := c.validateSeries(series); err != nil {
+		return err
+	}
+	return c.rateLimited(ErrorClassSeries, func() error {
+		return c.sendSeries(context.Background(), series)
+	})
+}
+
+// prepareSeries fills in the host, namespace, and global tags of series.
+func (c *Stats) prepareSeries(series []*client.DDMetric) {
 	for _, m := range series {
 		if m.Host == "" {
 			m.Host = c.host
 		}
-		m.Metric = prependNamespace(c.namespace, m.Metric)
-		m.Tags = combineTags(c.tags, m.Tags)
+		m.Metric = c.withNamespace(m.Metric)
+		m.Tags = combineTags(c.globalTags(), m.Tags)
+	}
+}
+
+// sendSeries posts series to the api as is. The send is cancelled when ctx is done, if the
+// api client implements client.ContextClient.
+func (c *Stats) sendSeries(ctx context.Context, series []*client.DDMetric) error {
+	if err := c.faultError(FaultEndpointSeries); err != nil {
+		return err
 	}
-	return c.client.SendSeries(&client.DDMetricSeries{Series: series})
+	return client.SendSeriesContext(ctx, c.client, &client.DDMetricSeries{Series: series})
 }
 
-// QueueSeries adds a series of metrics to the queue to be be sent with the next flush.
-func (c *Stats) QueueSeries(series []*client.DDMetric) {
+// QueueSeries adds a series of metrics to the queue to be be sent with the next flush. In
+// strict series mode, the series is validated, and if any metric is invalid, nothing is
+// queued, and a *client.SeriesError is returned.
+func (c *Stats) QueueSeries(series []*client.DDMetric) error {
+	if c.noop {
+		return nil
+	}
 	for _, m := range series {
 		if m.Host == "" {
 			m.Host = c.host
 		}
-		m.Metric = prependNamespace(c.namespace, m.Metric)
-		m.Tags = combineTags(c.tags, m.Tags)
+		m.Tags = combineTags(c.globalTags(), m.Tags)
+	}
+	if err := c.validateSeries(series); err != nil {
+		return err
 	}
 	c.metricQueueLock.Lock()
 	defer c.metricQueueLock.Unlock()
 	c.metricsQueue = append(c.metricsQueue, series...)
+	return nil
+}
+
+// validateSeries validates series with client.ValidateSeries in strict series mode. Names
+// are validated with the namespace prepended.
+func (c *Stats) validateSeries(series []*client.DDMetric) error {
+	if !c.strictSeries {
+		return nil
+	}
+	named := make([]*client.DDMetric, len(series))
+	for i, m := range series {
+		copied := *m
+		copied.Metric = c.withNamespace(m.Metric)
+		named[i] = &copied
+	}
+	return client.ValidateSeries(named, c.clock.Now())
 }
 
 // ServiceCheck immediately posts an DDServiceCheck to he Datadog api. The namespace is
 // prepended to the check name, if it is missing. Host, and time is automatically added.
-// Global tags are appended to tags passed to the method.
+// Global tags are appended to tags passed to the method. The call is subject to the rate
+// limit, see Config.WithRateLimit.
 func (c *Stats) ServiceCheck(check, message string, status client.Status, tags []string) error {
-	return c.client.SendServiceCheck(&client.DDServiceCheck{
-		Check:     prependNamespace(c.namespace, check),
+	if c.noop {
+		return nil
+	}
+	serviceCheck := &client.DDServiceCheck{
+		Check:     c.withNamespace(check),
 		Hostname:  c.host,
 		Message:   message,
 		Status:    status,
-		Tags:      combineTags(c.tags, tags),
-		Timestamp: time.Now().Unix(),
+		Tags:      combineTags(c.globalTags(), tags),
+		Timestamp: c.clock.Now().Unix(),
+	}
+	return c.rateLimited(ErrorClassServiceCheck, func() error {
+		if err := c.faultError(FaultEndpointServiceCheck); err != nil {
+			return err
+		}
+		return c.client.SendServiceCheck(serviceCheck)
 	})
 }
 
 // Event immediately posts an DDEvent to he Datadog api. If host, or namespace vales are missing,
 // the values will be filled before sending to the api. Global tags are appended to the event.
+// The call is subject to the rate limit, see Config.WithRateLimit.
 func (c *Stats) Event(event *client.DDEvent) error {
+	if c.noop {
+		return nil
+	}
 	if event.Host == "" {
 		event.Host = c.host
 	}
 	if event.DateHappened == 0 {
-		event.DateHappened = time.Now().Unix()
+		event.DateHappened = c.clock.Now().Unix()
 	}
-	event.AggregationKey = prependNamespace(c.namespace, event.AggregationKey)
-	event.Tags = combineTags(c.tags, event.Tags)
-	return c.client.SendEvent(event)
+	event.AggregationKey = c.withNamespace(event.AggregationKey)
+	event.Tags = combineTags(c.globalTags(), event.Tags)
+	return c.rateLimited(ErrorClassWarning, func() error {
+		if err := c.faultError(FaultEndpointEvent); err != nil {
+			return err
+		}
+		return c.client.SendEvent(event)
+	})
 }
 
 // Increment creates or increments a count metric by +1. This is a non-blocking method, if
 // the channel buffer is full, then the metric is not recorded. Count stats are sent as count,
 // by taking the sum value of all values in the flush interval.
-func (c *Stats) Increment(name string, tags []string) {
-	c.Count(name, 1, tags)
+func (c *Stats) Increment(name string, tags []string, opts ...MetricOption) {
+	c.Count(name, 1, tags, opts...)
 }
 
 // Decrement creates or subtracts a count metric by -1. This is a non-blocking method, if
 // the channel buffer is full, then the metric is not recorded. Count stats are sent as count,
 // by taking the sum value of all values in the flush interval.
-func (c *Stats) Decrement(name string, tags []string) {
-	c.Count(name, -1, tags)
+func (c *Stats) Decrement(name string, tags []string, opts ...MetricOption) {
+	c.Count(name, -1, tags, opts...)
 }
 
 // Count creates or adds a count metric by value. This is a non-blocking method, if
 // the channel buffer is full, then the metric is not recorded. Count stats are sent as count,
 // by taking the sum value of all values in the flush interval.
-func (c *Stats) Count(name string, value float64, tags []string) {
-	select {
-	case c.jobs <- &job{metric: &metric{
-		name:  name,
-		class: client.Count,
-		value: value,
-		tags:  tags,
-	}}:
-	default:
-		atomic.AddUint64(&c.dropped, 1)
-	}
+func (c *Stats) Count(name string, value float64, tags []string, opts ...MetricOption) {
+	c.submit(name, client.Count, value, tags, opts...)
 }
 
 // IncrementRate creates or increments a rate metric by +1. This is a non-blocking method, if
 // the channel buffer is full, then the metric is not recorded. Rate stats are sent as rate,
 // by taking the count value and dividing by the number of seconds since the last flush.
-func (c *Stats) IncrementRate(name string, tags []string) {
-	c.Rate(name, 1, tags)
+func (c *Stats) IncrementRate(name string, tags []string, opts ...MetricOption) {
+	c.Rate(name, 1, tags, opts...)
 }
 
 // DecrementRate creates or subtracts a rate metric by -1. This is a non-blocking method, if
 // the channel buffer is full, then the metric is not recorded. Rate stats are sent as rate,
 // by taking the count value and dividing by the number of seconds since the last flush.
-func (c *Stats) DecrementRate(name string, tags []string) {
-	c.Rate(name, -1, tags)
+func (c *Stats) DecrementRate(name string, tags []string, opts ...MetricOption) {
+	c.Rate(name, -1, tags, opts...)
 }
 
 // Rate creates or adds a rate metric by value. This is a non-blocking method, if
 // the channel buffer is full, then the metric is not recorded. Rate stats are sent as rate,
 // by taking the count value and dividing by the number of seconds since the last flush.
-func (c *Stats) Rate(name string, value float64, tags []string) {
-	select {
-	case c.jobs <- &job{metric: &metric{
-		name:  name,
-		class: client.Rate,
-		value: value,
-		tags:  tags,
-	}}:
-	default:
-		atomic.AddUint64(&c.dropped, 1)
-	}
+func (c *Stats) Rate(name string, value float64, tags []string, opts ...MetricOption) {
+	c.submit(name, client.Rate, value, tags, opts...)
 }
 
 // Gauge creates or updates a gauge metric by value. This is a non-blocking method, if
 // the channel buffer is full, then the metric not recorded. Gauge stats are reported
 // as the last value sent before flush is called.
-func (c *Stats) Gauge(name string, value float64, tags []string) {
+func (c *Stats) Gauge(name string, value float64, tags []string, opts ...MetricOption) {
+	c.submit(name, client.Gauge, value, tags, opts...)
+}
+
+// Histogram records value in a histogram metric. This is a non-blocking method, if the
+// channel buffer is full, then the metric is not recorded. Values are aggregated over the
+// flush interval, and sent as gauges with the suffixes .max, .min, .avg, .median, and
+// .95percentile, and the number of values as a rate with the suffix .count. The aggregates,
+// and percentiles are set with Config.WithHistogramAggregates, and WithHistogramPercentiles.
+//
+// The first 1024 values of a flush interval are stored, and summarized exactly. Further
+// values are recorded in a quantile sketch, which uses bounded memory, with percentiles
+// accurate to within 1% of the exact value.
+func (c *Stats) Histogram(name string, value float64, tags []string, opts ...MetricOption) {
+	c.submit(name, histogram, value, tags, opts...)
+}
+
+// submit sends a new metric update to the main worker, the update is dropped if the
+// jobs channel is full.
+func (c *Stats) submit(name, class string, value float64, tags []string, opts ...MetricOption) {
+
+	tags, o, ok := c.prepareSubmission(name, tags, opts)
+	if !ok {
+		return
+	}
+	c.submitMetric(&metric{name: name, class: class, value: value, tags: tags}, o)
+}
+
+// submitMetric completes a metric update with the stats options, and enqueues it.
+func (c *Stats) submitMetric(m *metric, o metricOptions) {
+
+	class := m.class
+	value := sampleValue(class, m.value, o.sampleRate)
+	m.value = value
+	o.apply(m)
+	if class == client.Gauge {
+		m.aggregation = c.gaugeAggregation
+		m.sequence = atomic.AddUint64(&c.sequence, 1)
+	}
+	if class == histogram {
+		m.values = []float64{value}
+		m.histogram = c.histogramAggregation
+	} else if class == client.Distribution {
+		m.values = []float64{value}
+	} else if c.downsample > 0 {
+		m.window = c.downsample
+		m.timestamp = c.clock.Now().Unix()
+		if m.observed > 0 {
+			m.timestamp = m.observed
+		}
+	}
+	c.enqueue(m, o)
+}
+
+// prepareSubmission applies opts, and prepares the tags of an update, it returns false if
+// the update should be dropped, because it was sampled out, or by the tag policies.
+func (c *Stats) prepareSubmission(name string, tags []string, opts []MetricOption) ([]string, metricOptions, bool) {
+
+	if c.noop {
+		return nil, metricOptions{}, false
+	}
+
+	o := applyMetricOptions(opts)
+	if !sampled(o.sampleRate) {
+		return nil, o, false
+	}
+
+	// The tags are copied, the worker sorts them in place when creating the metric key,
+	// and the caller may reuse its slice
+	if len(tags) > 0 || len(o.tags) > 0 {
+		tags = append(append(make([]string, 0, len(tags)+len(o.tags)+1), tags...), o.tags...)
+	}
+	if c.sourceTags {
+		if source := sourceTag(); source != "" {
+			tags = append(tags, source)
+		}
+	}
+
+	tags, ok := c.prepareTags(name, tags)
+	return tags, o, ok
+}
+
+// prepareTags applies tag normalization, the tag length policy, and tenant limits to the
+// tags of an update, it returns false if the update should be dropped.
+func (c *Stats) prepareTags(name string, tags []string) ([]string, bool) {
+
+	if c.tagNormalization {
+		tags = SanitizeTags(tags, c.maxTagLength)
+	}
+
+	tags, long, reject := limitTagLength(tags, c.maxTagLength, c.tagLengthPolicy)
+	if long > 0 {
+		atomic.AddUint64(&c.longTags, uint64(long))
+	}
+	if reject {
+		return nil, false
+	}
+
+	if c.tenants != nil {
+		tags = c.tenants.limit(name, tags)
+	}
+	return tags, true
+}
+
+// GetLongTagCount returns the number of tags submitted that were longer than the max tag
+// length, and were truncated, or rejected. Long tags are not counted when the tag length
+// policy is TagLengthAllow.
+func (c *Stats) GetLongTagCount() uint64 {
+	return atomic.LoadUint64(&c.longTags)
+}
+
+// enqueue sends m to the main worker, m is dropped if the jobs channel is full.
+func (c *Stats) enqueue(m *metric, o metricOptions) {
+	c.accounting.add(m.class, stageSubmitted, 1)
+	if c.faults != nil && c.faults.DropMetric() {
+		c.drop(m)
+		return
+	}
+
+	j := &job{metric: m}
 	select {
-	case c.jobs <- &job{metric: &metric{
-		name:  name,
-		class: client.Gauge,
-		value: value,
-		tags:  tags,
-	}}:
+	case c.jobs <- j:
+		return
 	default:
-		atomic.AddUint64(&c.dropped, 1)
+	}
+
+	// The queue is full, unless blocking is enabled, the metric is dropped
+	block, timeout := c.blockOnFull, c.blockTimeout
+	if o.block {
+		block, timeout = true, o.blockTimeout
+	}
+	if !block {
+		c.drop(m)
+		return
+	}
+
+	var expired <-chan time.Time
+	if timeout > 0 {
+		timer := c.clock.NewTimer(timeout)
+		defer timer.Stop()
+		expired = timer.C()
+	}
+	select {
+	case c.jobs <- j:
+	case <-expired:
+		c.drop(m)
+	case <-c.stopCollectors:
+		// Stats is closing, the queue may never be read again
+		c.drop(m)
 	}
 }
 
+// drop counts a metric dropped before it was queued, or a distribution value dropped
+// because the distribution has the max values for the flush interval.
+func (c *Stats) drop(m *metric) {
+	atomic.AddUint64(&c.dropped, 1)
+	c.countDropped(m.name)
+	c.accounting.add(m.class, stageDropped, 1)
+}
+
 // GetDroppedMetricCount returns the number off metrics submitted to the metric queue,
 // and where dropped because the queue was full.
 func (c *Stats) GetDroppedMetricCount() uint64 {
@@ -416,21 +1237,52 @@ func (c *Stats) GetDroppedMetricCount() uint64 {
 
 // Flush signals the main worker thread to copy all current metrics, and send them
 // to the Datadog api. Flush blocks until all flush jobs complete.
-// been sent, use FlushWait.
 func (c *Stats) Flush() {
-	// Add a job to the flush wait group
-	c.flushWG.Add(1)
-	c.jobs <- &job{flush: true}
-	c.flushWG.Wait()
+	c.flush(context.Background())
+}
+
+// FlushContext is the same as Flush, but returns ctx.Err() if ctx is done before the flush
+// completes. When ctx is done, the sends of the flush are cancelled, if the api client
+// implements client.ContextClient, the flush then completes in the background.
+func (c *Stats) FlushContext(ctx context.Context) error {
+	return waitContext(ctx, func() { c.flush(ctx) })
+}
+
+// flush flushes all groups, the sends are cancelled when ctx is done.
+func (c *Stats) flush(ctx context.Context) {
+	if c.noop {
+		return
+	}
+	c.beforeFlush()
+
+	done := make(chan bool)
+	c.jobs <- &job{flush: true, group: flushAllGroups, ctx: ctx, done: done}
+	<-done
 }
 
 // FlushCallback registers a call back function that will be called at the end of every successful flush.
+// Callbacks are invoked once per flush, in flush order, and never concurrently.
 func (c *Stats) FlushCallback(f func(metricSeries []*client.DDMetric)) {
 	c.flushCallback = f
 }
 
+// NegativeCountCallback registers a call back function that will be called for each count,
+// or rate metric with a negative value for the flush interval, when the negative count
+// policy is NegativeCountClamp, or NegativeCountReport. The value is the value before any
+// clamping. Callbacks are invoked in flush order, and never concurrently.
+func (c *Stats) NegativeCountCallback(f func(name string, tags []string, value float64)) {
+	c.negativeCountCallback = f
+}
+
 // ErrorCallback registers a call back function that will be called if any error is returned
-// by the api client during a flush.
+// by the api client during a flush. When the whole flush failed, metricSeries holds every
+// series of the flush. When the series was split into multiple requests, and only some of
+// them failed, err is a *client.PartialError, with the number of requests, and failed
+// requests, and metricSeries only holds the series of the failed requests. Callbacks are
+// invoked in flush order, and never concurrently, from a separate goroutine, so a slow
+// callback doesn't delay flushes. If more errors than Config.ErrorCallbackBuffer are
+// waiting for the callback, errors are dropped, and counted by GetDroppedErrorCallbackCount.
+// See ErrorsChan, to receive the errors on a channel instead.
 func (c *Stats) ErrorCallback(f func(err error, metricSeries []*client.DDMetric)) {
 	c.errorCallback = f
 }
@@ -439,12 +1291,20 @@ func (c *Stats) ErrorCallback(f func(err error, metricSeries []*client.DDMetric)
 func (c *Stats) Errors() []error {
 	c.errorLock.RLock()
 	defer c.errorLock.RUnlock()
-	errs := c.errors
+	errs := make([]error, len(c.errors))
+	copy(errs, c.errors)
 	return errs
 }
 
 // Close signals a shutdown, and blocks while waiting for flush to complete, and all workers to shutdown.
+// Sends still waiting on the api are abandoned once the shutdown timeout expires, see
+// Config.WithShutdownTimeout.
 func (c *Stats) Close() {
+	c.close(context.Background())
+}
+
+// close shuts down stats, the sends of the final flush are cancelled when ctx is done.
+func (c *Stats) close(ctx context.Context) {
 
 	c.shutdownLock.Lock()
 	defer c.shutdownLock.Unlock()
@@ -453,18 +1313,101 @@ func (c *Stats) Close() {
 	}
 
 	c.shutdown = true
-	c.flushWG.Add(1)
-	c.jobs <- &job{shutdown: true}
-	c.workerWG.Wait()
-	c.flushWG.Wait()
+	c.log().Infof("closing, flushing remaining metrics")
+
+	// Stop any collectors first, so their final values are included in the last flush
+	close(c.stopCollectors)
+	c.collectorWG.Wait()
+	c.closeRateLimit()
+
+	stopTimeout := c.startShutdownTimeout()
+	defer stopTimeout()
+	done := make(chan bool)
+	c.jobs <- &job{shutdown: true, ctx: ctx, done: done}
+	<-done
+	close(c.errorCallbacks)
+	c.errorCallbackWG.Wait()
+	close(c.flushErrors)
+	c.closeSubscribers()
+	if c.mirror != nil {
+		_ = c.mirror.close()
+	}
+	c.log().Infof("closed, %d metrics dropped, %d errors", c.GetDroppedMetricCount(), len(c.Errors()))
+}
+
+// CloseContext is the same as Close, but returns ctx.Err() if ctx is done before the close
+// completes. When ctx is done, the sends of the final flush are cancelled, if the api client
+// implements client.ContextClient, the close then completes in the background, and any
+// further calls to Close block until then.
+func (c *Stats) CloseContext(ctx context.Context) error {
+	return waitContext(ctx, func() { c.close(ctx) })
+}
+
+// waitContext calls fn in a new goroutine, and waits for fn to return, or ctx to be done.
+func waitContext(ctx context.Context, fn func()) error {
+	done := make(chan bool)
+	go func() {
+		fn()
+		close(done)
+	}()
+	select {
+	case <-done:
+		return nil
+	case <-ctx.Done():
+		return ctx.Err()
+	}
+}
+
+// startCollector calls fn every interval, until stats is closed.
+func (c *Stats) startCollector(interval time.Duration, fn func()) {
+	if c.noop {
+		return
+	}
+	c.collectorWG.Add(1)
+	go func() {
+		defer c.collectorWG.Done()
+		ticker := time.NewTicker(interval)
+		defer ticker.Stop()
+		for {
+			select {
+			case <-ticker.C:
+				fn()
+			case <-c.stopCollectors:
+				return
+			}
+		}
+	}()
+}
+
+// withNamespace prepends the namespace to name, according to the namespace mode.
+func (c *Stats) withNamespace(name string) string {
+	return applyNamespace(c.namespace, name, c.namespaceMode)
 }
 
 func prependNamespace(namespace, name string) string {
+	return applyNamespace(namespace, name, NamespaceModePrefix)
+}
+
+func applyNamespace(namespace, name string, mode NamespaceMode) string {
 
-	if namespace == "" || strings.HasPrefix(name, namespace) {
+	// A trailing dot on the namespace is allowed, and ignored
+	namespace = strings.TrimRight(namespace, ".")
+	if namespace == "" {
 		return name
 	}
 
+	switch mode {
+	case NamespaceModeStrict:
+	case NamespaceModeBoundary:
+		if name == namespace || strings.HasPrefix(name, namespace+".") {
+			return name
+		}
+	default:
+		if strings.HasPrefix(name, namespace) {
+			return name
+		}
+	}
+
 	return fmt.Sprintf("%s.%s", namespace, name)
 }
 
@@ -493,26 +1436,10 @@ func combineTags(tags1, tags2 []string) []string {
 	return newTags
 }
 
-func metricKey(name string, tags []string) string {
-
-	// We have to sort the tags, in order to generate a consistent key.
-	// If a user swaps the order of a key, we don't want to store that
-	// metric as new metric.
-	sort.Strings(tags)
-	return fmt.Sprintf("%s%s", name, strings.Join(tags, ""))
-}
-
-func fnv1a(v string) uint32 {
-	h := fnv.New32a()
-	_, _ = h.Write([]byte(v))
-	return h.Sum32()
-}
-
-func appendErrorsList(errors []error, err error, max int) []error {
-
-	if len(errors) >= max {
-		errors = errors[1:]
+func joinErrors(errs []error) string {
+	msgs := make([]string, len(errs))
+	for i, err := range errs {
+		msgs[i] = err.Error()
 	}
-
-	return append(errors, err)
+	return strings.Join(msgs, "; ")
 }
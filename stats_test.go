@@ -1,12 +1,17 @@
 package ddstats
 
 import (
+	"context"
 	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"strings"
 	"sync"
 	"testing"
 	"time"
 
 	"github.com/jmizell/ddstats/client"
+	"github.com/jmizell/ddstats/internal/engine"
 )
 
 type TestAPIClient struct {
@@ -57,9 +62,9 @@ func (t *TestAPIClient) FindMetric(callIndex int, ddMetric *client.DDMetric) err
 		return fmt.Errorf("")
 	}
 
-	findKey := metricKey(ddMetric.Metric, ddMetric.Tags)
+	findKey := engine.Key(ddMetric.Metric, ddMetric.Tags)
 	for _, m := range t.series[callIndex].Series {
-		key := metricKey(m.Metric, m.Tags)
+		key := engine.Key(m.Metric, m.Tags)
 		if key == findKey {
 
 			if m.Host != ddMetric.Host {
@@ -146,14 +151,10 @@ func NewTestStats() (*Stats, *TestAPIClient, error) {
 	return stat, testClient, err
 }
 
+// NewTestStatsWithStart returns stats once the main worker thread is ready. NewStats starts
+// it, starting a second one would race the first for the jobs, and wait groups.
 func NewTestStatsWithStart() (*Stats, *TestAPIClient, error) {
-	stats, testApi, err := NewTestStats()
-	if err != nil {
-		return nil, nil, err
-	}
-	go stats.start()
-	stats.blockReady()
-	return stats, testApi, err
+	return NewTestStats()
 }
 
 func TestNewStats(t *testing.T) {
@@ -404,6 +405,75 @@ func TestStats_Close(t *testing.T) {
 	})
 }
 
+func TestStats_Context(t *testing.T) {
+
+	testClient := &delayAPIClient{
+		TestAPIClient: NewTestAPIClient(),
+		delay:         map[string]time.Duration{"testNamespace.slow": time.Millisecond * 200},
+	}
+	stats, err := NewStats(NewConfig().WithNamespace(testNamespace).WithClient(testClient))
+	if err != nil {
+		t.Fatalf(err.Error())
+	}
+
+	stats.Gauge("slow", 1, nil)
+	ctx, cancel := context.WithTimeout(context.Background(), time.Millisecond*10)
+	defer cancel()
+	if err := stats.FlushContext(ctx); err != context.DeadlineExceeded {
+		t.Fatalf("expected flush error %v, have %v", context.DeadlineExceeded, err)
+	}
+
+	stats.Gauge("fast", 1, nil)
+	if err := stats.CloseContext(context.Background()); err != nil {
+		t.Fatalf("expected close to complete, have %v", err)
+	}
+	if len(testClient.series) != 2 {
+		t.Fatalf("expected %d series calls, have %d", 2, len(testClient.series))
+	}
+}
+
+func TestStats_ContextCancelsSend(t *testing.T) {
+
+	release := make(chan bool)
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		<-release
+	}))
+	defer server.Close()
+	defer close(release)
+
+	apiClient := client.NewDDClient("testKey")
+	apiClient.SetHTTPClient(&http.Client{})
+	if err := apiClient.SetBaseURL(server.URL); err != nil {
+		t.Fatalf(err.Error())
+	}
+	stats, err := NewStats(NewConfig().WithNamespace(testNamespace).WithClient(apiClient))
+	if err != nil {
+		t.Fatalf(err.Error())
+	}
+
+	stats.Gauge("slow", 1, nil)
+	ctx, cancel := context.WithTimeout(context.Background(), time.Millisecond*10)
+	defer cancel()
+	if err := stats.FlushContext(ctx); err != context.DeadlineExceeded {
+		t.Fatalf("expected flush error %v, have %v", context.DeadlineExceeded, err)
+	}
+
+	// Close waits for the previous flush, it only completes if the request was cancelled
+	closed := make(chan bool)
+	go func() {
+		stats.Close()
+		close(closed)
+	}()
+	select {
+	case <-closed:
+	case <-time.After(time.Second * 5):
+		t.Fatalf("expected the flush request to be cancelled with the context")
+	}
+	if errs := stats.Errors(); len(errs) != 1 || !strings.Contains(errs[0].Error(), context.DeadlineExceeded.Error()) {
+		t.Fatalf("expected a %v error, have %v", context.DeadlineExceeded, errs)
+	}
+}
+
 func TestStats_Flush(t *testing.T) {
 	baseMetric := client.DDMetric{
 		Host:     testHost,
@@ -427,6 +497,82 @@ func TestStats_Flush(t *testing.T) {
 	}
 }
 
+func TestStats_ExpectedMetricCount(t *testing.T) {
+
+	testApi := NewTestAPIClient()
+	cfg := NewConfig().
+		WithNamespace(testNamespace).
+		WithHost(testHost).
+		WithTags(testTags).
+		WithClient(testApi).
+		WithExpectedMetricCount(10)
+	cfg.WorkerCount = 2
+	stats, err := NewStats(cfg)
+	if err != nil {
+		t.Fatalf(err.Error())
+	}
+	defer stats.Close()
+
+	if hint := stats.workerMetricsHint(); hint != 6 {
+		t.Fatalf("expected worker hint to be %d, have %d", 6, hint)
+	}
+
+	stats.Gauge("test1", 1, nil)
+	stats.Gauge("test2", 2, nil)
+	stats.Flush()
+	stats.Gauge("test3", 3, nil)
+	stats.Flush()
+
+	testApi.lock.Lock()
+	defer testApi.lock.Unlock()
+	if len(testApi.series) != 2 {
+		t.Fatalf("expected %d calls to SendSeries, have %d", 2, len(testApi.series))
+	}
+	if len(testApi.series[0].Series) != 2 {
+		t.Fatalf("expected first flush to have %d metrics, have %d", 2, len(testApi.series[0].Series))
+	}
+	if len(testApi.series[1].Series) != 1 {
+		t.Fatalf("expected second flush to have %d metrics, have %d", 1, len(testApi.series[1].Series))
+	}
+	if n := stats.shards.Len(); n != 0 {
+		t.Fatalf("expected worker shards to be cleared after flush, have %d metrics", n)
+	}
+}
+
+func TestStats_EffectiveFlushInterval(t *testing.T) {
+
+	t.Run("adaptive disabled", func(tt *testing.T) {
+		stats := &Stats{flushInterval: time.Second * 10}
+		stats.recordFlushResult(false)
+		if interval := stats.EffectiveFlushInterval(); interval != time.Second*10 {
+			tt.Fatalf("expected interval to be %s, have %s", time.Second*10, interval)
+		}
+	})
+
+	t.Run("widen and shrink", func(tt *testing.T) {
+		stats := &Stats{flushInterval: time.Second * 10, maxInterval: time.Second * 60}
+		steps := []struct {
+			ok       bool
+			interval time.Duration
+		}{
+			{false, time.Second * 20},
+			{false, time.Second * 40},
+			{false, time.Second * 60},
+			{false, time.Second * 60},
+			{true, time.Second * 40},
+			{true, time.Second * 20},
+			{true, time.Second * 10},
+			{true, time.Second * 10},
+		}
+		for i, step := range steps {
+			stats.recordFlushResult(step.ok)
+			if interval := stats.EffectiveFlushInterval(); interval != step.interval {
+				tt.Fatalf("step %d, expected interval to be %s, have %s", i, step.interval, interval)
+			}
+		}
+	})
+}
+
 func TestStats_FlushWorker(t *testing.T) {
 
 	baseMetric := client.DDMetric{
@@ -458,6 +604,28 @@ func TestStats_FlushWorker(t *testing.T) {
 	}
 }
 
+func TestStats_FlushOnStart(t *testing.T) {
+
+	testApi := NewTestAPIClient()
+	stats, err := NewStats(NewConfig().
+		WithNamespace(testNamespace).
+		WithHost(testHost).
+		WithClient(testApi).
+		WithFlushOnStart(true))
+	if err != nil {
+		t.Fatalf(err.Error())
+	}
+	defer stats.Close()
+	stats.Increment("test", nil)
+	time.Sleep(DefaultStartFlushInterval + time.Millisecond*500)
+
+	testApi.lock.Lock()
+	defer testApi.lock.Unlock()
+	if len(testApi.series) != 1 {
+		t.Fatalf("expected %d flush after start, have %d", 1, len(testApi.series))
+	}
+}
+
 func TestStats_CountGaugeRate(t *testing.T) {
 
 	baseMetric := client.DDMetric{
@@ -899,13 +1067,14 @@ func TestStats_Errors(t *testing.T) {
 
 		stats.flushWG.Add(1)
 		stats.send(
+			context.Background(),
 			map[string]*metric{
 				"test": {
 					name:  "test",
 					class: client.Gauge,
 					value: 10,
 				},
-			}, time.Second*10,
+			}, time.Second*10, nil,
 		)
 
 		errors := stats.Errors()
@@ -923,13 +1092,14 @@ func TestStats_Errors(t *testing.T) {
 
 		stats.flushWG.Add(1)
 		stats.send(
+			context.Background(),
 			map[string]*metric{
 				"test": {
 					name:  "test",
 					class: client.Gauge,
 					value: 10,
 				},
-			}, time.Second*10,
+			}, time.Second*10, nil,
 		)
 
 		errors := stats.Errors()
@@ -958,13 +1128,14 @@ func TestStats_FlushCallback(t *testing.T) {
 
 		stats.flushWG.Add(1)
 		stats.send(
+			context.Background(),
 			map[string]*metric{
 				"test": {
 					name:  "test",
 					class: client.Gauge,
 					value: 10,
 				},
-			}, time.Second*10,
+			}, time.Second*10, nil,
 		)
 
 		if len(callbackStats) != 1 {
@@ -973,6 +1144,104 @@ func TestStats_FlushCallback(t *testing.T) {
 	})
 }
 
+type delayAPIClient struct {
+	*TestAPIClient
+	delay map[string]time.Duration
+}
+
+func (d *delayAPIClient) SendSeries(series *client.DDMetricSeries) error {
+	for _, m := range series.Series {
+		time.Sleep(d.delay[m.Metric])
+	}
+	return d.TestAPIClient.SendSeries(series)
+}
+
+func TestStats_FlushCallbackOrder(t *testing.T) {
+
+	stats, _, err := NewTestStatsWithStart()
+	if err != nil {
+		t.Fatalf(err.Error())
+	}
+	stats.client = &delayAPIClient{
+		TestAPIClient: NewTestAPIClient(),
+		delay:         map[string]time.Duration{"testNamespace.first": time.Millisecond * 100},
+	}
+
+	lock := &sync.Mutex{}
+	var order []string
+	stats.FlushCallback(func(metricSeries []*client.DDMetric) {
+		lock.Lock()
+		defer lock.Unlock()
+		order = append(order, metricSeries[0].Metric)
+	})
+
+	first := &flushOrder{done: make(chan bool)}
+	second := &flushOrder{prev: first.done, done: make(chan bool)}
+	third := &flushOrder{prev: second.done, done: make(chan bool)}
+	stats.flushWG.Add(3)
+	go stats.send(context.Background(), map[string]*metric{"first": {name: "first", class: client.Gauge, value: 1}}, time.Second, first)
+	go stats.send(context.Background(), map[string]*metric{}, time.Second, second)
+	go stats.send(context.Background(), map[string]*metric{"third": {name: "third", class: client.Gauge, value: 1}}, time.Second, third)
+	stats.flushWG.Wait()
+
+	if len(order) != 2 || order[0] != "testNamespace.first" || order[1] != "testNamespace.third" {
+		t.Fatalf("expected callbacks in flush order, have %v", order)
+	}
+}
+
+func TestStats_NegativeCounts(t *testing.T) {
+
+	tests := []struct {
+		policy   NegativeCountPolicy
+		value    float64
+		reported int
+	}{
+		{NegativeCountAllow, -2, 0},
+		{NegativeCountClamp, 0, 1},
+		{NegativeCountReport, -2, 1},
+	}
+
+	for _, test := range tests {
+		t.Run(string(test.policy), func(tt *testing.T) {
+			testApi := NewTestAPIClient()
+			cfg := NewConfig().
+				WithNamespace(testNamespace).
+				WithHost(testHost).
+				WithClient(testApi).
+				WithNegativeCountPolicy(test.policy)
+			stats, err := NewStats(cfg)
+			if err != nil {
+				tt.Fatalf(err.Error())
+			}
+
+			var reported []float64
+			stats.NegativeCountCallback(func(name string, tags []string, value float64) {
+				if name != "testNamespace.test" {
+					tt.Fatalf("expected name to be %s, have %s", "testNamespace.test", name)
+				}
+				reported = append(reported, value)
+			})
+
+			stats.Decrement("test", nil)
+			stats.Decrement("test", nil)
+			stats.Gauge("gauge", -1, nil)
+			stats.Close()
+
+			if len(reported) != test.reported {
+				tt.Fatalf("expected %d reported negative counts, have %d", test.reported, len(reported))
+			}
+			if test.reported > 0 && reported[0] != -2 {
+				tt.Fatalf("expected reported value to be %f, have %f", -2.0, reported[0])
+			}
+			for _, m := range testApi.series[0].Series {
+				if m.Metric == "testNamespace.test" && m.Points[0][1] != test.value {
+					tt.Fatalf("expected sent value to be %f, have %v", test.value, m.Points[0][1])
+				}
+			}
+		})
+	}
+}
+
 func TestStats_ErrorCallback(t *testing.T) {
 
 	t.Run("no error", func(tt *testing.T) {
@@ -988,13 +1257,14 @@ func TestStats_ErrorCallback(t *testing.T) {
 
 		stats.flushWG.Add(1)
 		stats.send(
+			context.Background(),
 			map[string]*metric{
 				"test": {
 					name:  "test",
 					class: client.Gauge,
 					value: 10,
 				},
-			}, time.Second*10,
+			}, time.Second*10, nil,
 		)
 
 		if callbackError != nil {
@@ -1011,22 +1281,29 @@ func TestStats_ErrorCallback(t *testing.T) {
 
 		var callbackError error
 		var metricSeries []*client.DDMetric
+		called := make(chan bool)
 		stats.ErrorCallback(func(err error, m []*client.DDMetric) {
 			callbackError = err
 			metricSeries = m
+			close(called)
 		})
 
 		stats.flushWG.Add(1)
 		stats.send(
+			context.Background(),
 			map[string]*metric{
 				"test": {
 					name:  "test",
 					class: client.Gauge,
 					value: 10,
 				},
-			}, time.Second*10,
+			}, time.Second*10, nil,
 		)
 
+		select {
+		case <-called:
+		case <-time.After(time.Second):
+		}
 		if callbackError == nil {
 			tt.Fatalf("expected to have call back called with error, have nil")
 		}
@@ -1103,7 +1380,7 @@ func Test_appendErrorsList(t *testing.T) {
 
 	t.Run("under max", func(tt *testing.T) {
 
-		result := appendErrorsList([]error{fmt.Errorf("one")}, fmt.Errorf("two"), 2)
+		result, _ := appendErrorsList([]error{fmt.Errorf("one")}, []ErrorClass{ErrorClassSeries}, fmt.Errorf("two"), ErrorClassSeries, 2)
 
 		if len(result) != 2 {
 			tt.Fatalf("expected to have %d errors returned, have %d", 2, len(result))
@@ -1112,7 +1389,7 @@ func Test_appendErrorsList(t *testing.T) {
 
 	t.Run("over max", func(tt *testing.T) {
 
-		result := appendErrorsList([]error{fmt.Errorf("one")}, fmt.Errorf("two"), 1)
+		result, _ := appendErrorsList([]error{fmt.Errorf("one")}, []ErrorClass{ErrorClassSeries}, fmt.Errorf("two"), ErrorClassSeries, 1)
 
 		if len(result) != 1 {
 			tt.Fatalf("expected to have %d errors returned, have %d", 1, len(result))
@@ -1122,3 +1399,198 @@ func Test_appendErrorsList(t *testing.T) {
 		}
 	})
 }
+
+func Test_applyNamespace(t *testing.T) {
+
+	tests := []struct {
+		namespace string
+		name      string
+		mode      NamespaceMode
+		expected  string
+	}{
+		{"", "count", NamespaceModePrefix, "count"},
+		{"app", "count", NamespaceModePrefix, "app.count"},
+		{"app", "app.count", NamespaceModePrefix, "app.count"},
+		{"app", "apples.count", NamespaceModePrefix, "apples.count"},
+		{"app.", "count", NamespaceModePrefix, "app.count"},
+		{"app", "count", NamespaceModeBoundary, "app.count"},
+		{"app", "app.count", NamespaceModeBoundary, "app.count"},
+		{"app", "apples.count", NamespaceModeBoundary, "app.apples.count"},
+		{"app.", "app.count", NamespaceModeBoundary, "app.count"},
+		{"app", "app", NamespaceModeBoundary, "app"},
+		{"app", "app.count", NamespaceModeStrict, "app.app.count"},
+		{"app.", "count", NamespaceModeStrict, "app.count"},
+	}
+
+	for _, test := range tests {
+		t.Run(fmt.Sprintf("%s %s %s", test.mode, test.namespace, test.name), func(tt *testing.T) {
+			if name := applyNamespace(test.namespace, test.name, test.mode); name != test.expected {
+				tt.Fatalf("expected name to be %s, have %s", test.expected, name)
+			}
+		})
+	}
+}
+
+func TestStats_StrictNamespaceFlush(t *testing.T) {
+
+	testClient := NewTestAPIClient()
+	stats, err := NewStats(NewConfig().
+		WithNamespace(testNamespace).
+		WithNamespaceMode(NamespaceModeStrict).
+		WithHost(testHost).
+		WithClient(testClient))
+	if err != nil {
+		t.Fatalf(err.Error())
+	}
+	stats.Gauge("test", 1, nil)
+	stats.QueueSeries([]*client.DDMetric{{Metric: "queued", Type: client.Gauge}})
+	stats.Close()
+
+	for _, m := range testClient.series[0].Series {
+		if m.Metric != "testNamespace.test" && m.Metric != "testNamespace.queued" {
+			t.Fatalf("expected namespace to be prepended once, have %s", m.Metric)
+		}
+	}
+}
+
+func TestStats_enqueueBlocking(t *testing.T) {
+
+	newFullStats := func() *Stats {
+		s := &Stats{
+			jobs:           make(chan *job, 1),
+			accounting:     newAccounting(),
+			stopCollectors: make(chan bool),
+			clock:          systemClock{},
+		}
+		s.jobs <- &job{}
+		return s
+	}
+	m := &metric{name: "test", class: client.Count, value: 1}
+
+	t.Run("drop", func(tt *testing.T) {
+		s := newFullStats()
+		s.enqueue(m, metricOptions{})
+		if s.GetDroppedMetricCount() != 1 {
+			tt.Fatalf("expected %d dropped metric, have %d", 1, s.GetDroppedMetricCount())
+		}
+	})
+
+	t.Run("timeout", func(tt *testing.T) {
+		s := newFullStats()
+		s.blockOnFull, s.blockTimeout = true, time.Millisecond*20
+		start := time.Now()
+		s.enqueue(m, metricOptions{})
+		if time.Since(start) < s.blockTimeout {
+			tt.Fatalf("expected enqueue to block for %s, blocked for %s", s.blockTimeout, time.Since(start))
+		}
+		if s.GetDroppedMetricCount() != 1 {
+			tt.Fatalf("expected %d dropped metric after timeout, have %d", 1, s.GetDroppedMetricCount())
+		}
+	})
+
+	t.Run("timeout clock", func(tt *testing.T) {
+		s := newFullStats()
+		clock := NewManualClock(time.Unix(1000, 0))
+		s.clock = clock
+		s.blockOnFull, s.blockTimeout = true, time.Minute
+		done := make(chan bool)
+		go func() {
+			s.enqueue(m, metricOptions{})
+			close(done)
+		}()
+
+		// The timeout is measured by the stats clock, enqueue blocks until it's advanced
+		for {
+			clock.lock.Lock()
+			timers := len(clock.timers)
+			clock.lock.Unlock()
+			if timers > 0 {
+				break
+			}
+			time.Sleep(time.Millisecond)
+		}
+		select {
+		case <-done:
+			tt.Fatalf("expected enqueue to block until the clock is advanced")
+		case <-time.After(time.Millisecond * 20):
+		}
+		clock.Advance(time.Minute)
+		<-done
+		if s.GetDroppedMetricCount() != 1 {
+			tt.Fatalf("expected %d dropped metric after timeout, have %d", 1, s.GetDroppedMetricCount())
+		}
+	})
+
+	t.Run("queued", func(tt *testing.T) {
+		s := newFullStats()
+		go func() {
+			time.Sleep(time.Millisecond * 10)
+			<-s.jobs
+		}()
+		s.enqueue(m, metricOptions{block: true})
+		if s.GetDroppedMetricCount() != 0 {
+			tt.Fatalf("expected no dropped metrics, have %d", s.GetDroppedMetricCount())
+		}
+		if j := <-s.jobs; j.metric != m {
+			tt.Fatalf("expected metric to be queued")
+		}
+	})
+
+	t.Run("closed", func(tt *testing.T) {
+		s := newFullStats()
+		close(s.stopCollectors)
+		s.enqueue(m, metricOptions{block: true})
+		if s.GetDroppedMetricCount() != 1 {
+			tt.Fatalf("expected %d dropped metric after close, have %d", 1, s.GetDroppedMetricCount())
+		}
+	})
+}
+
+func TestStats_StrictSeries(t *testing.T) {
+
+	testApi := NewTestAPIClient()
+	stats, err := NewStats(NewConfig().
+		WithNamespace(testNamespace).
+		WithHost(testHost).
+		WithClient(testApi).
+		WithStrictSeries(true))
+	if err != nil {
+		t.Fatalf(err.Error())
+	}
+	defer stats.Close()
+
+	invalid := []*client.DDMetric{{Metric: "test", Points: [][2]interface{}{{time.Now().Unix(), "one"}}}}
+	if err := stats.SendSeries(invalid); err == nil {
+		t.Fatalf("expected error sending invalid series")
+	}
+	if err := stats.QueueSeries(invalid); err == nil {
+		t.Fatalf("expected error queueing invalid series")
+	}
+	valid := []*client.DDMetric{{Metric: "test", Points: [][2]interface{}{{time.Now().Unix(), 1.0}}}}
+	if err := stats.SendSeries(valid); err != nil {
+		t.Fatalf("expected no error sending valid series, have %s", err.Error())
+	}
+
+	testApi.lock.Lock()
+	defer testApi.lock.Unlock()
+	if len(testApi.series) != 1 {
+		t.Fatalf("expected only the valid series to be sent, have %d series", len(testApi.series))
+	}
+}
+
+func TestStats_SubmitCopiesTags(t *testing.T) {
+
+	stats, _, err := NewTestStats()
+	if err != nil {
+		t.Fatalf(err.Error())
+	}
+
+	tags := []string{"b:2", "a:1"}
+	stats.Increment("test", tags)
+	stats.Gauge("test", 1, tags, WithTags("c:3"))
+	stats.Close()
+
+	if tags[0] != "b:2" || tags[1] != "a:1" || cap(tags) != 2 {
+		t.Fatalf("expected the caller tags to be unchanged, have %v", tags)
+	}
+}
@@ -0,0 +1,258 @@
+package ddstats
+
+import (
+	"fmt"
+	"net"
+	"strconv"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"github.com/jmizell/ddstats/client"
+)
+
+// statsDMaxPacketSize is the largest datagram the statsd server reads.
+const statsDMaxPacketSize = 65535
+
+// StatsDServer is a UDP listener that parses statsd, and dogstatsd lines sent by other
+// processes, and records them with stats, so they're aggregated, and flushed with the
+// metrics of the application. This makes stats a lightweight forwarding agent.
+//
+// Counts (c), gauges (g), histograms (h), timings (ms), distributions (d), and sets (s)
+// are supported, with sample rates, tags, timestamps, and multiple values per line.
+// Timings are recorded with Timing, so the duration unit applies. Service checks, and
+// events are sent to the api as they're received. Names are recorded as is, the
+// namespace is applied the same as to any other metric.
+type StatsDServer struct {
+	conn     net.PacketConn
+	stats    *Stats
+	received uint64
+	invalid  uint64
+	closed   int32
+	wg       sync.WaitGroup
+}
+
+// NewStatsDServer starts a statsd server listening on the UDP address addr, such as
+// 127.0.0.1:8125. Metrics are recorded with stats until the server is closed.
+func NewStatsDServer(addr string, stats *Stats) (*StatsDServer, error) {
+
+	conn, err := net.ListenPacket("udp", addr)
+	if err != nil {
+		return nil, fmt.Errorf("could not listen for statsd, %s", err.Error())
+	}
+
+	s := &StatsDServer{
+		conn:  conn,
+		stats: stats,
+	}
+	s.wg.Add(1)
+	go s.serve()
+	return s, nil
+}
+
+// Addr returns the address the server is listening on.
+func (s *StatsDServer) Addr() net.Addr {
+	return s.conn.LocalAddr()
+}
+
+// Close stops the server, and waits for the received lines to be recorded.
+func (s *StatsDServer) Close() error {
+	if !atomic.CompareAndSwapInt32(&s.closed, 0, 1) {
+		return nil
+	}
+	err := s.conn.Close()
+	s.wg.Wait()
+	return err
+}
+
+// GetReceivedCount returns the number of lines received.
+func (s *StatsDServer) GetReceivedCount() uint64 {
+	return atomic.LoadUint64(&s.received)
+}
+
+// GetInvalidCount returns the number of lines that could not be parsed, and were
+// dropped.
+func (s *StatsDServer) GetInvalidCount() uint64 {
+	return atomic.LoadUint64(&s.invalid)
+}
+
+func (s *StatsDServer) serve() {
+	defer s.wg.Done()
+	buf := make([]byte, statsDMaxPacketSize)
+	for {
+		n, _, err := s.conn.ReadFrom(buf)
+		if err != nil {
+			if atomic.LoadInt32(&s.closed) == 1 {
+				return
+			}
+			continue
+		}
+		for _, line := range strings.Split(string(buf[:n]), "\n") {
+			line = strings.TrimRight(line, "\r")
+			if line == "" {
+				continue
+			}
+			atomic.AddUint64(&s.received, 1)
+			if err := s.handle(line); err != nil {
+				atomic.AddUint64(&s.invalid, 1)
+			}
+		}
+	}
+}
+
+// handle parses line, and records it with stats.
+func (s *StatsDServer) handle(line string) error {
+	switch {
+	case strings.HasPrefix(line, "_sc|"):
+		return s.handleServiceCheck(line)
+	case strings.HasPrefix(line, "_e{"):
+		return s.handleEvent(line)
+	}
+	return s.handleMetric(line)
+}
+
+// handleMetric records a metric line, name:value[:value...]|type[|@rate][|#tags][|Tts].
+func (s *StatsDServer) handleMetric(line string) error {
+
+	fields := strings.Split(line, "|")
+	colon := strings.Index(fields[0], ":")
+	if len(fields) < 2 || colon <= 0 {
+		return fmt.Errorf("invalid statsd line %q", line)
+	}
+	name, rawValues, metricType := fields[0][:colon], fields[0][colon+1:], fields[1]
+
+	rate := 1.0
+	var tags []string
+	var opts []MetricOption
+	for _, field := range fields[2:] {
+		switch {
+		case strings.HasPrefix(field, "@"):
+			r, err := strconv.ParseFloat(field[1:], 64)
+			if err != nil || r <= 0 || r > 1 {
+				return fmt.Errorf("invalid statsd sample rate %q", field)
+			}
+			rate = r
+		case strings.HasPrefix(field, "#"):
+			tags = strings.Split(field[1:], ",")
+		case strings.HasPrefix(field, "T"):
+			ts, err := strconv.ParseInt(field[1:], 10, 64)
+			if err != nil {
+				return fmt.Errorf("invalid statsd timestamp %q", field)
+			}
+			opts = append(opts, WithTimestamp(time.Unix(ts, 0)))
+		}
+	}
+
+	// Set members may contain colons, other types may pack multiple values in a line
+	if metricType == "s" {
+		s.stats.Set(name, rawValues, tags, opts...)
+		return nil
+	}
+	values := make([]float64, 0, 1)
+	for _, raw := range strings.Split(rawValues, ":") {
+		v, err := strconv.ParseFloat(raw, 64)
+		if err != nil {
+			return fmt.Errorf("invalid statsd value %q", raw)
+		}
+		values = append(values, v)
+	}
+
+	for _, v := range values {
+		switch metricType {
+		case "c":
+			s.stats.Count(name, v/rate, tags, opts...)
+		case "g":
+			s.stats.Gauge(name, v, tags, opts...)
+		case "h":
+			s.stats.Histogram(name, v, tags, opts...)
+		case "ms":
+			s.stats.Timing(name, time.Duration(v*float64(time.Millisecond)), tags, opts...)
+		case "d":
+			s.stats.Distribution(name, v, tags, opts...)
+		default:
+			return fmt.Errorf("invalid statsd metric type %q", metricType)
+		}
+	}
+	return nil
+}
+
+// handleServiceCheck sends a service check line, _sc|name|status[|d:ts][|h:host][|#tags][|m:msg].
+func (s *StatsDServer) handleServiceCheck(line string) error {
+
+	fields := strings.Split(line, "|")
+	if len(fields) < 3 {
+		return fmt.Errorf("invalid statsd service check %q", line)
+	}
+	status, err := strconv.Atoi(fields[2])
+	if err != nil || status < client.Okay || status > client.Unknown {
+		return fmt.Errorf("invalid statsd service check status %q", fields[2])
+	}
+
+	var tags []string
+	var message string
+	for i := 3; i < len(fields); i++ {
+		if strings.HasPrefix(fields[i], "m:") {
+			// The message is the last field, and may contain the separator
+			message = strings.Replace(strings.Join(fields[i:], "|")[2:], "\\n", "\n", -1)
+			break
+		}
+		if strings.HasPrefix(fields[i], "#") {
+			tags = strings.Split(fields[i][1:], ",")
+		}
+	}
+
+	if err := s.stats.ServiceCheck(fields[1], message, client.Status(status), tags); err != nil {
+		s.stats.addError(ErrorClassServiceCheck, err)
+	}
+	return nil
+}
+
+// handleEvent sends an event line, _e{title length,text length}:title|text[|fields]. The
+// text is dropped, DDEvent has no text.
+func (s *StatsDServer) handleEvent(line string) error {
+
+	header := strings.Index(line, "}:")
+	if header < 0 {
+		return fmt.Errorf("invalid statsd event %q", line)
+	}
+	lengths := strings.Split(line[len("_e{"):header], ",")
+	if len(lengths) != 2 {
+		return fmt.Errorf("invalid statsd event %q", line)
+	}
+	titleLen, err1 := strconv.Atoi(lengths[0])
+	textLen, err2 := strconv.Atoi(lengths[1])
+	body := line[header+2:]
+	if err1 != nil || err2 != nil || titleLen < 0 || textLen < 0 || titleLen+1+textLen > len(body) || body[titleLen] != '|' {
+		return fmt.Errorf("invalid statsd event %q", line)
+	}
+
+	event := &client.DDEvent{
+		Title: strings.Replace(body[:titleLen], "\\n", "\n", -1),
+	}
+	if rest := body[titleLen+1+textLen:]; rest != "" {
+		for _, field := range strings.Split(strings.TrimPrefix(rest, "|"), "|") {
+			switch {
+			case strings.HasPrefix(field, "d:"):
+				event.DateHappened, _ = strconv.ParseInt(field[2:], 10, 64)
+			case strings.HasPrefix(field, "h:"):
+				event.Host = field[2:]
+			case strings.HasPrefix(field, "k:"):
+				event.AggregationKey = field[2:]
+			case strings.HasPrefix(field, "p:"):
+				event.Priority = client.Priority(field[2:])
+			case strings.HasPrefix(field, "s:"):
+				event.SourceTypeName = field[2:]
+			case strings.HasPrefix(field, "t:"):
+				event.AlertType = client.AlertType(field[2:])
+			case strings.HasPrefix(field, "#"):
+				event.Tags = strings.Split(field[1:], ",")
+			}
+		}
+	}
+
+	if err := s.stats.Event(event); err != nil {
+		s.stats.addError(ErrorClassWarning, err)
+	}
+	return nil
+}
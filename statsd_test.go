@@ -0,0 +1,99 @@
+package ddstats
+
+import (
+	"net"
+	"testing"
+	"time"
+
+	"github.com/jmizell/ddstats/client"
+)
+
+func TestStatsDServer(t *testing.T) {
+
+	stats, testApi, err := NewTestStats()
+	if err != nil {
+		t.Fatalf(err.Error())
+	}
+	server, err := NewStatsDServer("127.0.0.1:0", stats)
+	if err != nil {
+		t.Fatalf(err.Error())
+	}
+
+	conn, err := net.Dial("udp", server.Addr().String())
+	if err != nil {
+		t.Fatalf(err.Error())
+	}
+	lines := "page.views:1|c|@0.5|#env:test\n" +
+		"page.views:1:2|c|#env:test\n" +
+		"queue.size:7|g\n" +
+		"users:alice|s\n" +
+		"users:bob|s\n" +
+		"latency:10|h\n" +
+		"not a metric\n" +
+		"_sc|db.up|2|#env:test|m:down|hard\n" +
+		"_e{5,4}:title|text|p:low|#env:test"
+	if _, err := conn.Write([]byte(lines)); err != nil {
+		t.Fatalf(err.Error())
+	}
+	_ = conn.Close()
+
+	for i := 0; i < 100 && server.GetReceivedCount() < 9; i++ {
+		time.Sleep(time.Millisecond * 10)
+	}
+	if err := server.Close(); err != nil {
+		t.Fatalf(err.Error())
+	}
+	stats.Close()
+
+	if server.GetReceivedCount() != 9 || server.GetInvalidCount() != 1 {
+		t.Fatalf("expected 9 lines received, and 1 invalid, have %d, and %d", server.GetReceivedCount(), server.GetInvalidCount())
+	}
+
+	testApi.lock.Lock()
+	defer testApi.lock.Unlock()
+	values := map[string]float64{}
+	for _, series := range testApi.series {
+		for _, m := range series.Series {
+			values[m.Metric] = m.Points[0][1].(float64)
+		}
+	}
+	for name, expected := range map[string]float64{
+		"page.views":  5,
+		"queue.size":  7,
+		"users":       2,
+		"latency.max": 10,
+	} {
+		if v := values[prependNamespace(testNamespace, name)]; v != expected {
+			t.Fatalf("expected metric %s to be %f, have %f", name, expected, v)
+		}
+	}
+
+	if len(testApi.checks) != 1 || testApi.checks[0].Status != client.Critical || testApi.checks[0].Message != "down|hard" {
+		t.Fatalf("expected a critical service check with message down|hard, have %v", testApi.checks)
+	}
+	if len(testApi.events) != 1 || testApi.events[0].Title != "title" || testApi.events[0].Priority != client.PriorityLow {
+		t.Fatalf("expected a low priority event titled title, have %v", testApi.events)
+	}
+}
+
+func TestStatsDServer_handle(t *testing.T) {
+
+	s := &StatsDServer{}
+	for _, line := range []string{
+		"name",
+		"name:1",
+		":1|c",
+		"name:x|c",
+		"name:1|x",
+		"name:1|c|@2",
+		"name:1|c|Tnow",
+		"_sc|check",
+		"_sc|check|9",
+		"_e{5,4}:short",
+		"_e{x,4}:title|text",
+	} {
+		if err := s.handle(line); err == nil {
+			t.Fatalf("expected line %q to be invalid", line)
+		}
+	}
+}
@@ -0,0 +1,29 @@
+package ddstats
+
+import (
+	"time"
+
+	"github.com/jmizell/ddstats/client"
+)
+
+// Statser is the interface of the common methods of Stats, for packages that report
+// metrics, but leave creating stats to the application. Depending on Statser, instead
+// of *Stats, lets tests, and applications pass in another implementation, such as a test
+// double. Stats from NewNoop, or with a RecordingClient from the ddstatstest package also
+// satisfy it.
+type Statser interface {
+	Increment(name string, tags []string, opts ...MetricOption)
+	Decrement(name string, tags []string, opts ...MetricOption)
+	Count(name string, value float64, tags []string, opts ...MetricOption)
+	Gauge(name string, value float64, tags []string, opts ...MetricOption)
+	Histogram(name string, value float64, tags []string, opts ...MetricOption)
+	Distribution(name string, value float64, tags []string, opts ...MetricOption)
+	Set(name string, value string, tags []string, opts ...MetricOption)
+	Timing(name string, d time.Duration, tags []string, opts ...MetricOption)
+	Event(event *client.DDEvent) error
+	ServiceCheck(check, message string, status client.Status, tags []string) error
+	Flush()
+	Close()
+}
+
+var _ Statser = (*Stats)(nil)
@@ -0,0 +1,107 @@
+package ddstats
+
+import (
+	"testing"
+	"time"
+
+	"github.com/jmizell/ddstats/client"
+)
+
+// recordingStatser is a Statser test double, it counts the calls of each method.
+type recordingStatser struct {
+	calls map[string]int
+}
+
+func (r *recordingStatser) Increment(string, []string, ...MetricOption) {
+	r.calls["increment"]++
+}
+
+func (r *recordingStatser) Decrement(string, []string, ...MetricOption) {
+	r.calls["decrement"]++
+}
+
+func (r *recordingStatser) Count(string, float64, []string, ...MetricOption) {
+	r.calls["count"]++
+}
+
+func (r *recordingStatser) Gauge(string, float64, []string, ...MetricOption) {
+	r.calls["gauge"]++
+}
+
+func (r *recordingStatser) Histogram(string, float64, []string, ...MetricOption) {
+	r.calls["histogram"]++
+}
+
+func (r *recordingStatser) Distribution(string, float64, []string, ...MetricOption) {
+	r.calls["distribution"]++
+}
+
+func (r *recordingStatser) Set(string, string, []string, ...MetricOption) {
+	r.calls["set"]++
+}
+
+func (r *recordingStatser) Timing(string, time.Duration, []string, ...MetricOption) {
+	r.calls["timing"]++
+}
+
+func (r *recordingStatser) Event(*client.DDEvent) error {
+	r.calls["event"]++
+	return nil
+}
+
+func (r *recordingStatser) ServiceCheck(string, string, client.Status, []string) error {
+	r.calls["service_check"]++
+	return nil
+}
+
+func (r *recordingStatser) Flush() {
+	r.calls["flush"]++
+}
+
+func (r *recordingStatser) Close() {
+	r.calls["close"]++
+}
+
+// handleRequest is an example of code that depends on Statser, instead of *Stats.
+func handleRequest(stats Statser) {
+	stats.Increment("requests", []string{"path:/"})
+	stats.Timing("request.duration", time.Millisecond, nil)
+}
+
+func TestStatser(t *testing.T) {
+
+	t.Run("stats", func(tt *testing.T) {
+		stats, testApi, err := NewTestStats()
+		if err != nil {
+			tt.Fatalf(err.Error())
+		}
+		handleRequest(stats)
+		stats.Close()
+
+		testApi.lock.Lock()
+		defer testApi.lock.Unlock()
+		found := map[string]bool{}
+		for _, series := range testApi.series {
+			for _, m := range series.Series {
+				found[m.Metric] = true
+			}
+		}
+		if !found[prependNamespace(testNamespace, "requests")] {
+			tt.Fatalf("expected requests to be sent, have %v", found)
+		}
+	})
+
+	t.Run("noop", func(tt *testing.T) {
+		stats := NewNoop()
+		handleRequest(stats)
+		stats.Close()
+	})
+
+	t.Run("test double", func(tt *testing.T) {
+		stats := &recordingStatser{calls: map[string]int{}}
+		handleRequest(stats)
+		if stats.calls["increment"] != 1 || stats.calls["timing"] != 1 {
+			tt.Fatalf("expected increment, and timing calls, have %v", stats.calls)
+		}
+	})
+}
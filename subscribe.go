@@ -0,0 +1,118 @@
+package ddstats
+
+import (
+	"sync/atomic"
+	"time"
+
+	"github.com/jmizell/ddstats/client"
+)
+
+// SubscribePolicy controls what happens when a subscriber's channel buffer is full.
+type SubscribePolicy int
+
+// Subscribe policies
+const (
+	// SubscribeDrop drops the flushed series for a subscriber whose buffer is full.
+	// Dropped series are counted by GetDroppedSubscriptionCount.
+	SubscribeDrop SubscribePolicy = iota
+
+	// SubscribeBlock blocks delivery until the subscriber has room in its buffer. A slow
+	// subscriber will delay the callbacks, and subscribers of every following flush. If the
+	// subscriber has no room within one flush interval, or the shutdown timeout expires,
+	// the series are dropped, and counted by GetDroppedSubscriptionCount.
+	SubscribeBlock
+)
+
+type subscriber struct {
+	ch     chan []*client.DDMetric
+	policy SubscribePolicy
+}
+
+// Subscribe returns a channel that receives the series of every flush, in flush order.
+// Buffer sets the capacity of the channel, and policy sets the behavior when the buffer
+// is full. The channel is closed after the final flush, when stats is closed. The
+// series slice is shared with other subscribers, and callbacks, and must not be modified.
+func (c *Stats) Subscribe(buffer int, policy SubscribePolicy) <-chan []*client.DDMetric {
+
+	sub := &subscriber{
+		ch:     make(chan []*client.DDMetric, buffer),
+		policy: policy,
+	}
+
+	c.subscriberLock.Lock()
+	defer c.subscriberLock.Unlock()
+	if c.subscribersClosed {
+		close(sub.ch)
+		return sub.ch
+	}
+	c.subscribers = append(c.subscribers, sub)
+	return sub.ch
+}
+
+// GetDroppedSubscriptionCount returns the number of flushed series not delivered to
+// subscribers, because the subscriber's buffer was full.
+func (c *Stats) GetDroppedSubscriptionCount() uint64 {
+	return atomic.LoadUint64(&c.subscriberDropped)
+}
+
+// publish delivers the series to every subscriber. The subscriber list is copied, so the
+// lock isn't held while waiting on a blocking subscriber. Publish is only called in flush
+// order, and subscribers are closed after the final flush, so a copied subscriber can't
+// be closed during delivery.
+func (c *Stats) publish(series []*client.DDMetric) {
+
+	c.subscriberLock.Lock()
+	subscribers := make([]*subscriber, len(c.subscribers))
+	copy(subscribers, c.subscribers)
+	c.subscriberLock.Unlock()
+
+	for _, sub := range subscribers {
+		if sub.policy == SubscribeBlock {
+			c.publishBlocking(sub, series)
+			continue
+		}
+		select {
+		case sub.ch <- series:
+		default:
+			atomic.AddUint64(&c.subscriberDropped, 1)
+		}
+	}
+}
+
+// publishBlocking waits for the subscriber to have room for the series. A stalled
+// subscriber is given up on after one flush interval, or once the shutdown timeout
+// expires, so it can't block flushes, or Close forever.
+func (c *Stats) publishBlocking(sub *subscriber, series []*client.DDMetric) {
+
+	select {
+	case sub.ch <- series:
+		return
+	default:
+	}
+
+	var timeout <-chan time.Time
+	if c.flushInterval > 0 {
+		timer := c.clock.NewTimer(c.flushInterval)
+		defer timer.Stop()
+		timeout = timer.C()
+	}
+	select {
+	case sub.ch <- series:
+	case <-timeout:
+		c.log().Warnf("subscriber did not receive flushed series within %s, dropping them", c.flushInterval)
+		atomic.AddUint64(&c.subscriberDropped, 1)
+	case <-c.shutdownExpired:
+		atomic.AddUint64(&c.subscriberDropped, 1)
+	}
+}
+
+func (c *Stats) closeSubscribers() {
+
+	c.subscriberLock.Lock()
+	defer c.subscriberLock.Unlock()
+	for _, sub := range c.subscribers {
+		close(sub.ch)
+	}
+	c.subscribers = nil
+	c.subscribersClosed = true
+}
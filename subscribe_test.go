@@ -0,0 +1,134 @@
+package ddstats
+
+import (
+	"testing"
+	"time"
+
+	"github.com/jmizell/ddstats/client"
+)
+
+func TestStats_Subscribe(t *testing.T) {
+
+	t.Run("receive flushes", func(tt *testing.T) {
+		stats, _, err := NewTestStats()
+		if err != nil {
+			tt.Fatalf(err.Error())
+		}
+
+		ch := stats.Subscribe(10, SubscribeBlock)
+		stats.Gauge("test1", 1, nil)
+		stats.Flush()
+		stats.Gauge("test2", 2, nil)
+		stats.Close()
+
+		var received [][]*client.DDMetric
+		for series := range ch {
+			received = append(received, series)
+		}
+		if len(received) != 2 {
+			tt.Fatalf("expected %d flushes, have %d", 2, len(received))
+		}
+		if received[0][0].Metric != "testNamespace.test1" {
+			tt.Fatalf("expected first flush to have metric %s, have %s", "testNamespace.test1", received[0][0].Metric)
+		}
+		if received[1][0].Metric != "testNamespace.test2" {
+			tt.Fatalf("expected second flush to have metric %s, have %s", "testNamespace.test2", received[1][0].Metric)
+		}
+	})
+
+	t.Run("drop when full", func(tt *testing.T) {
+		stats, _, err := NewTestStats()
+		if err != nil {
+			tt.Fatalf(err.Error())
+		}
+
+		ch := stats.Subscribe(1, SubscribeDrop)
+		for i := 0; i < 3; i++ {
+			stats.Gauge("test", 1, nil)
+			stats.Flush()
+		}
+		stats.Close()
+
+		count := 0
+		for range ch {
+			count++
+		}
+		if count != 1 {
+			tt.Fatalf("expected %d flushes, have %d", 1, count)
+		}
+		if dropped := stats.GetDroppedSubscriptionCount(); dropped != 2 {
+			tt.Fatalf("expected %d dropped flushes, have %d", 2, dropped)
+		}
+	})
+
+	t.Run("subscribe after close", func(tt *testing.T) {
+		stats, _, err := NewTestStats()
+		if err != nil {
+			tt.Fatalf(err.Error())
+		}
+		stats.Close()
+
+		if _, ok := <-stats.Subscribe(1, SubscribeDrop); ok {
+			tt.Fatalf("expected channel to be closed")
+		}
+	})
+	t.Run("stalled blocking subscriber", func(tt *testing.T) {
+		clock := NewManualClock(time.Unix(1000, 0))
+		cfg := NewConfig().
+			WithNamespace(testNamespace).
+			WithHost(testHost).
+			WithClient(NewTestAPIClient()).
+			WithClock(clock)
+		stats, err := NewStats(cfg)
+		if err != nil {
+			tt.Fatalf(err.Error())
+		}
+
+		stats.Subscribe(0, SubscribeBlock)
+		stats.Gauge("test", 1, nil)
+		flushed := make(chan bool)
+		go func() {
+			stats.Flush()
+			close(flushed)
+		}()
+
+		// The subscriber never receives, so the flush is released once the clock
+		// passes one flush interval
+		for done := false; !done; {
+			select {
+			case <-flushed:
+				done = true
+			case <-time.After(10 * time.Millisecond):
+				clock.Advance(stats.flushInterval)
+			}
+		}
+		if dropped := stats.GetDroppedSubscriptionCount(); dropped == 0 {
+			tt.Fatalf("expected the stalled subscriber's flush to be dropped")
+		}
+	})
+
+	t.Run("close with stalled blocking subscriber", func(tt *testing.T) {
+		cfg := NewConfig().
+			WithNamespace(testNamespace).
+			WithHost(testHost).
+			WithClient(NewTestAPIClient()).
+			WithShutdownTimeout(50 * time.Millisecond)
+		stats, err := NewStats(cfg)
+		if err != nil {
+			tt.Fatalf(err.Error())
+		}
+
+		stats.Subscribe(0, SubscribeBlock)
+		stats.Gauge("test", 1, nil)
+		closed := make(chan bool)
+		go func() {
+			stats.Close()
+			close(closed)
+		}()
+		select {
+		case <-closed:
+		case <-time.After(5 * time.Second):
+			tt.Fatalf("expected close to return once the shutdown timeout expired")
+		}
+	})
+}
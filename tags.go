@@ -0,0 +1,281 @@
+package ddstats
+
+import (
+	"fmt"
+	"strings"
+	"unicode"
+	"unicode/utf8"
+)
+
+// OverflowTagValue is the tag value used in place of values beyond a cap.
+const OverflowTagValue = "other"
+
+// DefaultMaxTagLength is the longest tag accepted by Datadog, longer tags are truncated
+// by the api.
+const DefaultMaxTagLength = 200
+
+// TagLengthPolicy controls how tags longer than the max tag length are handled.
+type TagLengthPolicy string
+
+// Tag length policies
+const (
+	// TagLengthAllow sends long tags as is, and leaves truncation to the api. This is the
+	// default.
+	TagLengthAllow = TagLengthPolicy("")
+
+	// TagLengthTruncate truncates long tags to the max tag length, so metrics with tags
+	// that only differ after the max length are aggregated client side, the same as they
+	// would be by the api.
+	TagLengthTruncate = TagLengthPolicy("truncate")
+
+	// TagLengthReject drops metric updates with a long tag.
+	TagLengthReject = TagLengthPolicy("reject")
+)
+
+// ExpandTags returns a key:value tag for each unique value, for use with slice valued
+// dimensions, ExpandTags("features", []string{"a", "b"}, 10) returns features:a, and
+// features:b. At most max tags are returned. If there are more than max unique values,
+// the first max-1 values are kept, and the rest are replaced by a single key:other tag.
+// A max of zero or less is treated as no cap. Empty values are ignored.
+func ExpandTags(key string, values []string, max int) []string {
+
+	seen := make(map[string]bool, len(values))
+	unique := make([]string, 0, len(values))
+	for _, v := range values {
+		if v == "" || seen[v] {
+			continue
+		}
+		seen[v] = true
+		unique = append(unique, v)
+	}
+
+	overflow := false
+	if max > 0 && len(unique) > max {
+		unique = unique[:max-1]
+		overflow = true
+	}
+
+	tags := make([]string, 0, len(unique)+1)
+	for _, v := range unique {
+		tags = append(tags, fmt.Sprintf("%s:%s", key, v))
+	}
+	if overflow {
+		tags = append(tags, fmt.Sprintf("%s:%s", key, OverflowTagValue))
+	}
+
+	return tags
+}
+
+// TagError describes a problem found with a tag during normalization.
+type TagError struct {
+	Tag    string
+	Reason string
+}
+
+func (e *TagError) Error() string {
+	return fmt.Sprintf("invalid tag %q, %s", e.Tag, e.Reason)
+}
+
+// ParseTags parses a comma separated list of tags from a string, such as an environment
+// variable. Whitespace around each tag is trimmed, whitespace inside a tag is kept, and
+// reported by NormalizeTags, which the parsed tags are normalized with.
+func ParseTags(s string) ([]string, []error) {
+	tags := strings.Split(s, ",")
+	for i, tag := range tags {
+		tags[i] = strings.TrimSpace(tag)
+	}
+	return NormalizeTags(tags)
+}
+
+// NormalizeTags cleans a list of tags, and reports any problems found. Tags are
+// normalized with the following steps, in order.
+//
+// Invalid UTF-8 sequences are removed, and leading, and trailing whitespace is trimmed.
+// Empty tags are dropped. Duplicate tags are dropped, keeping the first occurrence.
+//
+// The returned errors are *TagError values describing tags that were dropped, or that are
+// likely mistakes: tags that had invalid UTF-8, or surrounding whitespace, tags with
+// whitespace inside, tags without a colon, and tags with an empty key, or value. Tags
+// without a colon are accepted by Datadog, and are kept. In strict mode any reported
+// problem is treated as an error.
+func NormalizeTags(tags []string) ([]string, []error) {
+
+	var errs []error
+	seen := make(map[string]bool, len(tags))
+	normalized := make([]string, 0, len(tags))
+	for _, raw := range tags {
+
+		tag := strings.ToValidUTF8(raw, "")
+		if tag != raw {
+			errs = append(errs, &TagError{Tag: raw, Reason: "invalid utf-8 removed"})
+		}
+		if trimmed := strings.TrimSpace(tag); trimmed != tag {
+			if trimmed != "" {
+				errs = append(errs, &TagError{Tag: raw, Reason: "surrounding whitespace trimmed"})
+			}
+			tag = trimmed
+		}
+
+		switch {
+		case tag == "":
+			errs = append(errs, &TagError{Tag: raw, Reason: "empty tag dropped"})
+			continue
+		case seen[tag]:
+			errs = append(errs, &TagError{Tag: raw, Reason: "duplicate tag dropped"})
+			continue
+		case strings.IndexFunc(tag, unicode.IsSpace) >= 0:
+			errs = append(errs, &TagError{Tag: raw, Reason: "contains whitespace"})
+		case !strings.Contains(tag, ":"):
+			errs = append(errs, &TagError{Tag: raw, Reason: "missing key:value colon"})
+		case strings.HasPrefix(tag, ":"):
+			errs = append(errs, &TagError{Tag: raw, Reason: "empty key"})
+		case strings.HasSuffix(tag, ":"):
+			errs = append(errs, &TagError{Tag: raw, Reason: "empty value"})
+		}
+
+		seen[tag] = true
+		normalized = append(normalized, tag)
+	}
+
+	return normalized, errs
+}
+
+// limitTagLength applies policy to any tag in tags longer than max bytes. It returns the
+// tags to use, the number of long tags, and true if the tags were rejected. Tags are
+// copied before they're modified.
+func limitTagLength(tags []string, max int, policy TagLengthPolicy) ([]string, int, bool) {
+
+	if policy == TagLengthAllow || max <= 0 {
+		return tags, 0, false
+	}
+
+	long := 0
+	limited := tags
+	for i, tag := range tags {
+		if len(tag) <= max {
+			continue
+		}
+		long++
+		if policy == TagLengthReject {
+			continue
+		}
+		if long == 1 {
+			limited = append([]string(nil), tags...)
+		}
+		limited[i] = truncateUTF8(tag, max)
+	}
+
+	return limited, long, policy == TagLengthReject && long > 0
+}
+
+// truncateUTF8 truncates s to at most max bytes, without splitting a multi byte character.
+func truncateUTF8(s string, max int) string {
+	if len(s) <= max {
+		return s
+	}
+	for max > 0 && !utf8.RuneStart(s[max]) {
+		max--
+	}
+	return s[:max]
+}
+
+// SanitizeTag converts tag to the form Datadog stores it in. The tag is lower cased, any
+// character other than a letter, digit, underscore, minus, colon, period, or slash is
+// replaced with an underscore, and leading characters that aren't a letter are removed,
+// as tags must start with a letter. Tags longer than max bytes are truncated. An empty
+// result means the tag should be dropped.
+func SanitizeTag(tag string, max int) string {
+
+	if sanitizedTag(tag, max) {
+		return tag
+	}
+
+	tag = strings.ToLower(strings.ToValidUTF8(tag, ""))
+	tag = strings.Map(func(r rune) rune {
+		if unicode.IsLetter(r) || unicode.IsDigit(r) || strings.ContainsRune("_-:./", r) {
+			return r
+		}
+		return '_'
+	}, tag)
+	tag = strings.TrimLeftFunc(tag, func(r rune) bool { return !unicode.IsLetter(r) })
+	if max > 0 {
+		tag = truncateUTF8(tag, max)
+	}
+	return tag
+}
+
+// sanitizedTag returns true if tag is already sanitized, so the common case doesn't
+// allocate.
+func sanitizedTag(tag string, max int) bool {
+	if tag == "" || max > 0 && len(tag) > max {
+		return false
+	}
+	for i := 0; i < len(tag); i++ {
+		ch := tag[i]
+		switch {
+		case ch >= 'a' && ch <= 'z':
+		case i == 0:
+			return false
+		case ch >= '0' && ch <= '9', ch == '_', ch == '-', ch == ':', ch == '.', ch == '/':
+		default:
+			return false
+		}
+	}
+	return true
+}
+
+// SanitizeTags sanitizes each tag with SanitizeTag, and removes duplicate keys, keeping
+// the last value of each key, in the position of the first. Tags that are empty after
+// sanitizing are dropped. Tags is not modified, a new slice is returned if any tag
+// changed.
+func SanitizeTags(tags []string, max int) []string {
+
+	clean := true
+	for _, tag := range tags {
+		if !sanitizedTag(tag, max) {
+			clean = false
+			break
+		}
+	}
+	if clean && !duplicateTagKeys(tags) {
+		return tags
+	}
+
+	sanitized := make([]string, 0, len(tags))
+	index := make(map[string]int, len(tags))
+	for _, tag := range tags {
+		tag = SanitizeTag(tag, max)
+		if tag == "" {
+			continue
+		}
+		key := tagKey(tag)
+		if i, ok := index[key]; ok {
+			sanitized[i] = tag
+			continue
+		}
+		index[key] = len(sanitized)
+		sanitized = append(sanitized, tag)
+	}
+	return sanitized
+}
+
+// duplicateTagKeys returns true if any key appears in tags more than once.
+func duplicateTagKeys(tags []string) bool {
+	for i := 1; i < len(tags); i++ {
+		key := tagKey(tags[i])
+		for _, tag := range tags[:i] {
+			if tagKey(tag) == key {
+				return true
+			}
+		}
+	}
+	return false
+}
+
+// tagKey returns the key of a key:value tag, or the tag if it has no value.
+func tagKey(tag string) string {
+	if i := strings.Index(tag, ":"); i >= 0 {
+		return tag[:i]
+	}
+	return tag
+}
@@ -0,0 +1,185 @@
+package ddstats
+
+import (
+	"strings"
+	"testing"
+)
+
+func TestExpandTags(t *testing.T) {
+
+	tests := []struct {
+		name     string
+		values   []string
+		max      int
+		expected []string
+	}{
+		{"empty", nil, 3, []string{}},
+		{"under max", []string{"a", "b"}, 3, []string{"features:a", "features:b"}},
+		{"at max", []string{"a", "b", "c"}, 3, []string{"features:a", "features:b", "features:c"}},
+		{"over max", []string{"a", "b", "c", "d"}, 3, []string{"features:a", "features:b", "features:other"}},
+		{"duplicates", []string{"a", "a", "", "b"}, 3, []string{"features:a", "features:b"}},
+		{"no max", []string{"a", "b", "c", "d"}, 0, []string{"features:a", "features:b", "features:c", "features:d"}},
+		{"max one", []string{"a", "b"}, 1, []string{"features:other"}},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(tt *testing.T) {
+			tags := ExpandTags("features", test.values, test.max)
+			if strings.Join(tags, ",") != strings.Join(test.expected, ",") {
+				tt.Fatalf("expected tags to be %v, have %v", test.expected, tags)
+			}
+		})
+	}
+}
+
+func TestParseTags(t *testing.T) {
+
+	tests := []struct {
+		name     string
+		input    string
+		expected []string
+		errors   int
+	}{
+		{"comma separated", "a:1,b:2", []string{"a:1", "b:2"}, 0},
+		{"whitespace around tags", " a:1, b:2 ,\tc:3 ", []string{"a:1", "b:2", "c:3"}, 0},
+		{"whitespace inside tag", "a:1 b:2,c:3", []string{"a:1 b:2", "c:3"}, 1},
+		{"empty", " , ,", []string{}, 3},
+		{"duplicates", "a:1,a:1", []string{"a:1"}, 1},
+		{"colon-less", "a:1,flag", []string{"a:1", "flag"}, 1},
+		{"empty key and value", ":1,a:", []string{":1", "a:"}, 2},
+		{"unicode", "région:europe", []string{"région:europe"}, 0},
+		{"invalid utf-8", "a:\xff1", []string{"a:1"}, 1},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(tt *testing.T) {
+			tags, errs := ParseTags(test.input)
+			if strings.Join(tags, ",") != strings.Join(test.expected, ",") {
+				tt.Fatalf("expected tags to be %v, have %v", test.expected, tags)
+			}
+			if len(errs) != test.errors {
+				tt.Fatalf("expected %d errors, have %d: %v", test.errors, len(errs), errs)
+			}
+		})
+	}
+}
+
+func TestNormalizeTags(t *testing.T) {
+
+	tags, errs := NormalizeTags([]string{" a:1", "", "a:1", "b :2"})
+	if strings.Join(tags, ",") != "a:1,b :2" {
+		t.Fatalf("expected tags to be [a:1 b :2], have %v", tags)
+	}
+	if len(errs) != 4 {
+		t.Fatalf("expected %d errors, have %d: %v", 4, len(errs), errs)
+	}
+	if _, ok := errs[0].(*TagError); !ok {
+		t.Fatalf("expected errors to be *TagError, have %T", errs[0])
+	}
+}
+
+func TestNewStats_StrictTags(t *testing.T) {
+
+	t.Run("strict", func(tt *testing.T) {
+		cfg := NewConfig().WithClient(NewTestAPIClient()).WithTags([]string{"a:1", "flag"}).WithStrictTags(true)
+		if _, err := NewStats(cfg); err == nil {
+			tt.Fatalf("expected error with invalid global tags in strict mode")
+		}
+	})
+
+	t.Run("not strict", func(tt *testing.T) {
+		cfg := NewConfig().WithClient(NewTestAPIClient()).WithTags([]string{" a:1", "", "flag"})
+		stats, err := NewStats(cfg)
+		if err != nil {
+			tt.Fatalf("expected no error, have %s", err.Error())
+		}
+		defer stats.Close()
+		if strings.Join(stats.tags, ",") != "a:1,flag" {
+			tt.Fatalf("expected global tags to be normalized, have %v", stats.tags)
+		}
+	})
+}
+
+func Test_limitTagLength(t *testing.T) {
+
+	tags := []string{"short:1", "url:/a/very/long/path", "name:hhéllo"}
+
+	t.Run("allow", func(tt *testing.T) {
+		limited, long, reject := limitTagLength(tags, 8, TagLengthAllow)
+		if long != 0 || reject || limited[1] != tags[1] {
+			tt.Fatalf("expected tags to be unchanged, have %v %d %t", limited, long, reject)
+		}
+	})
+
+	t.Run("truncate", func(tt *testing.T) {
+		limited, long, reject := limitTagLength(tags, 8, TagLengthTruncate)
+		if long != 2 || reject {
+			tt.Fatalf("expected %d long tags, have %d %t", 2, long, reject)
+		}
+		expected := []string{"short:1", "url:/a/v", "name:hh"}
+		for i := range expected {
+			if limited[i] != expected[i] {
+				tt.Fatalf("expected tag %d to be %q, have %q", i, expected[i], limited[i])
+			}
+		}
+		if tags[1] != "url:/a/very/long/path" {
+			tt.Fatalf("expected original tags to be unchanged, have %v", tags)
+		}
+	})
+
+	t.Run("reject", func(tt *testing.T) {
+		_, long, reject := limitTagLength(tags, 8, TagLengthReject)
+		if long != 2 || !reject {
+			tt.Fatalf("expected tags to be rejected, have %d %t", long, reject)
+		}
+		if _, _, reject := limitTagLength(tags[:1], 8, TagLengthReject); reject {
+			tt.Fatalf("expected short tags to be accepted")
+		}
+	})
+}
+
+func TestSanitizeTags(t *testing.T) {
+
+	tags := []string{"env:prod", "Env:Staging", "_team:API Core", "1:2", "path:/a.b-c", "héllo:wörld", "long:" + strings.Repeat("x", 30)}
+	expected := []string{"env:staging", "team:api_core", "path:/a.b-c", "héllo:wörld", "long:" + strings.Repeat("x", 15)}
+	sanitized := SanitizeTags(tags, 20)
+	if strings.Join(sanitized, ",") != strings.Join(expected, ",") {
+		t.Fatalf("expected tags %v, have %v", expected, sanitized)
+	}
+	if tags[1] != "Env:Staging" {
+		t.Fatalf("expected the tags to not be modified, have %v", tags)
+	}
+
+	clean := []string{"a:1", "b:2"}
+	if sanitized := SanitizeTags(clean, 200); &sanitized[0] != &clean[0] {
+		t.Fatalf("expected sanitized tags to be returned as is")
+	}
+}
+
+func TestStats_WithTagNormalization(t *testing.T) {
+
+	testApi := NewTestAPIClient()
+	cfg := NewConfig().
+		WithNamespace(testNamespace).
+		WithHost(testHost).
+		WithClient(testApi).
+		WithTags([]string{"Region:US-East"}).
+		WithTagNormalization(true)
+	stats, err := NewStats(cfg)
+	if err != nil {
+		t.Fatalf(err.Error())
+	}
+	stats.Increment("test", []string{"Status:OK"})
+	stats.Increment("test", []string{"status:ok"})
+	stats.Close()
+
+	testApi.lock.Lock()
+	defer testApi.lock.Unlock()
+	if len(testApi.series) != 1 || len(testApi.series[0].Series) != 1 {
+		t.Fatalf("expected both increments to be aggregated in one series, have %v", testApi.series)
+	}
+	m := testApi.series[0].Series[0]
+	if !hasTag(m.Tags, "status:ok") || !hasTag(m.Tags, "region:us-east") || m.Points[0][1] != 2.0 {
+		t.Fatalf("expected a count of 2 with normalized tags, have %v, and %v", m.Points, m.Tags)
+	}
+}
@@ -0,0 +1,61 @@
+package ddstats
+
+import (
+	"github.com/jmizell/ddstats/internal/engine"
+)
+
+// TagSet is a canonical set of tags, sorted, and without duplicates, that is prepared once,
+// and reused across submissions with the typed metric handles, and persistent gauges.
+// Submissions with a tag set skip copying, sorting, and joining the tags, unless an option
+// adds tags, or a tag policy may change them, source tags, tag normalization, tag length
+// limits, or tenant limits, in which case the tags are submitted as usual.
+//
+//	var loginTags = ddstats.NewTagSet("endpoint:/login", "method:post")
+//
+//	metrics.RequestsTotal.IncWith(stats, loginTags)
+type TagSet struct {
+	set     *engine.TagSet
+	longest int
+}
+
+// NewTagSet creates a tag set from a copy of tags.
+func NewTagSet(tags ...string) TagSet {
+	set := engine.NewTagSet(tags)
+	longest := 0
+	for _, tag := range set.Tags() {
+		if len(tag) > longest {
+			longest = len(tag)
+		}
+	}
+	return TagSet{set: &set, longest: longest}
+}
+
+// Tags returns a copy of the sorted tags of the set.
+func (s TagSet) Tags() []string {
+	if s.set == nil {
+		return nil
+	}
+	return append([]string(nil), s.set.Tags()...)
+}
+
+// submitTagSet submits an update with the tags of ts. The key of the set is used as is,
+// when no option, or tag policy can change the tags.
+func (c *Stats) submitTagSet(name, class string, value float64, ts TagSet, opts []MetricOption) {
+
+	o := applyMetricOptions(opts)
+	if ts.set == nil || len(o.tags) > 0 || !c.tagSetReady(ts) {
+		c.submit(name, class, value, ts.Tags(), opts...)
+		return
+	}
+	if c.noop || !sampled(o.sampleRate) {
+		return
+	}
+
+	c.submitMetric(&metric{name: name, class: class, value: value, tags: ts.set.Tags(), tagKey: ts.set}, o)
+}
+
+// tagSetReady returns true if the tags of ts would be submitted unchanged.
+func (c *Stats) tagSetReady(ts TagSet) bool {
+	return !c.sourceTags && !c.tagNormalization && c.tenants == nil &&
+		(c.tagLengthPolicy == TagLengthAllow || c.maxTagLength <= 0 || ts.longest <= c.maxTagLength)
+}
@@ -0,0 +1,102 @@
+package ddstats
+
+import (
+	"strings"
+	"testing"
+)
+
+func TestTagSet(t *testing.T) {
+
+	requests := CountMetric{Name: "requests"}
+
+	t.Run("same key as tags", func(tt *testing.T) {
+		stats, testApi, err := NewTestStats()
+		if err != nil {
+			tt.Fatalf(err.Error())
+		}
+
+		tags := NewTagSet("b:2", "a:1", "b:2")
+		requests.IncWith(stats, tags)
+		requests.AddWith(stats, 2, tags)
+		requests.Inc(stats, []string{"a:1", "b:2"})
+		stats.Close()
+
+		testApi.lock.Lock()
+		defer testApi.lock.Unlock()
+		if len(testApi.series) != 1 || len(testApi.series[0].Series) != 1 {
+			tt.Fatalf("expected one series, have %v", testApi.series)
+		}
+		m := testApi.series[0].Series[0]
+		if value := m.Points[0][1].(float64); value != 4 {
+			tt.Fatalf("expected value %f, have %f", 4.0, value)
+		}
+		if !hasTag(m.Tags, "a:1") || !hasTag(m.Tags, "b:2") || !hasTag(m.Tags, testTags[0]) {
+			tt.Fatalf("expected the set, and global tags, have %v", m.Tags)
+		}
+		if strings.Join(tags.Tags(), ",") != "a:1,b:2" {
+			tt.Fatalf("expected the set to be unchanged, have %v", tags.Tags())
+		}
+	})
+
+	t.Run("options add tags", func(tt *testing.T) {
+		stats, testApi, err := NewTestStats()
+		if err != nil {
+			tt.Fatalf(err.Error())
+		}
+
+		requests.IncWith(stats, NewTagSet("a:1"), WithTags("c:3"))
+		stats.Close()
+
+		testApi.lock.Lock()
+		defer testApi.lock.Unlock()
+		if m := testApi.series[0].Series[0]; !hasTag(m.Tags, "a:1") || !hasTag(m.Tags, "c:3") {
+			tt.Fatalf("expected the set, and option tags, have %v", m.Tags)
+		}
+	})
+
+	t.Run("tag normalization", func(tt *testing.T) {
+		testApi := NewTestAPIClient()
+		stats, err := NewStats(NewConfig().
+			WithNamespace(testNamespace).
+			WithHost(testHost).
+			WithClient(testApi).
+			WithTagNormalization(true))
+		if err != nil {
+			tt.Fatalf(err.Error())
+		}
+
+		requests.IncWith(stats, NewTagSet("Env:Prod"))
+		requests.Inc(stats, []string{"Env:Prod"})
+		stats.Close()
+
+		testApi.lock.Lock()
+		defer testApi.lock.Unlock()
+		if len(testApi.series[0].Series) != 1 {
+			tt.Fatalf("expected the set to be normalized like tags, have %v", testApi.series[0].Series)
+		}
+	})
+}
+
+func BenchmarkTagSet(b *testing.B) {
+
+	stats, _, err := NewTestStats()
+	if err != nil {
+		b.Fatalf(err.Error())
+	}
+	defer stats.Close()
+
+	requests := CountMetric{Name: "requests"}
+	tags := []string{"endpoint:/login", "method:post", "status:200", "region:us-east-1"}
+	set := NewTagSet(tags...)
+
+	b.Run("tags", func(b *testing.B) {
+		for i := 0; i < b.N; i++ {
+			requests.Inc(stats, tags)
+		}
+	})
+	b.Run("tag set", func(b *testing.B) {
+		for i := 0; i < b.N; i++ {
+			requests.IncWith(stats, set)
+		}
+	})
+}
@@ -0,0 +1,145 @@
+package ddstats
+
+import (
+	"encoding/json"
+	"runtime/debug"
+	"sync/atomic"
+	"time"
+
+	"github.com/jmizell/ddstats/client"
+	"github.com/jmizell/ddstats/internal/engine"
+)
+
+// Client telemetry metric names, these are prepended with the namespace.
+const (
+	MetricClientFlushDuration = "ddstats.client.flush.duration"
+	MetricClientPayloadBytes  = "ddstats.client.payload_bytes"
+	MetricClientSeries        = "ddstats.client.series"
+	MetricClientAPIErrors     = "ddstats.client.api_errors"
+	MetricClientRetries       = "ddstats.client.retries"
+	MetricClientQueueDepth    = "ddstats.client.queue_depth"
+)
+
+// modulePath is the import path of the ddstats module.
+const modulePath = "github.com/jmizell/ddstats"
+
+// TagVersionKey is the key of the tag with the ddstats module version, added to the
+// client telemetry metrics.
+const TagVersionKey = "ddstats_version"
+
+// versionTag is the module version tag, read once from the build info.
+var versionTag = TagVersionKey + ":" + moduleVersion()
+
+// moduleVersion returns the version of the ddstats module in the build, or devel when
+// the version isn't known, such as in a build from a local checkout.
+func moduleVersion() string {
+
+	info, ok := debug.ReadBuildInfo()
+	if !ok {
+		return "devel"
+	}
+
+	// The module is a dependency, unless ddstats itself is being built, as in its tests
+	var module *debug.Module
+	if info.Main.Path == modulePath {
+		module = &info.Main
+	}
+	for _, dep := range info.Deps {
+		if dep.Path == modulePath {
+			module = dep
+		}
+	}
+	if module == nil {
+		return "devel"
+	}
+	if module.Replace != nil {
+		module = module.Replace
+	}
+	if module.Version == "" || module.Version == "(devel)" {
+		return "devel"
+	}
+	return module.Version
+}
+
+// telemetry holds the client telemetry recorded since the last report. The last flush
+// duration is stored in nanoseconds.
+type telemetry struct {
+	flushDuration int64
+	payloadBytes  uint64
+	series        uint64
+	apiErrors     uint64
+	retries       uint64
+}
+
+// recordSend records the result of sending series to the api during a flush.
+func (c *Stats) recordSend(series []*client.DDMetric, start time.Time, err error) {
+	if !c.telemetryEnabled {
+		return
+	}
+	atomic.StoreInt64(&c.telemetry.flushDuration, int64(time.Since(start)))
+	atomic.AddUint64(&c.telemetry.series, uint64(len(series)))
+	atomic.AddUint64(&c.telemetry.payloadBytes, uint64(seriesPayloadSize(series)))
+	if err != nil {
+		atomic.AddUint64(&c.telemetry.apiErrors, 1)
+	}
+}
+
+// seriesPayloadSize returns the size of the json encoded series, before compression.
+func seriesPayloadSize(series []*client.DDMetric) int {
+	b, err := json.Marshal(&client.DDMetricSeries{Series: series})
+	if err != nil {
+		return 0
+	}
+	return len(b)
+}
+
+// recordAPIError counts a failed api call, outside of the series send.
+func (c *Stats) recordAPIError() {
+	if c.telemetryEnabled {
+		atomic.AddUint64(&c.telemetry.apiErrors, 1)
+	}
+}
+
+// recordRetry counts an attempt to resend a series from the spool.
+func (c *Stats) recordRetry() {
+	if c.telemetryEnabled {
+		atomic.AddUint64(&c.telemetry.retries, 1)
+	}
+}
+
+// reportTelemetry queues the client telemetry recorded since the last report, if
+// enabled. The duration of the last flush is reported once, by the flush following it.
+// Queue depth is the number of metric updates waiting for the workers. Telemetry is tagged
+// with the ddstats module version.
+func (c *Stats) reportTelemetry() {
+
+	if !c.telemetryEnabled {
+		return
+	}
+
+	now := c.clock.Now().Unix()
+	interval, _ := engine.IntervalSeconds(c.EffectiveFlushInterval())
+	tags := combineTags(c.runtimeTags, []string{versionTag})
+	newMetric := func(name, class string, value float64) *client.DDMetric {
+		return &client.DDMetric{
+			Interval: interval,
+			Metric:   name,
+			Points:   [][2]interface{}{{now, value}},
+			Tags:     tags,
+			Type:     class,
+		}
+	}
+
+	series := []*client.DDMetric{
+		newMetric(MetricClientQueueDepth, client.Gauge, float64(len(c.jobs))),
+		newMetric(MetricClientPayloadBytes, client.Count, float64(atomic.SwapUint64(&c.telemetry.payloadBytes, 0))),
+		newMetric(MetricClientSeries, client.Count, float64(atomic.SwapUint64(&c.telemetry.series, 0))),
+		newMetric(MetricClientAPIErrors, client.Count, float64(atomic.SwapUint64(&c.telemetry.apiErrors, 0))),
+		newMetric(MetricClientRetries, client.Count, float64(atomic.SwapUint64(&c.telemetry.retries, 0))),
+	}
+	if d := atomic.SwapInt64(&c.telemetry.flushDuration, 0); d > 0 {
+		series = append(series, newMetric(MetricClientFlushDuration, client.Gauge, time.Duration(d).Seconds()))
+	}
+
+	_ = c.QueueSeries(series)
+}
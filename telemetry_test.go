@@ -0,0 +1,70 @@
+package ddstats
+
+import (
+	"fmt"
+	"testing"
+)
+
+func TestStats_ClientTelemetry(t *testing.T) {
+
+	testApi := NewTestAPIClient()
+	stats, err := NewStats(NewConfig().
+		WithNamespace(testNamespace).
+		WithHost(testHost).
+		WithClient(testApi).
+		WithClientTelemetry(true))
+	if err != nil {
+		t.Fatalf(err.Error())
+	}
+
+	stats.Gauge("a", 1, nil)
+	stats.Gauge("b", 1, nil)
+	stats.Flush()
+	testApi.lock.Lock()
+	testApi.sendSeriesError = fmt.Errorf("failed send")
+	testApi.lock.Unlock()
+	stats.Flush()
+	testApi.lock.Lock()
+	testApi.sendSeriesError = nil
+	testApi.lock.Unlock()
+	stats.Flush()
+	stats.Close()
+
+	testApi.lock.Lock()
+	defer testApi.lock.Unlock()
+	if len(testApi.series) < 3 {
+		t.Fatalf("expected at least %d series, have %d", 3, len(testApi.series))
+	}
+
+	// The second flush reports the first, which sent the two gauges, and five telemetry
+	// metrics. The third flush reports the failed second flush.
+	values := func(i int) map[string]float64 {
+		found := map[string]float64{}
+		for _, m := range testApi.series[i].Series {
+			found[m.Metric] = m.Points[0][1].(float64)
+		}
+		return found
+	}
+	for _, m := range testApi.series[1].Series {
+		if m.Metric != prependNamespace(testNamespace, MetricClientSeries) {
+			continue
+		}
+		if !hasTag(m.Tags, versionTag) {
+			t.Fatalf("expected telemetry to be tagged with %s, have %v", versionTag, m.Tags)
+		}
+	}
+	second := values(1)
+	if second[prependNamespace(testNamespace, MetricClientSeries)] != 7 {
+		t.Fatalf("expected %f series to be reported, have %v", 7.0, second)
+	}
+	if second[prependNamespace(testNamespace, MetricClientPayloadBytes)] <= 0 {
+		t.Fatalf("expected payload bytes to be reported, have %v", second)
+	}
+	if _, ok := second[prependNamespace(testNamespace, MetricClientFlushDuration)]; !ok {
+		t.Fatalf("expected flush duration to be reported, have %v", second)
+	}
+	third := values(2)
+	if third[prependNamespace(testNamespace, MetricClientAPIErrors)] != 1 {
+		t.Fatalf("expected %d api error to be reported, have %v", 1, third)
+	}
+}
@@ -0,0 +1,105 @@
+package ddstats
+
+import (
+	"strings"
+	"sync"
+	"sync/atomic"
+
+	"github.com/jmizell/ddstats/internal/engine"
+)
+
+// DefaultTenantTagKey is the tag key used to identify the tenant of a metric.
+const DefaultTenantTagKey = "tenant"
+
+// tenantLimiter caps the number of distinct series, and points each tenant can submit in
+// a flush interval. Submissions over a tenant's limits have the tenant tag value replaced
+// with OverflowTagValue, so one tenant can't exhaust a shared series budget.
+type tenantLimiter struct {
+	prefix    string // Tag key, and separator, tenant:
+	maxSeries int
+	maxPoints int
+	tenants   map[string]*tenantUsage
+	lock      *sync.Mutex
+	overflow  uint64
+}
+
+type tenantUsage struct {
+	series map[string]bool
+	points int
+}
+
+func newTenantLimiter(key string, maxSeries, maxPoints int) *tenantLimiter {
+	if key == "" {
+		key = DefaultTenantTagKey
+	}
+	return &tenantLimiter{
+		prefix:    key + ":",
+		maxSeries: maxSeries,
+		maxPoints: maxPoints,
+		tenants:   map[string]*tenantUsage{},
+		lock:      &sync.Mutex{},
+	}
+}
+
+// limit records a submission of name with tags, and returns the tags to use. If the tenant
+// is over its limits, a copy of tags is returned with the tenant replaced by the overflow
+// value. Tags without a tenant are returned unchanged.
+func (l *tenantLimiter) limit(name string, tags []string) []string {
+
+	index := -1
+	for i, tag := range tags {
+		if strings.HasPrefix(tag, l.prefix) {
+			index = i
+			break
+		}
+	}
+	if index < 0 {
+		return tags
+	}
+	tenant := tags[index][len(l.prefix):]
+	if tenant == OverflowTagValue {
+		return tags
+	}
+
+	// Copy the tags before creating the key, engine.Key sorts the tags in place
+	key := engine.Key(name, append([]string(nil), tags...))
+
+	l.lock.Lock()
+	usage, ok := l.tenants[tenant]
+	if !ok {
+		usage = &tenantUsage{series: map[string]bool{}}
+		l.tenants[tenant] = usage
+	}
+	over := (l.maxPoints > 0 && usage.points >= l.maxPoints) ||
+		(l.maxSeries > 0 && !usage.series[key] && len(usage.series) >= l.maxSeries)
+	if !over {
+		usage.series[key] = true
+		usage.points++
+	}
+	l.lock.Unlock()
+
+	if !over {
+		return tags
+	}
+	atomic.AddUint64(&l.overflow, 1)
+	overflowTags := append([]string(nil), tags...)
+	overflowTags[index] = l.prefix + OverflowTagValue
+	return overflowTags
+}
+
+// reset clears the usage of all tenants, at the start of a flush interval.
+func (l *tenantLimiter) reset() {
+	l.lock.Lock()
+	defer l.lock.Unlock()
+	l.tenants = make(map[string]*tenantUsage, len(l.tenants))
+}
+
+// GetTenantOverflowCount returns the number of metric submissions that were recorded under
+// the overflow tenant, because their tenant was over its limits. If tenant limits are not
+// enabled, zero is returned.
+func (c *Stats) GetTenantOverflowCount() uint64 {
+	if c.tenants == nil {
+		return 0
+	}
+	return atomic.LoadUint64(&c.tenants.overflow)
+}
@@ -0,0 +1,86 @@
+package ddstats
+
+import (
+	"testing"
+
+	"github.com/jmizell/ddstats/client"
+)
+
+func TestTenantLimiter(t *testing.T) {
+
+	t.Run("series limit", func(tt *testing.T) {
+		l := newTenantLimiter("", 2, 0)
+		for _, name := range []string{"a", "b", "a"} {
+			if tags := l.limit(name, []string{"tenant:acme"}); tags[0] != "tenant:acme" {
+				tt.Fatalf("expected metric %s to keep tenant, have %v", name, tags)
+			}
+		}
+		if tags := l.limit("c", []string{"env:prod", "tenant:acme"}); tags[1] != "tenant:other" {
+			tt.Fatalf("expected third series to overflow, have %v", tags)
+		}
+		if tags := l.limit("c", []string{"tenant:globex"}); tags[0] != "tenant:globex" {
+			tt.Fatalf("expected other tenants to be unaffected, have %v", tags)
+		}
+		if l.overflow != 1 {
+			tt.Fatalf("expected overflow count %d, have %d", 1, l.overflow)
+		}
+
+		l.reset()
+		if tags := l.limit("c", []string{"tenant:acme"}); tags[0] != "tenant:acme" {
+			tt.Fatalf("expected limits to reset, have %v", tags)
+		}
+	})
+
+	t.Run("points limit", func(tt *testing.T) {
+		l := newTenantLimiter("customer", 0, 2)
+		original := []string{"customer:acme"}
+		l.limit("a", original)
+		l.limit("a", original)
+		if tags := l.limit("a", original); tags[0] != "customer:other" {
+			tt.Fatalf("expected third point to overflow, have %v", tags)
+		}
+		if original[0] != "customer:acme" {
+			tt.Fatalf("expected caller tags to be unchanged, have %v", original)
+		}
+	})
+
+	t.Run("no tenant", func(tt *testing.T) {
+		l := newTenantLimiter("", 1, 1)
+		for i := 0; i < 3; i++ {
+			if tags := l.limit("a", []string{"env:prod"}); tags[0] != "env:prod" {
+				tt.Fatalf("expected untagged metric to be unchanged, have %v", tags)
+			}
+		}
+	})
+}
+
+func TestStats_TenantLimits(t *testing.T) {
+
+	testClient := NewTestAPIClient()
+	stats, err := NewStats(NewConfig().
+		WithNamespace(testNamespace).
+		WithHost(testHost).
+		WithClient(testClient).
+		WithTenantLimits("", 1, 0))
+	if err != nil {
+		t.Fatalf(err.Error())
+	}
+
+	stats.Count("a", 1, []string{"tenant:acme"})
+	stats.Count("b", 1, []string{"tenant:acme"})
+	stats.Close()
+
+	if err := testClient.FindMetric(0, &client.DDMetric{
+		Host:     testHost,
+		Interval: 1,
+		Metric:   "testNamespace.b",
+		Points:   [][2]interface{}{{0, float64(1)}},
+		Tags:     []string{"tenant:other"},
+		Type:     client.Count,
+	}); err != nil {
+		t.Fatalf(err.Error())
+	}
+	if overflow := stats.GetTenantOverflowCount(); overflow != 1 {
+		t.Fatalf("expected overflow count %d, have %d", 1, overflow)
+	}
+}
@@ -0,0 +1,43 @@
+package ddstats
+
+import (
+	"time"
+)
+
+// Timing records the duration d in a histogram metric. Durations are recorded in seconds,
+// or in the unit set with Config.WithDurationUnit, and are sent with the same suffixes as
+// Histogram. This is a non-blocking method, if the channel buffer is full, then the metric
+// is not recorded.
+func (c *Stats) Timing(name string, d time.Duration, tags []string, opts ...MetricOption) {
+	c.Histogram(name, durationValue(d, c.durationUnit), tags, opts...)
+}
+
+// Timer measures the duration of a block of code, see NewTimer.
+type Timer struct {
+	stats *Stats
+	name  string
+	tags  []string
+	opts  []MetricOption
+	start time.Time
+}
+
+// NewTimer starts a timer that records the elapsed time with Timing when it's stopped.
+//
+//	timer := stats.NewTimer("request.duration", tags)
+//	defer timer.Stop()
+func (c *Stats) NewTimer(name string, tags []string, opts ...MetricOption) *Timer {
+	return &Timer{
+		stats: c,
+		name:  name,
+		tags:  tags,
+		opts:  opts,
+		start: time.Now(),
+	}
+}
+
+// Stop records the time elapsed since the timer was started, and returns it.
+func (t *Timer) Stop() time.Duration {
+	d := time.Since(t.start)
+	t.stats.Timing(t.name, d, t.tags, t.opts...)
+	return d
+}
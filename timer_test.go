@@ -0,0 +1,31 @@
+package ddstats
+
+import (
+	"testing"
+	"time"
+)
+
+func TestStats_Timer(t *testing.T) {
+
+	stats, testClient, err := NewTestStats()
+	if err != nil {
+		t.Fatalf(err.Error())
+	}
+
+	timer := stats.NewTimer("test", nil)
+	time.Sleep(time.Millisecond * 10)
+	elapsed := timer.Stop()
+	stats.Timing("test", elapsed*2, nil)
+	stats.Close()
+
+	metrics := map[string]interface{}{}
+	for _, m := range testClient.series[0].Series {
+		metrics[m.Metric] = m.Points[0][1]
+	}
+	if metrics["testNamespace.test.max"] != (elapsed * 2).Seconds() {
+		t.Fatalf("expected max to be %v, have %v", (elapsed * 2).Seconds(), metrics["testNamespace.test.max"])
+	}
+	if metrics["testNamespace.test.min"] != elapsed.Seconds() {
+		t.Fatalf("expected min to be %v, have %v", elapsed.Seconds(), metrics["testNamespace.test.min"])
+	}
+}
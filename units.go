@@ -0,0 +1,25 @@
+package ddstats
+
+import (
+	"time"
+)
+
+// GaugeDuration creates or updates a gauge metric with the duration d. The duration is
+// reported in seconds, or in the unit set with Config.WithDurationUnit. Using a single unit
+// for durations keeps metrics across a codebase comparable.
+func (c *Stats) GaugeDuration(name string, d time.Duration, tags []string, opts ...MetricOption) {
+	c.Gauge(name, durationValue(d, c.durationUnit), tags, opts...)
+}
+
+// GaugeBytes creates or updates a gauge metric with the size n in bytes.
+func (c *Stats) GaugeBytes(name string, n int64, tags []string, opts ...MetricOption) {
+	c.Gauge(name, float64(n), tags, opts...)
+}
+
+// durationValue returns d in unit, seconds for an unknown unit.
+func durationValue(d time.Duration, unit DurationUnit) float64 {
+	if unit == DurationMilliseconds {
+		return float64(d) / float64(time.Millisecond)
+	}
+	return d.Seconds()
+}
@@ -0,0 +1,23 @@
+package ddstats
+
+import (
+	"testing"
+	"time"
+)
+
+func Test_durationValue(t *testing.T) {
+
+	tests := []struct {
+		unit     DurationUnit
+		expected float64
+	}{
+		{"", 1.5},
+		{DurationSeconds, 1.5},
+		{DurationMilliseconds, 1500},
+	}
+	for _, test := range tests {
+		if value := durationValue(time.Millisecond*1500, test.unit); value != test.expected {
+			t.Fatalf("expected %q duration to be %f, have %f", test.unit, test.expected, value)
+		}
+	}
+}